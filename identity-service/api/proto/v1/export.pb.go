@@ -0,0 +1,174 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        v5.29.3
+// source: api/proto/v1/export.proto
+
+package v1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ExportUserDataRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExportUserDataRequest) Reset() {
+	*x = ExportUserDataRequest{}
+	mi := &file_api_proto_v1_export_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExportUserDataRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExportUserDataRequest) ProtoMessage() {}
+
+func (x *ExportUserDataRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_v1_export_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExportUserDataRequest.ProtoReflect.Descriptor instead.
+func (*ExportUserDataRequest) Descriptor() ([]byte, []int) {
+	return file_api_proto_v1_export_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ExportUserDataRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+type ExportUserDataResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Json          []byte                 `protobuf:"bytes,1,opt,name=json,proto3" json:"json,omitempty"` // a single JSON value; an empty value exports as null
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExportUserDataResponse) Reset() {
+	*x = ExportUserDataResponse{}
+	mi := &file_api_proto_v1_export_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExportUserDataResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExportUserDataResponse) ProtoMessage() {}
+
+func (x *ExportUserDataResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_v1_export_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExportUserDataResponse.ProtoReflect.Descriptor instead.
+func (*ExportUserDataResponse) Descriptor() ([]byte, []int) {
+	return file_api_proto_v1_export_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ExportUserDataResponse) GetJson() []byte {
+	if x != nil {
+		return x.Json
+	}
+	return nil
+}
+
+var File_api_proto_v1_export_proto protoreflect.FileDescriptor
+
+const file_api_proto_v1_export_proto_rawDesc = "" +
+	"\n" +
+	"\x19api/proto/v1/export.proto\x12\n" +
+	"identityv1\"0\n" +
+	"\x15ExportUserDataRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\",\n" +
+	"\x16ExportUserDataResponse\x12\x12\n" +
+	"\x04json\x18\x01 \x01(\fR\x04json2p\n" +
+	"\x15DataExportContributor\x12W\n" +
+	"\x0eExportUserData\x12!.identityv1.ExportUserDataRequest\x1a\".identityv1.ExportUserDataResponseB3Z1github.com/watup-lk/identity-service/api/proto/v1b\x06proto3"
+
+var (
+	file_api_proto_v1_export_proto_rawDescOnce sync.Once
+	file_api_proto_v1_export_proto_rawDescData []byte
+)
+
+func file_api_proto_v1_export_proto_rawDescGZIP() []byte {
+	file_api_proto_v1_export_proto_rawDescOnce.Do(func() {
+		file_api_proto_v1_export_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_api_proto_v1_export_proto_rawDesc), len(file_api_proto_v1_export_proto_rawDesc)))
+	})
+	return file_api_proto_v1_export_proto_rawDescData
+}
+
+var file_api_proto_v1_export_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_api_proto_v1_export_proto_goTypes = []any{
+	(*ExportUserDataRequest)(nil),  // 0: identityv1.ExportUserDataRequest
+	(*ExportUserDataResponse)(nil), // 1: identityv1.ExportUserDataResponse
+}
+var file_api_proto_v1_export_proto_depIdxs = []int32{
+	0, // 0: identityv1.DataExportContributor.ExportUserData:input_type -> identityv1.ExportUserDataRequest
+	1, // 1: identityv1.DataExportContributor.ExportUserData:output_type -> identityv1.ExportUserDataResponse
+	1, // [1:2] is the sub-list for method output_type
+	0, // [0:1] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_api_proto_v1_export_proto_init() }
+func file_api_proto_v1_export_proto_init() {
+	if File_api_proto_v1_export_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_proto_v1_export_proto_rawDesc), len(file_api_proto_v1_export_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_api_proto_v1_export_proto_goTypes,
+		DependencyIndexes: file_api_proto_v1_export_proto_depIdxs,
+		MessageInfos:      file_api_proto_v1_export_proto_msgTypes,
+	}.Build()
+	File_api_proto_v1_export_proto = out.File
+	file_api_proto_v1_export_proto_goTypes = nil
+	file_api_proto_v1_export_proto_depIdxs = nil
+}
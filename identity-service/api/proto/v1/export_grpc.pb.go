@@ -0,0 +1,135 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             v5.29.3
+// source: api/proto/v1/export.proto
+
+package v1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	DataExportContributor_ExportUserData_FullMethodName = "/identityv1.DataExportContributor/ExportUserData"
+)
+
+// DataExportContributorClient is the client API for DataExportContributor service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// DataExportContributor is implemented by other services that hold personal data
+// about a user (salary-service, vote-service, ...). When a user requests a copy of
+// their data, identity-service calls every configured contributor and embeds each
+// response as one section of the export bundle.
+type DataExportContributorClient interface {
+	// ExportUserData returns everything the service holds about the user. Secrets
+	// (password hashes, tokens, keys) must be left out.
+	ExportUserData(ctx context.Context, in *ExportUserDataRequest, opts ...grpc.CallOption) (*ExportUserDataResponse, error)
+}
+
+type dataExportContributorClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDataExportContributorClient(cc grpc.ClientConnInterface) DataExportContributorClient {
+	return &dataExportContributorClient{cc}
+}
+
+func (c *dataExportContributorClient) ExportUserData(ctx context.Context, in *ExportUserDataRequest, opts ...grpc.CallOption) (*ExportUserDataResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExportUserDataResponse)
+	err := c.cc.Invoke(ctx, DataExportContributor_ExportUserData_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DataExportContributorServer is the server API for DataExportContributor service.
+// All implementations must embed UnimplementedDataExportContributorServer
+// for forward compatibility.
+//
+// DataExportContributor is implemented by other services that hold personal data
+// about a user (salary-service, vote-service, ...). When a user requests a copy of
+// their data, identity-service calls every configured contributor and embeds each
+// response as one section of the export bundle.
+type DataExportContributorServer interface {
+	// ExportUserData returns everything the service holds about the user. Secrets
+	// (password hashes, tokens, keys) must be left out.
+	ExportUserData(context.Context, *ExportUserDataRequest) (*ExportUserDataResponse, error)
+	mustEmbedUnimplementedDataExportContributorServer()
+}
+
+// UnimplementedDataExportContributorServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedDataExportContributorServer struct{}
+
+func (UnimplementedDataExportContributorServer) ExportUserData(context.Context, *ExportUserDataRequest) (*ExportUserDataResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ExportUserData not implemented")
+}
+func (UnimplementedDataExportContributorServer) mustEmbedUnimplementedDataExportContributorServer() {}
+func (UnimplementedDataExportContributorServer) testEmbeddedByValue()                               {}
+
+// UnsafeDataExportContributorServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DataExportContributorServer will
+// result in compilation errors.
+type UnsafeDataExportContributorServer interface {
+	mustEmbedUnimplementedDataExportContributorServer()
+}
+
+func RegisterDataExportContributorServer(s grpc.ServiceRegistrar, srv DataExportContributorServer) {
+	// If the following call panics, it indicates UnimplementedDataExportContributorServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&DataExportContributor_ServiceDesc, srv)
+}
+
+func _DataExportContributor_ExportUserData_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExportUserDataRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DataExportContributorServer).ExportUserData(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DataExportContributor_ExportUserData_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DataExportContributorServer).ExportUserData(ctx, req.(*ExportUserDataRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// DataExportContributor_ServiceDesc is the grpc.ServiceDesc for DataExportContributor service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var DataExportContributor_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "identityv1.DataExportContributor",
+	HandlerType: (*DataExportContributorServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ExportUserData",
+			Handler:    _DataExportContributor_ExportUserData_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "api/proto/v1/export.proto",
+}
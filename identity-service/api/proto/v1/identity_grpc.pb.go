@@ -19,8 +19,14 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	IdentityService_ValidateToken_FullMethodName = "/identityv1.IdentityService/ValidateToken"
-	IdentityService_GetUser_FullMethodName       = "/identityv1.IdentityService/GetUser"
+	IdentityService_ValidateToken_FullMethodName      = "/identityv1.IdentityService/ValidateToken"
+	IdentityService_GetUser_FullMethodName            = "/identityv1.IdentityService/GetUser"
+	IdentityService_UnlockAccount_FullMethodName      = "/identityv1.IdentityService/UnlockAccount"
+	IdentityService_IntrospectToken_FullMethodName    = "/identityv1.IdentityService/IntrospectToken"
+	IdentityService_RevokeAccessToken_FullMethodName  = "/identityv1.IdentityService/RevokeAccessToken"
+	IdentityService_RevokeUserSessions_FullMethodName = "/identityv1.IdentityService/RevokeUserSessions"
+	IdentityService_QueryAuditLogs_FullMethodName     = "/identityv1.IdentityService/QueryAuditLogs"
+	IdentityService_AuditLogStats_FullMethodName      = "/identityv1.IdentityService/AuditLogStats"
 )
 
 // IdentityServiceClient is the client API for IdentityService service.
@@ -31,10 +37,32 @@ const (
 // Other services (vote-service, salary-service) call ValidateToken to verify user JWTs
 // without routing through the BFF.
 type IdentityServiceClient interface {
-	// ValidateToken checks whether an access token is valid and returns the user_id.
+	// ValidateToken checks whether an access token is valid and returns the user_id
+	// and roles.
 	ValidateToken(ctx context.Context, in *ValidateTokenRequest, opts ...grpc.CallOption) (*ValidateTokenResponse, error)
-	// GetUser returns basic user metadata given a user_id. Email is never returned.
+	// GetUser returns basic user metadata given a user_id. Email is never returned,
+	// only whether it has been verified.
 	GetUser(ctx context.Context, in *GetUserRequest, opts ...grpc.CallOption) (*GetUserResponse, error)
+	// UnlockAccount lifts a temporary lockout caused by repeated failed logins
+	// before it expires. Admin only: the request's access_token must carry the
+	// admin role.
+	UnlockAccount(ctx context.Context, in *UnlockAccountRequest, opts ...grpc.CallOption) (*UnlockAccountResponse, error)
+	// IntrospectToken describes an access token in the manner of RFC 7662: whether
+	// it is active, its claims and whether it has been revoked.
+	IntrospectToken(ctx context.Context, in *IntrospectTokenRequest, opts ...grpc.CallOption) (*IntrospectTokenResponse, error)
+	// RevokeAccessToken denylists one access token until it expires. The session
+	// it belongs to stays signed in. Admins may revoke any token; other callers
+	// only the token they call with.
+	RevokeAccessToken(ctx context.Context, in *RevokeAccessTokenRequest, opts ...grpc.CallOption) (*RevokeAccessTokenResponse, error)
+	// RevokeUserSessions signs a user out everywhere: refresh tokens are revoked and
+	// access tokens already issued stop validating immediately. Admin only.
+	RevokeUserSessions(ctx context.Context, in *RevokeUserSessionsRequest, opts ...grpc.CallOption) (*RevokeUserSessionsResponse, error)
+	// QueryAuditLogs pages through the audit log, newest first. Admin only: the
+	// caller's access_token must carry the admin role.
+	QueryAuditLogs(ctx context.Context, in *QueryAuditLogsRequest, opts ...grpc.CallOption) (*QueryAuditLogsResponse, error)
+	// AuditLogStats counts audit logs per hour or day and per group, e.g. failed
+	// logins per IP per hour. Admin only, like QueryAuditLogs.
+	AuditLogStats(ctx context.Context, in *AuditLogStatsRequest, opts ...grpc.CallOption) (*AuditLogStatsResponse, error)
 }
 
 type identityServiceClient struct {
@@ -65,6 +93,66 @@ func (c *identityServiceClient) GetUser(ctx context.Context, in *GetUserRequest,
 	return out, nil
 }
 
+func (c *identityServiceClient) UnlockAccount(ctx context.Context, in *UnlockAccountRequest, opts ...grpc.CallOption) (*UnlockAccountResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UnlockAccountResponse)
+	err := c.cc.Invoke(ctx, IdentityService_UnlockAccount_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *identityServiceClient) IntrospectToken(ctx context.Context, in *IntrospectTokenRequest, opts ...grpc.CallOption) (*IntrospectTokenResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(IntrospectTokenResponse)
+	err := c.cc.Invoke(ctx, IdentityService_IntrospectToken_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *identityServiceClient) RevokeAccessToken(ctx context.Context, in *RevokeAccessTokenRequest, opts ...grpc.CallOption) (*RevokeAccessTokenResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RevokeAccessTokenResponse)
+	err := c.cc.Invoke(ctx, IdentityService_RevokeAccessToken_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *identityServiceClient) RevokeUserSessions(ctx context.Context, in *RevokeUserSessionsRequest, opts ...grpc.CallOption) (*RevokeUserSessionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RevokeUserSessionsResponse)
+	err := c.cc.Invoke(ctx, IdentityService_RevokeUserSessions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *identityServiceClient) QueryAuditLogs(ctx context.Context, in *QueryAuditLogsRequest, opts ...grpc.CallOption) (*QueryAuditLogsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(QueryAuditLogsResponse)
+	err := c.cc.Invoke(ctx, IdentityService_QueryAuditLogs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *identityServiceClient) AuditLogStats(ctx context.Context, in *AuditLogStatsRequest, opts ...grpc.CallOption) (*AuditLogStatsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AuditLogStatsResponse)
+	err := c.cc.Invoke(ctx, IdentityService_AuditLogStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // IdentityServiceServer is the server API for IdentityService service.
 // All implementations must embed UnimplementedIdentityServiceServer
 // for forward compatibility.
@@ -73,10 +161,32 @@ func (c *identityServiceClient) GetUser(ctx context.Context, in *GetUserRequest,
 // Other services (vote-service, salary-service) call ValidateToken to verify user JWTs
 // without routing through the BFF.
 type IdentityServiceServer interface {
-	// ValidateToken checks whether an access token is valid and returns the user_id.
+	// ValidateToken checks whether an access token is valid and returns the user_id
+	// and roles.
 	ValidateToken(context.Context, *ValidateTokenRequest) (*ValidateTokenResponse, error)
-	// GetUser returns basic user metadata given a user_id. Email is never returned.
+	// GetUser returns basic user metadata given a user_id. Email is never returned,
+	// only whether it has been verified.
 	GetUser(context.Context, *GetUserRequest) (*GetUserResponse, error)
+	// UnlockAccount lifts a temporary lockout caused by repeated failed logins
+	// before it expires. Admin only: the request's access_token must carry the
+	// admin role.
+	UnlockAccount(context.Context, *UnlockAccountRequest) (*UnlockAccountResponse, error)
+	// IntrospectToken describes an access token in the manner of RFC 7662: whether
+	// it is active, its claims and whether it has been revoked.
+	IntrospectToken(context.Context, *IntrospectTokenRequest) (*IntrospectTokenResponse, error)
+	// RevokeAccessToken denylists one access token until it expires. The session
+	// it belongs to stays signed in. Admins may revoke any token; other callers
+	// only the token they call with.
+	RevokeAccessToken(context.Context, *RevokeAccessTokenRequest) (*RevokeAccessTokenResponse, error)
+	// RevokeUserSessions signs a user out everywhere: refresh tokens are revoked and
+	// access tokens already issued stop validating immediately. Admin only.
+	RevokeUserSessions(context.Context, *RevokeUserSessionsRequest) (*RevokeUserSessionsResponse, error)
+	// QueryAuditLogs pages through the audit log, newest first. Admin only: the
+	// caller's access_token must carry the admin role.
+	QueryAuditLogs(context.Context, *QueryAuditLogsRequest) (*QueryAuditLogsResponse, error)
+	// AuditLogStats counts audit logs per hour or day and per group, e.g. failed
+	// logins per IP per hour. Admin only, like QueryAuditLogs.
+	AuditLogStats(context.Context, *AuditLogStatsRequest) (*AuditLogStatsResponse, error)
 	mustEmbedUnimplementedIdentityServiceServer()
 }
 
@@ -93,6 +203,24 @@ func (UnimplementedIdentityServiceServer) ValidateToken(context.Context, *Valida
 func (UnimplementedIdentityServiceServer) GetUser(context.Context, *GetUserRequest) (*GetUserResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method GetUser not implemented")
 }
+func (UnimplementedIdentityServiceServer) UnlockAccount(context.Context, *UnlockAccountRequest) (*UnlockAccountResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method UnlockAccount not implemented")
+}
+func (UnimplementedIdentityServiceServer) IntrospectToken(context.Context, *IntrospectTokenRequest) (*IntrospectTokenResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method IntrospectToken not implemented")
+}
+func (UnimplementedIdentityServiceServer) RevokeAccessToken(context.Context, *RevokeAccessTokenRequest) (*RevokeAccessTokenResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method RevokeAccessToken not implemented")
+}
+func (UnimplementedIdentityServiceServer) RevokeUserSessions(context.Context, *RevokeUserSessionsRequest) (*RevokeUserSessionsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method RevokeUserSessions not implemented")
+}
+func (UnimplementedIdentityServiceServer) QueryAuditLogs(context.Context, *QueryAuditLogsRequest) (*QueryAuditLogsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method QueryAuditLogs not implemented")
+}
+func (UnimplementedIdentityServiceServer) AuditLogStats(context.Context, *AuditLogStatsRequest) (*AuditLogStatsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method AuditLogStats not implemented")
+}
 func (UnimplementedIdentityServiceServer) mustEmbedUnimplementedIdentityServiceServer() {}
 func (UnimplementedIdentityServiceServer) testEmbeddedByValue()                         {}
 
@@ -150,6 +278,114 @@ func _IdentityService_GetUser_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _IdentityService_UnlockAccount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnlockAccountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IdentityServiceServer).UnlockAccount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IdentityService_UnlockAccount_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IdentityServiceServer).UnlockAccount(ctx, req.(*UnlockAccountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IdentityService_IntrospectToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IntrospectTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IdentityServiceServer).IntrospectToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IdentityService_IntrospectToken_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IdentityServiceServer).IntrospectToken(ctx, req.(*IntrospectTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IdentityService_RevokeAccessToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeAccessTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IdentityServiceServer).RevokeAccessToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IdentityService_RevokeAccessToken_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IdentityServiceServer).RevokeAccessToken(ctx, req.(*RevokeAccessTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IdentityService_RevokeUserSessions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeUserSessionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IdentityServiceServer).RevokeUserSessions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IdentityService_RevokeUserSessions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IdentityServiceServer).RevokeUserSessions(ctx, req.(*RevokeUserSessionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IdentityService_QueryAuditLogs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryAuditLogsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IdentityServiceServer).QueryAuditLogs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IdentityService_QueryAuditLogs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IdentityServiceServer).QueryAuditLogs(ctx, req.(*QueryAuditLogsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IdentityService_AuditLogStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AuditLogStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IdentityServiceServer).AuditLogStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IdentityService_AuditLogStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IdentityServiceServer).AuditLogStats(ctx, req.(*AuditLogStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // IdentityService_ServiceDesc is the grpc.ServiceDesc for IdentityService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -165,6 +401,30 @@ var IdentityService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetUser",
 			Handler:    _IdentityService_GetUser_Handler,
 		},
+		{
+			MethodName: "UnlockAccount",
+			Handler:    _IdentityService_UnlockAccount_Handler,
+		},
+		{
+			MethodName: "IntrospectToken",
+			Handler:    _IdentityService_IntrospectToken_Handler,
+		},
+		{
+			MethodName: "RevokeAccessToken",
+			Handler:    _IdentityService_RevokeAccessToken_Handler,
+		},
+		{
+			MethodName: "RevokeUserSessions",
+			Handler:    _IdentityService_RevokeUserSessions_Handler,
+		},
+		{
+			MethodName: "QueryAuditLogs",
+			Handler:    _IdentityService_QueryAuditLogs_Handler,
+		},
+		{
+			MethodName: "AuditLogStats",
+			Handler:    _IdentityService_AuditLogStats_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "api/proto/v1/identity.proto",
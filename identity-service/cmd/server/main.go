@@ -22,12 +22,20 @@ import (
 	"google.golang.org/grpc/status"
 
 	pb "github.com/watup-lk/identity-service/api/proto/v1"
+	"github.com/watup-lk/identity-service/internal/auditchain"
+	"github.com/watup-lk/identity-service/internal/auditlog"
 	"github.com/watup-lk/identity-service/internal/config"
+	"github.com/watup-lk/identity-service/internal/export"
 	"github.com/watup-lk/identity-service/internal/grpcserver"
 	"github.com/watup-lk/identity-service/internal/handlers"
 	"github.com/watup-lk/identity-service/internal/kafka"
+	"github.com/watup-lk/identity-service/internal/keys"
+	"github.com/watup-lk/identity-service/internal/mailer"
 	"github.com/watup-lk/identity-service/internal/middleware"
+	"github.com/watup-lk/identity-service/internal/oauth"
+	"github.com/watup-lk/identity-service/internal/outbox"
 	"github.com/watup-lk/identity-service/internal/repository"
+	"github.com/watup-lk/identity-service/internal/roles"
 	"github.com/watup-lk/identity-service/internal/service"
 )
 
@@ -52,13 +60,56 @@ func main() {
 	log.Println("[startup] Connected to PostgreSQL")
 
 	repo := repository.NewPostgresRepo(db)
+	checkEmailRules(cfg, repo)
 
 	// --- Kafka ---
 	producer := kafka.NewProducer(cfg.KafkaBrokers)
 	defer producer.Close()
 
+	// --- Mailer ---
+	mail := newMailer(cfg)
+
+	// --- Token signing keys ---
+	keyring, err := keys.FromConfig(cfg)
+	if err != nil {
+		log.Fatalf("[startup] Failed to load JWT signing keys: %v", err)
+	}
+
 	// --- Service ---
-	identitySvc := service.NewIdentityService(repo, producer, cfg)
+	identitySvc := service.NewIdentityService(repo, mail, keyring, cfg)
+
+	// --- Audit log writer: batches audit events off the request path ---
+	auditWriter := auditlog.NewWriter(repo, cfg.AuditWriterConfig())
+	identitySvc.SetAuditRecorder(auditWriter)
+	if cfg.AuditSpillDir == "" {
+		log.Println("[startup] AUDIT_SPILL_DIR is not set — audit events are dropped while the database is unavailable")
+	}
+
+	// --- Data export contributors: other services' sections of a user's export ---
+	endpoints, _ := export.ParseEndpoints(cfg.ExportContributors) // validated above
+	for _, e := range endpoints {
+		c, err := export.Dial(e)
+		if err != nil {
+			log.Fatalf("[startup] Failed to set up data export contributor: %v", err)
+		}
+		defer c.Close()
+		if err := identitySvc.AddExportContributors(c); err != nil {
+			log.Fatalf("[startup] Invalid DATA_EXPORT_CONTRIBUTORS: %v", err)
+		}
+		log.Printf("[startup] Data export section %q from %s", e.Section, e.Addr)
+	}
+
+	// --- Social login providers ---
+	for _, c := range cfg.OAuthConfigs() {
+		p, err := oauth.New(c, nil)
+		if err != nil {
+			log.Fatalf("[startup] Invalid OAUTH_PROVIDERS: %v", err)
+		}
+		if err := identitySvc.AddIdentityProviders(p); err != nil {
+			log.Fatalf("[startup] Invalid OAUTH_PROVIDERS: %v", err)
+		}
+		log.Printf("[startup] Social login via %s (%s)", c.Name, c.Issuer)
+	}
 
 	// --- Start servers ---
 	ctx, cancel := context.WithCancel(context.Background())
@@ -66,11 +117,20 @@ func main() {
 
 	var wg sync.WaitGroup
 
+	// Audit writer: stopped only after the servers, so it flushes the events of the
+	// last requests
+	auditCtx, stopAudit := context.WithCancel(context.Background())
+	auditDone := make(chan struct{})
+	go func() {
+		defer close(auditDone)
+		auditWriter.Run(auditCtx)
+	}()
+
 	// HTTP API server: auth routes (rate-limited) + health probes (not rate-limited)
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		startHTTPServer(ctx, cfg, identitySvc, repo)
+		startHTTPServer(ctx, cfg, identitySvc, repo, keyring)
 	}()
 
 	// Metrics server: dedicated port for Prometheus scraping — bypasses rate limiter
@@ -80,6 +140,45 @@ func main() {
 		startMetricsServer(ctx, cfg)
 	}()
 
+	// Signing key rotation: reload keys on SIGHUP (and optionally on a timer)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		watchKeyRotation(ctx, cfg, keyring)
+	}()
+
+	// Purge jobs: scrub PII of deleted accounts once their grace period ends and
+	// delete expired data exports
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		runPurgeJobs(ctx, cfg, identitySvc)
+	}()
+
+	// Outbox relay: publishes every Kafka event the service records, e.g. with
+	// signups, logins and password changes. The producer is closed only after it has stopped.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		outbox.NewRelay(repo, producer, cfg.OutboxConfig()).Run(ctx)
+	}()
+
+	// Audit chain checkpoints: periodically sign the head of the hash-chained audit
+	// log, so it cannot be rewritten or truncated unnoticed
+	if cfg.AuditKeyFile != "" {
+		key, err := auditchain.ReadKey(cfg.AuditKeyFile)
+		if err != nil {
+			log.Fatalf("[startup] Invalid AUDIT_CHECKPOINT_KEY_FILE: %v", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			auditchain.NewCheckpointer(repo, key, time.Duration(cfg.AuditCheckpointMins)*time.Minute).Run(ctx)
+		}()
+	} else {
+		log.Println("[startup] AUDIT_CHECKPOINT_KEY_FILE is not set — the audit log hash chain is not checkpointed")
+	}
+
 	// gRPC server: internal service-to-service token validation
 	wg.Add(1)
 	go func() {
@@ -94,20 +193,90 @@ func main() {
 	log.Printf("[shutdown] Received signal: %v — beginning graceful shutdown...", sig)
 	cancel()
 	wg.Wait()
+	stopAudit()
+	<-auditDone
 	log.Println("[shutdown] Identity service stopped cleanly")
 }
 
+// checkEmailRules refuses to start when EMAIL_STRIP_SUBADDRESS or EMAIL_DOTLESS_DOMAINS
+// would rewrite addresses already stored: lookups normalise the input, so those
+// users could no longer sign in or reset their password.
+func checkEmailRules(cfg *config.Config, repo *repository.PostgresRepo) {
+	rules := cfg.EmailRules()
+	if !rules.StripSubaddress && len(rules.DotlessDomains) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	n, err := repo.CountEmailsChangedByRules(ctx, rules.StripSubaddress, rules.DotlessDomains)
+	if err != nil {
+		log.Fatalf("[startup] Failed to check stored email addresses: %v", err)
+	}
+	if n > 0 {
+		log.Fatalf("[startup] EMAIL_STRIP_SUBADDRESS / EMAIL_DOTLESS_DOMAINS would change %d stored email addresses; "+
+			"these rules can only be enabled before such addresses exist", n)
+	}
+}
+
 // validateConfig checks required configuration at startup and fails fast.
 func validateConfig(cfg *config.Config) {
 	if cfg.DatabaseURL == "" {
 		log.Fatal("[startup] DATABASE_URL is required (set via env var or Azure Key Vault)")
 	}
-	if cfg.JWTSecret == "" {
-		log.Fatal("[startup] JWT_SECRET is required (min 32 chars recommended)")
+	switch cfg.JWTSigningAlg {
+	case keys.AlgRS256, keys.AlgEdDSA:
+		if cfg.JWTPrivateKey == "" && cfg.JWTPrivateKeyFile == "" && cfg.JWTKeyDir == "" {
+			log.Fatalf("[startup] JWT_PRIVATE_KEY, JWT_PRIVATE_KEY_FILE or JWT_KEY_DIR is required for JWT_SIGNING_ALG=%s", cfg.JWTSigningAlg)
+		}
+		if cfg.JWTSecret != "" {
+			log.Println("[startup] JWT_SECRET is set — HS256 tokens are still accepted for verification; unset it once they have expired")
+		}
+	default:
+		if cfg.JWTSecret == "" {
+			log.Fatal("[startup] JWT_SECRET is required (min 32 chars recommended)")
+		}
 	}
-	if len(cfg.JWTSecret) < 32 {
+	if cfg.JWTSecret != "" && len(cfg.JWTSecret) < 32 {
 		log.Println("[startup] WARNING: JWT_SECRET is shorter than 32 characters — use a stronger secret in production")
 	}
+	if err := cfg.PasswordPolicy().Validate(); err != nil {
+		log.Fatalf("[startup] invalid password hashing settings: %v", err)
+	}
+	if err := cfg.PasswordRules().Validate(); err != nil {
+		log.Fatalf("[startup] invalid password policy settings: %v", err)
+	}
+	if err := cfg.EmailRules().Validate(); err != nil {
+		log.Fatalf("[startup] invalid email normalisation settings: %v", err)
+	}
+	if _, err := export.ParseEndpoints(cfg.ExportContributors); err != nil {
+		log.Fatalf("[startup] invalid DATA_EXPORT_CONTRIBUTORS: %v", err)
+	}
+	for _, p := range cfg.OAuthProviders {
+		if p.Issuer == "" || p.ClientID == "" || p.ClientSecret == "" {
+			log.Fatalf("[startup] OAuth provider %q needs an issuer, client id and client secret", p.Name)
+		}
+	}
+	if cfg.OutboxPollMillis <= 0 || cfg.OutboxBatchSize <= 0 {
+		log.Fatal("[startup] OUTBOX_POLL_INTERVAL_MS and OUTBOX_BATCH_SIZE must be positive")
+	}
+	if cfg.AuditQueueSize <= 0 || cfg.AuditBatchSize <= 0 || cfg.AuditFlushMillis <= 0 {
+		log.Fatal("[startup] AUDIT_QUEUE_SIZE, AUDIT_BATCH_SIZE and AUDIT_FLUSH_INTERVAL_MS must be positive")
+	}
+	if cfg.AuditBatchSize > 5000 { // 11 statement parameters per event, at most 65535 in PostgreSQL
+		log.Fatal("[startup] AUDIT_BATCH_SIZE must be at most 5000")
+	}
+	if cfg.AuditKeyFile != "" && cfg.AuditCheckpointMins <= 0 {
+		log.Fatal("[startup] AUDIT_CHECKPOINT_INTERVAL_MINUTES must be positive")
+	}
+	if len(cfg.OAuthProviders) > 0 && cfg.OAuthStateMinutes <= 0 {
+		log.Fatal("[startup] OAUTH_STATE_MINUTES must be positive")
+	}
+	if err := cfg.WebAuthnConfig().Validate(); err != nil {
+		log.Fatalf("[startup] invalid passkey settings: %v", err)
+	}
+	if cfg.BreachedPasswordsDir == "" {
+		log.Println("[startup] BREACHED_PASSWORDS_DIR is not set — new passwords are not checked against breach data")
+	}
 	// Check that at least one non-empty broker address is configured
 	hasValidBroker := false
 	for _, b := range cfg.KafkaBrokers {
@@ -119,13 +288,104 @@ func validateConfig(cfg *config.Config) {
 	if !hasValidBroker {
 		log.Fatal("[startup] KAFKA_BROKERS must contain at least one broker address")
 	}
-	log.Printf("[startup] Port=%s GRPCPort=%s MetricsPort=%s AccessTokenMins=%d RefreshTokenDays=%d",
-		cfg.Port, cfg.GRPCPort, cfg.MetricsPort, cfg.AccessTokenMinutes, cfg.RefreshTokenDays)
+	log.Printf("[startup] Port=%s GRPCPort=%s MetricsPort=%s AccessTokenMins=%d RefreshTokenDays=%d JWTSigningAlg=%s PasswordHashAlg=%s",
+		cfg.Port, cfg.GRPCPort, cfg.MetricsPort, cfg.AccessTokenMinutes, cfg.RefreshTokenDays, cfg.JWTSigningAlg, cfg.PasswordHashAlg)
+}
+
+// newMailer selects the email transport from MAIL_DRIVER.
+// "log" (default) and "file" are local stand-ins; "smtp" is used in production.
+func newMailer(cfg *config.Config) service.Mailer {
+	switch cfg.MailDriver {
+	case "smtp":
+		if cfg.SMTPAddr == "" {
+			log.Fatal("[startup] SMTP_ADDR is required when MAIL_DRIVER=smtp")
+		}
+		log.Printf("[startup] Mailer: smtp via %s", cfg.SMTPAddr)
+		return mailer.NewSMTPMailer(cfg.SMTPAddr, cfg.MailFrom, cfg.SMTPUsername, cfg.SMTPPassword)
+	case "file":
+		m, err := mailer.NewFileMailer(cfg.MailOutboxDir, cfg.MailFrom)
+		if err != nil {
+			log.Fatalf("[startup] Failed to initialise file mailer: %v", err)
+		}
+		log.Printf("[startup] Mailer: writing emails to %s", cfg.MailOutboxDir)
+		return m
+	default:
+		log.Println("[startup] Mailer: logging emails to stdout (MAIL_DRIVER=log)")
+		return mailer.NewLogMailer()
+	}
+}
+
+// watchKeyRotation reloads the JWT signing keys on SIGHUP, and every
+// JWT_KEY_RELOAD_MINUTES if set. Secrets are re-read from Azure Key Vault (or the
+// key directory), and the previous signing key keeps verifying tokens until they expire.
+func watchKeyRotation(ctx context.Context, cfg *config.Config, keyring *keys.Keyring) {
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+	defer signal.Stop(hupCh)
+
+	var tick <-chan time.Time
+	if cfg.JWTKeyReloadMinutes > 0 {
+		ticker := time.NewTicker(time.Duration(cfg.JWTKeyReloadMinutes) * time.Minute)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hupCh:
+			log.Println("[keys] SIGHUP received — reloading signing keys")
+		case <-tick:
+		}
+
+		rotated, err := keyring.Reload(cfg.ReloadSecrets())
+		if err != nil {
+			log.Printf("[keys] Reload failed, keeping current keys: %v", err)
+			continue
+		}
+		if rotated {
+			log.Printf("[keys] Rotated signing key to kid=%s", keyring.SigningKeyID())
+		}
+	}
+}
+
+// runPurgeJobs periodically anonymises accounts whose deletion grace period has
+// ended and deletes data exports past their download window. Every replica runs it;
+// the repository claims accounts with SKIP LOCKED.
+func runPurgeJobs(ctx context.Context, cfg *config.Config, svc *service.IdentityService) {
+	if cfg.AccountPurgeMinutes <= 0 {
+		log.Println("[purge] ACCOUNT_PURGE_INTERVAL_MINUTES is 0 — deleted accounts and expired exports are not purged")
+		return
+	}
+	ticker := time.NewTicker(time.Duration(cfg.AccountPurgeMinutes) * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		n, err := svc.PurgeDeletedAccounts(ctx)
+		if err != nil {
+			log.Printf("[purge] Purging deleted accounts failed: %v", err)
+		} else if n > 0 {
+			log.Printf("[purge] Anonymised %d deleted account(s)", n)
+		}
+		if n, err := svc.PurgeExpiredDataExports(ctx); err != nil {
+			log.Printf("[purge] Deleting expired data exports failed: %v", err)
+		} else if n > 0 {
+			log.Printf("[purge] Deleted %d expired data export(s)", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
 }
 
-func startHTTPServer(ctx context.Context, cfg *config.Config, svc *service.IdentityService, repo *repository.PostgresRepo) {
+func startHTTPServer(ctx context.Context, cfg *config.Config, svc *service.IdentityService, repo *repository.PostgresRepo, keyring *keys.Keyring) {
 	authH := handlers.NewAuthHandler(svc)
 	healthH := handlers.NewHealthHandler(repo)
+	jwksH := handlers.NewJWKSHandler(keyring)
 
 	// Auth-only sub-mux — this is the handler that gets rate-limited
 	authMux := http.NewServeMux()
@@ -134,16 +394,60 @@ func startHTTPServer(ctx context.Context, cfg *config.Config, svc *service.Ident
 	authMux.HandleFunc("POST /auth/refresh", authH.Refresh)
 	authMux.HandleFunc("POST /auth/logout", authH.Logout)
 	authMux.HandleFunc("GET /auth/validate", authH.ValidateToken)
-
-	// Per-IP rate limiter: burst of 20, refills at 5 req/s — applied to auth routes only
+	authMux.HandleFunc("POST /auth/password/forgot", authH.ForgotPassword)
+	authMux.HandleFunc("POST /auth/password/reset", authH.ResetPassword)
+	authMux.HandleFunc("POST /auth/password/change", authH.ChangePassword)
+	authMux.HandleFunc("GET /auth/sessions", authH.ListSessions)
+	authMux.HandleFunc("DELETE /auth/sessions", authH.RevokeOtherSessions)
+	authMux.HandleFunc("DELETE /auth/sessions/{id}", authH.RevokeSession)
+	authMux.HandleFunc("POST /auth/email/verify", authH.VerifyEmail)
+	authMux.HandleFunc("POST /auth/email/resend", authH.ResendVerificationEmail)
+	authMux.HandleFunc("POST /auth/email/change", authH.RequestEmailChange)
+	authMux.HandleFunc("POST /auth/email/change/confirm", authH.ConfirmEmailChange)
+	authMux.HandleFunc("POST /auth/email/change/cancel", authH.CancelEmailChange)
+	authMux.HandleFunc("POST /auth/mfa/verify", authH.VerifyMFA)
+	authMux.HandleFunc("POST /auth/mfa/totp/enroll", authH.EnrollTOTP)
+	authMux.HandleFunc("POST /auth/mfa/totp/confirm", authH.ConfirmTOTP)
+	authMux.HandleFunc("POST /auth/mfa/totp/disable", authH.DisableTOTP)
+	authMux.HandleFunc("POST /auth/mfa/recovery-codes", authH.RegenerateRecoveryCodes)
+	authMux.HandleFunc("GET /auth/me", authH.GetProfile)
+	authMux.HandleFunc("PATCH /auth/me", authH.UpdateProfile)
+	authMux.HandleFunc("DELETE /auth/account", authH.DeleteAccount)
+	authMux.HandleFunc("POST /auth/account/export", authH.RequestDataExport)
+	authMux.HandleFunc("GET /auth/account/export", authH.DownloadDataExport)
+	authMux.HandleFunc("GET /auth/account/export/status", authH.DataExportStatus)
+	authMux.HandleFunc("GET /auth/oauth/providers", authH.ListOAuthProviders)
+	authMux.HandleFunc("POST /auth/oauth/{provider}/start", authH.StartOAuth)
+	authMux.HandleFunc("POST /auth/oauth/{provider}/reauth", authH.StartOAuthReauth)
+	authMux.HandleFunc("POST /auth/oauth/{provider}/callback", authH.CompleteOAuth)
+	authMux.HandleFunc("GET /auth/oauth/identities", authH.ListLinkedIdentities)
+	authMux.HandleFunc("DELETE /auth/oauth/identities/{provider}", authH.UnlinkIdentity)
+	authMux.HandleFunc("POST /auth/passkeys/register/begin", authH.BeginPasskeyRegistration)
+	authMux.HandleFunc("POST /auth/passkeys/register/finish", authH.FinishPasskeyRegistration)
+	authMux.HandleFunc("GET /auth/passkeys", authH.ListPasskeys)
+	authMux.HandleFunc("DELETE /auth/passkeys/{id}", authH.DeletePasskey)
+	authMux.HandleFunc("POST /auth/passkeys/login/begin", authH.BeginPasskeyLogin)
+	authMux.HandleFunc("POST /auth/passkeys/login/finish", authH.FinishPasskeyLogin)
+	authMux.HandleFunc("POST /auth/mfa/passkey/begin", authH.BeginPasskeyMFA)
+	authMux.HandleFunc("POST /auth/mfa/passkey/verify", authH.VerifyMFAPasskey)
+
+	// Admin-only sub-mux: every route requires a token with the admin role
+	adminMux := http.NewServeMux()
+	adminMux.HandleFunc("GET /admin/audit-logs", authH.QueryAuditLogs)
+	adminMux.HandleFunc("GET /admin/audit-logs/stats", authH.AuditLogStats)
+
+	// Per-IP rate limiter: burst of 20, refills at 5 req/s — applied to auth and admin routes
 	limiter := middleware.NewRateLimiter(20, 5)
 
 	// Top-level mux: health probes bypass the rate limiter entirely.
 	// Kubelet hits /health/live and /health/ready frequently — never rate-limit them.
 	topMux := http.NewServeMux()
 	topMux.Handle("/auth/", limiter.Limit(authMux))
+	topMux.Handle("/admin/", limiter.Limit(middleware.RequireRole(svc, roles.Admin)(adminMux)))
 	topMux.HandleFunc("GET /health/live", healthH.Liveness)
 	topMux.HandleFunc("GET /health/ready", healthH.Readiness)
+	// Public keys for offline token verification — cached by clients, not rate-limited
+	topMux.HandleFunc("GET /.well-known/jwks.json", jwksH.JWKS)
 
 	// CORS, SecurityHeaders, Metrics, RequestLogger apply to ALL routes (auth + health)
 	handler := middleware.Chain(
@@ -0,0 +1,185 @@
+// Package auditchain makes identity_schema.audit_logs tamper-evident. Every row is
+// numbered (seq) and stores the hash of its content together with the hash of the
+// row before it, so editing, removing or reordering a row breaks the chain from that
+// point on. Periodic checkpoints, signed with a key that is not kept in the database,
+// pin the chain head so it cannot be silently rewritten or cut short either.
+//
+// The IP address is the one field that is legitimately erased (when a deleted
+// account is anonymised), so it enters the chain through a salted digest rather than
+// directly: scrubbing clears the address and its salt but keeps the digest, and the
+// chain still verifies.
+package auditchain
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// HashSize is the length of every hash in the chain.
+const HashSize = sha256.Size
+
+// hashDomain versions the row hash encoding.
+const hashDomain = "watup-audit-log/v1"
+
+// Genesis is the previous hash of the first row in the chain.
+var Genesis = make([]byte, HashSize)
+
+// Record is one chained audit_logs row.
+type Record struct {
+	Seq       int64 // position in the chain, from 1
+	ID        string
+	UserID    string // empty if the user was unknown
+	EventType string
+	Success   bool
+	IPAddress string // empty if unknown or scrubbed
+	CreatedAt time.Time
+	IPSalt    []byte // random salt of IPDigest; nil once the address is scrubbed
+	IPDigest  []byte // nil if no address was recorded
+	PrevHash  []byte
+	Hash      []byte
+}
+
+// Seal appends r to a chain whose last row has sequence number seq-1 and hash prev.
+// It normalises r's fields to the form they are read back from the database in,
+// salts and digests the IP address, and fills in Seq, PrevHash and Hash.
+func Seal(r *Record, seq int64, prev []byte) {
+	r.Seq = seq
+	r.UserID = strings.ToLower(r.UserID)
+	r.IPAddress = canonicalIP(r.IPAddress)
+	r.CreatedAt = r.CreatedAt.UTC().Truncate(time.Microsecond) // PostgreSQL precision
+	r.IPSalt, r.IPDigest = nil, nil
+	if r.IPAddress != "" {
+		r.IPSalt = make([]byte, 16)
+		rand.Read(r.IPSalt) //nolint:errcheck // never fails
+		r.IPDigest = ipDigest(r.IPSalt, r.IPAddress)
+	}
+	r.PrevHash = prev
+	r.Hash = rowHash(r)
+}
+
+// rowHash hashes every field of the row except the IP address and its salt, which
+// are covered by IPDigest. Variable-length fields are length-prefixed.
+func rowHash(r *Record) []byte {
+	h := sha256.New()
+	writeField(h, []byte(hashDomain))
+	binary.Write(h, binary.BigEndian, r.Seq) //nolint:errcheck // hashes never fail
+	writeField(h, r.PrevHash)
+	writeField(h, []byte(r.ID))
+	writeField(h, []byte(r.UserID))
+	writeField(h, []byte(r.EventType))
+	binary.Write(h, binary.BigEndian, r.Success)               //nolint:errcheck
+	binary.Write(h, binary.BigEndian, r.CreatedAt.UnixMicro()) //nolint:errcheck
+	writeField(h, r.IPDigest)
+	return h.Sum(nil)
+}
+
+func ipDigest(salt []byte, ip string) []byte {
+	h := sha256.New()
+	writeField(h, salt)
+	writeField(h, []byte(ip))
+	return h.Sum(nil)
+}
+
+func writeField(h interface{ Write([]byte) (int, error) }, b []byte) {
+	binary.Write(h, binary.BigEndian, uint32(len(b))) //nolint:errcheck
+	h.Write(b)                                        //nolint:errcheck
+}
+
+// canonicalIP returns the address in one textual form, whatever form the client or
+// the database wrote it in. Values that are not an IP address are dropped.
+func canonicalIP(s string) string {
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return ""
+	}
+	return addr.String()
+}
+
+// Break describes the first row at which the chain fails to verify.
+type Break struct {
+	Seq    int64
+	ID     string // empty when the row itself is missing
+	Reason string
+}
+
+func (b *Break) Error() string {
+	if b.ID == "" {
+		return fmt.Sprintf("audit chain broken at seq %d: %s", b.Seq, b.Reason)
+	}
+	return fmt.Sprintf("audit chain broken at seq %d (row %s): %s", b.Seq, b.ID, b.Reason)
+}
+
+// Verifier walks the chain in seq order and stops at the first break.
+type Verifier struct {
+	Rows     int64 // rows verified so far
+	Scrubbed int64 // of which the IP address has been scrubbed
+
+	next        int64
+	prev        []byte
+	checkpoints map[int64]Checkpoint
+	lastAnchor  *Checkpoint // checkpoint with the highest seq
+}
+
+// NewVerifier returns a verifier for a chain starting at seq 1. Each checkpoint's
+// hash must match the row at its seq, and the chain must reach the last of them.
+// Checkpoint signatures are checked separately, see VerifyCheckpoints.
+func NewVerifier(checkpoints []Checkpoint) *Verifier {
+	v := &Verifier{next: 1, prev: Genesis, checkpoints: make(map[int64]Checkpoint)}
+	for _, cp := range checkpoints {
+		v.checkpoints[cp.Seq] = cp
+		if v.lastAnchor == nil || cp.Seq > v.lastAnchor.Seq {
+			v.lastAnchor = &cp
+		}
+	}
+	return v
+}
+
+// Check verifies the next row of the chain and returns a *Break if it does not fit.
+func (v *Verifier) Check(r Record) error {
+	if r.Seq != v.next {
+		return &Break{Seq: v.next, Reason: fmt.Sprintf("row is missing (the next row has seq %d)", r.Seq)}
+	}
+	fail := func(reason string) error { return &Break{Seq: r.Seq, ID: r.ID, Reason: reason} }
+
+	if !bytes.Equal(r.PrevHash, v.prev) {
+		return fail("previous hash does not match the row before it")
+	}
+	r.UserID = strings.ToLower(r.UserID)
+	r.IPAddress = canonicalIP(r.IPAddress)
+	switch {
+	case r.IPAddress != "" && (r.IPSalt == nil || !bytes.Equal(ipDigest(r.IPSalt, r.IPAddress), r.IPDigest)):
+		return fail("IP address does not match its digest")
+	case r.IPAddress == "" && r.IPSalt != nil:
+		return fail("IP address removed without being scrubbed")
+	}
+	if !bytes.Equal(rowHash(&r), r.Hash) {
+		return fail("row content does not match its hash")
+	}
+	if cp, ok := v.checkpoints[r.Seq]; ok && !bytes.Equal(cp.Hash, r.Hash) {
+		return fail(fmt.Sprintf("hash differs from the checkpoint of %s", cp.CreatedAt.UTC().Format(time.RFC3339)))
+	}
+
+	v.Rows++
+	if r.IPAddress == "" && r.IPDigest != nil {
+		v.Scrubbed++
+	}
+	v.next++
+	v.prev = r.Hash
+	return nil
+}
+
+// Finish checks that the chain was not cut short: it must reach every checkpoint.
+// Call it once all rows have been checked.
+func (v *Verifier) Finish() error {
+	if v.lastAnchor != nil && v.lastAnchor.Seq >= v.next {
+		return &Break{Seq: v.next, Reason: fmt.Sprintf("chain ends at seq %d but the checkpoint of %s covers seq %d",
+			v.next-1, v.lastAnchor.CreatedAt.UTC().Format(time.RFC3339), v.lastAnchor.Seq)}
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package auditchain_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/watup-lk/identity-service/internal/auditchain"
+	"github.com/watup-lk/identity-service/internal/keys"
+)
+
+// chain seals n rows the way the repository writes them.
+func chain(n int) []auditchain.Record {
+	var recs []auditchain.Record
+	prev := auditchain.Genesis
+	for i := range n {
+		r := auditchain.Record{
+			ID:        fmt.Sprintf("00000000-0000-0000-0000-%012d", i+1),
+			UserID:    "11111111-1111-1111-1111-111111111111",
+			EventType: "login",
+			Success:   true,
+			IPAddress: "203.0.113.7",
+			CreatedAt: time.Now(),
+		}
+		auditchain.Seal(&r, int64(i+1), prev)
+		prev = r.Hash
+		recs = append(recs, r)
+	}
+	return recs
+}
+
+// verify runs the verifier over recs and returns the first break.
+func verify(recs []auditchain.Record, checkpoints ...auditchain.Checkpoint) (*auditchain.Verifier, *auditchain.Break) {
+	v := auditchain.NewVerifier(checkpoints)
+	var b *auditchain.Break
+	for _, r := range recs {
+		if err := v.Check(r); err != nil {
+			errors.As(err, &b)
+			return v, b
+		}
+	}
+	if err := v.Finish(); err != nil {
+		errors.As(err, &b)
+	}
+	return v, b
+}
+
+func testKey(t *testing.T) *keys.Key {
+	t.Helper()
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	k, err := keys.NewKey("", priv)
+	if err != nil {
+		t.Fatalf("NewKey: %v", err)
+	}
+	return k
+}
+
+// ── Chain Tests ───────────────────────────────────────────────────────────────
+
+func TestVerify_IntactChain(t *testing.T) {
+	v, b := verify(chain(5))
+	if b != nil {
+		t.Fatalf("expected an intact chain, got %v", b)
+	}
+	if v.Rows != 5 {
+		t.Errorf("expected 5 rows verified, got %d", v.Rows)
+	}
+}
+
+func TestSeal_NormalisesLikeTheDatabase(t *testing.T) {
+	r := auditchain.Record{
+		ID:        "00000000-0000-0000-0000-000000000001",
+		UserID:    "AAAAAAAA-1111-1111-1111-111111111111",
+		IPAddress: "2001:DB8:0:0::1",
+		CreatedAt: time.Date(2026, 3, 1, 12, 0, 0, 123456789, time.FixedZone("IST", 19800)),
+	}
+	auditchain.Seal(&r, 1, auditchain.Genesis)
+
+	// Read back from PostgreSQL: lower-case uuid, compressed address, microseconds
+	back := r
+	back.UserID = "aaaaaaaa-1111-1111-1111-111111111111"
+	back.IPAddress = "2001:db8::1"
+	back.CreatedAt = time.Date(2026, 3, 1, 6, 30, 0, 123456000, time.UTC)
+	if _, b := verify([]auditchain.Record{back}); b != nil {
+		t.Errorf("expected the row read back to verify, got %v", b)
+	}
+}
+
+func TestVerify_DetectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(recs []auditchain.Record) []auditchain.Record
+		seq    int64
+		reason string
+	}{
+		{"edited event", func(recs []auditchain.Record) []auditchain.Record {
+			recs[2].Success = false
+			return recs
+		}, 3, "content"},
+		{"edited user", func(recs []auditchain.Record) []auditchain.Record {
+			recs[1].UserID = "22222222-2222-2222-2222-222222222222"
+			return recs
+		}, 2, "content"},
+		{"edited IP address", func(recs []auditchain.Record) []auditchain.Record {
+			recs[3].IPAddress = "198.51.100.1"
+			return recs
+		}, 4, "IP address"},
+		{"removed IP address", func(recs []auditchain.Record) []auditchain.Record {
+			recs[3].IPAddress = ""
+			return recs
+		}, 4, "without being scrubbed"},
+		{"deleted row", func(recs []auditchain.Record) []auditchain.Record {
+			return append(recs[:2], recs[3:]...)
+		}, 3, "missing"},
+		{"rehashed row", func(recs []auditchain.Record) []auditchain.Record {
+			recs[1].EventType = "logout"
+			auditchain.Seal(&recs[1], 2, recs[0].Hash)
+			return recs
+		}, 3, "previous hash"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, b := verify(tt.tamper(chain(5)))
+			if b == nil {
+				t.Fatal("expected a break")
+			}
+			if b.Seq != tt.seq || !strings.Contains(b.Reason, tt.reason) {
+				t.Errorf("expected a break at seq %d mentioning %q, got %v", tt.seq, tt.reason, b)
+			}
+		})
+	}
+}
+
+func TestVerify_ScrubbedIPAddress(t *testing.T) {
+	recs := chain(3)
+	recs[1].IPAddress, recs[1].IPSalt = "", nil // account anonymised
+
+	v, b := verify(recs)
+	if b != nil {
+		t.Fatalf("expected a scrubbed row to verify, got %v", b)
+	}
+	if v.Scrubbed != 1 {
+		t.Errorf("expected 1 scrubbed row, got %d", v.Scrubbed)
+	}
+}
+
+// ── Checkpoint Tests ──────────────────────────────────────────────────────────
+
+func TestCheckpoints(t *testing.T) {
+	key := testKey(t)
+	recs := chain(4)
+	cp, err := auditchain.SignCheckpoint(key, 4, recs[3].Hash, time.Now())
+	if err != nil {
+		t.Fatalf("SignCheckpoint: %v", err)
+	}
+	ring := keys.NewKeyring(key)
+
+	if err := auditchain.VerifyCheckpoints(ring, []auditchain.Checkpoint{cp}); err != nil {
+		t.Errorf("expected a valid checkpoint, got %v", err)
+	}
+	if _, b := verify(recs, cp); b != nil {
+		t.Errorf("expected the chain to match its checkpoint, got %v", b)
+	}
+
+	// The last row was deleted
+	if _, b := verify(recs[:3], cp); b == nil || !strings.Contains(b.Reason, "checkpoint") {
+		t.Errorf("expected a truncated chain to break, got %v", b)
+	}
+
+	// The whole chain was rewritten from the second row on
+	rewritten := chain(4)
+	rewritten[0] = recs[0]
+	prev := recs[0].Hash
+	for i := 1; i < 4; i++ {
+		auditchain.Seal(&rewritten[i], int64(i+1), prev)
+		prev = rewritten[i].Hash
+	}
+	if _, b := verify(rewritten, cp); b == nil || b.Seq != 4 {
+		t.Errorf("expected a rewritten chain to break at the checkpoint, got %v", b)
+	}
+
+	// The checkpoint row was edited to match a rewritten chain
+	forged := cp
+	forged.Hash = rewritten[3].Hash
+	if err := auditchain.VerifyCheckpoints(ring, []auditchain.Checkpoint{forged}); err == nil {
+		t.Error("expected an altered checkpoint to fail")
+	}
+
+	// Signed with another key
+	if err := auditchain.VerifyCheckpoints(keys.NewKeyring(testKey(t)), []auditchain.Checkpoint{cp}); err == nil {
+		t.Error("expected a checkpoint signed with an unknown key to fail")
+	}
+}
+
+type fakeStore struct {
+	seq         int64
+	hash        []byte
+	checkpoints []auditchain.Checkpoint
+}
+
+func (s *fakeStore) AuditChainHead(_ context.Context) (int64, []byte, error) {
+	return s.seq, s.hash, nil
+}
+
+func (s *fakeStore) InsertAuditCheckpoint(_ context.Context, cp auditchain.Checkpoint) error {
+	s.checkpoints = append(s.checkpoints, cp)
+	return nil
+}
+
+func TestCheckpointer_SkipsUnchangedHead(t *testing.T) {
+	store := &fakeStore{}
+	c := auditchain.NewCheckpointer(store, testKey(t), time.Hour)
+	ctx := context.Background()
+
+	c.Checkpoint(ctx) // empty chain
+	store.seq, store.hash = 7, chain(1)[0].Hash
+	c.Checkpoint(ctx)
+	c.Checkpoint(ctx) // nothing new
+	if len(store.checkpoints) != 1 || store.checkpoints[0].Seq != 7 {
+		t.Fatalf("expected one checkpoint at seq 7, got %+v", store.checkpoints)
+	}
+	store.seq = 8
+	c.Checkpoint(ctx)
+	if len(store.checkpoints) != 2 {
+		t.Errorf("expected a second checkpoint after new rows, got %d", len(store.checkpoints))
+	}
+}
@@ -0,0 +1,145 @@
+package auditchain
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/watup-lk/identity-service/internal/keys"
+)
+
+// Checkpoint pins the chain: the hash of the row at Seq, signed when it was taken.
+type Checkpoint struct {
+	Seq       int64
+	Hash      []byte
+	Token     string // JWT signed with the checkpoint key, carrying Seq and Hash
+	CreatedAt time.Time
+}
+
+const checkpointSubject = "audit-log-checkpoint"
+
+type checkpointClaims struct {
+	Seq  int64  `json:"seq"`
+	Hash string `json:"hash"` // hex
+	jwt.RegisteredClaims
+}
+
+// ReadKey loads a checkpoint key: an RSA or Ed25519 private key in a PEM file. Its
+// kid is derived from the public key.
+func ReadKey(path string) (*keys.Key, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading audit checkpoint key: %w", err)
+	}
+	key, err := keys.ParsePrivateKeyPEM("", b)
+	if err != nil {
+		return nil, fmt.Errorf("audit checkpoint key %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// SignCheckpoint signs the chain head. key must be an RS256 or EdDSA key: the
+// checkpoint is only worth anything if the key is not available to whoever can
+// write to the database.
+func SignCheckpoint(key *keys.Key, seq int64, hash []byte, at time.Time) (Checkpoint, error) {
+	token, err := key.Sign(checkpointClaims{
+		Seq:  seq,
+		Hash: hex.EncodeToString(hash),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:   "watup-identity-service",
+			Subject:  checkpointSubject,
+			IssuedAt: jwt.NewNumericDate(at),
+		},
+	})
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("signing audit checkpoint: %w", err)
+	}
+	return Checkpoint{Seq: seq, Hash: hash, Token: token, CreatedAt: at}, nil
+}
+
+// VerifyCheckpoints checks that every checkpoint was signed by one of the keys and
+// that its stored seq and hash are the ones that were signed. It returns a *Break
+// for the first checkpoint that fails.
+func VerifyCheckpoints(ring *keys.Keyring, checkpoints []Checkpoint) error {
+	for _, cp := range checkpoints {
+		claims := &checkpointClaims{}
+		_, err := jwt.ParseWithClaims(cp.Token, claims, ring.Keyfunc,
+			jwt.WithValidMethods([]string{keys.AlgRS256, keys.AlgEdDSA}), jwt.WithSubject(checkpointSubject))
+		if err != nil {
+			return &Break{Seq: cp.Seq, Reason: fmt.Sprintf("checkpoint of %s has an invalid signature: %v", cp.CreatedAt.UTC().Format(time.RFC3339), err)}
+		}
+		if claims.Seq != cp.Seq || claims.Hash != hex.EncodeToString(cp.Hash) {
+			return &Break{Seq: cp.Seq, Reason: fmt.Sprintf("checkpoint of %s was altered after signing", cp.CreatedAt.UTC().Format(time.RFC3339))}
+		}
+	}
+	return nil
+}
+
+// CheckpointStore reads the chain head and records checkpoints (see
+// repository.PostgresRepo).
+type CheckpointStore interface {
+	// AuditChainHead returns the seq and hash of the last row, or 0 and Genesis for
+	// an empty chain.
+	AuditChainHead(ctx context.Context) (int64, []byte, error)
+	// InsertAuditCheckpoint records a checkpoint; one for the same seq already
+	// recorded by another replica is kept.
+	InsertAuditCheckpoint(ctx context.Context, cp Checkpoint) error
+}
+
+// Checkpointer signs the chain head at an interval.
+type Checkpointer struct {
+	store    CheckpointStore
+	key      *keys.Key
+	interval time.Duration
+	lastSeq  int64
+}
+
+func NewCheckpointer(store CheckpointStore, key *keys.Key, interval time.Duration) *Checkpointer {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	return &Checkpointer{store: store, key: key, interval: interval}
+}
+
+// Run takes a checkpoint every interval until ctx is cancelled.
+func (c *Checkpointer) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.Checkpoint(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("[audit] Taking a checkpoint failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Checkpoint signs and records the current chain head, unless no rows were added
+// since the last checkpoint this Checkpointer took.
+func (c *Checkpointer) Checkpoint(ctx context.Context) error {
+	seq, hash, err := c.store.AuditChainHead(ctx)
+	if err != nil {
+		return err
+	}
+	if seq == 0 || seq == c.lastSeq {
+		return nil
+	}
+	cp, err := SignCheckpoint(c.key, seq, hash, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := c.store.InsertAuditCheckpoint(ctx, cp); err != nil {
+		return err
+	}
+	c.lastSeq = seq
+	return nil
+}
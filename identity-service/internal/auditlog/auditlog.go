@@ -0,0 +1,384 @@
+// Package auditlog writes audit events to identity_schema.audit_logs off the request
+// path. Events are queued in a bounded buffer and written in batches, one multi-row
+// INSERT per batch, so a burst of logins costs a few statements instead of a goroutine
+// and a transaction each.
+//
+// When the database cannot keep up, the queue fills and Record briefly blocks its
+// caller, slowing requests down instead of growing memory. Events that still do not
+// fit, and batches that cannot be written, are appended to a spill file on local disk
+// and written once the database is back. Only without a spill directory, or when the
+// spill file is full, are events dropped.
+package auditlog
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Event is one audit log entry.
+type Event struct {
+	UserID    string    `json:"user_id,omitempty"` // empty if the user is unknown
+	EventType string    `json:"event_type"`
+	Success   bool      `json:"success"`
+	IPAddress string    `json:"ip_address,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Store is the audit_logs table (see repository.PostgresRepo).
+type Store interface {
+	// InsertAuditLogs records events in the order given, all or none of them.
+	InsertAuditLogs(ctx context.Context, events []Event) error
+}
+
+// Config tunes the writer.
+type Config struct {
+	QueueSize     int           // events buffered before Record blocks
+	BatchSize     int           // events written per INSERT
+	FlushInterval time.Duration // longest an event waits for its batch to fill
+	SpillDir      string        // directory of the spill file; empty drops events instead
+}
+
+const (
+	enqueueTimeout  = 50 * time.Millisecond // how long Record waits for room in a full queue
+	writeTimeout    = 5 * time.Second
+	shutdownTimeout = 10 * time.Second // bounds the final flush
+	minRetryDelay   = time.Second
+	maxRetryDelay   = 30 * time.Second
+	maxSpillSize    = 64 << 20
+
+	spillFile  = "audit-spill.jsonl"
+	replayFile = "audit-spill.replay.jsonl" // spill file being written back
+)
+
+var errNoSpillDir = errors.New("no spill directory configured")
+
+var (
+	auditQueued = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "identity",
+		Name:      "audit_events_queued",
+		Help:      "Audit events waiting to be written.",
+	})
+
+	auditWritten = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "identity",
+		Name:      "audit_events_written_total",
+		Help:      "Audit events written to the database.",
+	})
+
+	auditSpilled = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "identity",
+		Name:      "audit_events_spilled_total",
+		Help:      "Audit events saved to the spill file because the queue was full or the database failed.",
+	})
+
+	auditDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "identity",
+		Name:      "audit_events_dropped_total",
+		Help:      "Audit events lost: neither written nor spilled.",
+	})
+)
+
+// Writer queues audit events and writes them in batches.
+type Writer struct {
+	store Store
+	cfg   Config
+	queue chan Event
+
+	stopped  atomic.Bool // Run is flushing the queue for the last time; new events are spilled
+	dropping atomic.Bool // a drop has been logged since the last successful write
+
+	spillMu    sync.Mutex
+	nextReplay time.Time // only used by Run
+}
+
+func NewWriter(store Store, cfg Config) *Writer {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 10000
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 200 * time.Millisecond
+	}
+	return &Writer{store: store, cfg: cfg, queue: make(chan Event, cfg.QueueSize)}
+}
+
+// Record queues ev for writing. If the queue is full it waits briefly for room, which
+// slows the caller down, and then spills ev to disk, or drops it without a spill
+// directory. Events recorded after Run has returned are spilled or dropped as well.
+func (w *Writer) Record(ev Event) {
+	if ev.CreatedAt.IsZero() {
+		ev.CreatedAt = time.Now()
+	}
+	if !w.stopped.Load() && w.enqueue(ev) {
+		return
+	}
+	w.spill([]Event{ev})
+}
+
+func (w *Writer) enqueue(ev Event) bool {
+	select {
+	case w.queue <- ev:
+		auditQueued.Inc()
+		return true
+	default:
+	}
+	timer := time.NewTimer(enqueueTimeout)
+	defer timer.Stop()
+	select {
+	case w.queue <- ev:
+		auditQueued.Inc()
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// Run writes queued events until ctx is cancelled, and then flushes the queue. Cancel
+// ctx only once nothing records events any more, e.g. after the servers have stopped.
+func (w *Writer) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Event, 0, w.cfg.BatchSize)
+	for {
+		select {
+		case <-ctx.Done():
+			w.shutdown(batch)
+			return
+		case ev := <-w.queue:
+			auditQueued.Dec()
+			batch = append(batch, ev)
+			if len(batch) < w.cfg.BatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				w.replaySpill(ctx)
+				continue
+			}
+		}
+		w.flush(ctx, batch)
+		batch = batch[:0]
+	}
+}
+
+// shutdown writes what is left in the queue. Whatever cannot be written within
+// shutdownTimeout is spilled or dropped.
+func (w *Writer) shutdown(batch []Event) {
+	w.stopped.Store(true)
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	for {
+		select {
+		case ev := <-w.queue:
+			auditQueued.Dec()
+			batch = append(batch, ev)
+			if len(batch) == w.cfg.BatchSize {
+				w.flush(ctx, batch)
+				batch = batch[:0]
+			}
+		default:
+			if len(batch) > 0 {
+				w.flush(ctx, batch)
+			}
+			return
+		}
+	}
+}
+
+// flush writes a batch. If that fails, the batch is spilled; without a spill
+// directory it is retried with backoff until it is written or ctx is cancelled,
+// while new events wait in the queue.
+func (w *Writer) flush(ctx context.Context, batch []Event) {
+	delay := minRetryDelay
+	for {
+		err := w.write(ctx, batch)
+		if err == nil {
+			return
+		}
+		log.Printf("[audit] Writing %d audit event(s) failed: %v", len(batch), err)
+		if w.cfg.SpillDir != "" || ctx.Err() != nil {
+			w.spill(batch)
+			w.nextReplay = time.Now().Add(maxRetryDelay)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			w.spill(batch)
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
+
+func (w *Writer) write(ctx context.Context, batch []Event) error {
+	// A write in progress when ctx is cancelled is finished, bounded by writeTimeout
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), writeTimeout)
+	defer cancel()
+
+	if err := w.store.InsertAuditLogs(ctx, batch); err != nil {
+		return err
+	}
+	auditWritten.Add(float64(len(batch)))
+	w.dropping.Store(false)
+	return nil
+}
+
+// spill appends events to the spill file, or counts them as dropped if they cannot
+// be saved. Only the first drop after a successful write is logged, so an outage
+// does not flood the log; identity_audit_events_dropped_total counts them all.
+func (w *Writer) spill(events []Event) {
+	if err := w.appendSpill(events); err != nil {
+		auditDropped.Add(float64(len(events)))
+		if !w.dropping.Swap(true) {
+			log.Printf("[audit] Dropping audit events: %v", err)
+		}
+		return
+	}
+	auditSpilled.Add(float64(len(events)))
+}
+
+func (w *Writer) appendSpill(events []Event) error {
+	if w.cfg.SpillDir == "" {
+		return errNoSpillDir
+	}
+	w.spillMu.Lock()
+	defer w.spillMu.Unlock()
+
+	path := filepath.Join(w.cfg.SpillDir, spillFile)
+	if fi, err := os.Stat(path); err == nil && fi.Size() >= maxSpillSize {
+		return fmt.Errorf("spill file %s is full", path)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening spill file: %w", err)
+	}
+	bw := bufio.NewWriter(f)
+	enc := json.NewEncoder(bw)
+	for _, ev := range events {
+		enc.Encode(ev) //nolint:errcheck // cannot fail for Event; write errors surface on Flush
+	}
+	if err := bw.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("writing spill file: %w", err)
+	}
+	return f.Close()
+}
+
+// replaySpill writes spilled events back to the database. The spill file is first
+// renamed, so events spilled meanwhile go to a new one; if the process stops while
+// replaying, the renamed file is replayed again on the next start, which can record
+// some of its events twice but loses none.
+func (w *Writer) replaySpill(ctx context.Context) {
+	if w.cfg.SpillDir == "" || time.Now().Before(w.nextReplay) {
+		return
+	}
+	replay := filepath.Join(w.cfg.SpillDir, replayFile)
+	w.spillMu.Lock()
+	_, err := os.Stat(replay)
+	if errors.Is(err, os.ErrNotExist) {
+		err = os.Rename(filepath.Join(w.cfg.SpillDir, spillFile), replay)
+	}
+	w.spillMu.Unlock()
+	if errors.Is(err, os.ErrNotExist) {
+		return // nothing spilled
+	}
+	if err == nil {
+		var n int
+		n, err = w.replay(ctx, replay)
+		if n > 0 {
+			log.Printf("[audit] Wrote %d spilled audit event(s)", n)
+		}
+	}
+	if err != nil {
+		log.Printf("[audit] Writing spilled audit events failed: %v", err)
+		w.nextReplay = time.Now().Add(maxRetryDelay)
+		return
+	}
+	os.Remove(replay) //nolint:errcheck // replayed again, at worst
+}
+
+// replay writes the events in path in batches and returns how many were written.
+// If a batch fails, path is rewritten to hold only the events not yet written.
+func (w *Writer) replay(ctx context.Context, path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	written := 0
+	batch := make([]Event, 0, w.cfg.BatchSize)
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for {
+		var ev Event
+		err := dec.Decode(&ev)
+		if err != nil && !errors.Is(err, io.EOF) {
+			// A torn last line from a crash while spilling; the events before it are kept
+			log.Printf("[audit] Skipping the rest of the spill file: %v", err)
+		}
+		if err == nil {
+			batch = append(batch, ev)
+			if len(batch) < w.cfg.BatchSize {
+				continue
+			}
+		}
+		if len(batch) > 0 {
+			if werr := w.write(ctx, batch); werr != nil {
+				return written, errors.Join(werr, keepUnwritten(path, batch, dec))
+			}
+			written += len(batch)
+			batch = batch[:0]
+		}
+		if err != nil {
+			return written, nil
+		}
+	}
+}
+
+// keepUnwritten replaces path with the batch that failed and the events still to be
+// read from dec.
+func keepUnwritten(path string, batch []Event, dec *json.Decoder) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	enc := json.NewEncoder(bw)
+	for _, ev := range batch {
+		enc.Encode(ev) //nolint:errcheck // write errors surface on Flush
+	}
+	for {
+		var ev Event
+		if dec.Decode(&ev) != nil {
+			break
+		}
+		enc.Encode(ev) //nolint:errcheck
+	}
+	if err := bw.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
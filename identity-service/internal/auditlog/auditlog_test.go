@@ -0,0 +1,248 @@
+package auditlog_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/watup-lk/identity-service/internal/auditlog"
+)
+
+// ── Fakes ─────────────────────────────────────────────────────────────────────
+
+type fakeStore struct {
+	mu      sync.Mutex
+	batches [][]auditlog.Event
+	calls   int
+	failOn  map[int]bool // InsertAuditLogs calls (from 1) that fail
+	down    bool         // every call fails
+}
+
+func (s *fakeStore) InsertAuditLogs(_ context.Context, events []auditlog.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.down || s.failOn[s.calls] {
+		return errors.New("database unavailable")
+	}
+	s.batches = append(s.batches, slices.Clone(events))
+	return nil
+}
+
+func (s *fakeStore) setDown(down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down = down
+}
+
+// written returns the event types written, in order.
+func (s *fakeStore) written() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var types []string
+	for _, b := range s.batches {
+		for _, ev := range b {
+			types = append(types, ev.EventType)
+		}
+	}
+	return types
+}
+
+func (s *fakeStore) batchSizes() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var sizes []int
+	for _, b := range s.batches {
+		sizes = append(sizes, len(b))
+	}
+	return sizes
+}
+
+func event(n int) auditlog.Event {
+	return auditlog.Event{UserID: "u1", EventType: fmt.Sprintf("e%d", n), Success: true, IPAddress: "203.0.113.7"}
+}
+
+func eventTypes(from, to int) []string {
+	var types []string
+	for i := from; i < to; i++ {
+		types = append(types, fmt.Sprintf("e%d", i))
+	}
+	return types
+}
+
+// run starts w and returns a function that stops it and waits for the final flush.
+func run(t *testing.T, w *auditlog.Writer) func() {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+	return func() {
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Run() did not return after cancel")
+		}
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// ── Writer Tests ──────────────────────────────────────────────────────────────
+
+func TestRun_WritesInBatches(t *testing.T) {
+	store := &fakeStore{}
+	w := auditlog.NewWriter(store, auditlog.Config{BatchSize: 100, FlushInterval: time.Hour})
+	for i := range 250 {
+		w.Record(event(i))
+	}
+	stop := run(t, w)
+	waitFor(t, func() bool { return len(store.written()) == 200 })
+	stop() // the last, partial batch is flushed on shutdown
+
+	if !slices.Equal(store.written(), eventTypes(0, 250)) {
+		t.Errorf("expected every event once in order, got %d events", len(store.written()))
+	}
+	if !slices.Equal(store.batchSizes(), []int{100, 100, 50}) {
+		t.Errorf("expected batches of 100, 100 and 50, got %v", store.batchSizes())
+	}
+}
+
+func TestRun_FlushesPartialBatchAfterInterval(t *testing.T) {
+	store := &fakeStore{}
+	w := auditlog.NewWriter(store, auditlog.Config{BatchSize: 100, FlushInterval: 10 * time.Millisecond})
+	stop := run(t, w)
+	defer stop()
+
+	w.Record(auditlog.Event{EventType: "login"})
+	waitFor(t, func() bool { return len(store.written()) == 1 })
+	if got := store.batches[0][0]; got.CreatedAt.IsZero() {
+		t.Error("expected Record to timestamp the event")
+	}
+}
+
+func TestRecord_FullQueueWithoutSpillDirDrops(t *testing.T) {
+	store := &fakeStore{}
+	w := auditlog.NewWriter(store, auditlog.Config{QueueSize: 1})
+
+	start := time.Now()
+	w.Record(event(0))
+	w.Record(event(1)) // waits for room, then is dropped
+	if time.Since(start) < 40*time.Millisecond {
+		t.Error("expected Record to wait for room in a full queue")
+	}
+	run(t, w)()
+
+	if !slices.Equal(store.written(), []string{"e0"}) {
+		t.Errorf("expected only the queued event, got %v", store.written())
+	}
+}
+
+func TestRecord_FullQueueSpillsAndReplays(t *testing.T) {
+	dir := t.TempDir()
+	store := &fakeStore{}
+	w := auditlog.NewWriter(store, auditlog.Config{QueueSize: 1, FlushInterval: 10 * time.Millisecond, SpillDir: dir})
+	for i := range 3 {
+		w.Record(event(i))
+	}
+
+	stop := run(t, w)
+	defer stop()
+	waitFor(t, func() bool { return len(store.written()) == 3 })
+
+	if !slices.Equal(store.written(), eventTypes(0, 3)) {
+		t.Errorf("expected the queued event, then the spilled ones, got %v", store.written())
+	}
+	waitFor(t, func() bool {
+		entries, _ := os.ReadDir(dir)
+		return len(entries) == 0
+	})
+}
+
+func TestRun_FailedBatchIsSpilled(t *testing.T) {
+	dir := t.TempDir()
+	store := &fakeStore{down: true}
+	w := auditlog.NewWriter(store, auditlog.Config{FlushInterval: 10 * time.Millisecond, SpillDir: dir})
+	w.Record(event(0))
+	run(t, w)()
+
+	if len(store.written()) != 0 {
+		t.Fatal("expected nothing to be written while the database is down")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "audit-spill.jsonl")); err != nil {
+		t.Fatalf("expected the event to be spilled: %v", err)
+	}
+
+	// The next writer, e.g. after a restart, writes it back
+	store.setDown(false)
+	defer run(t, auditlog.NewWriter(store, auditlog.Config{FlushInterval: 10 * time.Millisecond, SpillDir: dir}))()
+	waitFor(t, func() bool { return len(store.written()) == 1 })
+}
+
+func TestReplay_KeepsUnwrittenEvents(t *testing.T) {
+	dir := t.TempDir()
+	w := auditlog.NewWriter(&fakeStore{}, auditlog.Config{QueueSize: 1, SpillDir: dir})
+	w.Record(event(-1)) // fills the queue; never written
+	for i := range 25 {
+		w.Record(event(i))
+	}
+
+	// The second batch of the replay fails: the first is written, the rest kept
+	store := &fakeStore{failOn: map[int]bool{2: true}}
+	cfg := auditlog.Config{BatchSize: 10, FlushInterval: 10 * time.Millisecond, SpillDir: dir}
+	stop := run(t, auditlog.NewWriter(store, cfg))
+	waitFor(t, func() bool {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		return store.calls >= 2
+	})
+	stop()
+	if !slices.Equal(store.written(), eventTypes(0, 10)) {
+		t.Fatalf("expected the first batch to be written, got %d events", len(store.written()))
+	}
+
+	defer run(t, auditlog.NewWriter(store, cfg))()
+	waitFor(t, func() bool { return len(store.written()) == 25 })
+	if !slices.Equal(store.written(), eventTypes(0, 25)) {
+		t.Errorf("expected every spilled event exactly once in order, got %d events", len(store.written()))
+	}
+}
+
+func TestRun_RetriesWithoutSpillDir(t *testing.T) {
+	store := &fakeStore{failOn: map[int]bool{1: true}}
+	w := auditlog.NewWriter(store, auditlog.Config{FlushInterval: 10 * time.Millisecond})
+	stop := run(t, w)
+	defer stop()
+
+	w.Record(event(0))
+	waitFor(t, func() bool { return len(store.written()) == 1 })
+}
+
+func TestRecord_AfterRunSpills(t *testing.T) {
+	dir := t.TempDir()
+	w := auditlog.NewWriter(&fakeStore{}, auditlog.Config{SpillDir: dir})
+	run(t, w)()
+
+	w.Record(event(0))
+	if _, err := os.Stat(filepath.Join(dir, "audit-spill.jsonl")); err != nil {
+		t.Errorf("expected an event recorded after shutdown to be spilled: %v", err)
+	}
+}
@@ -3,12 +3,22 @@ package config
 import (
 	"context"
 	"log"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+
+	"github.com/watup-lk/identity-service/internal/auditlog"
+	"github.com/watup-lk/identity-service/internal/emailaddr"
+	"github.com/watup-lk/identity-service/internal/oauth"
+	"github.com/watup-lk/identity-service/internal/outbox"
+	"github.com/watup-lk/identity-service/internal/passhash"
+	"github.com/watup-lk/identity-service/internal/pwpolicy"
+	"github.com/watup-lk/identity-service/internal/webauthn"
 )
 
 type Config struct {
@@ -17,25 +27,133 @@ type Config struct {
 	MetricsPort          string
 	DatabaseURL          string
 	JWTSecret            string
+	JWTSigningAlg        string // HS256 (default), RS256 or EdDSA
+	JWTPrivateKey        string // PEM private key for RS256/EdDSA
+	JWTPrivateKeyFile    string // alternative to JWTPrivateKey, e.g. a mounted secret
+	JWTKeyID             string // optional kid; derived from the key thumbprint when empty
+	JWTKeyDir            string // directory of <kid>.pem keys; the last by name signs
+	JWTKeyReloadMinutes  int    // periodic key reload interval; 0 = only on SIGHUP
 	KafkaBrokers         []string
 	AzureKeyVaultURL     string
 	AccessTokenMinutes   int
 	RefreshTokenDays     int
+	PasswordResetMinutes int
+	EmailVerifyHours     int    // lifetime of the email verification link
+	EmailChangeHours     int    // lifetime of the confirmation link sent to a new email address
+	EmailChangeDays      int    // how long the old address can cancel or undo an email change
+	EmailLowercaseLocal  bool   // lower-case the local part of addresses, not only the domain
+	EmailStripSubaddress bool   // ignore "+tag" suffixes in the local part
+	EmailDotlessDomains  string // comma-separated domains whose local part ignores dots
+	LockoutThreshold     int    // failed logins before the account is temporarily locked; 0 disables lockout
+	LockoutBaseSeconds   int    // first lockout duration, doubled on each further failure
+	LockoutMaxMinutes    int    // cap on a single lockout
+	MFAChallengeMinutes  int    // lifetime of the token returned by Login when MFA is enabled
+	MFAIssuer            string // issuer label shown in authenticator apps
+	AccountDeletionDays  int    // grace period before a deleted account's PII is scrubbed
+	AccountPurgeMinutes  int    // how often the purge job looks for accounts past the grace period
+	DataExportHours      int    // how long a finished personal data export can be downloaded
+	ExportContributors   string // "section=host:port,..." services that add their data to exports
+	OutboxPollMillis     int    // how often the outbox relay looks for new events
+	OutboxBatchSize      int    // events the relay claims per batch
+	OutboxRetentionHours int    // how long published outbox events are kept; 0 keeps them
+	AuditKeyFile         string // PEM key that signs audit chain checkpoints; empty disables checkpoints
+	AuditCheckpointMins  int    // how often the audit chain head is checkpointed
+	AuditQueueSize       int    // audit events buffered before requests wait for room
+	AuditBatchSize       int    // audit events written per INSERT
+	AuditFlushMillis     int    // longest an audit event waits for its batch
+	AuditSpillDir        string // where audit events go when the database is unavailable; empty drops them
+	PasswordHashAlg      string // "argon2id" (default) or "bcrypt" for new and upgraded hashes
+	Argon2MemoryKiB      int
+	Argon2Time           int
+	Argon2Parallelism    int
+	BcryptCost           int
+	PasswordMinLength    int             // minimum characters for new passwords
+	PasswordMinScore     int             // minimum strength score (0–4) for new passwords
+	BreachedPasswordsDir string          // HIBP range files checked on signup and password change; empty disables
+	OAuthProviders       []OAuthProvider // social login providers, from OAUTH_PROVIDERS
+	OAuthRedirectURL     string          // frontend callback; the provider name is appended as a path segment
+	OAuthStateMinutes    int             // how long a started social login can be completed
+	WebAuthnRPID         string          // passkey relying party id, a bare domain; defaults to the FrontendURL host
+	WebAuthnRPName       string          // name shown by the authenticator; defaults to MFAIssuer
+	WebAuthnOrigins      []string        // origins allowed to run passkey ceremonies; defaults to FrontendURL
+	WebAuthnMinutes      int             // how long a started passkey ceremony can be completed
+	FrontendURL          string          // base URL used to build links in outgoing emails
+	MailDriver           string          // "log", "file" or "smtp"
+	MailFrom             string
+	MailOutboxDir        string // target directory for the "file" driver
+	SMTPAddr             string // host:port for the "smtp" driver
+	SMTPUsername         string
+	SMTPPassword         string
 }
 
 func Load() *Config {
 	cfg := &Config{
-		Port:               getEnv("PORT", "8080"),
-		GRPCPort:           getEnv("GRPC_PORT", "50052"),
-		MetricsPort:        getEnv("METRICS_PORT", "9090"),
-		DatabaseURL:        getEnv("DATABASE_URL", ""),
-		JWTSecret:          getEnv("JWT_SECRET", ""),
-		KafkaBrokers:       strings.Split(getEnv("KAFKA_BROKERS", "localhost:9092"), ","),
-		AzureKeyVaultURL:   getEnv("AZURE_KEYVAULT_URL", ""),
-		AccessTokenMinutes: getEnvInt("ACCESS_TOKEN_MINUTES", 15),
-		RefreshTokenDays:   getEnvInt("REFRESH_TOKEN_DAYS", 7),
+		Port:                getEnv("PORT", "8080"),
+		GRPCPort:            getEnv("GRPC_PORT", "50052"),
+		MetricsPort:         getEnv("METRICS_PORT", "9090"),
+		DatabaseURL:         getEnv("DATABASE_URL", ""),
+		JWTSecret:           getEnv("JWT_SECRET", ""),
+		JWTSigningAlg:       getEnv("JWT_SIGNING_ALG", "HS256"),
+		JWTPrivateKey:       getEnv("JWT_PRIVATE_KEY", ""),
+		JWTPrivateKeyFile:   getEnv("JWT_PRIVATE_KEY_FILE", ""),
+		JWTKeyID:            getEnv("JWT_KEY_ID", ""),
+		JWTKeyDir:           getEnv("JWT_KEY_DIR", ""),
+		JWTKeyReloadMinutes: getEnvInt("JWT_KEY_RELOAD_MINUTES", 0),
+		KafkaBrokers:        strings.Split(getEnv("KAFKA_BROKERS", "localhost:9092"), ","),
+		AzureKeyVaultURL:    getEnv("AZURE_KEYVAULT_URL", ""),
+		AccessTokenMinutes:  getEnvInt("ACCESS_TOKEN_MINUTES", 15),
+		RefreshTokenDays:    getEnvInt("REFRESH_TOKEN_DAYS", 7),
+
+		PasswordResetMinutes: getEnvInt("PASSWORD_RESET_MINUTES", 60),
+		EmailVerifyHours:     getEnvInt("EMAIL_VERIFY_HOURS", 48),
+		EmailChangeHours:     getEnvInt("EMAIL_CHANGE_HOURS", 24),
+		EmailChangeDays:      getEnvInt("EMAIL_CHANGE_CANCEL_DAYS", 7),
+		EmailLowercaseLocal:  getEnvBool("EMAIL_LOWERCASE_LOCAL_PART", emailaddr.DefaultRules.LowercaseLocal),
+		EmailStripSubaddress: getEnvBool("EMAIL_STRIP_SUBADDRESS", emailaddr.DefaultRules.StripSubaddress),
+		EmailDotlessDomains:  getEnv("EMAIL_DOTLESS_DOMAINS", ""),
+		LockoutThreshold:     getEnvInt("LOGIN_LOCKOUT_THRESHOLD", 5),
+		LockoutBaseSeconds:   getEnvInt("LOGIN_LOCKOUT_BASE_SECONDS", 30),
+		LockoutMaxMinutes:    getEnvInt("LOGIN_LOCKOUT_MAX_MINUTES", 60),
+		MFAChallengeMinutes:  getEnvInt("MFA_CHALLENGE_MINUTES", 5),
+		MFAIssuer:            getEnv("MFA_ISSUER", "WatUp"),
+		AccountDeletionDays:  getEnvInt("ACCOUNT_DELETION_GRACE_DAYS", 30),
+		AccountPurgeMinutes:  getEnvInt("ACCOUNT_PURGE_INTERVAL_MINUTES", 60),
+		DataExportHours:      getEnvInt("DATA_EXPORT_RETENTION_HOURS", 72),
+		ExportContributors:   getEnv("DATA_EXPORT_CONTRIBUTORS", ""),
+		OutboxPollMillis:     getEnvInt("OUTBOX_POLL_INTERVAL_MS", 500),
+		OutboxBatchSize:      getEnvInt("OUTBOX_BATCH_SIZE", 100),
+		OutboxRetentionHours: getEnvInt("OUTBOX_RETENTION_HOURS", 24),
+		AuditKeyFile:         getEnv("AUDIT_CHECKPOINT_KEY_FILE", ""),
+		AuditCheckpointMins:  getEnvInt("AUDIT_CHECKPOINT_INTERVAL_MINUTES", 60),
+		AuditQueueSize:       getEnvInt("AUDIT_QUEUE_SIZE", 10000),
+		AuditBatchSize:       getEnvInt("AUDIT_BATCH_SIZE", 100),
+		AuditFlushMillis:     getEnvInt("AUDIT_FLUSH_INTERVAL_MS", 200),
+		AuditSpillDir:        getEnv("AUDIT_SPILL_DIR", ""),
+		PasswordHashAlg:      getEnv("PASSWORD_HASH_ALG", passhash.DefaultPolicy.Algorithm),
+		Argon2MemoryKiB:      getEnvInt("ARGON2_MEMORY_KIB", int(passhash.DefaultPolicy.Argon2Memory)),
+		Argon2Time:           getEnvInt("ARGON2_TIME", int(passhash.DefaultPolicy.Argon2Time)),
+		Argon2Parallelism:    getEnvInt("ARGON2_PARALLELISM", int(passhash.DefaultPolicy.Argon2Parallelism)),
+		BcryptCost:           getEnvInt("BCRYPT_COST", passhash.DefaultPolicy.BcryptCost),
+		PasswordMinLength:    getEnvInt("PASSWORD_MIN_LENGTH", pwpolicy.DefaultRules.MinLength),
+		PasswordMinScore:     getEnvInt("PASSWORD_MIN_SCORE", pwpolicy.DefaultRules.MinScore),
+		BreachedPasswordsDir: getEnv("BREACHED_PASSWORDS_DIR", ""),
+		OAuthProviders:       loadOAuthProviders(getEnv("OAUTH_PROVIDERS", "")),
+		OAuthStateMinutes:    getEnvInt("OAUTH_STATE_MINUTES", 10),
+		FrontendURL:          getEnv("FRONTEND_URL", "http://localhost:3000"),
+		MailDriver:           getEnv("MAIL_DRIVER", "log"),
+		MailFrom:             getEnv("MAIL_FROM", "no-reply@watup.lk"),
+		MailOutboxDir:        getEnv("MAIL_OUTBOX_DIR", "./mail-outbox"),
+		SMTPAddr:             getEnv("SMTP_ADDR", ""),
+		SMTPUsername:         getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:         getEnv("SMTP_PASSWORD", ""),
 	}
 
+	cfg.OAuthRedirectURL = getEnv("OAUTH_REDIRECT_URL", cfg.FrontendURL+"/oauth/callback")
+	cfg.WebAuthnRPID = getEnv("WEBAUTHN_RP_ID", hostname(cfg.FrontendURL))
+	cfg.WebAuthnRPName = getEnv("WEBAUTHN_RP_NAME", cfg.MFAIssuer)
+	cfg.WebAuthnOrigins = splitList(getEnv("WEBAUTHN_ORIGINS", strings.TrimSuffix(cfg.FrontendURL, "/")))
+	cfg.WebAuthnMinutes = getEnvInt("WEBAUTHN_CHALLENGE_MINUTES", 5)
+
 	// Override secrets from Azure Key Vault when running in AKS with Workload Identity
 	if cfg.AzureKeyVaultURL != "" {
 		cfg.loadFromKeyVault()
@@ -44,6 +162,149 @@ func Load() *Config {
 	return cfg
 }
 
+// PasswordPolicy returns the password hashing policy. Unset (zero) values fall back
+// to passhash.DefaultPolicy.
+func (c *Config) PasswordPolicy() passhash.Policy {
+	return passhash.Policy{
+		Algorithm:         c.PasswordHashAlg,
+		Argon2Memory:      uint32(max(c.Argon2MemoryKiB, 0)),
+		Argon2Time:        uint32(max(c.Argon2Time, 0)),
+		Argon2Parallelism: uint8(min(max(c.Argon2Parallelism, 0), 255)),
+		BcryptCost:        c.BcryptCost,
+	}
+}
+
+// PasswordRules returns the rules new passwords must satisfy.
+func (c *Config) PasswordRules() pwpolicy.Rules {
+	return pwpolicy.Rules{
+		MinLength:   c.PasswordMinLength,
+		MaxLength:   pwpolicy.DefaultRules.MaxLength,
+		MinScore:    c.PasswordMinScore,
+		BreachedDir: c.BreachedPasswordsDir,
+	}
+}
+
+// EmailRules returns the rules used to normalise email addresses.
+func (c *Config) EmailRules() emailaddr.Rules {
+	return emailaddr.Rules{
+		LowercaseLocal:  c.EmailLowercaseLocal,
+		StripSubaddress: c.EmailStripSubaddress,
+		DotlessDomains:  emailaddr.ParseDomains(c.EmailDotlessDomains),
+	}
+}
+
+// OutboxConfig returns the settings of the Kafka outbox relay.
+func (c *Config) OutboxConfig() outbox.Config {
+	return outbox.Config{
+		PollInterval: time.Duration(c.OutboxPollMillis) * time.Millisecond,
+		BatchSize:    c.OutboxBatchSize,
+		Retention:    time.Duration(c.OutboxRetentionHours) * time.Hour,
+	}
+}
+
+// AuditWriterConfig returns the settings of the batching audit log writer.
+func (c *Config) AuditWriterConfig() auditlog.Config {
+	return auditlog.Config{
+		QueueSize:     c.AuditQueueSize,
+		BatchSize:     c.AuditBatchSize,
+		FlushInterval: time.Duration(c.AuditFlushMillis) * time.Millisecond,
+		SpillDir:      c.AuditSpillDir,
+	}
+}
+
+// OAuthProvider configures one social login provider. Every setting comes from
+// OAUTH_<NAME>_<SETTING>, e.g. OAUTH_GOOGLE_CLIENT_ID.
+type OAuthProvider struct {
+	Name         string
+	Issuer       string // defaults to the well-known issuer for google, linkedin and github
+	ClientID     string
+	ClientSecret string
+	Scopes       []string // OAUTH_<NAME>_SCOPES, space-separated; empty = provider default
+}
+
+// defaultOAuthIssuers lets the common providers be enabled with credentials alone.
+var defaultOAuthIssuers = map[string]string{
+	"google":   "https://accounts.google.com",
+	"linkedin": "https://www.linkedin.com/oauth",
+	"github":   "https://github.com",
+}
+
+func loadOAuthProviders(spec string) []OAuthProvider {
+	var out []OAuthProvider
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OAUTH_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		out = append(out, OAuthProvider{
+			Name:         name,
+			Issuer:       getEnv(prefix+"ISSUER", defaultOAuthIssuers[name]),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			Scopes:       strings.Fields(getEnv(prefix+"SCOPES", "")),
+		})
+	}
+	return out
+}
+
+// OAuthConfigs returns the settings for each enabled social login provider.
+func (c *Config) OAuthConfigs() []oauth.Config {
+	out := make([]oauth.Config, 0, len(c.OAuthProviders))
+	for _, p := range c.OAuthProviders {
+		out = append(out, oauth.Config{
+			Name:         p.Name,
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			Scopes:       p.Scopes,
+			RedirectURL:  strings.TrimSuffix(c.OAuthRedirectURL, "/") + "/" + p.Name,
+		})
+	}
+	return out
+}
+
+// WebAuthnConfig returns the passkey relying party settings.
+func (c *Config) WebAuthnConfig() webauthn.Config {
+	return webauthn.Config{
+		RPID:    c.WebAuthnRPID,
+		RPName:  c.WebAuthnRPName,
+		Origins: c.WebAuthnOrigins,
+		Timeout: time.Duration(c.WebAuthnMinutes) * time.Minute,
+	}
+}
+
+// hostname returns the host of rawURL without its port, or "" if it does not parse.
+func hostname(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// splitList splits a comma-separated setting, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// ReloadSecrets returns a copy of the config with secrets re-fetched from Azure Key
+// Vault, used to pick up a rotated jwt-signing-key / jwt-private-key without a restart.
+// The receiver is not modified, so it is safe to call while other goroutines read it.
+func (c *Config) ReloadSecrets() *Config {
+	fresh := *c
+	if fresh.AzureKeyVaultURL != "" {
+		fresh.loadFromKeyVault()
+	}
+	return &fresh
+}
+
 // loadFromKeyVault fetches secrets from Azure Key Vault using Managed Identity (Workload Identity).
 // Falls back gracefully to environment variables if Key Vault is not reachable.
 func (c *Config) loadFromKeyVault() {
@@ -68,12 +329,30 @@ func (c *Config) loadFromKeyVault() {
 		log.Printf("[config] Azure Key Vault: jwt-signing-key not found, using env var: %v", err)
 	}
 
+	if secret, err := client.GetSecret(ctx, "jwt-private-key", "", nil); err == nil {
+		c.JWTPrivateKey = *secret.Value
+		log.Println("[config] Loaded jwt-private-key from Azure Key Vault")
+	}
+
 	if secret, err := client.GetSecret(ctx, "identity-db-url", "", nil); err == nil {
 		c.DatabaseURL = *secret.Value
 		log.Println("[config] Loaded identity-db-url from Azure Key Vault")
 	} else {
 		log.Printf("[config] Azure Key Vault: identity-db-url not found, using env var: %v", err)
 	}
+
+	if secret, err := client.GetSecret(ctx, "smtp-password", "", nil); err == nil {
+		c.SMTPPassword = *secret.Value
+		log.Println("[config] Loaded smtp-password from Azure Key Vault")
+	}
+
+	for i, p := range c.OAuthProviders {
+		name := "oauth-" + p.Name + "-client-secret"
+		if secret, err := client.GetSecret(ctx, name, "", nil); err == nil {
+			c.OAuthProviders[i].ClientSecret = *secret.Value
+			log.Printf("[config] Loaded %s from Azure Key Vault", name)
+		}
+	}
 }
 
 func getEnv(key, fallback string) string {
@@ -91,3 +370,12 @@ func getEnvInt(key string, fallback int) int {
 	}
 	return fallback
 }
+
+func getEnvBool(key string, fallback bool) bool {
+	if v, ok := os.LookupEnv(key); ok {
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return fallback
+}
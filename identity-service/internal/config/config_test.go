@@ -3,13 +3,14 @@ package config_test
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/watup-lk/identity-service/internal/config"
 )
 
 func TestLoad_Defaults(t *testing.T) {
 	// Unset all env vars to get defaults
-	for _, k := range []string{"PORT", "GRPC_PORT", "METRICS_PORT", "DATABASE_URL", "JWT_SECRET", "KAFKA_BROKERS", "AZURE_KEYVAULT_URL", "ACCESS_TOKEN_MINUTES", "REFRESH_TOKEN_DAYS"} {
+	for _, k := range []string{"PORT", "GRPC_PORT", "METRICS_PORT", "DATABASE_URL", "JWT_SECRET", "KAFKA_BROKERS", "AZURE_KEYVAULT_URL", "ACCESS_TOKEN_MINUTES", "REFRESH_TOKEN_DAYS", "JWT_SIGNING_ALG", "LOGIN_LOCKOUT_THRESHOLD", "PASSWORD_HASH_ALG", "PASSWORD_MIN_LENGTH", "PASSWORD_MIN_SCORE", "BREACHED_PASSWORDS_DIR", "OUTBOX_POLL_INTERVAL_MS", "OUTBOX_BATCH_SIZE", "OUTBOX_RETENTION_HOURS"} {
 		os.Unsetenv(k)
 	}
 
@@ -39,6 +40,24 @@ func TestLoad_Defaults(t *testing.T) {
 	if cfg.RefreshTokenDays != 7 {
 		t.Errorf("RefreshTokenDays: expected 7, got %d", cfg.RefreshTokenDays)
 	}
+	if cfg.JWTSigningAlg != "HS256" {
+		t.Errorf("JWTSigningAlg: expected HS256, got %s", cfg.JWTSigningAlg)
+	}
+	if cfg.LockoutThreshold != 5 {
+		t.Errorf("LockoutThreshold: expected 5, got %d", cfg.LockoutThreshold)
+	}
+	if cfg.PasswordHashAlg != "argon2id" {
+		t.Errorf("PasswordHashAlg: expected argon2id, got %s", cfg.PasswordHashAlg)
+	}
+	if cfg.PasswordMinLength != 8 || cfg.PasswordMinScore != 2 || cfg.BreachedPasswordsDir != "" {
+		t.Errorf("password rules: expected 8/2/disabled, got %d/%d/%q", cfg.PasswordMinLength, cfg.PasswordMinScore, cfg.BreachedPasswordsDir)
+	}
+	if oc := cfg.OutboxConfig(); oc.PollInterval != 500*time.Millisecond || oc.BatchSize != 100 || oc.Retention != 24*time.Hour {
+		t.Errorf("outbox: expected 500ms/100/24h, got %+v", oc)
+	}
+	if ac := cfg.AuditWriterConfig(); ac.QueueSize != 10000 || ac.BatchSize != 100 || ac.FlushInterval != 200*time.Millisecond || ac.SpillDir != "" {
+		t.Errorf("audit writer: expected 10000/100/200ms/no spill, got %+v", ac)
+	}
 }
 
 func TestLoad_EnvOverrides(t *testing.T) {
@@ -95,3 +114,23 @@ func TestLoad_InvalidIntFallback(t *testing.T) {
 		t.Errorf("expected fallback 15, got %d", cfg.AccessTokenMinutes)
 	}
 }
+
+func TestLoad_WebAuthnDefaultsFromFrontendURL(t *testing.T) {
+	os.Setenv("FRONTEND_URL", "https://app.watup.lk/")
+	os.Unsetenv("AZURE_KEYVAULT_URL")
+	for _, k := range []string{"WEBAUTHN_RP_ID", "WEBAUTHN_RP_NAME", "WEBAUTHN_ORIGINS", "MFA_ISSUER"} {
+		os.Unsetenv(k)
+	}
+	defer os.Unsetenv("FRONTEND_URL")
+
+	wa := config.Load().WebAuthnConfig()
+	if wa.RPID != "app.watup.lk" || wa.RPName != "WatUp" {
+		t.Errorf("expected RP app.watup.lk/WatUp, got %s/%s", wa.RPID, wa.RPName)
+	}
+	if len(wa.Origins) != 1 || wa.Origins[0] != "https://app.watup.lk" {
+		t.Errorf("expected origins [https://app.watup.lk], got %v", wa.Origins)
+	}
+	if err := wa.Validate(); err != nil {
+		t.Errorf("default passkey settings should be valid, got %v", err)
+	}
+}
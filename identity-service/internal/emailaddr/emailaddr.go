@@ -0,0 +1,79 @@
+// Package emailaddr normalises email addresses before they are used as a login
+// identity, so that differently written forms of one mailbox map to one account.
+//
+// The domain is always trimmed and lower-cased, since DNS names are case-insensitive.
+// What counts as the same local part depends on the mail provider, so those rules
+// are configurable. Uniqueness is enforced by the database on LOWER(email), so
+// addresses differing only in case never become separate accounts, whatever the
+// rules.
+//
+// Stored addresses are not rewritten when the rules change, and lookups normalise
+// their input, so StripSubaddress and DotlessDomains must be chosen before any
+// address they would change is stored. The server checks this at startup.
+package emailaddr
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Rules decides which differences in the local part (before the @) are ignored.
+type Rules struct {
+	LowercaseLocal  bool     // store the local part in lower case
+	StripSubaddress bool     // drop a "+tag" suffix: "amaya+jobs@x.lk" becomes "amaya@x.lk"
+	DotlessDomains  []string // domains whose local part ignores dots, e.g. gmail.com
+}
+
+// DefaultRules lower-cases the whole address and keeps tags and dots, which is safe
+// for every provider.
+var DefaultRules = Rules{LowercaseLocal: true}
+
+// Validate checks that every dotless domain is a plain lower-case domain name.
+func (r Rules) Validate() error {
+	for _, d := range r.DotlessDomains {
+		if d == "" || d != strings.ToLower(d) || strings.ContainsAny(d, "@ ") {
+			return fmt.Errorf("dotless email domain %q must be a lower-case domain name", d)
+		}
+	}
+	return nil
+}
+
+// Normalise returns the canonical form of addr. Input without exactly one @ is
+// returned trimmed but otherwise unchanged; callers validate the format separately.
+func (r Rules) Normalise(addr string) string {
+	addr = strings.TrimSpace(addr)
+	local, domain, ok := strings.Cut(addr, "@")
+	if !ok || strings.Contains(domain, "@") {
+		return addr
+	}
+
+	domain = strings.ToLower(domain)
+	if r.LowercaseLocal {
+		local = strings.ToLower(local)
+	}
+	if r.StripSubaddress {
+		// A local part that starts with "+" has nothing left to keep
+		if i := strings.IndexByte(local, '+'); i > 0 {
+			local = local[:i]
+		}
+	}
+	if slices.Contains(r.DotlessDomains, domain) {
+		if dotless := strings.ReplaceAll(local, ".", ""); dotless != "" {
+			local = dotless
+		}
+	}
+	return local + "@" + domain
+}
+
+// ParseDomains splits a comma-separated domain list, as set in
+// EMAIL_DOTLESS_DOMAINS, lower-casing each entry.
+func ParseDomains(spec string) []string {
+	var out []string
+	for _, d := range strings.Split(spec, ",") {
+		if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+			out = append(out, d)
+		}
+	}
+	return out
+}
@@ -0,0 +1,48 @@
+package emailaddr_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/watup-lk/identity-service/internal/emailaddr"
+)
+
+func TestNormalise(t *testing.T) {
+	gmail := emailaddr.Rules{LowercaseLocal: true, StripSubaddress: true, DotlessDomains: []string{"gmail.com"}}
+	tests := []struct {
+		name  string
+		rules emailaddr.Rules
+		in    string
+		want  string
+	}{
+		{"trims and lower-cases", emailaddr.DefaultRules, "  Amaya.Silva@Example.LK\t", "amaya.silva@example.lk"},
+		{"keeps local case", emailaddr.Rules{}, "Amaya.Silva@Example.LK", "Amaya.Silva@example.lk"},
+		{"keeps tag by default", emailaddr.DefaultRules, "amaya+jobs@example.lk", "amaya+jobs@example.lk"},
+		{"strips tag", gmail, "amaya+jobs@example.lk", "amaya@example.lk"},
+		{"keeps a leading plus", gmail, "+amaya@example.lk", "+amaya@example.lk"},
+		{"drops dots for listed domain", gmail, "A.Ma.Ya+x@GMail.com", "amaya@gmail.com"},
+		{"keeps dots elsewhere", gmail, "a.maya@example.lk", "a.maya@example.lk"},
+		{"not an address", emailaddr.DefaultRules, " Not-An-Email ", "Not-An-Email"},
+		{"two at signs", emailaddr.DefaultRules, "a@b@C.lk", "a@b@C.lk"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rules.Normalise(tt.in); got != tt.want {
+				t.Errorf("Normalise(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDomainsAndValidate(t *testing.T) {
+	got := emailaddr.ParseDomains(" GMail.com, ,googlemail.com,")
+	if want := []string{"gmail.com", "googlemail.com"}; !slices.Equal(got, want) {
+		t.Errorf("ParseDomains() = %v, want %v", got, want)
+	}
+	if err := (emailaddr.Rules{DotlessDomains: got}).Validate(); err != nil {
+		t.Errorf("Validate() error: %v", err)
+	}
+	if err := (emailaddr.Rules{DotlessDomains: []string{"user@gmail.com"}}).Validate(); err == nil {
+		t.Error("Validate() accepted an address as a domain")
+	}
+}
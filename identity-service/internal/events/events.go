@@ -0,0 +1,97 @@
+// Package events builds and reads the envelope every Kafka message on the platform is
+// wrapped in (proto/events/v1/envelope.proto): a CloudEvents 1.0 event in protobuf form.
+//
+// The envelope's schema_version is "MAJOR.MINOR". Minor versions only add fields, which
+// older consumers skip; a new major version may change meaning, so Decode rejects any
+// major version other than SchemaMajor.
+//
+// identity-service and vote-service each carry this file, identical apart from the
+// import path; a test in vote-service fails when the two copies drift apart.
+package events
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	eventsv1 "github.com/watup-lk/identity-service/api/proto/events/v1"
+)
+
+const (
+	SpecVersion     = "1.0"                              // CloudEvents specification version
+	SchemaMajor     = 1                                  // data schema major version written and accepted
+	SchemaVersion   = "1.0"                              // data schema version written
+	ContentType     = "application/cloudevents+protobuf" // Kafka content-type header of an envelope
+	DataContentType = "application/protobuf"
+	TypePrefix      = "lk.watup." // event types are TypePrefix + topic, e.g. lk.watup.user.login
+)
+
+var (
+	ErrInvalidEvent       = errors.New("invalid event envelope")
+	ErrUnsupportedVersion = errors.New("unsupported event version")
+)
+
+// New wraps data in an envelope. subject is the entity the event is about, and at
+// is when it happened.
+func New(id, source, eventType, subject string, at time.Time, data proto.Message) (*eventsv1.Envelope, error) {
+	b, err := proto.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("encoding %s data: %w", eventType, err)
+	}
+	return &eventsv1.Envelope{
+		Id:              id,
+		Source:          source,
+		SpecVersion:     SpecVersion,
+		Type:            eventType,
+		SchemaVersion:   SchemaVersion,
+		Time:            timestamppb.New(at),
+		Subject:         subject,
+		DataContentType: DataContentType,
+		DataSchema:      string(data.ProtoReflect().Descriptor().FullName()),
+		Data:            b,
+	}, nil
+}
+
+// Decode parses an envelope. It returns ErrUnsupportedVersion for another CloudEvents
+// version or data schema major version, and ErrInvalidEvent if a required attribute
+// is missing.
+func Decode(b []byte) (*eventsv1.Envelope, error) {
+	env := &eventsv1.Envelope{}
+	if err := proto.Unmarshal(b, env); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEvent, err)
+	}
+	if env.Id == "" || env.Source == "" || env.Type == "" {
+		return nil, fmt.Errorf("%w: id, source and type are required", ErrInvalidEvent)
+	}
+	if env.SpecVersion != SpecVersion {
+		return nil, fmt.Errorf("%w: specversion %q", ErrUnsupportedVersion, env.SpecVersion)
+	}
+	if major, ok := schemaMajor(env.SchemaVersion); !ok || major != SchemaMajor {
+		return nil, fmt.Errorf("%w: schema version %q of %s", ErrUnsupportedVersion, env.SchemaVersion, env.Type)
+	}
+	return env, nil
+}
+
+// UnmarshalData decodes the envelope's data into msg, which must be the message the
+// envelope names in data_schema.
+func UnmarshalData(env *eventsv1.Envelope, msg proto.Message) error {
+	if want := string(msg.ProtoReflect().Descriptor().FullName()); env.DataSchema != want {
+		return fmt.Errorf("%w: data is %q, not %q", ErrInvalidEvent, env.DataSchema, want)
+	}
+	if err := proto.Unmarshal(env.Data, msg); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidEvent, err)
+	}
+	return nil
+}
+
+// schemaMajor returns the major part of "MAJOR" or "MAJOR.MINOR".
+func schemaMajor(version string) (int, bool) {
+	major, _, _ := strings.Cut(version, ".")
+	n, err := strconv.Atoi(major)
+	return n, err == nil && n >= 0
+}
@@ -0,0 +1,95 @@
+package events_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	eventsv1 "github.com/watup-lk/identity-service/api/proto/events/v1"
+	"github.com/watup-lk/identity-service/internal/events"
+)
+
+func newLogin(t *testing.T) *eventsv1.Envelope {
+	t.Helper()
+	env, err := events.New("outbox-42", "/watup/identity-service", "lk.watup.user.login", "user-1",
+		time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC), &eventsv1.UserEvent{UserId: "user-1", EventType: "user.login"})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	return env
+}
+
+func marshal(t *testing.T, env *eventsv1.Envelope) []byte {
+	t.Helper()
+	b, err := proto.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestNew_RoundTrip(t *testing.T) {
+	env, err := events.Decode(marshal(t, newLogin(t)))
+	if err != nil {
+		t.Fatalf("Decode() error: %v", err)
+	}
+	if env.Id != "outbox-42" || env.SpecVersion != "1.0" || env.SchemaVersion != events.SchemaVersion || env.Subject != "user-1" {
+		t.Errorf("unexpected envelope %v", env)
+	}
+	if env.DataSchema != "watup.events.v1.UserEvent" || env.DataContentType != "application/protobuf" {
+		t.Errorf("unexpected data attributes %q, %q", env.DataSchema, env.DataContentType)
+	}
+	if !env.Time.AsTime().Equal(time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the event time to be kept, got %v", env.Time.AsTime())
+	}
+
+	var data eventsv1.UserEvent
+	if err := events.UnmarshalData(env, &data); err != nil {
+		t.Fatalf("UnmarshalData() error: %v", err)
+	}
+	if data.UserId != "user-1" || data.EventType != "user.login" {
+		t.Errorf("unexpected data %v", &data)
+	}
+}
+
+func TestDecode_Versions(t *testing.T) {
+	cases := []struct {
+		spec, schema string
+		want         error
+	}{
+		{"1.0", "1.0", nil},
+		{"1.0", "1.7", nil}, // a newer minor only adds fields
+		{"1.0", "1", nil},
+		{"1.0", "2.0", events.ErrUnsupportedVersion},
+		{"1.0", "", events.ErrUnsupportedVersion},
+		{"1.0", "v1", events.ErrUnsupportedVersion},
+		{"0.3", "1.0", events.ErrUnsupportedVersion},
+	}
+	for _, c := range cases {
+		env := newLogin(t)
+		env.SpecVersion, env.SchemaVersion = c.spec, c.schema
+		if _, err := events.Decode(marshal(t, env)); !errors.Is(err, c.want) {
+			t.Errorf("spec %q, schema %q: Decode() = %v, want %v", c.spec, c.schema, err, c.want)
+		}
+	}
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	env := newLogin(t)
+	env.Id = ""
+	if _, err := events.Decode(marshal(t, env)); !errors.Is(err, events.ErrInvalidEvent) {
+		t.Errorf("expected ErrInvalidEvent without an id, got %v", err)
+	}
+	if _, err := events.Decode([]byte("Submission 1 reached upvote threshold")); !errors.Is(err, events.ErrInvalidEvent) {
+		t.Errorf("expected ErrInvalidEvent for a plain string, got %v", err)
+	}
+}
+
+func TestUnmarshalData_WrongSchema(t *testing.T) {
+	var data eventsv1.SubmissionThresholdReached
+	if err := events.UnmarshalData(newLogin(t), &data); !errors.Is(err, events.ErrInvalidEvent) {
+		t.Errorf("expected ErrInvalidEvent for another data schema, got %v", err)
+	}
+}
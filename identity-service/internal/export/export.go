@@ -0,0 +1,100 @@
+// Package export assembles a user's personal data export (a data subject access
+// request bundle).
+//
+// A Bundle holds one JSON section per source. identity-service fills in its own
+// sections directly; every other service that stores personal data contributes a
+// section through a Contributor, usually a GRPCContributor calling that service's
+// DataExportContributor endpoint. A contributor that fails or times out does not
+// fail the export: its section is listed as unavailable instead.
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Contributor supplies one section of a user's data export.
+type Contributor interface {
+	Section() string
+	Export(ctx context.Context, userID string) (json.RawMessage, error)
+}
+
+// sectionName keeps section names usable as JSON keys and file names alike.
+var sectionName = regexp.MustCompile(`^[a-z][a-z0-9_]{0,39}$`)
+
+// ValidSection reports whether name can be used as a section name.
+func ValidSection(name string) bool {
+	return sectionName.MatchString(name)
+}
+
+// Bundle is the document the user downloads.
+type Bundle struct {
+	UserID      string                     `json:"user_id"`
+	GeneratedAt string                     `json:"generated_at"`
+	Sections    map[string]json.RawMessage `json:"sections"`
+	// Unavailable lists the contributed sections that could not be fetched; a new
+	// export retries them.
+	Unavailable []string `json:"unavailable,omitempty"`
+}
+
+// NewBundle returns an empty bundle for userID generated at the given time.
+func NewBundle(userID string, generatedAt time.Time) *Bundle {
+	return &Bundle{
+		UserID:      userID,
+		GeneratedAt: generatedAt.UTC().Format("2006-01-02T15:04:05Z"),
+		Sections:    make(map[string]json.RawMessage),
+	}
+}
+
+// Add stores v, encoded as JSON, as the named section.
+func (b *Bundle) Add(section string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encoding %s section: %w", section, err)
+	}
+	b.Sections[section] = data
+	return nil
+}
+
+// Collect asks every contributor for its section concurrently, giving each at most
+// timeout. Sections that fail, time out or are not valid JSON are recorded in
+// Unavailable and the error is logged.
+func (b *Bundle) Collect(ctx context.Context, timeout time.Duration, contributors []Contributor) {
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, c := range contributors {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			data, err := c.Export(cctx, b.UserID)
+			if err == nil && len(data) > 0 && !json.Valid(data) {
+				err = fmt.Errorf("response is not valid JSON")
+			}
+			if len(data) == 0 {
+				data = json.RawMessage("null")
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				log.Printf("[export] section %s unavailable for user %s: %v", c.Section(), b.UserID, err)
+				b.Unavailable = append(b.Unavailable, c.Section())
+				return
+			}
+			b.Sections[c.Section()] = data
+		}()
+	}
+	wg.Wait()
+	sort.Strings(b.Unavailable)
+}
@@ -0,0 +1,133 @@
+package export_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	pb "github.com/watup-lk/identity-service/api/proto/v1"
+	"github.com/watup-lk/identity-service/internal/export"
+)
+
+type fakeContributor struct {
+	section string
+	data    string
+	err     error
+	delay   time.Duration
+}
+
+func (f fakeContributor) Section() string { return f.section }
+
+func (f fakeContributor) Export(ctx context.Context, _ string) (json.RawMessage, error) {
+	select {
+	case <-time.After(f.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return json.RawMessage(f.data), f.err
+}
+
+func TestParseEndpoints(t *testing.T) {
+	got, err := export.ParseEndpoints(" salaries=salary-service:50051, votes = vote-service:50053 ,")
+	if err != nil {
+		t.Fatalf("ParseEndpoints() error: %v", err)
+	}
+	want := []export.Endpoint{{Section: "salaries", Addr: "salary-service:50051"}, {Section: "votes", Addr: "vote-service:50053"}}
+	if !slices.Equal(got, want) {
+		t.Errorf("ParseEndpoints() = %v, want %v", got, want)
+	}
+
+	if got, err := export.ParseEndpoints(""); err != nil || got != nil {
+		t.Errorf("ParseEndpoints(\"\") = %v, %v; want nil, nil", got, err)
+	}
+	for _, bad := range []string{"salary-service:50051", "salaries=", "Salaries=s:1", "a=s:1,a=t:2"} {
+		if _, err := export.ParseEndpoints(bad); err == nil {
+			t.Errorf("ParseEndpoints(%q) = nil error, want error", bad)
+		}
+	}
+}
+
+func TestBundle_Collect(t *testing.T) {
+	b := export.NewBundle("user-1", time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC))
+	if err := b.Add("profile", map[string]string{"name": "Amaya"}); err != nil {
+		t.Fatal(err)
+	}
+	b.Collect(context.Background(), 50*time.Millisecond, []export.Contributor{
+		fakeContributor{section: "salaries", data: `[{"amount":150000}]`},
+		fakeContributor{section: "votes", data: ""},
+		fakeContributor{section: "reviews", err: errors.New("connection refused")},
+		fakeContributor{section: "comments", data: `{"broken"`},
+		fakeContributor{section: "badges", data: `[]`, delay: time.Second},
+	})
+
+	if string(b.Sections["salaries"]) != `[{"amount":150000}]` {
+		t.Errorf("salaries = %s", b.Sections["salaries"])
+	}
+	if string(b.Sections["votes"]) != "null" {
+		t.Errorf("an empty response should export as null, got %s", b.Sections["votes"])
+	}
+	if want := []string{"badges", "comments", "reviews"}; !slices.Equal(b.Unavailable, want) {
+		t.Errorf("Unavailable = %v, want %v", b.Unavailable, want)
+	}
+
+	data, err := json.Marshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		UserID      string                     `json:"user_id"`
+		GeneratedAt string                     `json:"generated_at"`
+		Sections    map[string]json.RawMessage `json:"sections"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.UserID != "user-1" || decoded.GeneratedAt != "2026-03-01T10:00:00Z" || len(decoded.Sections) != 3 {
+		t.Errorf("unexpected bundle: %s", data)
+	}
+}
+
+type contributorServer struct {
+	pb.UnimplementedDataExportContributorServer
+}
+
+func (contributorServer) ExportUserData(_ context.Context, req *pb.ExportUserDataRequest) (*pb.ExportUserDataResponse, error) {
+	return &pb.ExportUserDataResponse{Json: []byte(`{"user_id":"` + req.UserId + `","submissions":2}`)}, nil
+}
+
+func TestGRPCContributor(t *testing.T) {
+	lis := bufconn.Listen(1 << 16)
+	srv := grpc.NewServer()
+	pb.RegisterDataExportContributorServer(srv, contributorServer{})
+	go srv.Serve(lis) //nolint:errcheck
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	c := export.NewGRPCContributor("salaries", pb.NewDataExportContributorClient(conn))
+	data, err := c.Export(context.Background(), "user-7")
+	if err != nil {
+		t.Fatalf("Export() error: %v", err)
+	}
+	if string(data) != `{"user_id":"user-7","submissions":2}` {
+		t.Errorf("Export() = %s", data)
+	}
+	if c.Section() != "salaries" {
+		t.Errorf("Section() = %q", c.Section())
+	}
+}
@@ -0,0 +1,88 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	pb "github.com/watup-lk/identity-service/api/proto/v1"
+)
+
+// Endpoint is a contributing service: the section it fills and its gRPC address.
+type Endpoint struct {
+	Section string
+	Addr    string
+}
+
+// ParseEndpoints parses a comma-separated list of "section=host:port" pairs, as
+// set in DATA_EXPORT_CONTRIBUTORS. An empty spec yields no endpoints.
+func ParseEndpoints(spec string) ([]Endpoint, error) {
+	var out []Endpoint
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		section, addr, ok := strings.Cut(item, "=")
+		section, addr = strings.TrimSpace(section), strings.TrimSpace(addr)
+		if !ok || addr == "" {
+			return nil, fmt.Errorf("contributor %q: want section=host:port", item)
+		}
+		if !ValidSection(section) {
+			return nil, fmt.Errorf("contributor %q: section must be lowercase letters, digits and underscores", item)
+		}
+		if seen[section] {
+			return nil, fmt.Errorf("contributor section %q is listed twice", section)
+		}
+		seen[section] = true
+		out = append(out, Endpoint{Section: section, Addr: addr})
+	}
+	return out, nil
+}
+
+// GRPCContributor fetches a section from another service's DataExportContributor
+// endpoint.
+type GRPCContributor struct {
+	section string
+	client  pb.DataExportContributorClient
+	conn    *grpc.ClientConn // nil when constructed around an existing client
+}
+
+// NewGRPCContributor wraps an existing client.
+func NewGRPCContributor(section string, client pb.DataExportContributorClient) *GRPCContributor {
+	return &GRPCContributor{section: section, client: client}
+}
+
+// Dial connects lazily to the endpoint over plaintext gRPC; like the rest of the
+// service-to-service traffic it stays inside the cluster network.
+func Dial(e Endpoint) (*GRPCContributor, error) {
+	conn, err := grpc.NewClient(e.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("contributor %s: %w", e.Section, err)
+	}
+	return &GRPCContributor{section: e.Section, client: pb.NewDataExportContributorClient(conn), conn: conn}, nil
+}
+
+func (c *GRPCContributor) Section() string { return c.section }
+
+// Export calls ExportUserData for userID and returns the JSON it sent back.
+func (c *GRPCContributor) Export(ctx context.Context, userID string) (json.RawMessage, error) {
+	resp, err := c.client.ExportUserData(ctx, &pb.ExportUserDataRequest{UserId: userID})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Json, nil
+}
+
+// Close releases the connection opened by Dial.
+func (c *GRPCContributor) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
@@ -2,13 +2,16 @@ package grpcserver
 
 import (
 	"context"
+	"errors"
 	"log"
+	"time"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
 	pb "github.com/watup-lk/identity-service/api/proto/v1"
 	"github.com/watup-lk/identity-service/internal/repository"
+	"github.com/watup-lk/identity-service/internal/roles"
 	"github.com/watup-lk/identity-service/internal/service"
 )
 
@@ -24,13 +27,13 @@ func NewIdentityServer(svc *service.IdentityService) *IdentityServer {
 	return &IdentityServer{svc: svc}
 }
 
-// ValidateToken checks an access token JWT and returns the embedded user_id.
+// ValidateToken checks an access token JWT and returns the embedded user_id and roles.
 func (s *IdentityServer) ValidateToken(ctx context.Context, req *pb.ValidateTokenRequest) (*pb.ValidateTokenResponse, error) {
 	if req.Token == "" {
 		return &pb.ValidateTokenResponse{Valid: false, Error: "token is required"}, nil
 	}
 
-	userID, err := s.svc.ValidateAccessToken(ctx, req.Token)
+	userID, granted, err := s.svc.ValidateAccessTokenRoles(ctx, req.Token)
 	if err != nil {
 		log.Printf("[grpc] ValidateToken: invalid token: %v", err)
 		return &pb.ValidateTokenResponse{
@@ -42,11 +45,12 @@ func (s *IdentityServer) ValidateToken(ctx context.Context, req *pb.ValidateToke
 	return &pb.ValidateTokenResponse{
 		Valid:  true,
 		UserId: userID,
+		Roles:  granted,
 	}, nil
 }
 
 // GetUser returns basic user metadata given a user_id.
-// Email is never exposed — only user_id, is_active, and created_at.
+// Email is never exposed — only user_id, is_active, created_at and verification status.
 func (s *IdentityServer) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.GetUserResponse, error) {
 	if req.UserId == "" {
 		return nil, status.Error(codes.InvalidArgument, "user_id is required")
@@ -60,9 +64,225 @@ func (s *IdentityServer) GetUser(ctx context.Context, req *pb.GetUserRequest) (*
 		return nil, status.Error(codes.Internal, "failed to fetch user")
 	}
 
-	return &pb.GetUserResponse{
-		UserId:    user.ID,
-		IsActive:  user.IsActive,
-		CreatedAt: user.CreatedAt.UTC().Format("2006-01-02T15:04:05Z"),
-	}, nil
+	resp := &pb.GetUserResponse{
+		UserId:        user.ID,
+		IsActive:      user.IsActive,
+		CreatedAt:     user.CreatedAt.UTC().Format("2006-01-02T15:04:05Z"),
+		EmailVerified: user.EmailVerifiedAt != nil,
+	}
+	if user.EmailVerifiedAt != nil {
+		resp.EmailVerifiedAt = user.EmailVerifiedAt.UTC().Format("2006-01-02T15:04:05Z")
+	}
+	return resp, nil
+}
+
+// UnlockAccount clears a login lockout (see LOGIN_LOCKOUT_* settings) ahead of its expiry.
+// Only admins may call it.
+func (s *IdentityServer) UnlockAccount(ctx context.Context, req *pb.UnlockAccountRequest) (*pb.UnlockAccountResponse, error) {
+	if err := s.requireAdmin(ctx, req.AccessToken); err != nil {
+		return nil, err
+	}
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	if err := s.svc.UnlockAccount(ctx, req.UserId, ""); err != nil {
+		if err == repository.ErrNotFound {
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		log.Printf("[grpc] UnlockAccount: %v", err)
+		return nil, status.Error(codes.Internal, "failed to unlock account")
+	}
+
+	log.Printf("[grpc] UnlockAccount: user %s unlocked", req.UserId)
+	return &pb.UnlockAccountResponse{}, nil
+}
+
+// IntrospectToken reports whether an access token is active, with its claims and
+// revocation state. Tokens that do not verify come back with only active=false.
+func (s *IdentityServer) IntrospectToken(ctx context.Context, req *pb.IntrospectTokenRequest) (*pb.IntrospectTokenResponse, error) {
+	if req.Token == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+
+	info, err := s.svc.IntrospectToken(ctx, req.Token)
+	if err != nil {
+		log.Printf("[grpc] IntrospectToken: %v", err)
+		return nil, status.Error(codes.Internal, "failed to introspect token")
+	}
+	if info.UserID == "" {
+		return &pb.IntrospectTokenResponse{Active: false}, nil
+	}
+
+	resp := &pb.IntrospectTokenResponse{
+		Active:    info.Active,
+		Revoked:   info.Revoked,
+		UserId:    info.UserID,
+		Roles:     info.Roles,
+		SessionId: info.SessionID,
+		Jti:       info.TokenID,
+		Issuer:    info.Issuer,
+	}
+	if !info.IssuedAt.IsZero() {
+		resp.IssuedAt = info.IssuedAt.UTC().Format("2006-01-02T15:04:05Z")
+	}
+	if !info.ExpiresAt.IsZero() {
+		resp.ExpiresAt = info.ExpiresAt.UTC().Format("2006-01-02T15:04:05Z")
+	}
+	return resp, nil
+}
+
+// RevokeAccessToken denylists a single access token until it expires. Admins may
+// revoke any token; anyone else only the one they call with.
+func (s *IdentityServer) RevokeAccessToken(ctx context.Context, req *pb.RevokeAccessTokenRequest) (*pb.RevokeAccessTokenResponse, error) {
+	if req.Token == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+	if req.AccessToken != req.Token {
+		if err := s.requireAdmin(ctx, req.AccessToken); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.svc.RevokeAccessToken(ctx, req.Token, ""); err != nil {
+		if errors.Is(err, service.ErrInvalidToken) {
+			return nil, status.Error(codes.InvalidArgument, "invalid or expired token")
+		}
+		log.Printf("[grpc] RevokeAccessToken: %v", err)
+		return nil, status.Error(codes.Internal, "failed to revoke token")
+	}
+
+	return &pb.RevokeAccessTokenResponse{}, nil
+}
+
+// RevokeUserSessions signs a user out of every session, including access tokens
+// already handed out. Only admins may call it.
+func (s *IdentityServer) RevokeUserSessions(ctx context.Context, req *pb.RevokeUserSessionsRequest) (*pb.RevokeUserSessionsResponse, error) {
+	if err := s.requireAdmin(ctx, req.AccessToken); err != nil {
+		return nil, err
+	}
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	if err := s.svc.RevokeUserSessions(ctx, req.UserId, ""); err != nil {
+		if err == repository.ErrNotFound {
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		log.Printf("[grpc] RevokeUserSessions: %v", err)
+		return nil, status.Error(codes.Internal, "failed to revoke sessions")
+	}
+
+	log.Printf("[grpc] RevokeUserSessions: user %s signed out everywhere", req.UserId)
+	return &pb.RevokeUserSessionsResponse{}, nil
+}
+
+// QueryAuditLogs returns a page of audit logs to an admin caller.
+func (s *IdentityServer) QueryAuditLogs(ctx context.Context, req *pb.QueryAuditLogsRequest) (*pb.QueryAuditLogsResponse, error) {
+	if err := s.requireAdmin(ctx, req.AccessToken); err != nil {
+		return nil, err
+	}
+	q, err := auditQuery(req.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := s.svc.QueryAuditLogs(ctx, q, req.Cursor, int(req.Limit))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAuditQuery) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		log.Printf("[grpc] QueryAuditLogs: %v", err)
+		return nil, status.Error(codes.Internal, "failed to query audit logs")
+	}
+
+	resp := &pb.QueryAuditLogsResponse{NextCursor: page.NextCursor}
+	for _, l := range page.Logs {
+		resp.Logs = append(resp.Logs, &pb.AuditLogEntry{
+			Id:        l.ID,
+			UserId:    l.UserID,
+			EventType: l.EventType,
+			Success:   l.Success,
+			IpAddress: l.IPAddress,
+			CreatedAt: l.CreatedAt.UTC().Format("2006-01-02T15:04:05Z"),
+		})
+	}
+	return resp, nil
+}
+
+// AuditLogStats returns audit log counts per time bucket and group to an admin caller.
+func (s *IdentityServer) AuditLogStats(ctx context.Context, req *pb.AuditLogStatsRequest) (*pb.AuditLogStatsResponse, error) {
+	if err := s.requireAdmin(ctx, req.AccessToken); err != nil {
+		return nil, err
+	}
+	q, err := auditQuery(req.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := s.svc.AuditLogStats(ctx, q, req.Bucket, req.GroupBy, int(req.Limit))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAuditQuery) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		log.Printf("[grpc] AuditLogStats: %v", err)
+		return nil, status.Error(codes.Internal, "failed to count audit logs")
+	}
+
+	resp := &pb.AuditLogStatsResponse{}
+	for _, c := range counts {
+		resp.Counts = append(resp.Counts, &pb.AuditLogCount{
+			BucketStart: c.BucketStart.UTC().Format("2006-01-02T15:04:05Z"),
+			Key:         c.Key,
+			Count:       c.Count,
+		})
+	}
+	return resp, nil
+}
+
+// requireAdmin checks that the access token is valid and grants the admin role.
+func (s *IdentityServer) requireAdmin(ctx context.Context, token string) error {
+	if token == "" {
+		return status.Error(codes.Unauthenticated, "access_token is required")
+	}
+	_, granted, err := s.svc.ValidateAccessTokenRoles(ctx, token)
+	if err != nil {
+		return status.Error(codes.Unauthenticated, "invalid or expired token")
+	}
+	if !roles.Has(granted, roles.Admin) {
+		return status.Error(codes.PermissionDenied, "admin role required")
+	}
+	return nil
+}
+
+// auditQuery converts the request filter; a nil filter matches everything.
+func auditQuery(f *pb.AuditLogFilter) (service.AuditQuery, error) {
+	q := service.AuditQuery{
+		UserID:    f.GetUserId(),
+		EventType: f.GetEventType(),
+		IP:        f.GetIp(),
+	}
+	if f != nil {
+		q.Success = f.Success
+	}
+	var err error
+	if q.Since, err = parseTimestamp("since", f.GetSince()); err != nil {
+		return q, err
+	}
+	if q.Until, err = parseTimestamp("until", f.GetUntil()); err != nil {
+		return q, err
+	}
+	return q, nil
+}
+
+// parseTimestamp parses an optional RFC 3339 request field.
+func parseTimestamp(name, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, status.Error(codes.InvalidArgument, name+" must be an RFC 3339 timestamp")
+	}
+	return t, nil
 }
@@ -5,8 +5,15 @@ import (
 	"testing"
 	"time"
 
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/watup-lk/identity-service/internal/auditlog"
 	"github.com/watup-lk/identity-service/internal/config"
 	"github.com/watup-lk/identity-service/internal/grpcserver"
+	"github.com/watup-lk/identity-service/internal/keys"
+	"github.com/watup-lk/identity-service/internal/mailer"
+	"github.com/watup-lk/identity-service/internal/outbox"
 	"github.com/watup-lk/identity-service/internal/repository"
 	"github.com/watup-lk/identity-service/internal/service"
 
@@ -16,20 +23,28 @@ import (
 // ── Mock Repository ──────────────────────────────────────────────────────────
 
 type mockRepo struct {
-	users  map[string]*repository.User
-	byID   map[string]*repository.User
-	tokens map[string]*repository.RefreshToken
+	users       map[string]*repository.User
+	byID        map[string]*repository.User
+	tokens      map[string]*repository.RefreshToken
+	resetTokens map[string]*repository.PasswordResetToken
+	roles       map[string][]string   // user id -> granted roles
+	denied      map[string]time.Time  // access token or session id -> denied until
+	auditLogs   []repository.AuditLog // returned by QueryAuditLogs, newest first
+	auditCounts []repository.AuditCount
 }
 
 func newMockRepo() *mockRepo {
 	return &mockRepo{
-		users:  make(map[string]*repository.User),
-		byID:   make(map[string]*repository.User),
-		tokens: make(map[string]*repository.RefreshToken),
+		users:       make(map[string]*repository.User),
+		byID:        make(map[string]*repository.User),
+		tokens:      make(map[string]*repository.RefreshToken),
+		resetTokens: make(map[string]*repository.PasswordResetToken),
+		roles:       make(map[string][]string),
+		denied:      make(map[string]time.Time),
 	}
 }
 
-func (m *mockRepo) CreateUser(_ context.Context, id, name, email, passwordHash string, age *int) error {
+func (m *mockRepo) CreateUser(_ context.Context, id, name, email, passwordHash string, age *int, _ ...outbox.Event) error {
 	u := &repository.User{ID: id, Name: name, Email: email, PasswordHash: passwordHash, Age: age, IsActive: true, CreatedAt: time.Now()}
 	m.users[email] = u
 	m.byID[id] = u
@@ -53,8 +68,39 @@ func (m *mockRepo) FindUserByID(_ context.Context, id string) (*repository.User,
 	}
 	return u, nil
 }
-func (m *mockRepo) StoreRefreshToken(_ context.Context, id, userID, tokenHash string, expiresAt time.Time) error {
-	m.tokens[tokenHash] = &repository.RefreshToken{ID: id, UserID: userID, TokenHash: tokenHash, ExpiresAt: expiresAt}
+func (m *mockRepo) FindUserRoles(_ context.Context, userID string) ([]string, error) {
+	return m.roles[userID], nil
+}
+func (m *mockRepo) UpdateUserProfile(_ context.Context, _, _ string, _ *int, _ ...outbox.Event) error {
+	return nil
+}
+func (m *mockRepo) MarkUserDeleted(_ context.Context, _ string) error { return nil }
+func (m *mockRepo) AnonymiseDeletedUsers(_ context.Context, _ time.Time, _ int, _ func(string) []outbox.Event) ([]string, error) {
+	return nil, nil
+}
+func (m *mockRepo) RecordFailedLogin(_ context.Context, userID string, _ time.Duration) (int, error) {
+	u, ok := m.byID[userID]
+	if !ok {
+		return 0, repository.ErrNotFound
+	}
+	u.FailedLoginCount++
+	return u.FailedLoginCount, nil
+}
+func (m *mockRepo) LockUser(_ context.Context, userID string, until time.Time, _ ...outbox.Event) error {
+	if u, ok := m.byID[userID]; ok {
+		u.LockedUntil = &until
+	}
+	return nil
+}
+func (m *mockRepo) ResetFailedLogins(_ context.Context, userID string) error {
+	if u, ok := m.byID[userID]; ok {
+		u.FailedLoginCount = 0
+		u.LockedUntil = nil
+	}
+	return nil
+}
+func (m *mockRepo) StoreRefreshToken(_ context.Context, id, userID, familyID, parentID, tokenHash, _, _ string, expiresAt time.Time, _ ...outbox.Event) error {
+	m.tokens[tokenHash] = &repository.RefreshToken{ID: id, UserID: userID, FamilyID: familyID, ParentID: parentID, TokenHash: tokenHash, ExpiresAt: expiresAt}
 	return nil
 }
 func (m *mockRepo) FindRefreshToken(_ context.Context, tokenHash string) (*repository.RefreshToken, error) {
@@ -64,42 +110,232 @@ func (m *mockRepo) FindRefreshToken(_ context.Context, tokenHash string) (*repos
 	}
 	return rt, nil
 }
-func (m *mockRepo) RevokeRefreshToken(_ context.Context, tokenHash string) error {
+func (m *mockRepo) RevokeRefreshToken(_ context.Context, tokenHash string, _ ...outbox.Event) error {
 	if rt, ok := m.tokens[tokenHash]; ok {
 		rt.Revoked = true
 	}
 	return nil
 }
-func (m *mockRepo) RevokeAllUserTokens(_ context.Context, _ string) error { return nil }
-func (m *mockRepo) InsertAuditLog(_ context.Context, _, _ string, _ bool, _ string) error {
+func (m *mockRepo) RotateRefreshToken(_ context.Context, tokenHash, replacedBy string) (bool, error) {
+	rt, ok := m.tokens[tokenHash]
+	if !ok || rt.Revoked {
+		return false, nil
+	}
+	rt.Revoked = true
+	rt.ReplacedBy = replacedBy
+	return true, nil
+}
+func (m *mockRepo) RevokeTokenFamily(_ context.Context, familyID string, _ ...outbox.Event) error {
+	for _, rt := range m.tokens {
+		if rt.FamilyID == familyID {
+			rt.Revoked = true
+		}
+	}
+	return nil
+}
+func (m *mockRepo) ListActiveSessions(_ context.Context, userID string) ([]repository.Session, error) {
+	var sessions []repository.Session
+	for _, rt := range m.tokens {
+		if rt.UserID == userID && !rt.Revoked {
+			sessions = append(sessions, repository.Session{ID: rt.FamilyID, ExpiresAt: rt.ExpiresAt})
+		}
+	}
+	return sessions, nil
+}
+func (m *mockRepo) RevokeAllUserTokens(_ context.Context, userID string, _ ...outbox.Event) error {
+	for _, rt := range m.tokens {
+		if rt.UserID == userID {
+			rt.Revoked = true
+		}
+	}
+	return nil
+}
+func (m *mockRepo) RevokeAllUserTokensExcept(_ context.Context, userID, keepTokenHash string) error {
+	for _, rt := range m.tokens {
+		if rt.UserID == userID && rt.TokenHash != keepTokenHash {
+			rt.Revoked = true
+		}
+	}
+	return nil
+}
+func (m *mockRepo) UpdatePasswordHash(_ context.Context, userID, passwordHash string, _ ...outbox.Event) error {
+	u, ok := m.byID[userID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	u.PasswordHash = passwordHash
+	return nil
+}
+func (m *mockRepo) StorePasswordResetToken(_ context.Context, id, userID, tokenHash string, expiresAt time.Time, _ ...outbox.Event) error {
+	m.resetTokens[tokenHash] = &repository.PasswordResetToken{ID: id, UserID: userID, TokenHash: tokenHash, ExpiresAt: expiresAt}
+	return nil
+}
+func (m *mockRepo) FindPasswordResetToken(_ context.Context, tokenHash string) (*repository.PasswordResetToken, error) {
+	t, ok := m.resetTokens[tokenHash]
+	if !ok || t.UsedAt != nil {
+		return nil, repository.ErrNotFound
+	}
+	return t, nil
+}
+func (m *mockRepo) ResetPassword(_ context.Context, tokenHash, passwordHash string, _ ...outbox.Event) (*repository.PasswordResetToken, error) {
+	t, ok := m.resetTokens[tokenHash]
+	if !ok || t.UsedAt != nil {
+		return nil, repository.ErrNotFound
+	}
+	u, ok := m.byID[t.UserID]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	u.PasswordHash = passwordHash
+	return t, nil
+}
+func (m *mockRepo) StoreEmailVerificationToken(_ context.Context, _, _, _ string, _ time.Time) error {
+	return nil
+}
+func (m *mockRepo) ConsumeEmailVerificationToken(_ context.Context, _ string) (*repository.EmailVerificationToken, error) {
+	return nil, repository.ErrNotFound
+}
+func (m *mockRepo) MarkEmailVerified(_ context.Context, userID string, _ ...outbox.Event) error {
+	u, ok := m.byID[userID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	now := time.Now()
+	u.EmailVerifiedAt = &now
+	return nil
+}
+func (m *mockRepo) UpsertTOTPEnrollment(_ context.Context, _, _ string) (bool, error) {
+	return true, nil
+}
+func (m *mockRepo) FindTOTP(_ context.Context, _ string) (*repository.TOTPCredential, error) {
+	return nil, repository.ErrNotFound
+}
+func (m *mockRepo) ConfirmTOTP(_ context.Context, _ string, _ int64, _ []string, _ ...outbox.Event) error {
 	return nil
 }
-func (m *mockRepo) Ping(_ context.Context) error { return nil }
+func (m *mockRepo) UseTOTPStep(_ context.Context, _ string, _ int64) (bool, error)     { return true, nil }
+func (m *mockRepo) DeleteTOTP(_ context.Context, _ string, _ ...outbox.Event) error    { return nil }
+func (m *mockRepo) ReplaceRecoveryCodes(_ context.Context, _ string, _ []string) error { return nil }
+func (m *mockRepo) ConsumeRecoveryCode(_ context.Context, _, _ string) (bool, error) {
+	return false, nil
+}
+func (m *mockRepo) InsertAuditLogs(_ context.Context, _ []auditlog.Event) error {
+	return nil
+}
+func (m *mockRepo) ListAuditLogs(_ context.Context, _ string) ([]repository.AuditLog, error) {
+	return nil, nil
+}
+func (m *mockRepo) QueryAuditLogs(_ context.Context, _ repository.AuditFilter, _ *repository.AuditCursor, limit int) ([]repository.AuditLog, error) {
+	return m.auditLogs[:min(limit, len(m.auditLogs))], nil
+}
+func (m *mockRepo) CountAuditLogs(_ context.Context, _ repository.AuditFilter, _, _ string, _ int) ([]repository.AuditCount, error) {
+	return m.auditCounts, nil
+}
+func (m *mockRepo) CreateDataExport(_ context.Context, _, _ string, _ time.Time) error { return nil }
+func (m *mockRepo) FindLatestDataExport(_ context.Context, _ string) (*repository.DataExport, error) {
+	return nil, repository.ErrNotFound
+}
+func (m *mockRepo) CompleteDataExport(_ context.Context, _ string, _ []byte, _ time.Time) error {
+	return nil
+}
+func (m *mockRepo) FailDataExport(_ context.Context, _ string) error                     { return nil }
+func (m *mockRepo) DeleteExpiredDataExports(_ context.Context) (int64, error)            { return 0, nil }
+func (m *mockRepo) CreateEmailChange(_ context.Context, _ *repository.EmailChange) error { return nil }
+func (m *mockRepo) ConfirmEmailChange(_ context.Context, _ string, _ func(*repository.EmailChange) []outbox.Event) (*repository.EmailChange, error) {
+	return nil, repository.ErrNotFound
+}
+func (m *mockRepo) CancelEmailChange(_ context.Context, _ string, _ func(*repository.EmailChange) []outbox.Event) (*repository.EmailChange, error) {
+	return nil, repository.ErrNotFound
+}
+func (m *mockRepo) StoreOAuthState(_ context.Context, _ *repository.OAuthState) error { return nil }
+func (m *mockRepo) ConsumeOAuthState(_ context.Context, _ string) (*repository.OAuthState, error) {
+	return nil, repository.ErrNotFound
+}
+func (m *mockRepo) FindUserIdentity(_ context.Context, _, _ string) (*repository.UserIdentity, error) {
+	return nil, repository.ErrNotFound
+}
+func (m *mockRepo) ListUserIdentities(_ context.Context, _ string) ([]repository.UserIdentity, error) {
+	return nil, nil
+}
+func (m *mockRepo) LinkUserIdentity(_ context.Context, _ *repository.UserIdentity, _ ...outbox.Event) error {
+	return nil
+}
+func (m *mockRepo) CreateOAuthUser(_ context.Context, _, _, _ string, _ *repository.UserIdentity, _ ...outbox.Event) error {
+	return nil
+}
+func (m *mockRepo) DeleteUserIdentity(_ context.Context, _, _ string, _ ...outbox.Event) error {
+	return repository.ErrNotFound
+}
+func (m *mockRepo) StoreWebAuthnChallenge(_ context.Context, _ *repository.WebAuthnChallenge) error {
+	return nil
+}
+func (m *mockRepo) ConsumeWebAuthnChallenge(_ context.Context, _ string) (*repository.WebAuthnChallenge, error) {
+	return nil, repository.ErrNotFound
+}
+func (m *mockRepo) CreateWebAuthnCredential(_ context.Context, _ *repository.WebAuthnCredential, _ ...outbox.Event) error {
+	return nil
+}
+func (m *mockRepo) ListWebAuthnCredentials(_ context.Context, _ string) ([]repository.WebAuthnCredential, error) {
+	return nil, nil
+}
+func (m *mockRepo) FindWebAuthnCredential(_ context.Context, _ []byte) (*repository.WebAuthnCredential, error) {
+	return nil, repository.ErrNotFound
+}
+func (m *mockRepo) UseWebAuthnCredential(_ context.Context, _ string, _, _ uint32) (bool, error) {
+	return false, nil
+}
+func (m *mockRepo) DeleteWebAuthnCredential(_ context.Context, _, _ string, _ ...outbox.Event) error {
+	return repository.ErrNotFound
+}
+func (m *mockRepo) DenyAccessTokens(_ context.Context, _ string, ids []string, expiresAt time.Time) error {
+	for _, id := range ids {
+		m.denied[id] = expiresAt
+	}
+	return nil
+}
+func (m *mockRepo) AccessTokenDenied(_ context.Context, ids []string) (bool, error) {
+	for _, id := range ids {
+		if time.Now().Before(m.denied[id]) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+func (m *mockRepo) RecordEvents(_ context.Context, _ ...outbox.Event) error { return nil }
+func (m *mockRepo) Ping(_ context.Context) error                            { return nil }
 
-// ── Mock Publisher ────────────────────────────────────────────────────────────
+// ── Mock Mailer ───────────────────────────────────────────────────────────────
 
-type mockPublisher struct{}
+type mockMailer struct{}
 
-func (m *mockPublisher) PublishUserRegistered(_ context.Context, _ string) {}
-func (m *mockPublisher) PublishUserLogin(_ context.Context, _ string)      {}
-func (m *mockPublisher) PublishUserLogout(_ context.Context, _ string)     {}
-func (m *mockPublisher) PublishTokenRefresh(_ context.Context, _ string)   {}
-func (m *mockPublisher) Close()                                            {}
+func (m *mockMailer) Send(_ context.Context, _ mailer.Message) error { return nil }
 
 // ── Helpers ──────────────────────────────────────────────────────────────────
 
+func testKeyring() *keys.Keyring {
+	return keys.NewKeyring(keys.NewHMACKey([]byte(testConfig().JWTSecret)))
+}
+
 func testConfig() *config.Config {
 	return &config.Config{
 		JWTSecret:          "test-secret-key-at-least-32-chars!!",
 		AccessTokenMinutes: 15,
 		RefreshTokenDays:   7,
+		LockoutThreshold:   3,
+		LockoutBaseSeconds: 30,
+		LockoutMaxMinutes:  60,
 	}
 }
 
 func newTestServer() (*grpcserver.IdentityServer, *service.IdentityService) {
+	srv, svc, _ := newTestServerWithRepo()
+	return srv, svc
+}
+
+func newTestServerWithRepo() (*grpcserver.IdentityServer, *service.IdentityService, *mockRepo) {
 	repo := newMockRepo()
-	svc := service.NewIdentityService(repo, &mockPublisher{}, testConfig())
-	return grpcserver.NewIdentityServer(svc), svc
+	svc := service.NewIdentityService(repo, &mockMailer{}, testKeyring(), testConfig())
+	return grpcserver.NewIdentityServer(svc), svc, repo
 }
 
 // ── ValidateToken Tests ──────────────────────────────────────────────────────
@@ -140,7 +376,7 @@ func TestValidateToken_ValidToken(t *testing.T) {
 	}
 	time.Sleep(10 * time.Millisecond)
 
-	pair, err := svc.Login(ctx, "grpc@test.com", "SecurePass1", "127.0.0.1")
+	pair, err := svc.Login(ctx, "grpc@test.com", "SecurePass1", "127.0.0.1", "")
 	if err != nil {
 		t.Fatalf("Login error: %v", err)
 	}
@@ -155,6 +391,29 @@ func TestValidateToken_ValidToken(t *testing.T) {
 	if resp.UserId == "" {
 		t.Error("expected non-empty UserId")
 	}
+	if len(resp.Roles) != 1 || resp.Roles[0] != "user" {
+		t.Errorf("expected roles [user], got %v", resp.Roles)
+	}
+}
+
+func TestValidateToken_ReturnsGrantedRoles(t *testing.T) {
+	srv, svc, repo := newTestServerWithRepo()
+	ctx := context.Background()
+
+	result, _ := svc.Signup(ctx, "Mod", "mod@test.com", "SecurePass1", "127.0.0.1", nil)
+	repo.roles[result.UserID] = []string{"moderator"}
+	pair, err := svc.Login(ctx, "mod@test.com", "SecurePass1", "127.0.0.1", "")
+	if err != nil {
+		t.Fatalf("Login error: %v", err)
+	}
+
+	resp, err := srv.ValidateToken(ctx, &pb.ValidateTokenRequest{Token: pair.AccessToken})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Roles) != 2 || resp.Roles[0] != "user" || resp.Roles[1] != "moderator" {
+		t.Errorf("expected roles [user moderator], got %v", resp.Roles)
+	}
 }
 
 // ── GetUser Tests ────────────────────────────────────────────────────────────
@@ -198,4 +457,298 @@ func TestGetUser_Success(t *testing.T) {
 	if resp.CreatedAt == "" {
 		t.Error("expected non-empty created_at")
 	}
+	if resp.EmailVerified || resp.EmailVerifiedAt != "" {
+		t.Error("expected a new signup to be unverified")
+	}
+}
+
+// ── UnlockAccount Tests ──────────────────────────────────────────────────────
+
+// loginWithRoles signs a user up with the given roles and returns their access token.
+func loginWithRoles(t *testing.T, svc *service.IdentityService, repo *mockRepo, email string, granted ...string) string {
+	t.Helper()
+	ctx := context.Background()
+	result, _ := svc.Signup(ctx, "Auditor", email, "SecurePass1", "127.0.0.1", nil)
+	repo.roles[result.UserID] = granted
+	pair, err := svc.Login(ctx, email, "SecurePass1", "127.0.0.1", "")
+	if err != nil {
+		t.Fatalf("Login error: %v", err)
+	}
+	return pair.AccessToken
+}
+
+func TestUnlockAccount_RequiresAdmin(t *testing.T) {
+	srv, svc, repo := newTestServerWithRepo()
+	userToken := loginWithRoles(t, svc, repo, "user@test.com")
+
+	_, err := srv.UnlockAccount(context.Background(), &pb.UnlockAccountRequest{UserId: "nonexistent-id"})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected Unauthenticated without a token, got %v", err)
+	}
+	_, err = srv.UnlockAccount(context.Background(), &pb.UnlockAccountRequest{UserId: "nonexistent-id", AccessToken: "not-a-token"})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected Unauthenticated for an invalid token, got %v", err)
+	}
+	_, err = srv.UnlockAccount(context.Background(), &pb.UnlockAccountRequest{UserId: "nonexistent-id", AccessToken: userToken})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected PermissionDenied for a non-admin, got %v", err)
+	}
+}
+
+func TestUnlockAccount_EmptyID(t *testing.T) {
+	srv, svc, repo := newTestServerWithRepo()
+	token := loginWithRoles(t, svc, repo, "admin@test.com", "admin")
+	_, err := srv.UnlockAccount(context.Background(), &pb.UnlockAccountRequest{UserId: "", AccessToken: token})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestUnlockAccount_NotFound(t *testing.T) {
+	srv, svc, repo := newTestServerWithRepo()
+	token := loginWithRoles(t, svc, repo, "admin@test.com", "admin")
+	_, err := srv.UnlockAccount(context.Background(), &pb.UnlockAccountRequest{UserId: "nonexistent-id", AccessToken: token})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound, got %v", err)
+	}
+}
+
+func TestUnlockAccount_Success(t *testing.T) {
+	srv, svc, repo := newTestServerWithRepo()
+	ctx := context.Background()
+	token := loginWithRoles(t, svc, repo, "admin@test.com", "admin")
+
+	result, _ := svc.Signup(ctx, "Locked User", "locked@test.com", "SecurePass1", "127.0.0.1", nil)
+	for i := 0; i < 3; i++ {
+		_, _ = svc.Login(ctx, "locked@test.com", "WrongPass1", "127.0.0.1", "")
+	}
+	if _, err := svc.Login(ctx, "locked@test.com", "SecurePass1", "127.0.0.1", ""); err == nil {
+		t.Fatal("expected account to be locked")
+	}
+
+	if _, err := srv.UnlockAccount(ctx, &pb.UnlockAccountRequest{UserId: result.UserID, AccessToken: token}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := svc.Login(ctx, "locked@test.com", "SecurePass1", "127.0.0.1", ""); err != nil {
+		t.Errorf("expected login to succeed after unlock, got %v", err)
+	}
+}
+
+// ── Token Revocation Tests ───────────────────────────────────────────────────
+
+func TestIntrospectToken_EmptyToken(t *testing.T) {
+	srv, _ := newTestServer()
+	_, err := srv.IntrospectToken(context.Background(), &pb.IntrospectTokenRequest{Token: ""})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestIntrospectToken_InvalidToken(t *testing.T) {
+	srv, _ := newTestServer()
+	resp, err := srv.IntrospectToken(context.Background(), &pb.IntrospectTokenRequest{Token: "invalid.jwt.token"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Active || resp.UserId != "" {
+		t.Errorf("expected an empty inactive response, got %v", resp)
+	}
+}
+
+func TestRevokeAccessToken(t *testing.T) {
+	srv, svc := newTestServer()
+	ctx := context.Background()
+
+	result, _ := svc.Signup(ctx, "Revoke", "revoke@test.com", "SecurePass1", "127.0.0.1", nil)
+	pair, _ := svc.Login(ctx, "revoke@test.com", "SecurePass1", "127.0.0.1", "")
+
+	resp, err := srv.IntrospectToken(ctx, &pb.IntrospectTokenRequest{Token: pair.AccessToken})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Active || resp.Revoked || resp.UserId != result.UserID || resp.Jti == "" || resp.SessionId == "" || resp.ExpiresAt == "" {
+		t.Errorf("unexpected introspection of a fresh token: %v", resp)
+	}
+
+	if _, err := srv.RevokeAccessToken(ctx, &pb.RevokeAccessTokenRequest{Token: pair.AccessToken, AccessToken: pair.AccessToken}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	valid, _ := srv.ValidateToken(ctx, &pb.ValidateTokenRequest{Token: pair.AccessToken})
+	if valid.Valid {
+		t.Error("expected Valid=false for a revoked token")
+	}
+	resp, _ = srv.IntrospectToken(ctx, &pb.IntrospectTokenRequest{Token: pair.AccessToken})
+	if resp.Active || !resp.Revoked || resp.UserId != result.UserID {
+		t.Errorf("expected a revoked token to be inactive with its claims, got %v", resp)
+	}
+}
+
+func TestRevokeAccessToken_OtherCallers(t *testing.T) {
+	srv, svc, repo := newTestServerWithRepo()
+	ctx := context.Background()
+	target := loginWithRoles(t, svc, repo, "target@test.com")
+	other := loginWithRoles(t, svc, repo, "other@test.com")
+	admin := loginWithRoles(t, svc, repo, "admin@test.com", "admin")
+
+	_, err := srv.RevokeAccessToken(ctx, &pb.RevokeAccessTokenRequest{Token: target})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected Unauthenticated without a token, got %v", err)
+	}
+	_, err = srv.RevokeAccessToken(ctx, &pb.RevokeAccessTokenRequest{Token: target, AccessToken: other})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected PermissionDenied for another user's token, got %v", err)
+	}
+	if _, err := srv.RevokeAccessToken(ctx, &pb.RevokeAccessTokenRequest{Token: target, AccessToken: admin}); err != nil {
+		t.Fatalf("expected an admin to revoke the token, got %v", err)
+	}
+	if valid, _ := srv.ValidateToken(ctx, &pb.ValidateTokenRequest{Token: target}); valid.Valid {
+		t.Error("expected Valid=false for a revoked token")
+	}
+}
+
+func TestRevokeAccessToken_InvalidToken(t *testing.T) {
+	srv, _ := newTestServer()
+	for _, token := range []string{"", "invalid.jwt.token"} {
+		_, err := srv.RevokeAccessToken(context.Background(), &pb.RevokeAccessTokenRequest{Token: token, AccessToken: token})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("token %q: expected InvalidArgument, got %v", token, err)
+		}
+	}
+}
+
+func TestRevokeUserSessions_RequiresAdmin(t *testing.T) {
+	srv, svc, repo := newTestServerWithRepo()
+	userToken := loginWithRoles(t, svc, repo, "user@test.com")
+
+	_, err := srv.RevokeUserSessions(context.Background(), &pb.RevokeUserSessionsRequest{UserId: "nonexistent-id"})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected Unauthenticated without a token, got %v", err)
+	}
+	_, err = srv.RevokeUserSessions(context.Background(), &pb.RevokeUserSessionsRequest{UserId: "nonexistent-id", AccessToken: userToken})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected PermissionDenied for a non-admin, got %v", err)
+	}
+}
+
+func TestRevokeUserSessions_EmptyID(t *testing.T) {
+	srv, svc, repo := newTestServerWithRepo()
+	token := loginWithRoles(t, svc, repo, "admin@test.com", "admin")
+	_, err := srv.RevokeUserSessions(context.Background(), &pb.RevokeUserSessionsRequest{UserId: "", AccessToken: token})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestRevokeUserSessions_NotFound(t *testing.T) {
+	srv, svc, repo := newTestServerWithRepo()
+	token := loginWithRoles(t, svc, repo, "admin@test.com", "admin")
+	_, err := srv.RevokeUserSessions(context.Background(), &pb.RevokeUserSessionsRequest{UserId: "nonexistent-id", AccessToken: token})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound, got %v", err)
+	}
+}
+
+func TestRevokeUserSessions_Success(t *testing.T) {
+	srv, svc, repo := newTestServerWithRepo()
+	ctx := context.Background()
+	token := loginWithRoles(t, svc, repo, "admin@test.com", "admin")
+
+	result, _ := svc.Signup(ctx, "Everywhere", "everywhere@test.com", "SecurePass1", "127.0.0.1", nil)
+	first, _ := svc.Login(ctx, "everywhere@test.com", "SecurePass1", "127.0.0.1", "")
+	second, _ := svc.Login(ctx, "everywhere@test.com", "SecurePass1", "127.0.0.1", "")
+
+	if _, err := srv.RevokeUserSessions(ctx, &pb.RevokeUserSessionsRequest{UserId: result.UserID, AccessToken: token}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, pair := range []*service.TokenPair{first, second} {
+		resp, _ := srv.ValidateToken(ctx, &pb.ValidateTokenRequest{Token: pair.AccessToken})
+		if resp.Valid {
+			t.Error("expected Valid=false after RevokeUserSessions")
+		}
+		if _, err := svc.Refresh(ctx, pair.RefreshToken, "127.0.0.1", ""); err == nil {
+			t.Error("expected refresh to fail after RevokeUserSessions")
+		}
+	}
+}
+
+// ── Audit Log Query Tests ────────────────────────────────────────────────────
+
+func TestQueryAuditLogs_RequiresAdmin(t *testing.T) {
+	srv, svc, repo := newTestServerWithRepo()
+	modToken := loginWithRoles(t, svc, repo, "mod@test.com", "moderator")
+
+	_, err := srv.QueryAuditLogs(context.Background(), &pb.QueryAuditLogsRequest{})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected Unauthenticated without a token, got %v", err)
+	}
+	_, err = srv.QueryAuditLogs(context.Background(), &pb.QueryAuditLogsRequest{AccessToken: modToken})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected PermissionDenied for a moderator, got %v", err)
+	}
+	_, err = srv.AuditLogStats(context.Background(), &pb.AuditLogStatsRequest{AccessToken: modToken})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected PermissionDenied for a moderator, got %v", err)
+	}
+}
+
+func TestQueryAuditLogs_Success(t *testing.T) {
+	srv, svc, repo := newTestServerWithRepo()
+	token := loginWithRoles(t, svc, repo, "admin@test.com", "admin")
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	repo.auditLogs = []repository.AuditLog{
+		{ID: "00000000-0000-0000-0000-000000000002", EventType: "login_failed", IPAddress: "203.0.113.7", CreatedAt: at},
+		{ID: "00000000-0000-0000-0000-000000000001", UserID: "u1", EventType: "login", Success: true, CreatedAt: at},
+	}
+
+	resp, err := srv.QueryAuditLogs(context.Background(), &pb.QueryAuditLogsRequest{AccessToken: token, Limit: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Logs) != 1 || resp.Logs[0].EventType != "login_failed" || resp.Logs[0].IpAddress != "203.0.113.7" {
+		t.Errorf("expected the newest log only, got %v", resp.Logs)
+	}
+	if resp.Logs[0].CreatedAt != "2026-03-01T12:00:00Z" {
+		t.Errorf("unexpected created_at %q", resp.Logs[0].CreatedAt)
+	}
+	if resp.NextCursor == "" {
+		t.Error("expected a next_cursor with more logs to come")
+	}
+}
+
+func TestQueryAuditLogs_InvalidFilter(t *testing.T) {
+	srv, svc, repo := newTestServerWithRepo()
+	token := loginWithRoles(t, svc, repo, "admin@test.com", "admin")
+
+	for _, f := range []*pb.AuditLogFilter{{Ip: "not-an-ip"}, {UserId: "nope"}, {Since: "yesterday"}} {
+		_, err := srv.QueryAuditLogs(context.Background(), &pb.QueryAuditLogsRequest{AccessToken: token, Filter: f})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("filter %v: expected InvalidArgument, got %v", f, err)
+		}
+	}
+}
+
+func TestAuditLogStats_Success(t *testing.T) {
+	srv, svc, repo := newTestServerWithRepo()
+	token := loginWithRoles(t, svc, repo, "admin@test.com", "admin")
+	repo.auditCounts = []repository.AuditCount{
+		{BucketStart: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), Key: "203.0.113.7", Count: 42},
+	}
+
+	failed := false
+	resp, err := srv.AuditLogStats(context.Background(), &pb.AuditLogStatsRequest{
+		AccessToken: token,
+		Filter:      &pb.AuditLogFilter{EventType: "login_failed", Success: &failed},
+		GroupBy:     "ip",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Counts) != 1 || resp.Counts[0].Key != "203.0.113.7" || resp.Counts[0].Count != 42 || resp.Counts[0].BucketStart != "2026-03-01T12:00:00Z" {
+		t.Errorf("unexpected counts %v", resp.Counts)
+	}
+
+	_, err = srv.AuditLogStats(context.Background(), &pb.AuditLogStatsRequest{AccessToken: token, Bucket: "minute"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for an unknown bucket, got %v", err)
+	}
 }
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/watup-lk/identity-service/internal/service"
+)
+
+type deleteAccountRequest struct {
+	reauthRequest
+}
+
+type deleteAccountResponse struct {
+	PurgeAt string `json:"purge_at"` // when the personal data is permanently erased
+}
+
+// DeleteAccount godoc
+// DELETE /auth/account
+// Header: Authorization: Bearer <access_token>
+// Body: {"password": "..."} or {"reauth_token": "..."}
+// Deactivates the account and signs out every session; PII is erased after the grace period.
+func (h *AuthHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	var req deleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.missing() {
+		writeError(w, http.StatusBadRequest, reauthRequired)
+		return
+	}
+
+	purgeAt, err := h.svc.DeleteAccount(r.Context(), userID, req.proof(), clientIP(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidCredentials):
+			writeError(w, http.StatusForbidden, reauthRejected)
+		case errors.Is(err, service.ErrAccountLocked):
+			writeError(w, http.StatusTooManyRequests, "too many failed attempts, try again later")
+		case errors.Is(err, service.ErrInvalidToken), errors.Is(err, service.ErrAccountDisabled):
+			writeError(w, http.StatusUnauthorized, "invalid or expired token")
+		default:
+			writeError(w, http.StatusInternalServerError, "account deletion failed")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, deleteAccountResponse{PurgeAt: purgeAt.UTC().Format("2006-01-02T15:04:05Z")})
+}
+
+type dataExportResponse struct {
+	ID          string `json:"id"`
+	Status      string `json:"status"` // "pending", "ready" or "failed"
+	RequestedAt string `json:"requested_at"`
+	ExpiresAt   string `json:"expires_at,omitempty"`
+}
+
+// RequestDataExport godoc
+// POST /auth/account/export
+// Header: Authorization: Bearer <access_token>
+// Starts building a copy of the caller's personal data; it is emailed about once
+// ready and downloaded from GET /auth/account/export.
+func (h *AuthHandler) RequestDataExport(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	e, err := h.svc.RequestDataExport(r.Context(), userID, clientIP(r))
+	if err != nil {
+		writeExportError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/auth/account/export")
+	writeJSON(w, http.StatusAccepted, toDataExportResponse(e))
+}
+
+// DataExportStatus godoc
+// GET /auth/account/export/status
+// Header: Authorization: Bearer <access_token>
+// Returns the status of the caller's latest export without the bundle, for polling.
+func (h *AuthHandler) DataExportStatus(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	e, err := h.svc.LatestDataExport(r.Context(), userID)
+	if err != nil {
+		writeExportError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toDataExportResponse(e))
+}
+
+// DownloadDataExport godoc
+// GET /auth/account/export
+// Header: Authorization: Bearer <access_token>
+// Returns the JSON bundle of the caller's latest export once it is ready, or its
+// status with 202 while it is still being built. Each download is audited.
+func (h *AuthHandler) DownloadDataExport(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	e, err := h.svc.DownloadDataExport(r.Context(), userID, clientIP(r))
+	if err != nil {
+		writeExportError(w, err)
+		return
+	}
+
+	switch e.Status {
+	case service.ExportPending:
+		w.Header().Set("Retry-After", "30")
+		writeJSON(w, http.StatusAccepted, toDataExportResponse(e))
+	case service.ExportReady:
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition",
+			`attachment; filename="watup-data-export-`+e.RequestedAt.UTC().Format("2006-01-02")+`.json"`)
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		w.Write(e.Bundle) //nolint:errcheck
+	default:
+		writeError(w, http.StatusInternalServerError, "data export failed; request a new one")
+	}
+}
+
+func writeExportError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrExportNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrInvalidToken), errors.Is(err, service.ErrAccountDisabled):
+		writeError(w, http.StatusUnauthorized, "invalid or expired token")
+	default:
+		writeError(w, http.StatusInternalServerError, "data export failed")
+	}
+}
+
+func toDataExportResponse(e *service.DataExport) dataExportResponse {
+	resp := dataExportResponse{
+		ID:          e.ID,
+		Status:      e.Status,
+		RequestedAt: e.RequestedAt.UTC().Format("2006-01-02T15:04:05Z"),
+	}
+	if e.ExpiresAt != nil {
+		resp.ExpiresAt = e.ExpiresAt.UTC().Format("2006-01-02T15:04:05Z")
+	}
+	return resp
+}
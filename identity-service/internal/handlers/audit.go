@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/watup-lk/identity-service/internal/service"
+)
+
+// --- Request / Response types ---
+
+type auditLogResponse struct {
+	ID        string `json:"id"`
+	UserID    string `json:"user_id,omitempty"`
+	EventType string `json:"event_type"`
+	Success   bool   `json:"success"`
+	IPAddress string `json:"ip_address,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+type auditLogsResponse struct {
+	Logs       []auditLogResponse `json:"logs"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
+type auditCountResponse struct {
+	BucketStart string `json:"bucket_start"`
+	Key         string `json:"key,omitempty"`
+	Count       int64  `json:"count"`
+}
+
+type auditStatsResponse struct {
+	Bucket  string               `json:"bucket"`
+	GroupBy string               `json:"group_by,omitempty"`
+	Counts  []auditCountResponse `json:"counts"`
+}
+
+// --- Handlers ---
+// The admin routes are wrapped in middleware.RequireRole(svc, roles.Admin), so the
+// handlers do not authenticate the caller themselves.
+
+// QueryAuditLogs godoc
+// GET /admin/audit-logs?user_id=&event_type=&success=&ip=&since=&until=&cursor=&limit=
+// Header: Authorization: Bearer <admin access_token>
+// Lists audit logs newest first. ip takes an address or a CIDR block; since and until
+// are RFC 3339. Pass next_cursor back as cursor for the next page.
+func (h *AuthHandler) QueryAuditLogs(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	q, err := parseAuditQuery(params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit, err := optionalInt(params, "limit")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := h.svc.QueryAuditLogs(r.Context(), q, params.Get("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAuditQuery) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "querying audit logs failed")
+		return
+	}
+
+	resp := auditLogsResponse{Logs: make([]auditLogResponse, 0, len(page.Logs)), NextCursor: page.NextCursor}
+	for _, l := range page.Logs {
+		resp.Logs = append(resp.Logs, auditLogResponse{
+			ID:        l.ID,
+			UserID:    l.UserID,
+			EventType: l.EventType,
+			Success:   l.Success,
+			IPAddress: l.IPAddress,
+			CreatedAt: l.CreatedAt.UTC().Format("2006-01-02T15:04:05Z"),
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// AuditLogStats godoc
+// GET /admin/audit-logs/stats?bucket=hour|day&group_by=ip|event_type|user_id&limit=&<filters>
+// Header: Authorization: Bearer <admin access_token>
+// Counts audit logs per time bucket and group, e.g. failed logins per IP per hour:
+// ?event_type=login&success=false&group_by=ip. Takes the filters of /admin/audit-logs;
+// without since it covers the last 24 hours (hour buckets) or 30 days (day buckets).
+func (h *AuthHandler) AuditLogStats(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	q, err := parseAuditQuery(params)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit, err := optionalInt(params, "limit")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	bucket := params.Get("bucket")
+	if bucket == "" {
+		bucket = "hour"
+	}
+	groupBy := params.Get("group_by")
+
+	counts, err := h.svc.AuditLogStats(r.Context(), q, bucket, groupBy, limit)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAuditQuery) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "counting audit logs failed")
+		return
+	}
+
+	resp := auditStatsResponse{Bucket: bucket, GroupBy: groupBy, Counts: make([]auditCountResponse, 0, len(counts))}
+	for _, c := range counts {
+		resp.Counts = append(resp.Counts, auditCountResponse{
+			BucketStart: c.BucketStart.UTC().Format("2006-01-02T15:04:05Z"),
+			Key:         c.Key,
+			Count:       c.Count,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// parseAuditQuery reads the audit log filters shared by both admin routes.
+func parseAuditQuery(params url.Values) (service.AuditQuery, error) {
+	q := service.AuditQuery{
+		UserID:    params.Get("user_id"),
+		EventType: params.Get("event_type"),
+		IP:        params.Get("ip"),
+	}
+	if v := params.Get("success"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return q, errors.New("success must be true or false")
+		}
+		q.Success = &b
+	}
+	for name, dst := range map[string]*time.Time{"since": &q.Since, "until": &q.Until} {
+		if v := params.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return q, errors.New(name + " must be an RFC 3339 timestamp")
+			}
+			*dst = t
+		}
+	}
+	return q, nil
+}
+
+func optionalInt(params url.Values, name string) (int, error) {
+	v := params.Get(name)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, errors.New(name + " must be a non-negative integer")
+	}
+	return n, nil
+}
@@ -7,7 +7,7 @@ import (
 	"net/http"
 	"regexp"
 	"strings"
-	"unicode"
+	"unicode/utf8"
 
 	"github.com/watup-lk/identity-service/internal/service"
 )
@@ -15,8 +15,10 @@ import (
 // emailRegex validates basic RFC 5322 email format.
 var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`)
 
-// validateEmail returns an error message if the email is invalid.
+// validateEmail returns an error message if the email is invalid. Surrounding
+// whitespace is ignored; the service trims it when normalising the address.
 func validateEmail(email string) string {
+	email = strings.TrimSpace(email)
 	if email == "" {
 		return "email is required"
 	}
@@ -26,23 +28,25 @@ func validateEmail(email string) string {
 	return ""
 }
 
-// validatePassword enforces a minimum password policy:
-// at least 8 characters, at least one letter and one digit.
-func validatePassword(password string) string {
-	if len(password) < 8 {
-		return "password must be at least 8 characters"
+// maxNameLen matches the users.name column (VARCHAR(100)).
+const maxNameLen = 100
+
+// validateName returns an error message if the display name is unusable.
+// Callers trim the name first.
+func validateName(name string) string {
+	if name == "" {
+		return "name is required"
 	}
-	var hasLetter, hasDigit bool
-	for _, c := range password {
-		if unicode.IsLetter(c) {
-			hasLetter = true
-		}
-		if unicode.IsDigit(c) {
-			hasDigit = true
-		}
+	if utf8.RuneCountInString(name) > maxNameLen {
+		return "name must be at most 100 characters"
 	}
-	if !hasLetter || !hasDigit {
-		return "password must contain at least one letter and one digit"
+	return ""
+}
+
+// validateAge returns an error message if an age is given but out of range.
+func validateAge(age *int) string {
+	if age != nil && (*age < 13 || *age > 120) {
+		return "age must be between 13 and 120"
 	}
 	return ""
 }
@@ -88,6 +92,23 @@ type logoutRequest struct {
 	RefreshToken string `json:"refresh_token"`
 }
 
+type forgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type resetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+	// RefreshToken optionally identifies the caller's session, which is kept alive.
+	// When omitted every session is revoked, including the caller's.
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
 type validateResponse struct {
 	UserID string `json:"user_id"`
 }
@@ -96,6 +117,18 @@ type errorResponse struct {
 	Error string `json:"error"`
 }
 
+// passwordPolicyResponse lists every password rule that failed, so the client can
+// show them all at once. Codes are stable; messages are English fallbacks.
+type passwordPolicyResponse struct {
+	Error   string           `json:"error"`
+	Reasons []passwordReason `json:"reasons"`
+}
+
+type passwordReason struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
 // --- Handlers ---
 
 // Signup godoc
@@ -109,22 +142,18 @@ func (h *AuthHandler) Signup(w http.ResponseWriter, r *http.Request) {
 	}
 
 	req.Name = strings.TrimSpace(req.Name)
-	if req.Name == "" {
-		writeError(w, http.StatusBadRequest, "name is required")
+	if msg := validateName(req.Name); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
 		return
 	}
 	if msg := validateEmail(req.Email); msg != "" {
 		writeError(w, http.StatusBadRequest, msg)
 		return
 	}
-	if msg := validatePassword(req.Password); msg != "" {
+	if msg := validateAge(req.Age); msg != "" {
 		writeError(w, http.StatusBadRequest, msg)
 		return
 	}
-	if req.Age != nil && (*req.Age < 13 || *req.Age > 120) {
-		writeError(w, http.StatusBadRequest, "age must be between 13 and 120")
-		return
-	}
 
 	result, err := h.svc.Signup(r.Context(), req.Name, req.Email, req.Password, clientIP(r), req.Age)
 	if err != nil {
@@ -132,6 +161,10 @@ func (h *AuthHandler) Signup(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusConflict, err.Error())
 			return
 		}
+		if errors.Is(err, service.ErrWeakPassword) {
+			writePasswordPolicyError(w, err)
+			return
+		}
 		writeError(w, http.StatusInternalServerError, "signup failed")
 		return
 	}
@@ -142,6 +175,8 @@ func (h *AuthHandler) Signup(w http.ResponseWriter, r *http.Request) {
 // Login godoc
 // POST /auth/login
 // Body: {"email": "...", "password": "..."}
+// Accounts with two-factor authentication get {"mfa_required": true, "mfa_token": "..."}
+// instead of tokens; the client completes the login via POST /auth/mfa/verify.
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var req loginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -149,9 +184,21 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pair, err := h.svc.Login(r.Context(), req.Email, req.Password, clientIP(r))
+	pair, err := h.svc.Login(r.Context(), req.Email, req.Password, clientIP(r), r.UserAgent())
 	if err != nil {
-		if errors.Is(err, service.ErrInvalidCredentials) || errors.Is(err, service.ErrAccountDisabled) {
+		var challenge *service.MFAChallenge
+		if errors.As(err, &challenge) {
+			writeJSON(w, http.StatusOK, mfaChallengeResponse{
+				MFARequired: true,
+				MFAToken:    challenge.Token,
+				ExpiresAt:   challenge.ExpiresAt.UTC().Format("2006-01-02T15:04:05Z"),
+				Methods:     challenge.Methods,
+			})
+			return
+		}
+		// Locked and disabled accounts get the same response as a wrong password
+		if errors.Is(err, service.ErrInvalidCredentials) || errors.Is(err, service.ErrAccountDisabled) ||
+			errors.Is(err, service.ErrAccountLocked) {
 			writeError(w, http.StatusUnauthorized, "invalid credentials")
 			return
 		}
@@ -180,7 +227,7 @@ func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pair, err := h.svc.Refresh(r.Context(), req.RefreshToken, clientIP(r))
+	pair, err := h.svc.Refresh(r.Context(), req.RefreshToken, clientIP(r), r.UserAgent())
 	if err != nil {
 		if errors.Is(err, service.ErrInvalidToken) {
 			writeError(w, http.StatusUnauthorized, "invalid or expired refresh token")
@@ -219,6 +266,103 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// ForgotPassword godoc
+// POST /auth/password/forgot
+// Body: {"email": "..."}
+// Always returns 202 so callers cannot probe which emails are registered.
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req forgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if msg := validateEmail(req.Email); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	if err := h.svc.RequestPasswordReset(r.Context(), req.Email, clientIP(r)); err != nil {
+		writeError(w, http.StatusInternalServerError, "password reset request failed")
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]string{
+		"status": "if the email is registered, a reset link has been sent",
+	})
+}
+
+// ResetPassword godoc
+// POST /auth/password/reset
+// Body: {"token": "...", "new_password": "..."}
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req resetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Token == "" {
+		writeError(w, http.StatusBadRequest, "token is required")
+		return
+	}
+
+	if err := h.svc.ResetPassword(r.Context(), req.Token, req.NewPassword, clientIP(r)); err != nil {
+		if errors.Is(err, service.ErrInvalidResetToken) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrWeakPassword) {
+			writePasswordPolicyError(w, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "password reset failed")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ChangePassword godoc
+// POST /auth/password/change
+// Header: Authorization: Bearer <access_token>
+// Body: {"current_password": "...", "new_password": "...", "refresh_token": "..." (optional)}
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	var req changePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.CurrentPassword == "" {
+		writeError(w, http.StatusBadRequest, "current_password is required")
+		return
+	}
+
+	err := h.svc.ChangePassword(r.Context(), userID, req.CurrentPassword, req.NewPassword, req.RefreshToken, clientIP(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidCredentials):
+			writeError(w, http.StatusForbidden, "current password is incorrect")
+		case errors.Is(err, service.ErrAccountLocked):
+			writeError(w, http.StatusTooManyRequests, "too many failed attempts, try again later")
+		case errors.Is(err, service.ErrSamePassword):
+			writeError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, service.ErrWeakPassword):
+			writePasswordPolicyError(w, err)
+		case errors.Is(err, service.ErrInvalidToken), errors.Is(err, service.ErrAccountDisabled):
+			writeError(w, http.StatusUnauthorized, "invalid or expired token")
+		default:
+			writeError(w, http.StatusInternalServerError, "password change failed")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // ValidateToken godoc
 // GET /auth/validate
 // Header: Authorization: Bearer <access_token>
@@ -258,6 +402,38 @@ func clientIP(r *http.Request) string {
 	return ip
 }
 
+// authenticate validates the Bearer access token and returns its user_id.
+// On failure it writes a 401 response and returns ok=false.
+func (h *AuthHandler) authenticate(w http.ResponseWriter, r *http.Request) (string, bool) {
+	tokenString := extractBearerToken(r)
+	if tokenString == "" {
+		writeError(w, http.StatusUnauthorized, "missing or malformed Authorization header")
+		return "", false
+	}
+	userID, err := h.svc.ValidateAccessToken(r.Context(), tokenString)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "invalid or expired token")
+		return "", false
+	}
+	return userID, true
+}
+
+// authenticateSession is authenticate for handlers that also need the caller's
+// session id (empty for tokens issued before sessions were tracked).
+func (h *AuthHandler) authenticateSession(w http.ResponseWriter, r *http.Request) (userID, sessionID string, ok bool) {
+	tokenString := extractBearerToken(r)
+	if tokenString == "" {
+		writeError(w, http.StatusUnauthorized, "missing or malformed Authorization header")
+		return "", "", false
+	}
+	userID, sessionID, err := h.svc.ValidateSessionToken(r.Context(), tokenString)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "invalid or expired token")
+		return "", "", false
+	}
+	return userID, sessionID, true
+}
+
 func extractBearerToken(r *http.Request) string {
 	auth := r.Header.Get("Authorization")
 	if !strings.HasPrefix(auth, "Bearer ") {
@@ -275,3 +451,15 @@ func writeJSON(w http.ResponseWriter, status int, v any) {
 func writeError(w http.ResponseWriter, status int, msg string) {
 	writeJSON(w, status, errorResponse{Error: msg})
 }
+
+// writePasswordPolicyError writes a 400 listing the password rules that failed.
+func writePasswordPolicyError(w http.ResponseWriter, err error) {
+	resp := passwordPolicyResponse{Error: err.Error(), Reasons: []passwordReason{}}
+	var policyErr *service.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		for _, r := range policyErr.Reasons {
+			resp.Reasons = append(resp.Reasons, passwordReason{Code: r.Code, Message: r.Message})
+		}
+	}
+	writeJSON(w, http.StatusBadRequest, resp)
+}
@@ -3,37 +3,72 @@ package handlers_test
 import (
 	"bytes"
 	"context"
+	"crypto/ed25519"
+	"crypto/rand"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/watup-lk/identity-service/internal/auditlog"
 	"github.com/watup-lk/identity-service/internal/config"
 	"github.com/watup-lk/identity-service/internal/handlers"
+	"github.com/watup-lk/identity-service/internal/keys"
+	"github.com/watup-lk/identity-service/internal/mailer"
+	"github.com/watup-lk/identity-service/internal/oauth"
+	"github.com/watup-lk/identity-service/internal/outbox"
 	"github.com/watup-lk/identity-service/internal/repository"
 	"github.com/watup-lk/identity-service/internal/service"
+	"github.com/watup-lk/identity-service/internal/totp"
+	"github.com/watup-lk/identity-service/internal/webauthn"
+	"github.com/watup-lk/identity-service/internal/webauthn/webauthntest"
 )
 
 // ── Mock Repository ──────────────────────────────────────────────────────────
 
 type mockRepo struct {
-	users  map[string]*repository.User
-	byID   map[string]*repository.User
-	tokens map[string]*repository.RefreshToken
+	users         map[string]*repository.User
+	byID          map[string]*repository.User
+	tokens        map[string]*repository.RefreshToken
+	verifyTokens  map[string]*repository.EmailVerificationToken
+	totp          map[string]*repository.TOTPCredential // keyed by user id
+	recoveryCodes map[string]map[string]bool            // user id -> code hash -> used
+	oauthStates   map[string]*repository.OAuthState     // keyed by state_hash
+	identities    []repository.UserIdentity
+	webauthnChals map[string]*repository.WebAuthnChallenge // keyed by session_hash
+	passkeys      []repository.WebAuthnCredential
+	denied        map[string]time.Time  // access token or session id -> denied until
+	auditLogs     []repository.AuditLog // returned by QueryAuditLogs, newest first
+	auditCounts   []repository.AuditCount
+
+	mu          sync.Mutex                        // guards exports and resetTokens, written from goroutines
+	exports     map[string]*repository.DataExport // user id -> latest export
+	resetTokens map[string]*repository.PasswordResetToken
 }
 
 func newMockRepo() *mockRepo {
 	return &mockRepo{
-		users:  make(map[string]*repository.User),
-		byID:   make(map[string]*repository.User),
-		tokens: make(map[string]*repository.RefreshToken),
+		users:         make(map[string]*repository.User),
+		byID:          make(map[string]*repository.User),
+		tokens:        make(map[string]*repository.RefreshToken),
+		resetTokens:   make(map[string]*repository.PasswordResetToken),
+		verifyTokens:  make(map[string]*repository.EmailVerificationToken),
+		totp:          make(map[string]*repository.TOTPCredential),
+		recoveryCodes: make(map[string]map[string]bool),
+		oauthStates:   make(map[string]*repository.OAuthState),
+		webauthnChals: make(map[string]*repository.WebAuthnChallenge),
+		denied:        make(map[string]time.Time),
+		exports:       make(map[string]*repository.DataExport),
 	}
 }
 
-func (m *mockRepo) CreateUser(_ context.Context, id, name, email, passwordHash string, age *int) error {
-	u := &repository.User{ID: id, Name: name, Email: email, PasswordHash: passwordHash, Age: age, IsActive: true, CreatedAt: time.Now()}
+func (m *mockRepo) CreateUser(_ context.Context, id, name, email, passwordHash string, age *int, _ ...outbox.Event) error {
+	u := &repository.User{ID: id, Name: name, Email: email, PasswordHash: passwordHash, Age: age, IsActive: true, CreatedAt: time.Now(), UpdatedAt: time.Now()}
 	m.users[email] = u
 	m.byID[id] = u
 	return nil
@@ -56,8 +91,53 @@ func (m *mockRepo) FindUserByID(_ context.Context, id string) (*repository.User,
 	}
 	return u, nil
 }
-func (m *mockRepo) StoreRefreshToken(_ context.Context, id, userID, tokenHash string, expiresAt time.Time) error {
-	m.tokens[tokenHash] = &repository.RefreshToken{ID: id, UserID: userID, TokenHash: tokenHash, ExpiresAt: expiresAt}
+func (m *mockRepo) UpdateUserProfile(_ context.Context, userID, name string, age *int, _ ...outbox.Event) error {
+	u, ok := m.byID[userID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	u.Name, u.Age, u.UpdatedAt = name, age, time.Now()
+	return nil
+}
+func (m *mockRepo) FindUserRoles(_ context.Context, _ string) ([]string, error) { return nil, nil }
+func (m *mockRepo) MarkUserDeleted(_ context.Context, userID string) error {
+	u, ok := m.byID[userID]
+	if !ok || u.DeletedAt != nil {
+		return repository.ErrNotFound
+	}
+	now := time.Now()
+	u.IsActive, u.DeletedAt = false, &now
+	return nil
+}
+func (m *mockRepo) AnonymiseDeletedUsers(_ context.Context, _ time.Time, _ int, _ func(string) []outbox.Event) ([]string, error) {
+	return nil, nil
+}
+func (m *mockRepo) RecordFailedLogin(_ context.Context, userID string, _ time.Duration) (int, error) {
+	u, ok := m.byID[userID]
+	if !ok {
+		return 0, repository.ErrNotFound
+	}
+	u.FailedLoginCount++
+	return u.FailedLoginCount, nil
+}
+func (m *mockRepo) LockUser(_ context.Context, userID string, until time.Time, _ ...outbox.Event) error {
+	if u, ok := m.byID[userID]; ok {
+		u.LockedUntil = &until
+	}
+	return nil
+}
+func (m *mockRepo) ResetFailedLogins(_ context.Context, userID string) error {
+	if u, ok := m.byID[userID]; ok {
+		u.FailedLoginCount = 0
+		u.LockedUntil = nil
+	}
+	return nil
+}
+func (m *mockRepo) StoreRefreshToken(_ context.Context, id, userID, familyID, parentID, tokenHash, userAgent, ipAddress string, expiresAt time.Time, _ ...outbox.Event) error {
+	m.tokens[tokenHash] = &repository.RefreshToken{
+		ID: id, UserID: userID, FamilyID: familyID, ParentID: parentID, TokenHash: tokenHash,
+		UserAgent: userAgent, IPAddress: ipAddress, ExpiresAt: expiresAt, CreatedAt: time.Now(),
+	}
 	return nil
 }
 func (m *mockRepo) FindRefreshToken(_ context.Context, tokenHash string) (*repository.RefreshToken, error) {
@@ -67,41 +147,392 @@ func (m *mockRepo) FindRefreshToken(_ context.Context, tokenHash string) (*repos
 	}
 	return rt, nil
 }
-func (m *mockRepo) RevokeRefreshToken(_ context.Context, tokenHash string) error {
+func (m *mockRepo) RevokeRefreshToken(_ context.Context, tokenHash string, _ ...outbox.Event) error {
 	if rt, ok := m.tokens[tokenHash]; ok {
 		rt.Revoked = true
 	}
 	return nil
 }
-func (m *mockRepo) RevokeAllUserTokens(_ context.Context, _ string) error { return nil }
-func (m *mockRepo) InsertAuditLog(_ context.Context, _, _ string, _ bool, _ string) error {
+func (m *mockRepo) RotateRefreshToken(_ context.Context, tokenHash, replacedBy string) (bool, error) {
+	rt, ok := m.tokens[tokenHash]
+	if !ok || rt.Revoked {
+		return false, nil
+	}
+	rt.Revoked = true
+	rt.ReplacedBy = replacedBy
+	return true, nil
+}
+func (m *mockRepo) RevokeTokenFamily(_ context.Context, familyID string, _ ...outbox.Event) error {
+	for _, rt := range m.tokens {
+		if rt.FamilyID == familyID {
+			rt.Revoked = true
+		}
+	}
+	return nil
+}
+func (m *mockRepo) ListActiveSessions(_ context.Context, userID string) ([]repository.Session, error) {
+	var sessions []repository.Session
+	for _, t := range m.tokens {
+		if t.UserID != userID || t.Revoked || time.Now().After(t.ExpiresAt) {
+			continue
+		}
+		created := t.CreatedAt
+		for _, root := range m.tokens {
+			if root.ID == t.FamilyID {
+				created = root.CreatedAt
+			}
+		}
+		sessions = append(sessions, repository.Session{
+			ID: t.FamilyID, UserAgent: t.UserAgent, IPAddress: t.IPAddress,
+			CreatedAt: created, LastUsedAt: t.CreatedAt, ExpiresAt: t.ExpiresAt,
+		})
+	}
+	return sessions, nil
+}
+func (m *mockRepo) RevokeAllUserTokens(_ context.Context, _ string, _ ...outbox.Event) error {
+	return nil
+}
+func (m *mockRepo) RevokeAllUserTokensExcept(_ context.Context, userID, keepTokenHash string) error {
+	for _, rt := range m.tokens {
+		if rt.UserID == userID && rt.TokenHash != keepTokenHash {
+			rt.Revoked = true
+		}
+	}
+	return nil
+}
+func (m *mockRepo) UpdatePasswordHash(_ context.Context, userID, passwordHash string, _ ...outbox.Event) error {
+	u, ok := m.byID[userID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	u.PasswordHash = passwordHash
+	return nil
+}
+func (m *mockRepo) StorePasswordResetToken(_ context.Context, id, userID, tokenHash string, expiresAt time.Time, _ ...outbox.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.resetTokens[tokenHash] = &repository.PasswordResetToken{ID: id, UserID: userID, TokenHash: tokenHash, ExpiresAt: expiresAt}
+	return nil
+}
+func (m *mockRepo) resetTokenCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.resetTokens)
+}
+func (m *mockRepo) FindPasswordResetToken(_ context.Context, tokenHash string) (*repository.PasswordResetToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.resetTokens[tokenHash]
+	if !ok || t.UsedAt != nil {
+		return nil, repository.ErrNotFound
+	}
+	return t, nil
+}
+func (m *mockRepo) ResetPassword(_ context.Context, tokenHash, passwordHash string, _ ...outbox.Event) (*repository.PasswordResetToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.resetTokens[tokenHash]
+	if !ok || t.UsedAt != nil {
+		return nil, repository.ErrNotFound
+	}
+	u, ok := m.byID[t.UserID]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	now := time.Now()
+	for _, other := range m.resetTokens {
+		if other.UserID == t.UserID && other.UsedAt == nil {
+			other.UsedAt = &now
+		}
+	}
+	u.PasswordHash = passwordHash
+	return t, nil
+}
+func (m *mockRepo) StoreEmailVerificationToken(_ context.Context, id, userID, tokenHash string, expiresAt time.Time) error {
+	m.verifyTokens[tokenHash] = &repository.EmailVerificationToken{ID: id, UserID: userID, TokenHash: tokenHash, ExpiresAt: expiresAt}
+	return nil
+}
+func (m *mockRepo) ConsumeEmailVerificationToken(_ context.Context, tokenHash string) (*repository.EmailVerificationToken, error) {
+	t, ok := m.verifyTokens[tokenHash]
+	if !ok || t.UsedAt != nil {
+		return nil, repository.ErrNotFound
+	}
+	now := time.Now()
+	t.UsedAt = &now
+	return t, nil
+}
+func (m *mockRepo) MarkEmailVerified(_ context.Context, userID string, _ ...outbox.Event) error {
+	u, ok := m.byID[userID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	now := time.Now()
+	u.EmailVerifiedAt = &now
+	return nil
+}
+func (m *mockRepo) UpsertTOTPEnrollment(_ context.Context, userID, secret string) (bool, error) {
+	if c, ok := m.totp[userID]; ok && c.ConfirmedAt != nil {
+		return false, nil
+	}
+	m.totp[userID] = &repository.TOTPCredential{UserID: userID, Secret: secret}
+	return true, nil
+}
+func (m *mockRepo) FindTOTP(_ context.Context, userID string) (*repository.TOTPCredential, error) {
+	c, ok := m.totp[userID]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	cp := *c
+	return &cp, nil
+}
+func (m *mockRepo) ConfirmTOTP(_ context.Context, userID string, step int64, recoveryCodeHashes []string, _ ...outbox.Event) error {
+	c, ok := m.totp[userID]
+	if !ok || c.ConfirmedAt != nil {
+		return repository.ErrNotFound
+	}
+	now := time.Now()
+	c.ConfirmedAt = &now
+	c.LastUsedStep = step
+	return m.ReplaceRecoveryCodes(context.Background(), userID, recoveryCodeHashes)
+}
+func (m *mockRepo) UseTOTPStep(_ context.Context, userID string, step int64) (bool, error) {
+	c, ok := m.totp[userID]
+	if !ok || c.LastUsedStep >= step {
+		return false, nil
+	}
+	c.LastUsedStep = step
+	return true, nil
+}
+func (m *mockRepo) DeleteTOTP(_ context.Context, userID string, _ ...outbox.Event) error {
+	delete(m.totp, userID)
+	delete(m.recoveryCodes, userID)
+	return nil
+}
+func (m *mockRepo) ReplaceRecoveryCodes(_ context.Context, userID string, codeHashes []string) error {
+	m.recoveryCodes[userID] = make(map[string]bool)
+	for _, h := range codeHashes {
+		m.recoveryCodes[userID][h] = false
+	}
+	return nil
+}
+func (m *mockRepo) ConsumeRecoveryCode(_ context.Context, userID, codeHash string) (bool, error) {
+	used, ok := m.recoveryCodes[userID][codeHash]
+	if !ok || used {
+		return false, nil
+	}
+	m.recoveryCodes[userID][codeHash] = true
+	return true, nil
+}
+func (m *mockRepo) InsertAuditLogs(_ context.Context, _ []auditlog.Event) error {
+	return nil
+}
+func (m *mockRepo) ListAuditLogs(_ context.Context, _ string) ([]repository.AuditLog, error) {
+	return nil, nil
+}
+func (m *mockRepo) QueryAuditLogs(_ context.Context, _ repository.AuditFilter, _ *repository.AuditCursor, limit int) ([]repository.AuditLog, error) {
+	return m.auditLogs[:min(limit, len(m.auditLogs))], nil
+}
+func (m *mockRepo) CountAuditLogs(_ context.Context, _ repository.AuditFilter, _, _ string, _ int) ([]repository.AuditCount, error) {
+	return m.auditCounts, nil
+}
+func (m *mockRepo) CreateDataExport(_ context.Context, id, userID string, staleBefore time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.exports[userID]; ok && e.Status == repository.ExportPending && !e.RequestedAt.Before(staleBefore) {
+		return repository.ErrExportPending
+	}
+	m.exports[userID] = &repository.DataExport{ID: id, UserID: userID, Status: repository.ExportPending, RequestedAt: time.Now()}
+	return nil
+}
+func (m *mockRepo) FindLatestDataExport(_ context.Context, userID string) (*repository.DataExport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.exports[userID]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	cp := *e
+	return &cp, nil
+}
+func (m *mockRepo) CompleteDataExport(_ context.Context, id string, payload []byte, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range m.exports {
+		if e.ID == id {
+			e.Status, e.Payload, e.ExpiresAt = repository.ExportReady, payload, &expiresAt
+			return nil
+		}
+	}
+	return repository.ErrNotFound
+}
+func (m *mockRepo) FailDataExport(_ context.Context, _ string) error                     { return nil }
+func (m *mockRepo) DeleteExpiredDataExports(_ context.Context) (int64, error)            { return 0, nil }
+func (m *mockRepo) CreateEmailChange(_ context.Context, _ *repository.EmailChange) error { return nil }
+func (m *mockRepo) ConfirmEmailChange(_ context.Context, _ string, _ func(*repository.EmailChange) []outbox.Event) (*repository.EmailChange, error) {
+	return nil, repository.ErrNotFound
+}
+func (m *mockRepo) CancelEmailChange(_ context.Context, _ string, _ func(*repository.EmailChange) []outbox.Event) (*repository.EmailChange, error) {
+	return nil, repository.ErrNotFound
+}
+func (m *mockRepo) StoreOAuthState(_ context.Context, st *repository.OAuthState) error {
+	m.oauthStates[st.StateHash] = st
+	return nil
+}
+func (m *mockRepo) ConsumeOAuthState(_ context.Context, stateHash string) (*repository.OAuthState, error) {
+	st, ok := m.oauthStates[stateHash]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	delete(m.oauthStates, stateHash)
+	return st, nil
+}
+func (m *mockRepo) FindUserIdentity(_ context.Context, provider, subject string) (*repository.UserIdentity, error) {
+	for i := range m.identities {
+		if m.identities[i].Provider == provider && m.identities[i].Subject == subject {
+			return &m.identities[i], nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+func (m *mockRepo) ListUserIdentities(_ context.Context, userID string) ([]repository.UserIdentity, error) {
+	var out []repository.UserIdentity
+	for _, id := range m.identities {
+		if id.UserID == userID {
+			out = append(out, id)
+		}
+	}
+	return out, nil
+}
+func (m *mockRepo) LinkUserIdentity(_ context.Context, id *repository.UserIdentity, _ ...outbox.Event) error {
+	id.CreatedAt = time.Now()
+	m.identities = append(m.identities, *id)
+	return nil
+}
+func (m *mockRepo) CreateOAuthUser(ctx context.Context, userID, name, email string, id *repository.UserIdentity, _ ...outbox.Event) error {
+	m.CreateUser(ctx, userID, name, email, "", nil)
+	now := time.Now()
+	m.byID[userID].EmailVerifiedAt = &now
+	id.UserID = userID
+	return m.LinkUserIdentity(ctx, id)
+}
+func (m *mockRepo) DeleteUserIdentity(_ context.Context, userID, provider string, _ ...outbox.Event) error {
+	for i, id := range m.identities {
+		if id.UserID == userID && id.Provider == provider {
+			m.identities = append(m.identities[:i], m.identities[i+1:]...)
+			return nil
+		}
+	}
+	return repository.ErrNotFound
+}
+func (m *mockRepo) StoreWebAuthnChallenge(_ context.Context, c *repository.WebAuthnChallenge) error {
+	m.webauthnChals[c.SessionHash] = c
+	return nil
+}
+func (m *mockRepo) ConsumeWebAuthnChallenge(_ context.Context, sessionHash string) (*repository.WebAuthnChallenge, error) {
+	c, ok := m.webauthnChals[sessionHash]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	delete(m.webauthnChals, sessionHash)
+	return c, nil
+}
+func (m *mockRepo) CreateWebAuthnCredential(_ context.Context, c *repository.WebAuthnCredential, _ ...outbox.Event) error {
+	c.CreatedAt = time.Now()
+	m.passkeys = append(m.passkeys, *c)
+	return nil
+}
+func (m *mockRepo) ListWebAuthnCredentials(_ context.Context, userID string) ([]repository.WebAuthnCredential, error) {
+	var out []repository.WebAuthnCredential
+	for _, c := range m.passkeys {
+		if c.UserID == userID {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+func (m *mockRepo) FindWebAuthnCredential(_ context.Context, credentialID []byte) (*repository.WebAuthnCredential, error) {
+	for _, c := range m.passkeys {
+		if string(c.CredentialID) == string(credentialID) {
+			return &c, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+func (m *mockRepo) UseWebAuthnCredential(_ context.Context, id string, _, signCount uint32) (bool, error) {
+	for i := range m.passkeys {
+		if m.passkeys[i].ID == id {
+			m.passkeys[i].SignCount = signCount
+			return true, nil
+		}
+	}
+	return false, nil
+}
+func (m *mockRepo) DeleteWebAuthnCredential(_ context.Context, userID, id string, _ ...outbox.Event) error {
+	for i, c := range m.passkeys {
+		if c.UserID == userID && c.ID == id {
+			m.passkeys = append(m.passkeys[:i], m.passkeys[i+1:]...)
+			return nil
+		}
+	}
+	return repository.ErrNotFound
+}
+func (m *mockRepo) DenyAccessTokens(_ context.Context, _ string, ids []string, expiresAt time.Time) error {
+	for _, id := range ids {
+		m.denied[id] = expiresAt
+	}
 	return nil
 }
-func (m *mockRepo) Ping(_ context.Context) error { return nil }
+func (m *mockRepo) AccessTokenDenied(_ context.Context, ids []string) (bool, error) {
+	for _, id := range ids {
+		if time.Now().Before(m.denied[id]) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+func (m *mockRepo) RecordEvents(_ context.Context, _ ...outbox.Event) error { return nil }
+func (m *mockRepo) Ping(_ context.Context) error                            { return nil }
 
-// ── Mock Publisher ────────────────────────────────────────────────────────────
+// ── Mock Mailer ───────────────────────────────────────────────────────────────
 
-type mockPublisher struct{}
+type mockMailer struct{}
 
-func (m *mockPublisher) PublishUserRegistered(_ context.Context, _ string) {}
-func (m *mockPublisher) PublishUserLogin(_ context.Context, _ string)      {}
-func (m *mockPublisher) PublishUserLogout(_ context.Context, _ string)     {}
-func (m *mockPublisher) PublishTokenRefresh(_ context.Context, _ string)   {}
-func (m *mockPublisher) Close()                                            {}
+func (m *mockMailer) Send(_ context.Context, _ mailer.Message) error { return nil }
 
 // ── Helpers ──────────────────────────────────────────────────────────────────
 
+func testKeyring() *keys.Keyring {
+	return keys.NewKeyring(keys.NewHMACKey([]byte(testConfig().JWTSecret)))
+}
+
 func testConfig() *config.Config {
 	return &config.Config{
-		JWTSecret:          "test-secret-key-at-least-32-chars!!",
-		AccessTokenMinutes: 15,
-		RefreshTokenDays:   7,
+		JWTSecret:           "test-secret-key-at-least-32-chars!!",
+		AccessTokenMinutes:  15,
+		RefreshTokenDays:    7,
+		LockoutThreshold:    3,
+		LockoutBaseSeconds:  30,
+		LockoutMaxMinutes:   60,
+		MFAChallengeMinutes: 5,
+		MFAIssuer:           "WatUp",
+		EmailVerifyHours:    48,
+		EmailChangeHours:    24,
+		EmailChangeDays:     7,
+		OAuthStateMinutes:   10,
+		WebAuthnRPID:        "localhost",
+		WebAuthnRPName:      "WatUp",
+		WebAuthnOrigins:     []string{"http://localhost:3000"},
+		WebAuthnMinutes:     5,
+		EmailLowercaseLocal: true,
+		AccountDeletionDays: 30,
+		DataExportHours:     72,
+		PasswordMinScore:    2,
 	}
 }
 
 func newTestHandler() (*handlers.AuthHandler, *mockRepo) {
 	repo := newMockRepo()
-	svc := service.NewIdentityService(repo, &mockPublisher{}, testConfig())
+	svc := service.NewIdentityService(repo, &mockMailer{}, testKeyring(), testConfig())
 	return handlers.NewAuthHandler(svc), repo
 }
 
@@ -183,6 +614,36 @@ func TestSignupHandler_PasswordNoDigit(t *testing.T) {
 	}
 }
 
+func TestSignupHandler_PasswordPolicyReasons(t *testing.T) {
+	h, _ := newTestHandler()
+	rr := postJSON(h.Signup, "/auth/signup", jsonBody{
+		"name": "Kasun", "email": "kasun@test.com", "password": "kasun123",
+	})
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+	var resp struct {
+		Error   string `json:"error"`
+		Reasons []struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"reasons"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	var codes []string
+	for _, r := range resp.Reasons {
+		if r.Message == "" {
+			t.Errorf("reason %s has no message", r.Code)
+		}
+		codes = append(codes, r.Code)
+	}
+	if !slices.Equal(codes, []string{"contains_personal_info", "too_weak"}) {
+		t.Errorf("expected contains_personal_info and too_weak, got %v", codes)
+	}
+}
+
 func TestSignupHandler_InvalidAge(t *testing.T) {
 	h, _ := newTestHandler()
 	rr := postJSON(h.Signup, "/auth/signup", jsonBody{
@@ -206,6 +667,19 @@ func TestSignupHandler_Duplicate(t *testing.T) {
 	}
 }
 
+func TestSignupHandler_DuplicateDifferentCase(t *testing.T) {
+	h, _ := newTestHandler()
+	postJSON(h.Signup, "/auth/signup", jsonBody{
+		"name": "Dup", "email": "dup@test.com", "password": "SecurePass1",
+	})
+	rr := postJSON(h.Signup, "/auth/signup", jsonBody{
+		"name": "Dup2", "email": " DUP@Test.com ", "password": "SecurePass1",
+	})
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", rr.Code)
+	}
+}
+
 func TestSignupHandler_InvalidJSON(t *testing.T) {
 	h, _ := newTestHandler()
 	req := httptest.NewRequest(http.MethodPost, "/auth/signup", bytes.NewReader([]byte("not json")))
@@ -270,6 +744,25 @@ func TestLoginHandler_WrongPassword(t *testing.T) {
 	}
 }
 
+func TestLoginHandler_LockedAccountLooksLikeWrongPassword(t *testing.T) {
+	h, _ := newTestHandler()
+	postJSON(h.Signup, "/auth/signup", jsonBody{
+		"name": "Locked", "email": "locked@test.com", "password": "SecurePass1",
+	})
+
+	var wrong *httptest.ResponseRecorder
+	for i := 0; i < 3; i++ {
+		wrong = postJSON(h.Login, "/auth/login", jsonBody{"email": "locked@test.com", "password": "WrongPass1"})
+	}
+	locked := postJSON(h.Login, "/auth/login", jsonBody{"email": "locked@test.com", "password": "SecurePass1"})
+	if locked.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 while locked, got %d", locked.Code)
+	}
+	if locked.Body.String() != wrong.Body.String() {
+		t.Errorf("locked response %q must match wrong-password response %q", locked.Body.String(), wrong.Body.String())
+	}
+}
+
 func TestLoginHandler_UnknownEmail(t *testing.T) {
 	h, _ := newTestHandler()
 	rr := postJSON(h.Login, "/auth/login", jsonBody{
@@ -426,6 +919,15 @@ func TestLogoutHandler_Success(t *testing.T) {
 	if rr.Code != http.StatusNoContent {
 		t.Fatalf("expected 204, got %d: %s", rr.Code, rr.Body.String())
 	}
+
+	// The session's access token stops working with it
+	req := httptest.NewRequest(http.MethodGet, "/auth/validate", nil)
+	req.Header.Set("Authorization", "Bearer "+loginResp["access_token"])
+	rr = httptest.NewRecorder()
+	h.ValidateToken(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for the access token after logout, got %d", rr.Code)
+	}
 }
 
 func TestLogoutHandler_EmptyToken(t *testing.T) {
@@ -448,6 +950,1050 @@ func TestLogoutHandler_InvalidJSON(t *testing.T) {
 	}
 }
 
+// ── Password Reset Handler Tests ─────────────────────────────────────────────
+
+func TestForgotPasswordHandler_KnownEmail(t *testing.T) {
+	h, repo := newTestHandler()
+	postJSON(h.Signup, "/auth/signup", jsonBody{
+		"name": "Forgetful", "email": "forgot@test.com", "password": "SecurePass1",
+	})
+
+	rr := postJSON(h.ForgotPassword, "/auth/password/forgot", jsonBody{"email": "forgot@test.com"})
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+	// The token is stored in the background
+	for i := 0; i < 100 && repo.resetTokenCount() == 0; i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := repo.resetTokenCount(); n != 1 {
+		t.Errorf("expected 1 reset token stored, got %d", n)
+	}
+}
+
+func TestForgotPasswordHandler_UnknownEmail(t *testing.T) {
+	h, _ := newTestHandler()
+	rr := postJSON(h.ForgotPassword, "/auth/password/forgot", jsonBody{"email": "ghost@test.com"})
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 for unknown email (no enumeration), got %d", rr.Code)
+	}
+}
+
+func TestForgotPasswordHandler_InvalidEmail(t *testing.T) {
+	h, _ := newTestHandler()
+	rr := postJSON(h.ForgotPassword, "/auth/password/forgot", jsonBody{"email": "nope"})
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+}
+
+func TestResetPasswordHandler_MissingToken(t *testing.T) {
+	h, _ := newTestHandler()
+	rr := postJSON(h.ResetPassword, "/auth/password/reset", jsonBody{"new_password": "SecurePass1"})
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+}
+
+func TestResetPasswordHandler_WeakPassword(t *testing.T) {
+	h, _ := newTestHandler()
+	rr := postJSON(h.ResetPassword, "/auth/password/reset", jsonBody{"token": "abc", "new_password": "short"})
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+}
+
+func TestResetPasswordHandler_InvalidToken(t *testing.T) {
+	h, _ := newTestHandler()
+	rr := postJSON(h.ResetPassword, "/auth/password/reset", jsonBody{"token": "bogus", "new_password": "SecurePass1"})
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+// ── Change Password Handler Tests ────────────────────────────────────────────
+
+// signupAndLogin registers a user and returns the login response body.
+func signupAndLogin(t *testing.T, h *handlers.AuthHandler, email string) map[string]string {
+	t.Helper()
+	postJSON(h.Signup, "/auth/signup", jsonBody{
+		"name": "Test User", "email": email, "password": "SecurePass1",
+	})
+	rr := postJSON(h.Login, "/auth/login", jsonBody{"email": email, "password": "SecurePass1"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("login failed: %d %s", rr.Code, rr.Body.String())
+	}
+	var resp map[string]string
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	return resp
+}
+
+func postJSONWithToken(handler http.HandlerFunc, path, token string, body any) *httptest.ResponseRecorder {
+	b, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+func TestChangePasswordHandler_Success(t *testing.T) {
+	h, _ := newTestHandler()
+	session := signupAndLogin(t, h, "change@test.com")
+
+	rr := postJSONWithToken(h.ChangePassword, "/auth/password/change", session["access_token"], jsonBody{
+		"current_password": "SecurePass1", "new_password": "NewSecure22", "refresh_token": session["refresh_token"],
+	})
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = postJSON(h.Login, "/auth/login", jsonBody{"email": "change@test.com", "password": "NewSecure22"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected login with new password to succeed, got %d", rr.Code)
+	}
+}
+
+func TestChangePasswordHandler_NoToken(t *testing.T) {
+	h, _ := newTestHandler()
+	rr := postJSON(h.ChangePassword, "/auth/password/change", jsonBody{
+		"current_password": "SecurePass1", "new_password": "NewSecure22",
+	})
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rr.Code)
+	}
+}
+
+func TestChangePasswordHandler_WrongCurrentPassword(t *testing.T) {
+	h, _ := newTestHandler()
+	session := signupAndLogin(t, h, "wrongcurrent@test.com")
+
+	rr := postJSONWithToken(h.ChangePassword, "/auth/password/change", session["access_token"], jsonBody{
+		"current_password": "NotMyPass1", "new_password": "NewSecure22",
+	})
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", rr.Code)
+	}
+}
+
+func TestChangePasswordHandler_WeakNewPassword(t *testing.T) {
+	h, _ := newTestHandler()
+	session := signupAndLogin(t, h, "weaknew@test.com")
+
+	rr := postJSONWithToken(h.ChangePassword, "/auth/password/change", session["access_token"], jsonBody{
+		"current_password": "SecurePass1", "new_password": "weak",
+	})
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+}
+
+// ── MFA Handler Tests ────────────────────────────────────────────────────────
+
+// enableTOTP enrolls and confirms TOTP for the session's user and returns the secret.
+func enableTOTP(t *testing.T, h *handlers.AuthHandler, accessToken string) string {
+	t.Helper()
+	rr := postJSONWithToken(h.EnrollTOTP, "/auth/mfa/totp/enroll", accessToken, jsonBody{})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("enroll failed: %d %s", rr.Code, rr.Body.String())
+	}
+	var enroll map[string]string
+	json.Unmarshal(rr.Body.Bytes(), &enroll)
+	if !strings.HasPrefix(enroll["otpauth_uri"], "otpauth://totp/") {
+		t.Errorf("expected otpauth_uri, got %q", enroll["otpauth_uri"])
+	}
+
+	code, _ := totp.Code(enroll["secret"], totp.Step(time.Now()))
+	rr = postJSONWithToken(h.ConfirmTOTP, "/auth/mfa/totp/confirm", accessToken, jsonBody{"code": code})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("confirm failed: %d %s", rr.Code, rr.Body.String())
+	}
+	var confirm map[string][]string
+	json.Unmarshal(rr.Body.Bytes(), &confirm)
+	if len(confirm["recovery_codes"]) == 0 {
+		t.Error("expected recovery_codes in confirm response")
+	}
+	return enroll["secret"]
+}
+
+func TestMFAHandlers_LoginFlow(t *testing.T) {
+	h, _ := newTestHandler()
+	session := signupAndLogin(t, h, "mfa@test.com")
+	secret := enableTOTP(t, h, session["access_token"])
+
+	rr := postJSON(h.Login, "/auth/login", jsonBody{"email": "mfa@test.com", "password": "SecurePass1"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var challenge map[string]any
+	json.Unmarshal(rr.Body.Bytes(), &challenge)
+	if challenge["mfa_required"] != true || challenge["mfa_token"] == "" {
+		t.Fatalf("expected MFA challenge, got %s", rr.Body.String())
+	}
+	if _, ok := challenge["access_token"]; ok {
+		t.Error("challenge response must not contain an access_token")
+	}
+
+	rr = postJSON(h.VerifyMFA, "/auth/mfa/verify", jsonBody{"mfa_token": challenge["mfa_token"], "code": "000000"})
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for wrong code, got %d", rr.Code)
+	}
+
+	code, _ := totp.Code(secret, totp.Step(time.Now())+1)
+	rr = postJSON(h.VerifyMFA, "/auth/mfa/verify", jsonBody{"mfa_token": challenge["mfa_token"], "code": code})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var tokens map[string]string
+	json.Unmarshal(rr.Body.Bytes(), &tokens)
+	if tokens["access_token"] == "" || tokens["refresh_token"] == "" {
+		t.Error("expected tokens after MFA verification")
+	}
+}
+
+func TestMFAHandlers_EnrollRequiresAuth(t *testing.T) {
+	h, _ := newTestHandler()
+	rr := postJSON(h.EnrollTOTP, "/auth/mfa/totp/enroll", jsonBody{})
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", rr.Code)
+	}
+}
+
+func TestMFAHandlers_EnrollTwiceConflicts(t *testing.T) {
+	h, _ := newTestHandler()
+	session := signupAndLogin(t, h, "mfa-twice@test.com")
+	enableTOTP(t, h, session["access_token"])
+
+	rr := postJSONWithToken(h.EnrollTOTP, "/auth/mfa/totp/enroll", session["access_token"], jsonBody{})
+	if rr.Code != http.StatusConflict {
+		t.Errorf("expected 409, got %d", rr.Code)
+	}
+}
+
+func TestMFAHandlers_VerifyMissingFields(t *testing.T) {
+	h, _ := newTestHandler()
+	rr := postJSON(h.VerifyMFA, "/auth/mfa/verify", jsonBody{"code": "123456"})
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", rr.Code)
+	}
+}
+
+func TestMFAHandlers_DisableWrongPassword(t *testing.T) {
+	h, _ := newTestHandler()
+	session := signupAndLogin(t, h, "mfa-disable@test.com")
+	secret := enableTOTP(t, h, session["access_token"])
+
+	code, _ := totp.Code(secret, totp.Step(time.Now())+1)
+	rr := postJSONWithToken(h.DisableTOTP, "/auth/mfa/totp/disable", session["access_token"], jsonBody{
+		"password": "WrongPass1", "code": code,
+	})
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403, got %d", rr.Code)
+	}
+
+	rr = postJSONWithToken(h.DisableTOTP, "/auth/mfa/totp/disable", session["access_token"], jsonBody{
+		"password": "SecurePass1", "code": code,
+	})
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+// ── Session Handler Tests ────────────────────────────────────────────────────
+
+func sendWithToken(handler http.HandlerFunc, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("User-Agent", "handler-test")
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+func listSessions(t *testing.T, h *handlers.AuthHandler, token string) []map[string]any {
+	t.Helper()
+	rr := sendWithToken(h.ListSessions, http.MethodGet, "/auth/sessions", token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		Sessions []map[string]any `json:"sessions"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	return resp.Sessions
+}
+
+func TestSessionHandlers_ListAndRevoke(t *testing.T) {
+	h, _ := newTestHandler()
+	current := signupAndLogin(t, h, "sessions@test.com")
+	rr := postJSON(h.Login, "/auth/login", jsonBody{"email": "sessions@test.com", "password": "SecurePass1"})
+	var other map[string]string
+	json.Unmarshal(rr.Body.Bytes(), &other)
+
+	sessions := listSessions(t, h, current["access_token"])
+	if len(sessions) != 2 {
+		t.Fatalf("expected 2 sessions, got %d", len(sessions))
+	}
+	var otherID string
+	for _, s := range sessions {
+		if s["current"] != true {
+			otherID, _ = s["id"].(string)
+		}
+	}
+	if otherID == "" {
+		t.Fatal("expected exactly one non-current session")
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/auth/sessions/"+otherID, nil)
+	req.SetPathValue("id", otherID)
+	req.Header.Set("Authorization", "Bearer "+current["access_token"])
+	rr = httptest.NewRecorder()
+	h.RevokeSession(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = postJSON(h.Refresh, "/auth/refresh", jsonBody{"refresh_token": other["refresh_token"]})
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("revoked session should not refresh, got %d", rr.Code)
+	}
+	if got := len(listSessions(t, h, current["access_token"])); got != 1 {
+		t.Errorf("expected 1 session left, got %d", got)
+	}
+}
+
+func TestSessionHandlers_RevokeUnknown(t *testing.T) {
+	h, _ := newTestHandler()
+	session := signupAndLogin(t, h, "unknown-session@test.com")
+
+	req := httptest.NewRequest(http.MethodDelete, "/auth/sessions/nope", nil)
+	req.SetPathValue("id", "nope")
+	req.Header.Set("Authorization", "Bearer "+session["access_token"])
+	rr := httptest.NewRecorder()
+	h.RevokeSession(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", rr.Code)
+	}
+}
+
+func TestSessionHandlers_RevokeOthers(t *testing.T) {
+	h, _ := newTestHandler()
+	current := signupAndLogin(t, h, "others@test.com")
+	postJSON(h.Login, "/auth/login", jsonBody{"email": "others@test.com", "password": "SecurePass1"})
+
+	rr := sendWithToken(h.RevokeOtherSessions, http.MethodDelete, "/auth/sessions", current["access_token"])
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rr.Code)
+	}
+	sessions := listSessions(t, h, current["access_token"])
+	if len(sessions) != 1 || sessions[0]["current"] != true {
+		t.Errorf("expected only the current session to remain, got %v", sessions)
+	}
+}
+
+func TestSessionHandlers_RequireAuth(t *testing.T) {
+	h, _ := newTestHandler()
+	rr := httptest.NewRecorder()
+	h.ListSessions(rr, httptest.NewRequest(http.MethodGet, "/auth/sessions", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", rr.Code)
+	}
+}
+
+// ── Email Verification Handler Tests ─────────────────────────────────────────
+
+func TestVerifyEmailHandler_MissingToken(t *testing.T) {
+	h, _ := newTestHandler()
+	rr := postJSON(h.VerifyEmail, "/auth/email/verify", jsonBody{})
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", rr.Code)
+	}
+}
+
+func TestVerifyEmailHandler_InvalidToken(t *testing.T) {
+	h, _ := newTestHandler()
+	rr := postJSON(h.VerifyEmail, "/auth/email/verify", jsonBody{"token": "bogus"})
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", rr.Code)
+	}
+}
+
+func TestResendVerificationHandler_RequiresAuth(t *testing.T) {
+	h, _ := newTestHandler()
+	rr := postJSON(h.ResendVerificationEmail, "/auth/email/resend", jsonBody{})
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", rr.Code)
+	}
+}
+
+func TestResendVerificationHandler_Success(t *testing.T) {
+	h, _ := newTestHandler()
+	session := signupAndLogin(t, h, "resend@test.com")
+
+	rr := postJSONWithToken(h.ResendVerificationEmail, "/auth/email/resend", session["access_token"], jsonBody{})
+	if rr.Code != http.StatusAccepted {
+		t.Errorf("expected 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestResendVerificationHandler_AlreadyVerified(t *testing.T) {
+	h, repo := newTestHandler()
+	session := signupAndLogin(t, h, "verified@test.com")
+	now := time.Now()
+	repo.users["verified@test.com"].EmailVerifiedAt = &now
+
+	rr := postJSONWithToken(h.ResendVerificationEmail, "/auth/email/resend", session["access_token"], jsonBody{})
+	if rr.Code != http.StatusConflict {
+		t.Errorf("expected 409, got %d", rr.Code)
+	}
+}
+
+// ── Account Deletion Handler Tests ───────────────────────────────────────────
+
+func deleteAccount(h *handlers.AuthHandler, token string, body any) *httptest.ResponseRecorder {
+	b, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodDelete, "/auth/account", bytes.NewReader(b))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	h.DeleteAccount(rr, req)
+	return rr
+}
+
+func TestDeleteAccountHandler_Success(t *testing.T) {
+	h, repo := newTestHandler()
+	session := signupAndLogin(t, h, "leaving@test.com")
+
+	rr := deleteAccount(h, session["access_token"], jsonBody{"password": "SecurePass1"})
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp map[string]string
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	purgeAt, err := time.Parse(time.RFC3339, resp["purge_at"])
+	if err != nil || purgeAt.Before(time.Now().Add(29*24*time.Hour)) {
+		t.Errorf("expected purge_at about 30 days out, got %q", resp["purge_at"])
+	}
+	if u := repo.users["leaving@test.com"]; u.IsActive || u.DeletedAt == nil {
+		t.Error("expected the account to be deactivated and marked deleted")
+	}
+
+	rr = postJSON(h.Login, "/auth/login", jsonBody{"email": "leaving@test.com", "password": "SecurePass1"})
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected login to fail after deletion, got %d", rr.Code)
+	}
+}
+
+func TestDeleteAccountHandler_WrongPassword(t *testing.T) {
+	h, repo := newTestHandler()
+	session := signupAndLogin(t, h, "staying@test.com")
+
+	rr := deleteAccount(h, session["access_token"], jsonBody{"password": "WrongPass1"})
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403, got %d", rr.Code)
+	}
+	if !repo.users["staying@test.com"].IsActive {
+		t.Error("account must stay active after a wrong password")
+	}
+}
+
+func TestDeleteAccountHandler_MissingPassword(t *testing.T) {
+	h, _ := newTestHandler()
+	session := signupAndLogin(t, h, "nopw@test.com")
+
+	rr := deleteAccount(h, session["access_token"], jsonBody{})
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", rr.Code)
+	}
+}
+
+func TestDeleteAccountHandler_RequiresAuth(t *testing.T) {
+	h, _ := newTestHandler()
+	rr := deleteAccount(h, "", jsonBody{"password": "SecurePass1"})
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", rr.Code)
+	}
+}
+
+// ── Profile Handler Tests ────────────────────────────────────────────────────
+
+func patchProfile(h *handlers.AuthHandler, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPatch, "/auth/me", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	h.UpdateProfile(rr, req)
+	return rr
+}
+
+func TestGetProfileHandler(t *testing.T) {
+	h, _ := newTestHandler()
+	session := signupAndLogin(t, h, "me@test.com")
+
+	rr := sendWithToken(h.GetProfile, http.MethodGet, "/auth/me", session["access_token"])
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp map[string]any
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp["email"] != "me@test.com" || resp["name"] != "Test User" || resp["email_verified"] != false {
+		t.Errorf("unexpected profile: %v", resp)
+	}
+	if strings.Contains(strings.ToLower(rr.Body.String()), "password") {
+		t.Errorf("profile must not mention the password: %s", rr.Body.String())
+	}
+
+	rr = sendWithToken(h.GetProfile, http.MethodGet, "/auth/me", "not-a-token")
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a valid token, got %d", rr.Code)
+	}
+}
+
+func TestUpdateProfileHandler(t *testing.T) {
+	h, repo := newTestHandler()
+	session := signupAndLogin(t, h, "patch@test.com")
+	token := session["access_token"]
+
+	rr := patchProfile(h, token, `{"name": "  Renuka Perera ", "age": 41}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp map[string]any
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp["name"] != "Renuka Perera" || resp["age"] != float64(41) {
+		t.Errorf("unexpected profile: %v", resp)
+	}
+
+	// Leaving age out keeps it; null clears it
+	rr = patchProfile(h, token, `{"name": "Renuka"}`)
+	if u := repo.users["patch@test.com"]; rr.Code != http.StatusOK || u.Age == nil || *u.Age != 41 {
+		t.Errorf("expected the age to be kept, got %d", rr.Code)
+	}
+	rr = patchProfile(h, token, `{"age": null}`)
+	if u := repo.users["patch@test.com"]; rr.Code != http.StatusOK || u.Age != nil || u.Name != "Renuka" {
+		t.Errorf("expected only the age to be cleared, got %d", rr.Code)
+	}
+}
+
+func TestUpdateProfileHandler_Validation(t *testing.T) {
+	h, _ := newTestHandler()
+	session := signupAndLogin(t, h, "invalid@test.com")
+
+	for _, body := range []string{
+		`{"name": "   "}`,
+		`{"name": "` + strings.Repeat("x", 101) + `"}`,
+		`{"age": 12}`,
+		`{"age": 121}`,
+		`{"age": "thirty"}`,
+		`not json`,
+	} {
+		if rr := patchProfile(h, session["access_token"], body); rr.Code != http.StatusBadRequest {
+			t.Errorf("PATCH %s: expected 400, got %d", body, rr.Code)
+		}
+	}
+}
+
+// ── Data Export Handler Tests ────────────────────────────────────────────────
+
+func TestDataExportHandlers_RequestThenDownload(t *testing.T) {
+	h, _ := newTestHandler()
+	session := signupAndLogin(t, h, "export@test.com")
+
+	rr := sendWithToken(h.DownloadDataExport, http.MethodGet, "/auth/account/export", session["access_token"])
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 before an export is requested, got %d", rr.Code)
+	}
+
+	rr = sendWithToken(h.RequestDataExport, http.MethodPost, "/auth/account/export", session["access_token"])
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var status map[string]string
+	json.Unmarshal(rr.Body.Bytes(), &status)
+	if status["id"] == "" || status["status"] != "pending" {
+		t.Errorf("unexpected export status: %v", status)
+	}
+
+	for range 100 {
+		rr = sendWithToken(h.DataExportStatus, http.MethodGet, "/auth/account/export/status", session["access_token"])
+		json.Unmarshal(rr.Body.Bytes(), &status)
+		if rr.Code != http.StatusOK || status["status"] != "pending" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if rr.Code != http.StatusOK || status["status"] != "ready" || status["expires_at"] == "" {
+		t.Fatalf("expected a ready status with an expiry, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = sendWithToken(h.DownloadDataExport, http.MethodGet, "/auth/account/export", session["access_token"])
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 once ready, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if cd := rr.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, "attachment;") {
+		t.Errorf("expected an attachment, got Content-Disposition %q", cd)
+	}
+	var bundle struct {
+		Sections map[string]json.RawMessage `json:"sections"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &bundle); err != nil {
+		t.Fatalf("bundle is not JSON: %v", err)
+	}
+	for _, section := range []string{"profile", "sessions", "audit_log", "mfa"} {
+		if _, ok := bundle.Sections[section]; !ok {
+			t.Errorf("bundle is missing the %s section", section)
+		}
+	}
+}
+
+func TestDataExportHandlers_RequireAuth(t *testing.T) {
+	h, _ := newTestHandler()
+	for _, handler := range []http.HandlerFunc{h.RequestDataExport, h.DownloadDataExport, h.DataExportStatus} {
+		rr := sendWithToken(handler, http.MethodGet, "/auth/account/export", "not-a-token")
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected 401, got %d", rr.Code)
+		}
+	}
+}
+
+// ── Email Change Handler Tests ───────────────────────────────────────────────
+
+func TestRequestEmailChangeHandler(t *testing.T) {
+	h, repo := newTestHandler()
+	session := signupAndLogin(t, h, "moving@test.com")
+	signupAndLogin(t, h, "taken@test.com")
+
+	tests := []struct {
+		name string
+		body jsonBody
+		want int
+	}{
+		{"success", jsonBody{"new_email": "moved@test.com", "password": "SecurePass1"}, http.StatusAccepted},
+		{"wrong password", jsonBody{"new_email": "moved@test.com", "password": "WrongPass1"}, http.StatusForbidden},
+		{"missing password", jsonBody{"new_email": "moved@test.com"}, http.StatusBadRequest},
+		{"invalid email", jsonBody{"new_email": "not-an-email", "password": "SecurePass1"}, http.StatusBadRequest},
+		{"same email", jsonBody{"new_email": "moving@test.com", "password": "SecurePass1"}, http.StatusBadRequest},
+		{"address taken", jsonBody{"new_email": "taken@test.com", "password": "SecurePass1"}, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := postJSONWithToken(h.RequestEmailChange, "/auth/email/change", session["access_token"], tt.body)
+			if rr.Code != tt.want {
+				t.Errorf("expected %d, got %d: %s", tt.want, rr.Code, rr.Body.String())
+			}
+		})
+	}
+	if repo.users["moving@test.com"] == nil {
+		t.Error("the email must not change before confirmation")
+	}
+
+	rr := postJSONWithToken(h.RequestEmailChange, "/auth/email/change", "not-a-token", jsonBody{"new_email": "moved@test.com", "password": "SecurePass1"})
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", rr.Code)
+	}
+}
+
+func TestEmailChangeTokenHandlers_InvalidToken(t *testing.T) {
+	h, _ := newTestHandler()
+	for _, handler := range []http.HandlerFunc{h.ConfirmEmailChange, h.CancelEmailChange} {
+		if rr := postJSON(handler, "/auth/email/change/confirm", jsonBody{"token": "unknown"}); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for an unknown token, got %d", rr.Code)
+		}
+		if rr := postJSON(handler, "/auth/email/change/confirm", jsonBody{}); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for a missing token, got %d", rr.Code)
+		}
+	}
+}
+
+// ── Social Login Handler Tests ───────────────────────────────────────────────
+
+// fakeIdP signs in every authorization code "good-code" as identity.
+type fakeIdP struct {
+	identity oauth.Identity
+}
+
+func (p *fakeIdP) Name() string { return "google" }
+func (p *fakeIdP) AuthCodeURL(_ context.Context, state, _, _ string) (string, error) {
+	return "https://idp.example.com/authorize?state=" + state, nil
+}
+func (p *fakeIdP) Exchange(_ context.Context, code, _, _ string) (*oauth.Identity, error) {
+	if code != "good-code" {
+		return nil, oauth.ErrExchange
+	}
+	id := p.identity
+	return &id, nil
+}
+
+func newOAuthTestHandler(t *testing.T) *handlers.AuthHandler {
+	t.Helper()
+	svc := service.NewIdentityService(newMockRepo(), &mockMailer{}, testKeyring(), testConfig())
+	if err := svc.AddIdentityProviders(&fakeIdP{identity: oauth.Identity{Subject: "g-1", Email: "social@test.com", EmailVerified: true}}); err != nil {
+		t.Fatal(err)
+	}
+	return handlers.NewAuthHandler(svc)
+}
+
+func startOAuth(t *testing.T, h *handlers.AuthHandler, provider string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/auth/oauth/"+provider+"/start", nil)
+	req.SetPathValue("provider", provider)
+	rr := httptest.NewRecorder()
+	h.StartOAuth(rr, req)
+	return rr
+}
+
+func startOAuthLink(t *testing.T, h *handlers.AuthHandler, accessToken string) string {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/auth/oauth/google/start", nil)
+	req.SetPathValue("provider", "google")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	rr := httptest.NewRecorder()
+	h.StartOAuth(rr, req)
+	var start map[string]string
+	json.Unmarshal(rr.Body.Bytes(), &start)
+	if rr.Code != http.StatusOK || start["state"] == "" {
+		t.Fatalf("expected a link state, got %d: %s", rr.Code, rr.Body.String())
+	}
+	return start["state"]
+}
+
+func completeOAuth(h *handlers.AuthHandler, body jsonBody) *httptest.ResponseRecorder {
+	return completeOAuthWithToken(h, "", body)
+}
+
+func completeOAuthWithToken(h *handlers.AuthHandler, accessToken string, body jsonBody) *httptest.ResponseRecorder {
+	b, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, "/auth/oauth/google/callback", bytes.NewReader(b))
+	req.SetPathValue("provider", "google")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	rr := httptest.NewRecorder()
+	h.CompleteOAuth(rr, req)
+	return rr
+}
+
+func TestOAuthHandlers_LoginFlow(t *testing.T) {
+	h := newOAuthTestHandler(t)
+
+	rr := httptest.NewRecorder()
+	h.ListOAuthProviders(rr, httptest.NewRequest(http.MethodGet, "/auth/oauth/providers", nil))
+	if !strings.Contains(rr.Body.String(), `"providers":["google"]`) {
+		t.Errorf("unexpected providers response: %s", rr.Body.String())
+	}
+	if rr := startOAuth(t, h, "myspace"); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown provider, got %d", rr.Code)
+	}
+
+	rr = startOAuth(t, h, "google")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var start map[string]string
+	json.Unmarshal(rr.Body.Bytes(), &start)
+	if !strings.HasPrefix(start["authorization_url"], "https://idp.example.com/authorize?") || start["state"] == "" {
+		t.Fatalf("unexpected start response: %v", start)
+	}
+
+	rr = completeOAuth(h, jsonBody{"state": start["state"], "code": "good-code"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var tokens map[string]string
+	json.Unmarshal(rr.Body.Bytes(), &tokens)
+	if tokens["access_token"] == "" || tokens["refresh_token"] == "" {
+		t.Fatalf("expected tokens, got %v", tokens)
+	}
+	if rr := completeOAuth(h, jsonBody{"state": start["state"], "code": "good-code"}); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a replayed state, got %d", rr.Code)
+	}
+
+	rr = sendWithToken(h.ListLinkedIdentities, http.MethodGet, "/auth/oauth/identities", tokens["access_token"])
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"provider":"google"`) {
+		t.Errorf("expected google in linked identities, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/auth/oauth/identities/google", nil)
+	req.SetPathValue("provider", "google")
+	req.Header.Set("Authorization", "Bearer "+tokens["access_token"])
+	rr = httptest.NewRecorder()
+	h.UnlinkIdentity(rr, req)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("expected 409 when unlinking the only sign-in method, got %d", rr.Code)
+	}
+}
+
+func TestOAuthHandlers_CallbackErrors(t *testing.T) {
+	h := newOAuthTestHandler(t)
+
+	if rr := completeOAuth(h, jsonBody{"state": "s"}); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a missing code, got %d", rr.Code)
+	}
+	var start map[string]string
+	json.Unmarshal(startOAuth(t, h, "google").Body.Bytes(), &start)
+	if rr := completeOAuth(h, jsonBody{"state": start["state"], "code": "bad-code"}); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for a rejected code, got %d", rr.Code)
+	}
+}
+
+func TestOAuthHandlers_LinkFlowRequiresTheStartingUser(t *testing.T) {
+	h := newOAuthTestHandler(t)
+	owner := signupAndLogin(t, h, "owner@test.com")
+	other := signupAndLogin(t, h, "other@test.com")
+
+	state := startOAuthLink(t, h, owner["access_token"])
+	if rr := completeOAuth(h, jsonBody{"state": state, "code": "good-code"}); rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a link callback without a token, got %d: %s", rr.Code, rr.Body.String())
+	}
+	state = startOAuthLink(t, h, owner["access_token"])
+	if rr := completeOAuthWithToken(h, other["access_token"], jsonBody{"state": state, "code": "good-code"}); rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a link callback by another user, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := completeOAuthWithToken(h, "not-a-token", jsonBody{"state": state, "code": "good-code"}); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for an invalid token, got %d", rr.Code)
+	}
+
+	state = startOAuthLink(t, h, owner["access_token"])
+	rr := completeOAuthWithToken(h, owner["access_token"], jsonBody{"state": state, "code": "good-code"})
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"status":"linked"`) {
+		t.Errorf("expected the owner to link google, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestOAuthHandlers_ReauthForPasswordlessAccount(t *testing.T) {
+	h := newOAuthTestHandler(t)
+	var start, tokens map[string]string
+	json.Unmarshal(startOAuth(t, h, "google").Body.Bytes(), &start)
+	json.Unmarshal(completeOAuth(h, jsonBody{"state": start["state"], "code": "good-code"}).Body.Bytes(), &tokens)
+
+	if rr := deleteAccount(h, tokens["access_token"], jsonBody{}); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without a password or reauth_token, got %d", rr.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/oauth/google/reauth", nil)
+	req.SetPathValue("provider", "google")
+	req.Header.Set("Authorization", "Bearer "+tokens["access_token"])
+	rr := httptest.NewRecorder()
+	h.StartOAuthReauth(rr, req)
+	json.Unmarshal(rr.Body.Bytes(), &start)
+	if rr.Code != http.StatusOK || start["state"] == "" {
+		t.Fatalf("expected a reauth state, got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = completeOAuthWithToken(h, tokens["access_token"], jsonBody{"state": start["state"], "code": "good-code"})
+	var reauth map[string]string
+	json.Unmarshal(rr.Body.Bytes(), &reauth)
+	if rr.Code != http.StatusOK || reauth["reauth_token"] == "" || reauth["expires_at"] == "" {
+		t.Fatalf("expected a reauth_token, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := deleteAccount(h, tokens["access_token"], jsonBody{"reauth_token": "forged"}); rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for an invalid reauth_token, got %d", rr.Code)
+	}
+	if rr := deleteAccount(h, tokens["access_token"], jsonBody{"reauth_token": reauth["reauth_token"]}); rr.Code != http.StatusAccepted {
+		t.Errorf("expected 202 with the reauth_token, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+// ── Passkey Handler Tests ────────────────────────────────────────────────────
+
+// passkeyStart decodes a begin response, with the options typed as T.
+func passkeyStart[T any](t *testing.T, rr *httptest.ResponseRecorder) (string, *T) {
+	t.Helper()
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		Session   string `json:"session"`
+		PublicKey *T     `json:"public_key"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || resp.Session == "" || resp.PublicKey == nil {
+		t.Fatalf("unexpected begin response: %s", rr.Body.String())
+	}
+	return resp.Session, resp.PublicKey
+}
+
+func TestPasskeyHandlers_RegisterAndSignIn(t *testing.T) {
+	h, _ := newTestHandler()
+	session := signupAndLogin(t, h, "passkey@test.com")
+	auth := webauthntest.New("http://localhost:3000")
+
+	rr := postJSONWithToken(h.BeginPasskeyRegistration, "/auth/passkeys/register/begin", session["access_token"], jsonBody{"password": "SecurePass1"})
+	regSession, creation := passkeyStart[webauthn.CreationOptions](t, rr)
+	if creation.RP.ID != "localhost" || creation.Attestation != "none" {
+		t.Errorf("unexpected creation options: %+v", creation)
+	}
+	cred, err := auth.Create(creation)
+	if err != nil {
+		t.Fatalf("authenticator Create() error: %v", err)
+	}
+	rr = postJSONWithToken(h.FinishPasskeyRegistration, "/auth/passkeys/register/finish", session["access_token"], jsonBody{
+		"session": regSession, "name": "Phone", "credential": cred,
+	})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = sendWithToken(h.ListPasskeys, http.MethodGet, "/auth/passkeys", session["access_token"])
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"name":"Phone"`) {
+		t.Errorf("expected the Phone passkey, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	loginSession, request := passkeyStart[webauthn.RequestOptions](t, postJSON(h.BeginPasskeyLogin, "/auth/passkeys/login/begin", nil))
+	assertion, err := auth.Get(request)
+	if err != nil {
+		t.Fatalf("authenticator Get() error: %v", err)
+	}
+	rr = postJSON(h.FinishPasskeyLogin, "/auth/passkeys/login/finish", jsonBody{"session": loginSession, "credential": assertion})
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"access_token"`) {
+		t.Fatalf("expected tokens, got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = postJSON(h.FinishPasskeyLogin, "/auth/passkeys/login/finish", jsonBody{"session": loginSession, "credential": assertion})
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a replayed session, got %d", rr.Code)
+	}
+}
+
+func TestPasskeyHandlers_Errors(t *testing.T) {
+	h, _ := newTestHandler()
+	session := signupAndLogin(t, h, "passkey-errors@test.com")
+
+	if rr := postJSON(h.BeginPasskeyRegistration, "/auth/passkeys/register/begin", nil); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a token, got %d", rr.Code)
+	}
+	begin := func(body jsonBody) int {
+		return postJSONWithToken(h.BeginPasskeyRegistration, "/auth/passkeys/register/begin", session["access_token"], body).Code
+	}
+	if code := begin(jsonBody{}); code != http.StatusBadRequest {
+		t.Errorf("expected 400 without a password, got %d", code)
+	}
+	if code := begin(jsonBody{"password": "WrongPass1"}); code != http.StatusForbidden {
+		t.Errorf("expected 403 for a wrong password, got %d", code)
+	}
+	if rr := postJSON(h.FinishPasskeyLogin, "/auth/passkeys/login/finish", jsonBody{"session": "s"}); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a missing credential, got %d", rr.Code)
+	}
+	rr := postJSON(h.FinishPasskeyLogin, "/auth/passkeys/login/finish", jsonBody{
+		"session": "made-up", "credential": jsonBody{"id": "x", "rawId": "x", "type": "public-key"},
+	})
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown session, got %d", rr.Code)
+	}
+	if rr := postJSON(h.BeginPasskeyMFA, "/auth/mfa/passkey/begin", jsonBody{"mfa_token": "bogus"}); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for a bad mfa_token, got %d", rr.Code)
+	}
+
+	b, _ := json.Marshal(jsonBody{"password": "SecurePass1"})
+	req := httptest.NewRequest(http.MethodDelete, "/auth/passkeys/00000000-0000-0000-0000-000000000000", bytes.NewReader(b))
+	req.SetPathValue("id", "00000000-0000-0000-0000-000000000000")
+	req.Header.Set("Authorization", "Bearer "+session["access_token"])
+	rr = httptest.NewRecorder()
+	h.DeletePasskey(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown passkey, got %d", rr.Code)
+	}
+}
+
+// ── Admin Audit Log Handler Tests ────────────────────────────────────────────
+
+func TestQueryAuditLogsHandler_Success(t *testing.T) {
+	h, repo := newTestHandler()
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	repo.auditLogs = []repository.AuditLog{
+		{ID: "00000000-0000-0000-0000-000000000003", EventType: "login_failed", IPAddress: "203.0.113.7", CreatedAt: at},
+		{ID: "00000000-0000-0000-0000-000000000002", EventType: "login_failed", IPAddress: "203.0.113.7", CreatedAt: at},
+		{ID: "00000000-0000-0000-0000-000000000001", EventType: "login_failed", IPAddress: "203.0.113.7", CreatedAt: at},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/audit-logs?event_type=login_failed&success=false&ip=203.0.113.0/24&limit=2", nil)
+	rr := httptest.NewRecorder()
+	h.QueryAuditLogs(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		Logs []struct {
+			ID        string `json:"id"`
+			CreatedAt string `json:"created_at"`
+		} `json:"logs"`
+		NextCursor string `json:"next_cursor"`
+	}
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if len(resp.Logs) != 2 || resp.Logs[0].CreatedAt != "2026-03-01T12:00:00Z" {
+		t.Errorf("expected two logs, got %+v", resp.Logs)
+	}
+	if resp.NextCursor == "" {
+		t.Error("expected a next_cursor")
+	}
+}
+
+func TestQueryAuditLogsHandler_BadRequest(t *testing.T) {
+	h, _ := newTestHandler()
+	for _, query := range []string{"success=maybe", "since=yesterday", "limit=-1", "ip=not-an-ip", "user_id=42", "cursor=%21%21"} {
+		req := httptest.NewRequest(http.MethodGet, "/admin/audit-logs?"+query, nil)
+		rr := httptest.NewRecorder()
+		h.QueryAuditLogs(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestAuditLogStatsHandler(t *testing.T) {
+	h, repo := newTestHandler()
+	repo.auditCounts = []repository.AuditCount{
+		{BucketStart: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), Key: "203.0.113.7", Count: 42},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/audit-logs/stats?event_type=login_failed&group_by=ip", nil)
+	rr := httptest.NewRecorder()
+	h.AuditLogStats(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		Bucket string `json:"bucket"`
+		Counts []struct {
+			BucketStart string `json:"bucket_start"`
+			Key         string `json:"key"`
+			Count       int64  `json:"count"`
+		} `json:"counts"`
+	}
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if resp.Bucket != "hour" || len(resp.Counts) != 1 || resp.Counts[0].Key != "203.0.113.7" || resp.Counts[0].Count != 42 {
+		t.Errorf("unexpected stats %+v", resp)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/audit-logs/stats?group_by=country", nil)
+	rr = httptest.NewRecorder()
+	h.AuditLogStats(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown group_by, got %d", rr.Code)
+	}
+}
+
+// ── JWKS Handler Tests ───────────────────────────────────────────────────────
+
+func TestJWKSHandler(t *testing.T) {
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	signing, _ := keys.NewKey("ed-1", priv)
+	h := handlers.NewJWKSHandler(keys.NewKeyring(signing, keys.NewHMACKey([]byte("secret"))))
+
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	rr := httptest.NewRecorder()
+	h.JWKS(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var set keys.JWKSet
+	json.Unmarshal(rr.Body.Bytes(), &set)
+	if len(set.Keys) != 1 || set.Keys[0].Kid != "ed-1" {
+		t.Errorf("expected only the Ed25519 key to be published, got %+v", set.Keys)
+	}
+	if rr.Header().Get("Cache-Control") == "" {
+		t.Error("expected Cache-Control header")
+	}
+}
+
 // ── Health Handler Tests ─────────────────────────────────────────────────────
 
 type healthMockRepo struct {
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/watup-lk/identity-service/internal/service"
+)
+
+type verifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// VerifyEmail godoc
+// POST /auth/email/verify
+// Body: {"token": "..."}
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req verifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Token == "" {
+		writeError(w, http.StatusBadRequest, "token is required")
+		return
+	}
+
+	if err := h.svc.VerifyEmail(r.Context(), req.Token, clientIP(r)); err != nil {
+		if errors.Is(err, service.ErrInvalidVerificationToken) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "email verification failed")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResendVerificationEmail godoc
+// POST /auth/email/resend
+// Header: Authorization: Bearer <access_token>
+// Emails a new verification link to the caller's address.
+func (h *AuthHandler) ResendVerificationEmail(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.ResendVerificationEmail(r.Context(), userID, clientIP(r)); err != nil {
+		switch {
+		case errors.Is(err, service.ErrEmailAlreadyVerified):
+			writeError(w, http.StatusConflict, err.Error())
+		case errors.Is(err, service.ErrInvalidToken), errors.Is(err, service.ErrAccountDisabled):
+			writeError(w, http.StatusUnauthorized, "invalid or expired token")
+		default:
+			writeError(w, http.StatusInternalServerError, "sending verification email failed")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "verification email sent"})
+}
+
+type changeEmailRequest struct {
+	NewEmail string `json:"new_email"`
+	reauthRequest
+}
+
+type changeEmailResponse struct {
+	Status    string `json:"status"`
+	ExpiresAt string `json:"expires_at"` // when the confirmation link stops working
+}
+
+type emailChangeTokenRequest struct {
+	Token string `json:"token"`
+}
+
+// RequestEmailChange godoc
+// POST /auth/email/change
+// Header: Authorization: Bearer <access_token>
+// Body: {"new_email": "...", "password": "..."} — or "reauth_token" instead of "password"
+// Emails a confirmation link to the new address and a cancellation link to the current one.
+func (h *AuthHandler) RequestEmailChange(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	var req changeEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if msg := validateEmail(req.NewEmail); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if req.missing() {
+		writeError(w, http.StatusBadRequest, reauthRequired)
+		return
+	}
+
+	expiresAt, err := h.svc.RequestEmailChange(r.Context(), userID, req.proof(), req.NewEmail, clientIP(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidCredentials):
+			writeError(w, http.StatusForbidden, reauthRejected)
+		case errors.Is(err, service.ErrAccountLocked):
+			writeError(w, http.StatusTooManyRequests, "too many failed attempts, try again later")
+		case errors.Is(err, service.ErrSameEmail):
+			writeError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, service.ErrUserAlreadyExists):
+			writeError(w, http.StatusConflict, err.Error())
+		case errors.Is(err, service.ErrInvalidToken), errors.Is(err, service.ErrAccountDisabled):
+			writeError(w, http.StatusUnauthorized, "invalid or expired token")
+		default:
+			writeError(w, http.StatusInternalServerError, "email change failed")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, changeEmailResponse{
+		Status:    "confirmation email sent",
+		ExpiresAt: expiresAt.UTC().Format("2006-01-02T15:04:05Z"),
+	})
+}
+
+// ConfirmEmailChange godoc
+// POST /auth/email/change/confirm
+// Body: {"token": "..."}  — from the email sent to the new address
+// Switches the login email and signs out every session.
+func (h *AuthHandler) ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	h.consumeEmailChangeToken(w, r, h.svc.ConfirmEmailChange)
+}
+
+// CancelEmailChange godoc
+// POST /auth/email/change/cancel
+// Body: {"token": "..."}  — from the notice sent to the old address
+// Cancels a pending change, or restores the old address if it already went through.
+func (h *AuthHandler) CancelEmailChange(w http.ResponseWriter, r *http.Request) {
+	h.consumeEmailChangeToken(w, r, h.svc.CancelEmailChange)
+}
+
+func (h *AuthHandler) consumeEmailChangeToken(w http.ResponseWriter, r *http.Request, consume func(ctx context.Context, token, clientIP string) error) {
+	var req emailChangeTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Token == "" {
+		writeError(w, http.StatusBadRequest, "token is required")
+		return
+	}
+
+	if err := consume(r.Context(), req.Token, clientIP(r)); err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidEmailChangeToken):
+			writeError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, service.ErrUserAlreadyExists):
+			writeError(w, http.StatusConflict, err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, "email change failed")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
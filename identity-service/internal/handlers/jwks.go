@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/watup-lk/identity-service/internal/keys"
+)
+
+// JWKSource returns the public keys used to verify access tokens.
+type JWKSource interface {
+	JWKS() keys.JWKSet
+}
+
+// JWKSHandler publishes the token verification keys so vote-service and the BFF
+// can validate access tokens locally instead of calling ValidateToken per request.
+type JWKSHandler struct {
+	keys JWKSource
+}
+
+func NewJWKSHandler(k JWKSource) *JWKSHandler {
+	return &JWKSHandler{keys: k}
+}
+
+// JWKS godoc
+// GET /.well-known/jwks.json
+// Returns an RFC 7517 JWK Set. Empty when only HS256 is configured — a shared
+// secret is never published.
+func (h *JWKSHandler) JWKS(w http.ResponseWriter, _ *http.Request) {
+	// Short cache so verifiers pick up newly rotated keys within minutes
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, h.keys.JWKS())
+}
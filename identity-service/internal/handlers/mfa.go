@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/watup-lk/identity-service/internal/service"
+)
+
+// --- Request / Response types ---
+
+type mfaChallengeResponse struct {
+	MFARequired bool     `json:"mfa_required"`
+	MFAToken    string   `json:"mfa_token"`
+	ExpiresAt   string   `json:"expires_at"`
+	Methods     []string `json:"methods"` // "totp", "recovery_code" and, if registered, "passkey"
+}
+
+type mfaVerifyRequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"` // TOTP code or recovery code
+}
+
+type totpEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type mfaCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type mfaDisableRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// --- Handlers ---
+
+// VerifyMFA godoc
+// POST /auth/mfa/verify
+// Body: {"mfa_token": "...", "code": "123456"}
+// Completes a login for an account with two-factor authentication. code may be a
+// TOTP code or one of the recovery codes.
+func (h *AuthHandler) VerifyMFA(w http.ResponseWriter, r *http.Request) {
+	var req mfaVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.MFAToken == "" || req.Code == "" {
+		writeError(w, http.StatusBadRequest, "mfa_token and code are required")
+		return
+	}
+
+	pair, err := h.svc.VerifyMFA(r.Context(), req.MFAToken, req.Code, clientIP(r), r.UserAgent())
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidMFACode), errors.Is(err, service.ErrAccountLocked):
+			writeError(w, http.StatusUnauthorized, service.ErrInvalidMFACode.Error())
+		case errors.Is(err, service.ErrInvalidToken), errors.Is(err, service.ErrAccountDisabled),
+			errors.Is(err, service.ErrMFANotEnrolled):
+			writeError(w, http.StatusUnauthorized, "invalid or expired mfa_token")
+		default:
+			writeError(w, http.StatusInternalServerError, "mfa verification failed")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, loginResponse{
+		AccessToken:  pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresAt:    pair.ExpiresAt.UTC().Format("2006-01-02T15:04:05Z"),
+	})
+}
+
+// EnrollTOTP godoc
+// POST /auth/mfa/totp/enroll
+// Header: Authorization: Bearer <access_token>
+// Returns a new TOTP secret and its otpauth:// URI for the QR code. Two-factor
+// authentication is not enforced until the enrollment is confirmed.
+func (h *AuthHandler) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	enrollment, err := h.svc.EnrollTOTP(r.Context(), userID, clientIP(r))
+	if err != nil {
+		writeMFAError(w, err, "totp enrollment failed")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, totpEnrollResponse{
+		Secret:     enrollment.Secret,
+		OTPAuthURI: enrollment.ProvisioningURI,
+	})
+}
+
+// ConfirmTOTP godoc
+// POST /auth/mfa/totp/confirm
+// Header: Authorization: Bearer <access_token>
+// Body: {"code": "123456"}
+// Enables two-factor authentication and returns the recovery codes (shown once).
+func (h *AuthHandler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	var req mfaCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Code == "" {
+		writeError(w, http.StatusBadRequest, "code is required")
+		return
+	}
+
+	codes, err := h.svc.ConfirmTOTP(r.Context(), userID, req.Code, clientIP(r))
+	if err != nil {
+		writeMFAError(w, err, "totp confirmation failed")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTOTP godoc
+// POST /auth/mfa/totp/disable
+// Header: Authorization: Bearer <access_token>
+// Body: {"password": "...", "code": "123456"}
+func (h *AuthHandler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	var req mfaDisableRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Password == "" || req.Code == "" {
+		writeError(w, http.StatusBadRequest, "password and code are required")
+		return
+	}
+
+	if err := h.svc.DisableTOTP(r.Context(), userID, req.Password, req.Code, clientIP(r)); err != nil {
+		writeMFAError(w, err, "disabling totp failed")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RegenerateRecoveryCodes godoc
+// POST /auth/mfa/recovery-codes
+// Header: Authorization: Bearer <access_token>
+// Body: {"code": "123456"}
+// Replaces all remaining recovery codes with a new set.
+func (h *AuthHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	var req mfaCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Code == "" {
+		writeError(w, http.StatusBadRequest, "code is required")
+		return
+	}
+
+	codes, err := h.svc.RegenerateRecoveryCodes(r.Context(), userID, req.Code, clientIP(r))
+	if err != nil {
+		writeMFAError(w, err, "regenerating recovery codes failed")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// writeMFAError maps the errors shared by the authenticated MFA endpoints.
+func writeMFAError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, service.ErrMFAAlreadyEnabled):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrMFANotEnrolled), errors.Is(err, service.ErrInvalidMFACode):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrInvalidCredentials):
+		writeError(w, http.StatusForbidden, "password is incorrect")
+	case errors.Is(err, service.ErrAccountLocked):
+		writeError(w, http.StatusTooManyRequests, "too many failed attempts, try again later")
+	case errors.Is(err, service.ErrInvalidToken), errors.Is(err, service.ErrAccountDisabled):
+		writeError(w, http.StatusUnauthorized, "invalid or expired token")
+	default:
+		writeError(w, http.StatusInternalServerError, fallback)
+	}
+}
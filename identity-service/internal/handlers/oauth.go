@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/watup-lk/identity-service/internal/service"
+)
+
+// --- Request / Response types ---
+
+type oauthProvidersResponse struct {
+	Providers []string `json:"providers"`
+}
+
+type oauthStartResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+	State            string `json:"state"`
+	ExpiresAt        string `json:"expires_at"`
+}
+
+type oauthCallbackRequest struct {
+	State string `json:"state"`
+	Code  string `json:"code"`
+}
+
+type oauthLinkedResponse struct {
+	Status   string `json:"status"`
+	Provider string `json:"provider"`
+}
+
+type oauthReauthResponse struct {
+	ReauthToken string `json:"reauth_token"`
+	ExpiresAt   string `json:"expires_at"`
+}
+
+// reauthRequest is embedded in the bodies of requests confirmed with the password or,
+// for an account without one, a reauth_token from a fresh provider sign-in.
+type reauthRequest struct {
+	Password    string `json:"password"`
+	ReauthToken string `json:"reauth_token"`
+}
+
+const (
+	reauthRequired = "password or reauth_token is required"
+	reauthRejected = "password or reauth_token is incorrect"
+)
+
+func (r reauthRequest) missing() bool { return r.Password == "" && r.ReauthToken == "" }
+
+func (r reauthRequest) proof() service.Reauthentication {
+	return service.Reauthentication{Password: r.Password, ReauthToken: r.ReauthToken}
+}
+
+type linkedIdentityResponse struct {
+	Provider string `json:"provider"`
+	Email    string `json:"email,omitempty"`
+	LinkedAt string `json:"linked_at"`
+}
+
+type linkedIdentitiesResponse struct {
+	Identities []linkedIdentityResponse `json:"identities"`
+}
+
+// --- Handlers ---
+
+// ListOAuthProviders godoc
+// GET /auth/oauth/providers
+// Lists the providers that can be used to sign in, for the login page.
+func (h *AuthHandler) ListOAuthProviders(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, oauthProvidersResponse{Providers: h.svc.IdentityProviders()})
+}
+
+// StartOAuth godoc
+// POST /auth/oauth/{provider}/start
+// Header (optional): Authorization: Bearer <access_token>
+// Returns the provider URL to send the browser to. Without a token this starts a
+// login; with one it links the provider to the caller's account. The client keeps
+// state to match the provider's redirect back.
+func (h *AuthHandler) StartOAuth(w http.ResponseWriter, r *http.Request) {
+	var linkUserID string
+	if r.Header.Get("Authorization") != "" {
+		userID, ok := h.authenticate(w, r)
+		if !ok {
+			return
+		}
+		linkUserID = userID
+	}
+
+	start, err := h.svc.StartOAuth(r.Context(), r.PathValue("provider"), linkUserID)
+	writeOAuthStart(w, start, err)
+}
+
+// StartOAuthReauth godoc
+// POST /auth/oauth/{provider}/reauth
+// Header: Authorization: Bearer <access_token>
+// Like StartOAuth, for a provider linked to the caller. Completing it returns a
+// reauth_token that stands in for the password of an account that has none, e.g.
+// to delete the account.
+func (h *AuthHandler) StartOAuthReauth(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	start, err := h.svc.StartOAuthReauth(r.Context(), r.PathValue("provider"), userID)
+	writeOAuthStart(w, start, err)
+}
+
+func writeOAuthStart(w http.ResponseWriter, start *service.OAuthStart, err error) {
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUnknownProvider), errors.Is(err, service.ErrIdentityNotLinked):
+			writeError(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, service.ErrInvalidToken), errors.Is(err, service.ErrAccountDisabled):
+			writeError(w, http.StatusUnauthorized, "invalid or expired token")
+		default:
+			writeError(w, http.StatusBadGateway, "identity provider unavailable")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, oauthStartResponse{
+		AuthorizationURL: start.URL,
+		State:            start.State,
+		ExpiresAt:        start.ExpiresAt.UTC().Format("2006-01-02T15:04:05Z"),
+	})
+}
+
+// CompleteOAuth godoc
+// POST /auth/oauth/{provider}/callback
+// Header (link and reauth only): Authorization: Bearer <access_token>
+// Body: {"state": "...", "code": "..."} — the query parameters of the provider's redirect.
+// Responds like POST /auth/login, with {"status": "linked"} when the flow was started
+// by a signed-in user to link the provider, or with {"reauth_token": "..."} when it
+// was started from /reauth. Those two require the starting user's token.
+func (h *AuthHandler) CompleteOAuth(w http.ResponseWriter, r *http.Request) {
+	var callerID string
+	if r.Header.Get("Authorization") != "" {
+		userID, ok := h.authenticate(w, r)
+		if !ok {
+			return
+		}
+		callerID = userID
+	}
+
+	var req oauthCallbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.State == "" || req.Code == "" {
+		writeError(w, http.StatusBadRequest, "state and code are required")
+		return
+	}
+
+	provider := r.PathValue("provider")
+	result, err := h.svc.CompleteOAuth(r.Context(), provider, req.State, req.Code, callerID, clientIP(r), r.UserAgent())
+	if err != nil {
+		var challenge *service.MFAChallenge
+		switch {
+		case errors.As(err, &challenge):
+			writeJSON(w, http.StatusOK, mfaChallengeResponse{
+				MFARequired: true,
+				MFAToken:    challenge.Token,
+				ExpiresAt:   challenge.ExpiresAt.UTC().Format("2006-01-02T15:04:05Z"),
+				Methods:     challenge.Methods,
+			})
+		case errors.Is(err, service.ErrUnknownProvider):
+			writeError(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, service.ErrInvalidOAuthState):
+			writeError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, service.ErrOAuthEmailUnverified), errors.Is(err, service.ErrOAuthLinkCaller):
+			writeError(w, http.StatusForbidden, err.Error())
+		case errors.Is(err, service.ErrLinkRequired), errors.Is(err, service.ErrIdentityLinked),
+			errors.Is(err, service.ErrUserAlreadyExists):
+			writeError(w, http.StatusConflict, err.Error())
+		case errors.Is(err, service.ErrOAuthFailed), errors.Is(err, service.ErrInvalidToken),
+			errors.Is(err, service.ErrAccountDisabled), errors.Is(err, service.ErrAccountLocked):
+			writeError(w, http.StatusUnauthorized, "login failed")
+		default:
+			writeError(w, http.StatusInternalServerError, "login failed")
+		}
+		return
+	}
+
+	if result.Linked {
+		writeJSON(w, http.StatusOK, oauthLinkedResponse{Status: "linked", Provider: provider})
+		return
+	}
+	if result.Reauth != nil {
+		writeJSON(w, http.StatusOK, oauthReauthResponse{
+			ReauthToken: result.Reauth.Token,
+			ExpiresAt:   result.Reauth.ExpiresAt.UTC().Format("2006-01-02T15:04:05Z"),
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, loginResponse{
+		AccessToken:  result.Tokens.AccessToken,
+		RefreshToken: result.Tokens.RefreshToken,
+		ExpiresAt:    result.Tokens.ExpiresAt.UTC().Format("2006-01-02T15:04:05Z"),
+	})
+}
+
+// ListLinkedIdentities godoc
+// GET /auth/oauth/identities
+// Header: Authorization: Bearer <access_token>
+// Lists the external accounts the caller can sign in with.
+func (h *AuthHandler) ListLinkedIdentities(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	identities, err := h.svc.ListLinkedIdentities(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidToken) || errors.Is(err, service.ErrAccountDisabled) {
+			writeError(w, http.StatusUnauthorized, "invalid or expired token")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "listing linked accounts failed")
+		return
+	}
+
+	resp := linkedIdentitiesResponse{Identities: make([]linkedIdentityResponse, 0, len(identities))}
+	for _, id := range identities {
+		resp.Identities = append(resp.Identities, linkedIdentityResponse{
+			Provider: id.Provider,
+			Email:    id.Email,
+			LinkedAt: id.LinkedAt.UTC().Format("2006-01-02T15:04:05Z"),
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// UnlinkIdentity godoc
+// DELETE /auth/oauth/identities/{provider}
+// Header: Authorization: Bearer <access_token>
+// Unlinks the caller's account of provider. Refused (409) when it is the only way
+// the caller can sign in.
+func (h *AuthHandler) UnlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.UnlinkIdentity(r.Context(), userID, r.PathValue("provider"), clientIP(r)); err != nil {
+		switch {
+		case errors.Is(err, service.ErrIdentityNotLinked):
+			writeError(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, service.ErrLastSignInMethod):
+			writeError(w, http.StatusConflict, err.Error())
+		case errors.Is(err, service.ErrInvalidToken), errors.Is(err, service.ErrAccountDisabled):
+			writeError(w, http.StatusUnauthorized, "invalid or expired token")
+		default:
+			writeError(w, http.StatusInternalServerError, "unlinking account failed")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/watup-lk/identity-service/internal/service"
+	"github.com/watup-lk/identity-service/internal/webauthn"
+)
+
+// --- Request / Response types ---
+
+// passkeyStartResponse carries the options for navigator.credentials.create() or
+// .get() in public_key; session is sent back with the browser's result.
+type passkeyStartResponse struct {
+	Session   string `json:"session"`
+	PublicKey any    `json:"public_key"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+type passkeyRegisterBeginRequest struct {
+	reauthRequest
+	Code string `json:"code"` // TOTP or recovery code, required instead when 2FA is on
+}
+
+type passkeyRegisterRequest struct {
+	Session    string                         `json:"session"`
+	Name       string                         `json:"name"`
+	Credential *webauthn.RegistrationResponse `json:"credential"`
+}
+
+type passkeyLoginRequest struct {
+	Session    string                      `json:"session"`
+	Credential *webauthn.AssertionResponse `json:"credential"`
+}
+
+type passkeyMFABeginRequest struct {
+	MFAToken string `json:"mfa_token"`
+}
+
+type passkeyMFAVerifyRequest struct {
+	MFAToken   string                      `json:"mfa_token"`
+	Session    string                      `json:"session"`
+	Credential *webauthn.AssertionResponse `json:"credential"`
+}
+
+type passkeyDeleteRequest struct {
+	reauthRequest
+}
+
+type passkeyResponse struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Transports []string `json:"transports,omitempty"`
+	CreatedAt  string   `json:"created_at"`
+	LastUsedAt string   `json:"last_used_at,omitempty"`
+}
+
+type passkeysResponse struct {
+	Passkeys []passkeyResponse `json:"passkeys"`
+}
+
+// --- Handlers ---
+
+// BeginPasskeyRegistration godoc
+// POST /auth/passkeys/register/begin
+// Header: Authorization: Bearer <access_token>
+// Body: {"password": "..."} or {"reauth_token": "..."}; {"code": "123456"} when 2FA is on
+// Returns the options to pass to navigator.credentials.create().
+func (h *AuthHandler) BeginPasskeyRegistration(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	var req passkeyRegisterBeginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.missing() && req.Code == "" {
+		writeError(w, http.StatusBadRequest, "password, reauth_token or code is required")
+		return
+	}
+
+	reg, err := h.svc.BeginPasskeyRegistration(r.Context(), userID, req.proof(), req.Code, clientIP(r))
+	if err != nil {
+		writePasskeyError(w, err, "starting passkey registration failed")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, passkeyStartResponse{
+		Session:   reg.Session,
+		PublicKey: reg.Options,
+		ExpiresAt: reg.ExpiresAt.UTC().Format("2006-01-02T15:04:05Z"),
+	})
+}
+
+// FinishPasskeyRegistration godoc
+// POST /auth/passkeys/register/finish
+// Header: Authorization: Bearer <access_token>
+// Body: {"session": "...", "name": "MacBook", "credential": <PublicKeyCredential JSON>}
+func (h *AuthHandler) FinishPasskeyRegistration(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	var req passkeyRegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Session == "" || req.Credential == nil {
+		writeError(w, http.StatusBadRequest, "session and credential are required")
+		return
+	}
+
+	passkey, err := h.svc.FinishPasskeyRegistration(r.Context(), userID, req.Session, req.Name, req.Credential, clientIP(r))
+	if err != nil {
+		writePasskeyError(w, err, "passkey registration failed")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, toPasskeyResponse(*passkey))
+}
+
+// ListPasskeys godoc
+// GET /auth/passkeys
+// Header: Authorization: Bearer <access_token>
+func (h *AuthHandler) ListPasskeys(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	passkeys, err := h.svc.ListPasskeys(r.Context(), userID)
+	if err != nil {
+		writePasskeyError(w, err, "listing passkeys failed")
+		return
+	}
+
+	resp := passkeysResponse{Passkeys: make([]passkeyResponse, 0, len(passkeys))}
+	for _, p := range passkeys {
+		resp.Passkeys = append(resp.Passkeys, toPasskeyResponse(p))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// DeletePasskey godoc
+// DELETE /auth/passkeys/{id}
+// Header: Authorization: Bearer <access_token>
+// Body: {"password": "..."} or {"reauth_token": "..."}
+func (h *AuthHandler) DeletePasskey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	var req passkeyDeleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.missing() {
+		writeError(w, http.StatusBadRequest, reauthRequired)
+		return
+	}
+
+	if err := h.svc.DeletePasskey(r.Context(), userID, r.PathValue("id"), req.proof(), clientIP(r)); err != nil {
+		writePasskeyError(w, err, "deleting passkey failed")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// BeginPasskeyLogin godoc
+// POST /auth/passkeys/login/begin
+// Returns the options to pass to navigator.credentials.get() for a passwordless
+// sign-in. No email is needed: the browser offers the user's passkeys for this site.
+func (h *AuthHandler) BeginPasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	assertion, err := h.svc.BeginPasskeyLogin(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "starting passkey login failed")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, passkeyStartResponse{
+		Session:   assertion.Session,
+		PublicKey: assertion.Options,
+		ExpiresAt: assertion.ExpiresAt.UTC().Format("2006-01-02T15:04:05Z"),
+	})
+}
+
+// FinishPasskeyLogin godoc
+// POST /auth/passkeys/login/finish
+// Body: {"session": "...", "credential": <PublicKeyCredential JSON>}
+// Responds like POST /auth/login. A passkey sign-in never asks for a second factor.
+func (h *AuthHandler) FinishPasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	var req passkeyLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Session == "" || req.Credential == nil {
+		writeError(w, http.StatusBadRequest, "session and credential are required")
+		return
+	}
+
+	pair, err := h.svc.FinishPasskeyLogin(r.Context(), req.Session, req.Credential, clientIP(r), r.UserAgent())
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidPasskeySession):
+			writeError(w, http.StatusBadRequest, err.Error())
+		// Locked and disabled accounts get the same response as an unknown passkey
+		case errors.Is(err, service.ErrInvalidPasskey), errors.Is(err, service.ErrAccountDisabled),
+			errors.Is(err, service.ErrAccountLocked):
+			writeError(w, http.StatusUnauthorized, "invalid credentials")
+		default:
+			writeError(w, http.StatusInternalServerError, "login failed")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, loginResponse{
+		AccessToken:  pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresAt:    pair.ExpiresAt.UTC().Format("2006-01-02T15:04:05Z"),
+	})
+}
+
+// BeginPasskeyMFA godoc
+// POST /auth/mfa/passkey/begin
+// Body: {"mfa_token": "..."}
+// Starts answering a login's MFA challenge with a passkey, when the challenge lists
+// "passkey" in its methods. Returns the options for navigator.credentials.get().
+func (h *AuthHandler) BeginPasskeyMFA(w http.ResponseWriter, r *http.Request) {
+	var req passkeyMFABeginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.MFAToken == "" {
+		writeError(w, http.StatusBadRequest, "mfa_token is required")
+		return
+	}
+
+	assertion, err := h.svc.BeginPasskeyMFA(r.Context(), req.MFAToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrMFANotEnrolled):
+			writeError(w, http.StatusBadRequest, "no passkey is registered")
+		case errors.Is(err, service.ErrInvalidToken):
+			writeError(w, http.StatusUnauthorized, "invalid or expired mfa_token")
+		default:
+			writeError(w, http.StatusInternalServerError, "starting passkey verification failed")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, passkeyStartResponse{
+		Session:   assertion.Session,
+		PublicKey: assertion.Options,
+		ExpiresAt: assertion.ExpiresAt.UTC().Format("2006-01-02T15:04:05Z"),
+	})
+}
+
+// VerifyMFAPasskey godoc
+// POST /auth/mfa/passkey/verify
+// Body: {"mfa_token": "...", "session": "...", "credential": <PublicKeyCredential JSON>}
+// Completes a login like POST /auth/mfa/verify, with a passkey as the second factor.
+func (h *AuthHandler) VerifyMFAPasskey(w http.ResponseWriter, r *http.Request) {
+	var req passkeyMFAVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.MFAToken == "" || req.Session == "" || req.Credential == nil {
+		writeError(w, http.StatusBadRequest, "mfa_token, session and credential are required")
+		return
+	}
+
+	pair, err := h.svc.VerifyMFAPasskey(r.Context(), req.MFAToken, req.Session, req.Credential, clientIP(r), r.UserAgent())
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidPasskeySession):
+			writeError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, service.ErrInvalidPasskey), errors.Is(err, service.ErrAccountLocked):
+			writeError(w, http.StatusUnauthorized, service.ErrInvalidPasskey.Error())
+		case errors.Is(err, service.ErrInvalidToken), errors.Is(err, service.ErrAccountDisabled):
+			writeError(w, http.StatusUnauthorized, "invalid or expired mfa_token")
+		default:
+			writeError(w, http.StatusInternalServerError, "mfa verification failed")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, loginResponse{
+		AccessToken:  pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresAt:    pair.ExpiresAt.UTC().Format("2006-01-02T15:04:05Z"),
+	})
+}
+
+// writePasskeyError maps the errors shared by the authenticated passkey endpoints.
+func writePasskeyError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, service.ErrInvalidPasskeySession), errors.Is(err, service.ErrInvalidPasskeyName),
+		errors.Is(err, service.ErrInvalidPasskey):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrPasskeyAlreadyRegistered):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrPasskeyNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrInvalidCredentials):
+		writeError(w, http.StatusForbidden, reauthRejected)
+	case errors.Is(err, service.ErrMFARequired), errors.Is(err, service.ErrInvalidMFACode):
+		writeError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrAccountLocked):
+		writeError(w, http.StatusTooManyRequests, "too many failed attempts, try again later")
+	case errors.Is(err, service.ErrInvalidToken), errors.Is(err, service.ErrAccountDisabled):
+		writeError(w, http.StatusUnauthorized, "invalid or expired token")
+	default:
+		writeError(w, http.StatusInternalServerError, fallback)
+	}
+}
+
+func toPasskeyResponse(p service.Passkey) passkeyResponse {
+	resp := passkeyResponse{
+		ID:         p.ID,
+		Name:       p.Name,
+		Transports: p.Transports,
+		CreatedAt:  p.CreatedAt.UTC().Format("2006-01-02T15:04:05Z"),
+	}
+	if p.LastUsedAt != nil {
+		resp.LastUsedAt = p.LastUsedAt.UTC().Format("2006-01-02T15:04:05Z")
+	}
+	return resp
+}
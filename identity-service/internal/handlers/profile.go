@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/watup-lk/identity-service/internal/service"
+)
+
+// --- Request / Response types ---
+
+// updateProfileRequest holds the fields to change. "age": null removes the age,
+// while leaving "age" out keeps it.
+type updateProfileRequest struct {
+	Name *string         `json:"name"`
+	Age  json.RawMessage `json:"age"`
+}
+
+// profileResponse is built field by field from service.Profile, so credentials
+// can never leak into it.
+type profileResponse struct {
+	UserID          string `json:"user_id"`
+	Name            string `json:"name"`
+	Email           string `json:"email"`
+	Age             *int   `json:"age"`
+	EmailVerified   bool   `json:"email_verified"`
+	EmailVerifiedAt string `json:"email_verified_at,omitempty"`
+	CreatedAt       string `json:"created_at"`
+	UpdatedAt       string `json:"updated_at"`
+}
+
+// --- Handlers ---
+
+// GetProfile godoc
+// GET /auth/me
+// Header: Authorization: Bearer <access_token>
+// Returns the caller's profile.
+func (h *AuthHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	p, err := h.svc.GetProfile(r.Context(), userID)
+	if err != nil {
+		writeProfileError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toProfileResponse(p))
+}
+
+// UpdateProfile godoc
+// PATCH /auth/me
+// Header: Authorization: Bearer <access_token>
+// Body: {"name": "...", "age": 30}  — both optional; "age": null removes the age
+// Name and age follow the same rules as signup. Email and password have their own endpoints.
+func (h *AuthHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	var req updateProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	var update service.ProfileUpdate
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if msg := validateName(name); msg != "" {
+			writeError(w, http.StatusBadRequest, msg)
+			return
+		}
+		update.Name = &name
+	}
+	switch {
+	case req.Age == nil: // absent: keep the current age
+	case bytes.Equal(req.Age, []byte("null")):
+		update.ClearAge = true
+	default:
+		var age int
+		if err := json.Unmarshal(req.Age, &age); err != nil {
+			writeError(w, http.StatusBadRequest, "age must be a whole number")
+			return
+		}
+		if msg := validateAge(&age); msg != "" {
+			writeError(w, http.StatusBadRequest, msg)
+			return
+		}
+		update.Age = &age
+	}
+
+	p, err := h.svc.UpdateProfile(r.Context(), userID, update, clientIP(r))
+	if err != nil {
+		writeProfileError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toProfileResponse(p))
+}
+
+func writeProfileError(w http.ResponseWriter, err error) {
+	if errors.Is(err, service.ErrInvalidToken) || errors.Is(err, service.ErrAccountDisabled) {
+		writeError(w, http.StatusUnauthorized, "invalid or expired token")
+		return
+	}
+	writeError(w, http.StatusInternalServerError, "profile request failed")
+}
+
+func toProfileResponse(p *service.Profile) profileResponse {
+	resp := profileResponse{
+		UserID:        p.ID,
+		Name:          p.Name,
+		Email:         p.Email,
+		Age:           p.Age,
+		EmailVerified: p.EmailVerifiedAt != nil,
+		CreatedAt:     p.CreatedAt.UTC().Format("2006-01-02T15:04:05Z"),
+		UpdatedAt:     p.UpdatedAt.UTC().Format("2006-01-02T15:04:05Z"),
+	}
+	if p.EmailVerifiedAt != nil {
+		resp.EmailVerifiedAt = p.EmailVerifiedAt.UTC().Format("2006-01-02T15:04:05Z")
+	}
+	return resp
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/watup-lk/identity-service/internal/service"
+)
+
+// --- Request / Response types ---
+
+type sessionResponse struct {
+	ID         string `json:"id"`
+	UserAgent  string `json:"user_agent"`
+	IPAddress  string `json:"ip_address"`
+	CreatedAt  string `json:"created_at"`
+	LastUsedAt string `json:"last_used_at"`
+	ExpiresAt  string `json:"expires_at"`
+	Current    bool   `json:"current"`
+}
+
+type sessionsResponse struct {
+	Sessions []sessionResponse `json:"sessions"`
+}
+
+// --- Handlers ---
+
+// ListSessions godoc
+// GET /auth/sessions
+// Header: Authorization: Bearer <access_token>
+// Lists the caller's active sessions; the one making the request has "current": true.
+func (h *AuthHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	userID, sessionID, ok := h.authenticateSession(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.svc.ListSessions(r.Context(), userID, sessionID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "listing sessions failed")
+		return
+	}
+
+	resp := sessionsResponse{Sessions: make([]sessionResponse, 0, len(sessions))}
+	for _, s := range sessions {
+		resp.Sessions = append(resp.Sessions, sessionResponse{
+			ID:         s.ID,
+			UserAgent:  s.UserAgent,
+			IPAddress:  s.IPAddress,
+			CreatedAt:  s.CreatedAt.UTC().Format("2006-01-02T15:04:05Z"),
+			LastUsedAt: s.LastUsedAt.UTC().Format("2006-01-02T15:04:05Z"),
+			ExpiresAt:  s.ExpiresAt.UTC().Format("2006-01-02T15:04:05Z"),
+			Current:    s.Current,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// RevokeSession godoc
+// DELETE /auth/sessions/{id}
+// Header: Authorization: Bearer <access_token>
+// Logs out a single session. Access tokens already issued to it stay valid until they expire.
+func (h *AuthHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.RevokeSession(r.Context(), userID, r.PathValue("id"), clientIP(r)); err != nil {
+		if errors.Is(err, service.ErrSessionNotFound) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "revoking session failed")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeOtherSessions godoc
+// DELETE /auth/sessions
+// Header: Authorization: Bearer <access_token>
+// Logs out every session except the one making the request.
+func (h *AuthHandler) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	userID, sessionID, ok := h.authenticateSession(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.RevokeOtherSessions(r.Context(), userID, sessionID, clientIP(r)); err != nil {
+		writeError(w, http.StatusInternalServerError, "revoking sessions failed")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -2,11 +2,16 @@ package kafka
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
 	"log"
 	"time"
 
 	"github.com/segmentio/kafka-go"
+	"google.golang.org/protobuf/proto"
+
+	eventsv1 "github.com/watup-lk/identity-service/api/proto/events/v1"
+	"github.com/watup-lk/identity-service/internal/events"
+	"github.com/watup-lk/identity-service/internal/outbox"
 )
 
 const (
@@ -14,78 +19,111 @@ const (
 	topicUserLogin      = "user.login"
 	topicUserLogout     = "user.logout"
 	topicTokenRefresh   = "user.token_refresh"
+
+	topicPasswordResetRequested = "user.password_reset_requested"
+	topicPasswordReset          = "user.password_reset"
+	topicPasswordChanged        = "user.password_changed"
+	topicSecurity               = "user.security"
+	topicEmailVerified          = "user.email_verified"
+	topicUserDeleted            = "user.deleted"
+	topicProfileUpdated         = "user.profile_updated"
 )
 
-// userEvent is the Kafka message payload for user lifecycle events.
-type userEvent struct {
-	UserID    string `json:"user_id"`
-	EventType string `json:"event_type"`
-	Timestamp string `json:"timestamp"`
-}
+// source identifies this service in the envelope of every event it publishes.
+const source = "/watup/identity-service"
 
-// Producer wraps kafka-go writers for user event topics.
+// Producer wraps kafka-go writers for user event topics. Events are only sent via
+// Publish, by the outbox relay.
 type Producer struct {
 	registeredWriter *kafka.Writer
 	loginWriter      *kafka.Writer
 	logoutWriter     *kafka.Writer
 	refreshWriter    *kafka.Writer
+	resetReqWriter   *kafka.Writer
+	resetWriter      *kafka.Writer
+	pwChangedWriter  *kafka.Writer
+	securityWriter   *kafka.Writer
+	verifiedWriter   *kafka.Writer
+	deletedWriter    *kafka.Writer
+	profileWriter    *kafka.Writer
+
+	byTopic map[string]*kafka.Writer // the writers above, for Publish
 }
 
 func NewProducer(brokers []string) *Producer {
+	// Writes wait for all in-sync replicas, so an outbox event marked published is
+	// durable. Each write is sent at once rather than waiting to fill a batch.
 	newWriter := func(topic string) *kafka.Writer {
 		return &kafka.Writer{
 			Addr:                   kafka.TCP(brokers...),
 			Topic:                  topic,
 			Balancer:               &kafka.LeastBytes{},
+			RequiredAcks:           kafka.RequireAll,
+			BatchSize:              1,
 			AllowAutoTopicCreation: true,
 		}
 	}
-	return &Producer{
+	p := &Producer{
 		registeredWriter: newWriter(topicUserRegistered),
 		loginWriter:      newWriter(topicUserLogin),
 		logoutWriter:     newWriter(topicUserLogout),
 		refreshWriter:    newWriter(topicTokenRefresh),
+		resetReqWriter:   newWriter(topicPasswordResetRequested),
+		resetWriter:      newWriter(topicPasswordReset),
+		pwChangedWriter:  newWriter(topicPasswordChanged),
+		securityWriter:   newWriter(topicSecurity),
+		verifiedWriter:   newWriter(topicEmailVerified),
+		deletedWriter:    newWriter(topicUserDeleted),
+		profileWriter:    newWriter(topicProfileUpdated),
 	}
+	p.byTopic = map[string]*kafka.Writer{
+		topicUserRegistered:         p.registeredWriter,
+		topicUserLogin:              p.loginWriter,
+		topicUserLogout:             p.logoutWriter,
+		topicTokenRefresh:           p.refreshWriter,
+		topicPasswordResetRequested: p.resetReqWriter,
+		topicPasswordReset:          p.resetWriter,
+		topicPasswordChanged:        p.pwChangedWriter,
+		topicSecurity:               p.securityWriter,
+		topicEmailVerified:          p.verifiedWriter,
+		topicUserDeleted:            p.deletedWriter,
+		topicProfileUpdated:         p.profileWriter,
+	}
+	return p
 }
 
-// PublishUserRegistered sends a user.registered event. Intended to be called in a goroutine.
-func (p *Producer) PublishUserRegistered(ctx context.Context, userID string) {
-	p.publish(ctx, p.registeredWriter, userID, "user.registered")
-}
-
-// PublishUserLogin sends a user.login event. Intended to be called in a goroutine.
-func (p *Producer) PublishUserLogin(ctx context.Context, userID string) {
-	p.publish(ctx, p.loginWriter, userID, "user.login")
-}
-
-// PublishUserLogout sends a user.logout event. Intended to be called in a goroutine.
-func (p *Producer) PublishUserLogout(ctx context.Context, userID string) {
-	p.publish(ctx, p.logoutWriter, userID, "user.logout")
-}
-
-// PublishTokenRefresh sends a user.token_refresh event. Intended to be called in a goroutine.
-func (p *Producer) PublishTokenRefresh(ctx context.Context, userID string) {
-	p.publish(ctx, p.refreshWriter, userID, "user.token_refresh")
-}
-
-func (p *Producer) publish(ctx context.Context, w *kafka.Writer, userID, eventType string) {
-	payload, err := json.Marshal(userEvent{
-		UserID:    userID,
-		EventType: eventType,
-		Timestamp: time.Now().UTC().Format(time.RFC3339),
-	})
+// Publish sends an event relayed from the outbox and returns once Kafka has
+// acknowledged it. The event id is derived from the outbox row, so a redelivered
+// event keeps it, and its time is when the event was recorded, not when it was relayed.
+func (p *Producer) Publish(ctx context.Context, ev outbox.Event) error {
+	w, ok := p.byTopic[ev.Topic]
+	if !ok {
+		return fmt.Errorf("unknown topic %q", ev.Topic)
+	}
+	msg, err := newMessage(fmt.Sprintf("outbox-%d", ev.ID), ev.Topic, ev.UserID, ev.EventType, ev.OccurredAt)
 	if err != nil {
-		log.Printf("[kafka] failed to marshal event %s: %v", eventType, err)
-		return
+		return err
 	}
+	return w.WriteMessages(ctx, msg)
+}
 
-	msg := kafka.Message{
-		Key:   []byte(userID),
-		Value: payload,
+// newMessage wraps a UserEvent in the platform's event envelope, keyed by user_id
+// so each user's events stay in order within a topic.
+func newMessage(id, topic, userID, eventType string, at time.Time) (kafka.Message, error) {
+	env, err := events.New(id, source, events.TypePrefix+topic, userID, at,
+		&eventsv1.UserEvent{UserId: userID, EventType: eventType})
+	if err != nil {
+		return kafka.Message{}, err
 	}
-	if err := w.WriteMessages(ctx, msg); err != nil {
-		log.Printf("[kafka] failed to publish %s for user %s: %v", eventType, userID, err)
+	value, err := proto.Marshal(env)
+	if err != nil {
+		return kafka.Message{}, err
 	}
+	return kafka.Message{
+		Key:     []byte(userID),
+		Value:   value,
+		Headers: []kafka.Header{{Key: "content-type", Value: []byte(events.ContentType)}},
+	}, nil
 }
 
 func (p *Producer) Close() {
@@ -101,4 +139,25 @@ func (p *Producer) Close() {
 	if err := p.refreshWriter.Close(); err != nil {
 		log.Printf("[kafka] error closing refresh writer: %v", err)
 	}
+	if err := p.resetReqWriter.Close(); err != nil {
+		log.Printf("[kafka] error closing password reset request writer: %v", err)
+	}
+	if err := p.resetWriter.Close(); err != nil {
+		log.Printf("[kafka] error closing password reset writer: %v", err)
+	}
+	if err := p.pwChangedWriter.Close(); err != nil {
+		log.Printf("[kafka] error closing password changed writer: %v", err)
+	}
+	if err := p.securityWriter.Close(); err != nil {
+		log.Printf("[kafka] error closing security writer: %v", err)
+	}
+	if err := p.verifiedWriter.Close(); err != nil {
+		log.Printf("[kafka] error closing email verified writer: %v", err)
+	}
+	if err := p.deletedWriter.Close(); err != nil {
+		log.Printf("[kafka] error closing user deleted writer: %v", err)
+	}
+	if err := p.profileWriter.Close(); err != nil {
+		log.Printf("[kafka] error closing profile updated writer: %v", err)
+	}
 }
@@ -0,0 +1,136 @@
+package keys
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Keyring holds the key used to sign new tokens plus every key still accepted for
+// verification. It is safe for concurrent use and can be rotated at runtime.
+type Keyring struct {
+	mu      sync.RWMutex
+	signing *Key
+	byID    map[string]*Key
+	ordered []*Key // signing key first, then verification keys in load order
+	retired []*Key // former signing keys, kept until their NotAfter
+}
+
+// NewKeyring creates a keyring that signs with signing and additionally accepts
+// tokens from the extra verification keys (e.g. the HS256 secret during migration).
+func NewKeyring(signing *Key, verify ...*Key) *Keyring {
+	r := &Keyring{}
+	r.set(signing, verify, time.Now())
+	return r
+}
+
+// Rotate installs a new signing key and verification set. If the signing key changed,
+// the previous one is kept for verification until retention elapses, so tokens it
+// signed stay valid until they expire. Expired keys are dropped.
+func (r *Keyring) Rotate(signing *Key, verify []*Key, retention time.Duration) (rotated bool) {
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rotated = r.signing.ID != signing.ID
+	var retired []*Key
+	if rotated {
+		retired = append(retired, r.signing.withExpiry(now.Add(retention)))
+	}
+	// Keys retired by earlier rotations are carried over until they expire
+	for _, k := range r.retired {
+		if !k.expired(now) && k.ID != signing.ID {
+			retired = append(retired, k)
+		}
+	}
+	r.retired = retired
+	r.set(signing, append(append([]*Key(nil), verify...), retired...), now)
+	return rotated
+}
+
+// set rebuilds the lookup tables. Duplicate kids keep their first occurrence.
+// Caller must hold r.mu (or own r exclusively).
+func (r *Keyring) set(signing *Key, verify []*Key, now time.Time) {
+	r.signing = signing
+	r.byID = make(map[string]*Key)
+	r.ordered = nil
+	for _, k := range append([]*Key{signing}, verify...) {
+		if k == nil || k.expired(now) {
+			continue
+		}
+		if _, dup := r.byID[k.ID]; dup {
+			continue
+		}
+		r.byID[k.ID] = k
+		r.ordered = append(r.ordered, k)
+	}
+}
+
+// SigningKeyID returns the kid of the current signing key.
+func (r *Keyring) SigningKeyID() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.signing.ID
+}
+
+// Sign signs claims with the current signing key.
+func (r *Keyring) Sign(claims jwt.Claims) (string, error) {
+	r.mu.RLock()
+	signing := r.signing
+	r.mu.RUnlock()
+	return signing.Sign(claims)
+}
+
+// Keyfunc resolves the verification key for a parsed token by its kid header,
+// and refuses tokens whose alg does not match the key (prevents alg confusion).
+// Tokens without a kid predate kid headers and are checked against the HS256 secret.
+func (r *Keyring) Keyfunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	now := time.Now()
+
+	r.mu.RLock()
+	k, ok := r.byID[kid]
+	if kid == "" {
+		k, ok = r.firstHMAC(now)
+	}
+	r.mu.RUnlock()
+
+	if !ok || k.expired(now) {
+		return nil, ErrUnknownKey
+	}
+	if t.Method.Alg() != k.Method.Alg() {
+		return nil, fmt.Errorf("token alg %s does not match key %q (%s)", t.Method.Alg(), kid, k.Method.Alg())
+	}
+	return k.verifyKey, nil
+}
+
+func (r *Keyring) firstHMAC(now time.Time) (*Key, bool) {
+	for _, k := range r.ordered {
+		if !k.IsAsymmetric() && !k.expired(now) {
+			return k, true
+		}
+	}
+	return nil, false
+}
+
+// JWKS returns the public verification keys that have not expired.
+// HMAC secrets are never included.
+func (r *Keyring) JWKS() JWKSet {
+	now := time.Now()
+	set := JWKSet{Keys: []JWK{}}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, k := range r.ordered {
+		if k.expired(now) {
+			continue
+		}
+		if jwk, ok := k.JWK(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
@@ -0,0 +1,132 @@
+package keys_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/watup-lk/identity-service/internal/config"
+	"github.com/watup-lk/identity-service/internal/keys"
+)
+
+func TestKeyring_RotateRetainsPreviousKey(t *testing.T) {
+	oldKey, _ := keys.NewKey("old", newEd25519Key(t))
+	newKey, _ := keys.NewKey("new", newEd25519Key(t))
+	ring := keys.NewKeyring(oldKey)
+
+	oldToken, _ := ring.Sign(testClaims())
+	if !ring.Rotate(newKey, nil, time.Hour) {
+		t.Fatal("Rotate() should report a changed signing key")
+	}
+	if ring.SigningKeyID() != "new" {
+		t.Errorf("expected signing kid new, got %s", ring.SigningKeyID())
+	}
+
+	if err := verify(ring, oldToken); err != nil {
+		t.Errorf("token from the previous key should still verify, got %v", err)
+	}
+	if n := len(ring.JWKS().Keys); n != 2 {
+		t.Errorf("expected both keys in JWKS during retention, got %d", n)
+	}
+
+	// Rotating again with the same key is a no-op and keeps the retired key
+	if ring.Rotate(newKey, nil, time.Hour) {
+		t.Error("Rotate() with the same key should report no change")
+	}
+	if err := verify(ring, oldToken); err != nil {
+		t.Errorf("retired key should survive a no-op reload, got %v", err)
+	}
+}
+
+func TestKeyring_RetiredKeyExpires(t *testing.T) {
+	oldKey, _ := keys.NewKey("old", newEd25519Key(t))
+	newKey, _ := keys.NewKey("new", newEd25519Key(t))
+	ring := keys.NewKeyring(oldKey)
+	oldToken, _ := ring.Sign(testClaims())
+
+	ring.Rotate(newKey, nil, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	if err := verify(ring, oldToken); !errors.Is(err, keys.ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey after retention, got %v", err)
+	}
+	if n := len(ring.JWKS().Keys); n != 1 {
+		t.Errorf("expected expired key to be dropped from JWKS, got %d keys", n)
+	}
+}
+
+func TestKeyring_HMACSecretRotation(t *testing.T) {
+	ring, err := keys.FromConfig(&config.Config{JWTSecret: "first-secret-key-at-least-32-chars!!", AccessTokenMinutes: 15})
+	if err != nil {
+		t.Fatalf("FromConfig() error: %v", err)
+	}
+	oldToken, _ := ring.Sign(testClaims())
+	oldKid := ring.SigningKeyID()
+
+	rotated, err := ring.Reload(&config.Config{JWTSecret: "second-secret-key-at-least-32-chars!", AccessTokenMinutes: 15})
+	if err != nil || !rotated {
+		t.Fatalf("Reload() = %v, %v; want rotation", rotated, err)
+	}
+	if ring.SigningKeyID() == oldKid {
+		t.Error("a new secret should get a new kid")
+	}
+	if err := verify(ring, oldToken); err != nil {
+		t.Errorf("tokens signed with the previous secret should verify during retention, got %v", err)
+	}
+}
+
+func TestKeyring_LegacyTokenWithoutKid(t *testing.T) {
+	secret := []byte("test-secret-key-at-least-32-chars!!")
+	ring := keys.NewKeyring(keys.NewHMACKey(secret))
+
+	legacy, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims()).SignedString(secret)
+	if err := verify(ring, legacy); err != nil {
+		t.Errorf("expected HS256 token without kid to verify, got %v", err)
+	}
+}
+
+func TestLoad_KeyDirectory(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, modTime time.Time) {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(pkcs8PEM(t, newEd25519Key(t))), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(path, modTime, modTime)
+	}
+	write("2026-01-01.pem", time.Now().Add(-48*time.Hour))
+	write("2026-02-01.pem", time.Now())
+	os.WriteFile(filepath.Join(dir, "README"), []byte("ignored"), 0o600)
+
+	cfg := &config.Config{JWTSigningAlg: "EdDSA", JWTKeyDir: dir, AccessTokenMinutes: 15}
+	ring, err := keys.FromConfig(cfg)
+	if err != nil {
+		t.Fatalf("FromConfig() error: %v", err)
+	}
+	if ring.SigningKeyID() != "2026-02-01" {
+		t.Errorf("expected the last key by name to sign, got %s", ring.SigningKeyID())
+	}
+	if n := len(ring.JWKS().Keys); n != 2 {
+		t.Errorf("expected previous key to remain published during retention, got %d keys", n)
+	}
+
+	// Once the current key file is older than the retention window the old key is dropped
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(dir, "2026-02-01.pem"), old, old)
+	if _, err := ring.Reload(cfg); err != nil {
+		t.Fatalf("Reload() error: %v", err)
+	}
+	if n := len(ring.JWKS().Keys); n != 1 {
+		t.Errorf("expected previous key to be dropped after retention, got %d keys", n)
+	}
+}
+
+func TestLoad_EmptyKeyDirectory(t *testing.T) {
+	if _, err := keys.FromConfig(&config.Config{JWTSigningAlg: "EdDSA", JWTKeyDir: t.TempDir()}); err == nil {
+		t.Error("expected error for an empty key directory")
+	}
+}
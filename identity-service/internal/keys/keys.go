@@ -0,0 +1,184 @@
+// Package keys manages the keys used to sign and verify access tokens.
+// Asymmetric keys (RS256, EdDSA) are published as a JWKS so other services can
+// verify tokens offline; HS256 with the shared JWT_SECRET remains available as a fallback.
+package keys
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms (values of JWT_SIGNING_ALG).
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+var (
+	ErrUnknownKey         = errors.New("unknown or expired signing key")
+	ErrUnsupportedKeyType = errors.New("unsupported private key type")
+)
+
+// Key is a single token signing/verification key.
+type Key struct {
+	ID       string // "kid" header
+	Method   jwt.SigningMethod
+	NotAfter time.Time // zero = no expiry; expired keys are dropped from the keyring
+
+	signKey   interface{} // []byte (HMAC), *rsa.PrivateKey or ed25519.PrivateKey
+	verifyKey interface{} // []byte (HMAC), *rsa.PublicKey or ed25519.PublicKey
+}
+
+// NewHMACKey wraps the shared HS256 secret. It is never published. Its kid is an
+// HMAC of a fixed label — stable across replicas, distinct per secret (so a rotated
+// secret gets a new kid), and reveals nothing about the secret itself.
+func NewHMACKey(secret []byte) *Key {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("watup-identity-service/kid"))
+	kid := "hs256-" + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))[:12]
+	return &Key{ID: kid, Method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret}
+}
+
+// ParsePrivateKeyPEM loads an RSA (PKCS#1 or PKCS#8) or Ed25519 (PKCS#8) private key.
+// If kid is empty it is derived from the RFC 7638 thumbprint of the public key, so
+// every replica computes the same kid for the same key material.
+func ParsePrivateKeyPEM(kid string, pemBytes []byte) (*Key, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("no PEM block found in private key")
+	}
+
+	var priv interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		priv, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		priv, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%w: PEM block %q", ErrUnsupportedKeyType, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key: %w", err)
+	}
+	return NewKey(kid, priv)
+}
+
+// NewKey wraps an *rsa.PrivateKey or ed25519.PrivateKey.
+func NewKey(kid string, priv interface{}) (*Key, error) {
+	k := &Key{ID: kid, signKey: priv}
+	switch p := priv.(type) {
+	case *rsa.PrivateKey:
+		if p.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("RSA key must be at least 2048 bits, got %d", p.N.BitLen())
+		}
+		k.Method = jwt.SigningMethodRS256
+		k.verifyKey = &p.PublicKey
+	case ed25519.PrivateKey:
+		k.Method = jwt.SigningMethodEdDSA
+		k.verifyKey = p.Public()
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKeyType, priv)
+	}
+	if k.ID == "" {
+		k.ID = k.thumbprint()
+	}
+	return k, nil
+}
+
+// expired reports whether the key is past its NotAfter.
+func (k *Key) expired(now time.Time) bool {
+	return !k.NotAfter.IsZero() && now.After(k.NotAfter)
+}
+
+// withExpiry returns a copy of the key that expires at notAfter.
+func (k *Key) withExpiry(notAfter time.Time) *Key {
+	c := *k
+	c.NotAfter = notAfter
+	return &c
+}
+
+// IsAsymmetric reports whether the key can be published in a JWKS.
+func (k *Key) IsAsymmetric() bool {
+	_, isHMAC := k.Method.(*jwt.SigningMethodHMAC)
+	return !isHMAC
+}
+
+// Sign creates a signed JWT carrying the key's kid header.
+func (k *Key) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.Method, claims)
+	if k.ID != "" {
+		token.Header["kid"] = k.ID
+	}
+	return token.SignedString(k.signKey)
+}
+
+// JWK is a public key in RFC 7517 JSON Web Key format.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`   // RSA modulus
+	E   string `json:"e,omitempty"`   // RSA exponent
+	Crv string `json:"crv,omitempty"` // OKP curve
+	X   string `json:"x,omitempty"`   // OKP public key
+}
+
+// JWKSet is the document served at /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK returns the public half of an asymmetric key. ok is false for HMAC keys.
+func (k *Key) JWK() (jwk JWK, ok bool) {
+	b64 := base64.RawURLEncoding.EncodeToString
+	switch pub := k.verifyKey.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA", Kid: k.ID, Use: "sig", Alg: k.Method.Alg(),
+			N: b64(pub.N.Bytes()), E: b64(big.NewInt(int64(pub.E)).Bytes()),
+		}, true
+	case ed25519.PublicKey:
+		return JWK{Kty: "OKP", Kid: k.ID, Use: "sig", Alg: k.Method.Alg(), Crv: "Ed25519", X: b64(pub)}, true
+	default:
+		return JWK{}, false
+	}
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint (base64url SHA-256 of the
+// required members in lexicographic order).
+func (k *Key) thumbprint() string {
+	jwk, _ := k.JWK()
+	var members interface{}
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	default:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+	b, _ := json.Marshal(members)
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,188 @@
+package keys_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/watup-lk/identity-service/internal/config"
+	"github.com/watup-lk/identity-service/internal/keys"
+)
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating RSA key: %v", err)
+	}
+	return k
+}
+
+func newEd25519Key(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, k, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating Ed25519 key: %v", err)
+	}
+	return k
+}
+
+func pkcs8PEM(t *testing.T, priv interface{}) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatalf("marshalling key: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+func testClaims() jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{Subject: "user-1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))}
+}
+
+func verify(r *keys.Keyring, token string) error {
+	_, err := jwt.ParseWithClaims(token, &jwt.RegisteredClaims{}, r.Keyfunc)
+	return err
+}
+
+func TestKeyring_SignVerify(t *testing.T) {
+	rsaKey, err := keys.NewKey("", newRSAKey(t))
+	if err != nil {
+		t.Fatalf("NewKey(RSA) error: %v", err)
+	}
+	edKey, err := keys.NewKey("ed-1", newEd25519Key(t))
+	if err != nil {
+		t.Fatalf("NewKey(Ed25519) error: %v", err)
+	}
+
+	for _, k := range []*keys.Key{rsaKey, edKey, keys.NewHMACKey([]byte("test-secret-key-at-least-32-chars!!"))} {
+		t.Run(k.Method.Alg(), func(t *testing.T) {
+			ring := keys.NewKeyring(k)
+			token, err := ring.Sign(testClaims())
+			if err != nil {
+				t.Fatalf("Sign() error: %v", err)
+			}
+			if err := verify(ring, token); err != nil {
+				t.Errorf("verify() error: %v", err)
+			}
+		})
+	}
+}
+
+func TestKeyring_KidHeaderAndThumbprint(t *testing.T) {
+	priv := newRSAKey(t)
+	a, _ := keys.NewKey("", priv)
+	b, _ := keys.NewKey("", priv)
+	if a.ID == "" || a.ID != b.ID {
+		t.Errorf("expected stable thumbprint kid, got %q and %q", a.ID, b.ID)
+	}
+
+	token, _ := keys.NewKeyring(a).Sign(testClaims())
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &jwt.RegisteredClaims{})
+	if err != nil {
+		t.Fatalf("ParseUnverified() error: %v", err)
+	}
+	if parsed.Header["kid"] != a.ID {
+		t.Errorf("expected kid header %q, got %v", a.ID, parsed.Header["kid"])
+	}
+}
+
+func TestKeyring_RejectsUnknownKey(t *testing.T) {
+	signer, _ := keys.NewKey("old", newEd25519Key(t))
+	other, _ := keys.NewKey("new", newEd25519Key(t))
+
+	token, _ := keys.NewKeyring(signer).Sign(testClaims())
+	if err := verify(keys.NewKeyring(other), token); !errors.Is(err, keys.ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey, got %v", err)
+	}
+}
+
+func TestKeyring_RejectsAlgConfusion(t *testing.T) {
+	rsaKey, _ := keys.NewKey("rsa-1", newRSAKey(t))
+	ring := keys.NewKeyring(rsaKey)
+
+	// Forge an HS256 token with the same kid — must not be verified with the RSA key
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+	forged.Header["kid"] = "rsa-1"
+	token, _ := forged.SignedString([]byte("attacker"))
+	if err := verify(ring, token); err == nil {
+		t.Error("expected HS256 token with an RSA kid to be rejected")
+	}
+}
+
+func TestKeyring_JWKSExcludesHMAC(t *testing.T) {
+	rsaKey, _ := keys.NewKey("rsa-1", newRSAKey(t))
+	edKey, _ := keys.NewKey("ed-1", newEd25519Key(t))
+	ring := keys.NewKeyring(rsaKey, edKey, keys.NewHMACKey([]byte("secret")))
+
+	set := ring.JWKS()
+	if len(set.Keys) != 2 {
+		t.Fatalf("expected 2 public keys, got %d", len(set.Keys))
+	}
+	if set.Keys[0].Kty != "RSA" || set.Keys[0].N == "" || set.Keys[0].E != "AQAB" {
+		t.Errorf("unexpected RSA JWK: %+v", set.Keys[0])
+	}
+	if set.Keys[1].Kty != "OKP" || set.Keys[1].Crv != "Ed25519" || set.Keys[1].X == "" {
+		t.Errorf("unexpected Ed25519 JWK: %+v", set.Keys[1])
+	}
+
+	if got := keys.NewKeyring(keys.NewHMACKey([]byte("secret"))).JWKS(); len(got.Keys) != 0 {
+		t.Errorf("HS256-only keyring must publish no keys, got %d", len(got.Keys))
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	edPEM := pkcs8PEM(t, newEd25519Key(t))
+
+	t.Run("HS256 default", func(t *testing.T) {
+		if _, err := keys.FromConfig(&config.Config{JWTSecret: "s"}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("HS256 without secret", func(t *testing.T) {
+		if _, err := keys.FromConfig(&config.Config{JWTSigningAlg: "HS256"}); err == nil {
+			t.Error("expected error")
+		}
+	})
+	t.Run("EdDSA keeps HS256 for verification", func(t *testing.T) {
+		secret := "test-secret-key-at-least-32-chars!!"
+		ring, err := keys.FromConfig(&config.Config{JWTSigningAlg: "EdDSA", JWTPrivateKey: edPEM, JWTSecret: secret})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		legacy, _ := keys.NewKeyring(keys.NewHMACKey([]byte(secret))).Sign(testClaims())
+		if err := verify(ring, legacy); err != nil {
+			t.Errorf("expected legacy HS256 token to verify, got %v", err)
+		}
+	})
+	t.Run("alg mismatch", func(t *testing.T) {
+		if _, err := keys.FromConfig(&config.Config{JWTSigningAlg: "RS256", JWTPrivateKey: edPEM}); err == nil {
+			t.Error("expected error for Ed25519 key with RS256")
+		}
+	})
+	t.Run("missing key", func(t *testing.T) {
+		if _, err := keys.FromConfig(&config.Config{JWTSigningAlg: "RS256"}); err == nil {
+			t.Error("expected error")
+		}
+	})
+	t.Run("unknown alg", func(t *testing.T) {
+		if _, err := keys.FromConfig(&config.Config{JWTSigningAlg: "none", JWTSecret: "s"}); err == nil {
+			t.Error("expected error")
+		}
+	})
+}
+
+func TestParsePrivateKeyPEM_RejectsSmallRSA(t *testing.T) {
+	small, _ := rsa.GenerateKey(rand.Reader, 1024)
+	pemStr := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(small)}))
+	if _, err := keys.ParsePrivateKeyPEM("", []byte(pemStr)); err == nil {
+		t.Error("expected error for 1024-bit RSA key")
+	}
+}
@@ -0,0 +1,140 @@
+package keys
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/watup-lk/identity-service/internal/config"
+)
+
+// retentionSkew is added to the access token lifetime when deciding how long a
+// retired key stays valid, to absorb clock skew between replicas.
+const retentionSkew = 5 * time.Minute
+
+// Retention is how long a retired signing key is still accepted for verification:
+// long enough for every token it signed to expire.
+func Retention(cfg *config.Config) time.Duration {
+	return time.Duration(cfg.AccessTokenMinutes)*time.Minute + retentionSkew
+}
+
+// FromConfig builds the keyring selected by JWT_SIGNING_ALG.
+func FromConfig(cfg *config.Config) (*Keyring, error) {
+	signing, verify, err := Load(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeyring(signing, verify...), nil
+}
+
+// Reload re-reads the key material from cfg and rotates the keyring. The previous
+// signing key is retained for Retention(cfg). Returns whether the signing key changed.
+func (r *Keyring) Reload(cfg *config.Config) (bool, error) {
+	signing, verify, err := Load(cfg)
+	if err != nil {
+		return false, err
+	}
+	return r.Rotate(signing, verify, Retention(cfg)), nil
+}
+
+// Load reads the signing key and any additional verification keys.
+//
+//   - HS256: the JWT_SECRET is the signing key.
+//   - RS256/EdDSA: the signing key comes from JWT_KEY_DIR, JWT_PRIVATE_KEY or
+//     JWT_PRIVATE_KEY_FILE (in that order of precedence). JWT_SECRET, if still set,
+//     is kept as a verification-only key so tokens issued before the switch stay
+//     valid until they expire.
+func Load(cfg *config.Config) (signing *Key, verify []*Key, err error) {
+	var hmacKey *Key
+	if cfg.JWTSecret != "" {
+		hmacKey = NewHMACKey([]byte(cfg.JWTSecret))
+	}
+
+	switch cfg.JWTSigningAlg {
+	case "", AlgHS256:
+		if hmacKey == nil {
+			return nil, nil, errors.New("JWT_SECRET is required for HS256")
+		}
+		return hmacKey, nil, nil
+	case AlgRS256, AlgEdDSA:
+	default:
+		return nil, nil, fmt.Errorf("unsupported JWT_SIGNING_ALG %q (use HS256, RS256 or EdDSA)", cfg.JWTSigningAlg)
+	}
+
+	if cfg.JWTKeyDir != "" {
+		signing, verify, err = loadDir(cfg.JWTKeyDir, Retention(cfg))
+	} else {
+		signing, err = loadSingle(cfg)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if signing.Method.Alg() != cfg.JWTSigningAlg {
+		return nil, nil, fmt.Errorf("JWT_SIGNING_ALG is %s but the private key is for %s", cfg.JWTSigningAlg, signing.Method.Alg())
+	}
+	if hmacKey != nil {
+		verify = append(verify, hmacKey)
+	}
+	return signing, verify, nil
+}
+
+func loadSingle(cfg *config.Config) (*Key, error) {
+	pemBytes := []byte(cfg.JWTPrivateKey)
+	if len(pemBytes) == 0 && cfg.JWTPrivateKeyFile != "" {
+		b, err := os.ReadFile(cfg.JWTPrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading JWT_PRIVATE_KEY_FILE: %w", err)
+		}
+		pemBytes = b
+	}
+	if len(pemBytes) == 0 {
+		return nil, fmt.Errorf("JWT_PRIVATE_KEY, JWT_PRIVATE_KEY_FILE or JWT_KEY_DIR is required for %s", cfg.JWTSigningAlg)
+	}
+	return ParsePrivateKeyPEM(cfg.JWTKeyID, pemBytes)
+}
+
+// loadDir reads every *.pem file in dir; the file name (without extension) is the kid.
+// Names are sorted and the last one signs, so date-prefixed names such as
+// "2026-10-01.pem" rotate naturally when a newer file is added. Older keys remain
+// valid for verification until retention after the signing key file was written,
+// and are ignored after that even if the file is still present.
+func loadDir(dir string, retention time.Duration) (*Key, []*Key, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(paths) == 0 {
+		return nil, nil, fmt.Errorf("no *.pem keys found in JWT_KEY_DIR %s", dir)
+	}
+	sort.Strings(paths)
+
+	current := paths[len(paths)-1]
+	info, err := os.Stat(current)
+	if err != nil {
+		return nil, nil, err
+	}
+	notAfter := info.ModTime().Add(retention)
+
+	var signing *Key
+	var verify []*Key
+	for _, p := range paths {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading %s: %w", p, err)
+		}
+		k, err := ParsePrivateKeyPEM(strings.TrimSuffix(filepath.Base(p), ".pem"), b)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", p, err)
+		}
+		if p == current {
+			signing = k
+		} else {
+			verify = append(verify, k.withExpiry(notAfter))
+		}
+	}
+	return signing, verify, nil
+}
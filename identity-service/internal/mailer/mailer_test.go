@@ -0,0 +1,136 @@
+package mailer_test
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/watup-lk/identity-service/internal/mailer"
+)
+
+func TestFileMailer_WritesMessage(t *testing.T) {
+	dir := t.TempDir()
+	m, err := mailer.NewFileMailer(dir, "no-reply@watup.lk")
+	if err != nil {
+		t.Fatalf("NewFileMailer() error: %v", err)
+	}
+
+	err = m.Send(context.Background(), mailer.Message{
+		To: "alice@example.com", Subject: "Hello", Body: "link: https://watup.lk/x",
+	})
+	if err != nil {
+		t.Fatalf("Send() error: %v", err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 file in outbox, got %d", len(entries))
+	}
+	raw, _ := os.ReadFile(dir + "/" + entries[0].Name())
+	content := string(raw)
+	for _, want := range []string{"From: no-reply@watup.lk", "To: alice@example.com", "Subject: Hello", "link: https://watup.lk/x"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("expected message to contain %q, got:\n%s", want, content)
+		}
+	}
+}
+
+func TestLogMailer_Send(t *testing.T) {
+	if err := mailer.NewLogMailer().Send(context.Background(), mailer.Message{To: "a@b.lk"}); err != nil {
+		t.Errorf("LogMailer.Send() error: %v", err)
+	}
+}
+
+// fakeRelay accepts one SMTP session without STARTTLS or auth and returns the
+// commands and message data it received.
+func fakeRelay(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	got := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var session strings.Builder
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		reply("220 relay ready")
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				break
+			}
+			session.WriteString(line)
+			switch {
+			case inData && line == ".\r\n":
+				inData = false
+				reply("250 queued")
+			case inData:
+			case strings.HasPrefix(line, "DATA"):
+				inData = true
+				reply("354 go ahead")
+			case strings.HasPrefix(line, "QUIT"):
+				reply("221 bye")
+				got <- session.String()
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+		got <- session.String()
+	}()
+	return ln.Addr().String(), got
+}
+
+func TestSMTPMailer_Send(t *testing.T) {
+	addr, got := fakeRelay(t)
+	m := mailer.NewSMTPMailer(addr, "no-reply@watup.lk", "", "")
+
+	err := m.Send(context.Background(), mailer.Message{To: "alice@example.com", Subject: "Hello", Body: "hi"})
+	if err != nil {
+		t.Fatalf("Send() error: %v", err)
+	}
+	session := <-got
+	for _, want := range []string{"MAIL FROM:<no-reply@watup.lk>", "RCPT TO:<alice@example.com>", "Subject: Hello"} {
+		if !strings.Contains(session, want) {
+			t.Errorf("expected the session to contain %q, got:\n%s", want, session)
+		}
+	}
+}
+
+func TestSMTPMailer_SendStopsWithContext(t *testing.T) {
+	// A relay that accepts the connection but never greets
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			defer conn.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = mailer.NewSMTPMailer(ln.Addr().String(), "no-reply@watup.lk", "", "").Send(ctx, mailer.Message{To: "alice@example.com"})
+	if err == nil {
+		t.Fatal("expected an error from a stalled relay")
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("expected Send to give up when the context ended, took %v", time.Since(start))
+	}
+}
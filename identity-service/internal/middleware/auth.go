@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/watup-lk/identity-service/internal/roles"
+)
+
+// ── Role-Based Access Control ─────────────────────────────────────────────────
+
+// RoleValidator verifies a bearer access token and returns its user_id and roles.
+// *service.IdentityService implements it.
+type RoleValidator interface {
+	ValidateAccessTokenRoles(ctx context.Context, token string) (userID string, roles []string, err error)
+}
+
+type principalKey struct{}
+
+type principal struct {
+	userID string
+	roles  []string
+}
+
+// RequireRole returns middleware that only lets requests through when the bearer
+// token grants role (admin implies moderator implies user). It responds 401 for a
+// missing or invalid token and 403 when the role is insufficient. Handlers behind it
+// read the caller with UserIDFromContext / RolesFromContext.
+func RequireRole(v RoleValidator, role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || token == "" {
+				http.Error(w, `{"error":"missing or malformed Authorization header"}`, http.StatusUnauthorized)
+				return
+			}
+			userID, granted, err := v.ValidateAccessTokenRoles(r.Context(), token)
+			if err != nil {
+				http.Error(w, `{"error":"invalid or expired token"}`, http.StatusUnauthorized)
+				return
+			}
+			if !roles.Has(granted, role) {
+				http.Error(w, `{"error":"insufficient role"}`, http.StatusForbidden)
+				return
+			}
+			ctx := context.WithValue(r.Context(), principalKey{}, principal{userID: userID, roles: granted})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// UserIDFromContext returns the user authenticated by RequireRole.
+func UserIDFromContext(ctx context.Context) (string, bool) {
+	p, ok := ctx.Value(principalKey{}).(principal)
+	return p.userID, ok
+}
+
+// RolesFromContext returns the roles of the user authenticated by RequireRole.
+func RolesFromContext(ctx context.Context) []string {
+	p, _ := ctx.Value(principalKey{}).(principal)
+	return p.roles
+}
@@ -3,6 +3,7 @@ package middleware
 import (
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -63,9 +64,31 @@ func Metrics(next http.Handler) http.Handler {
 func normalisePath(p string) string {
 	switch p {
 	case "/auth/signup", "/auth/login", "/auth/refresh", "/auth/logout", "/auth/validate",
-		"/health/live", "/health/ready", "/metrics":
+		"/auth/password/forgot", "/auth/password/reset", "/auth/password/change",
+		"/auth/mfa/verify", "/auth/mfa/totp/enroll", "/auth/mfa/totp/confirm", "/auth/mfa/totp/disable",
+		"/auth/mfa/recovery-codes", "/auth/email/verify", "/auth/email/resend", "/auth/sessions", "/auth/account",
+		"/auth/account/export", "/auth/account/export/status", "/auth/me", "/auth/email/change", "/auth/email/change/confirm",
+		"/auth/email/change/cancel", "/auth/oauth/providers", "/auth/oauth/identities",
+		"/auth/passkeys", "/auth/passkeys/register/begin", "/auth/passkeys/register/finish",
+		"/auth/passkeys/login/begin", "/auth/passkeys/login/finish", "/auth/mfa/passkey/begin",
+		"/auth/mfa/passkey/verify",
+		"/health/live", "/health/ready", "/metrics", "/.well-known/jwks.json":
 		return p
-	default:
-		return "/other"
 	}
+	// Collapse per-session paths so session ids do not become label values
+	if strings.HasPrefix(p, "/auth/sessions/") {
+		return "/auth/sessions/{id}"
+	}
+	if strings.HasPrefix(p, "/auth/passkeys/") {
+		return "/auth/passkeys/{id}"
+	}
+	if strings.HasPrefix(p, "/auth/oauth/identities/") {
+		return "/auth/oauth/identities/{provider}"
+	}
+	if rest, ok := strings.CutPrefix(p, "/auth/oauth/"); ok {
+		if _, step, ok := strings.Cut(rest, "/"); ok && (step == "start" || step == "reauth" || step == "callback") {
+			return "/auth/oauth/{provider}/" + step
+		}
+	}
+	return "/other"
 }
@@ -1,6 +1,8 @@
 package middleware_test
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -182,3 +184,53 @@ func TestMetrics_PassesThrough(t *testing.T) {
 		t.Errorf("expected 200, got %d", rr.Code)
 	}
 }
+
+// ── RequireRole Tests ────────────────────────────────────────────────────────
+
+type stubValidator map[string][]string // token -> roles
+
+func (v stubValidator) ValidateAccessTokenRoles(_ context.Context, token string) (string, []string, error) {
+	granted, ok := v[token]
+	if !ok {
+		return "", nil, errors.New("invalid token")
+	}
+	return "user-" + token, granted, nil
+}
+
+func TestRequireRole(t *testing.T) {
+	v := stubValidator{
+		"plain": {"user"},
+		"mod":   {"user", "moderator"},
+		"admin": {"user", "admin"},
+	}
+	var gotUser string
+	handler := middleware.RequireRole(v, "moderator")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUser, _ = middleware.UserIDFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		auth string
+		want int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer bogus", http.StatusUnauthorized},
+		{"Bearer plain", http.StatusForbidden},
+		{"Bearer mod", http.StatusOK},
+		{"Bearer admin", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("Authorization %q: expected %d, got %d", tt.auth, tt.want, rr.Code)
+		}
+	}
+	if gotUser != "user-admin" {
+		t.Errorf("expected user id in context, got %q", gotUser)
+	}
+}
@@ -0,0 +1,59 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// githubAPI returns the REST API root for a GitHub web URL: api.github.com for
+// github.com, <host>/api/v3 for GitHub Enterprise Server.
+func githubAPI(webURL string) string {
+	if u, err := url.Parse(webURL); err == nil && u.Host == "github.com" {
+		return "https://api.github.com"
+	}
+	return webURL + "/api/v3"
+}
+
+type githubUser struct {
+	ID    int64  `json:"id"`
+	Login string `json:"login"`
+	Name  string `json:"name"`
+}
+
+type githubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+// githubIdentity reads the signed-in user and their primary email. The subject is
+// the numeric user id, which survives renames of the login.
+func (p *Provider) githubIdentity(ctx context.Context, meta *metadata, accessToken string) (*Identity, error) {
+	api := strings.TrimSuffix(meta.UserinfoEndpoint, "/")
+
+	var user githubUser
+	if err := p.getJSON(ctx, api+"/user", accessToken, &user); err != nil {
+		return nil, fmt.Errorf("oauth provider %s: reading user: %w", p.cfg.Name, err)
+	}
+	if user.ID == 0 {
+		return nil, fmt.Errorf("%w: %s: user has no id", ErrExchange, p.cfg.Name)
+	}
+	var emails []githubEmail
+	if err := p.getJSON(ctx, api+"/user/emails", accessToken, &emails); err != nil {
+		return nil, fmt.Errorf("oauth provider %s: reading emails: %w", p.cfg.Name, err)
+	}
+
+	id := &Identity{Subject: strconv.FormatInt(user.ID, 10), Name: user.Name}
+	if id.Name == "" {
+		id.Name = user.Login
+	}
+	for _, e := range emails {
+		if e.Primary {
+			id.Email, id.EmailVerified = e.Email, e.Verified
+		}
+	}
+	return id, nil
+}
@@ -0,0 +1,283 @@
+// Package oauth signs users in through external identity providers with the OAuth 2.0
+// authorization code flow and PKCE (RFC 7636).
+//
+// OpenID Connect providers (Google, LinkedIn, or a local mock IdP in tests) are
+// configured by issuer alone: their endpoints and signing keys come from the
+// discovery document, fetched on first use, and the ID token is verified against
+// the provider's JWKS. GitHub does not implement OpenID Connect, so it is handled
+// by a small adapter that reads the user and their verified emails from its REST API.
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GitHub is the provider name handled by the GitHub adapter instead of OIDC.
+const GitHub = "github"
+
+// DefaultScopes are requested from OIDC providers when none are configured.
+var DefaultScopes = []string{"openid", "email", "profile"}
+
+var githubScopes = []string{"read:user", "user:email"}
+
+// ErrExchange is returned when the provider rejects the authorization code or
+// returns an identity that cannot be trusted.
+var ErrExchange = errors.New("identity provider rejected the login")
+
+// Config describes one provider.
+type Config struct {
+	Name         string // lower-case identifier used in URLs, e.g. "google"
+	Issuer       string // OIDC issuer URL; for GitHub, the web URL (https://github.com or an Enterprise host)
+	ClientID     string
+	ClientSecret string
+	Scopes       []string // empty = DefaultScopes (OIDC) or read:user + user:email (GitHub)
+	RedirectURL  string   // where the provider sends the browser back with the code
+}
+
+// Identity is the user as reported by the provider.
+type Identity struct {
+	Subject       string // stable provider user id; never reused
+	Email         string
+	EmailVerified bool // the provider vouches that the user controls Email
+	Name          string
+}
+
+// Provider runs the code flow against one identity provider. It is safe for
+// concurrent use.
+type Provider struct {
+	cfg    Config
+	client *http.Client
+
+	mu   sync.Mutex
+	meta *metadata // nil until discovered
+	keys *keySet
+}
+
+// metadata is the subset of the OIDC discovery document that is used.
+type metadata struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	UserinfoEndpoint      string   `json:"userinfo_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	TokenAuthMethods      []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+// New returns a provider. Nothing is fetched until the first login, so an
+// unreachable provider does not stop the service from starting. A nil client
+// uses one with a 10 second timeout.
+func New(cfg Config, client *http.Client) (*Provider, error) {
+	if cfg.Name == "" || cfg.Issuer == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, fmt.Errorf("oauth provider %q: name, issuer, client id and redirect URL are required", cfg.Name)
+	}
+	if _, err := url.Parse(cfg.Issuer); err != nil {
+		return nil, fmt.Errorf("oauth provider %s: issuer: %w", cfg.Name, err)
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = DefaultScopes
+		if cfg.Name == GitHub {
+			cfg.Scopes = githubScopes
+		}
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Provider{cfg: cfg, client: client}, nil
+}
+
+func (p *Provider) Name() string { return p.cfg.Name }
+
+// AuthCodeURL returns the provider's authorization URL for a login carrying the
+// given state, nonce and PKCE challenge.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {state},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+	if p.cfg.Name != GitHub {
+		q.Set("nonce", nonce)
+	}
+	sep := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return meta.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange redeems the authorization code and returns the verified identity. For
+// OIDC providers the ID token must carry the nonce sent in AuthCodeURL.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Identity, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tok, err := p.redeem(ctx, meta, code, codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+	if p.cfg.Name == GitHub {
+		return p.githubIdentity(ctx, meta, tok.AccessToken)
+	}
+
+	id, err := p.verifyIDToken(ctx, meta, tok.IDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+	// Some providers leave the email out of the ID token and only serve it from userinfo
+	if id.Email == "" && meta.UserinfoEndpoint != "" {
+		var info idClaims
+		if err := p.getJSON(ctx, meta.UserinfoEndpoint, tok.AccessToken, &info); err != nil {
+			return nil, err
+		}
+		if info.Subject == id.Subject {
+			id.Email, id.EmailVerified = info.Email, bool(info.EmailVerified)
+			if id.Name == "" {
+				id.Name = info.displayName()
+			}
+		}
+	}
+	return id, nil
+}
+
+// discover loads the provider's endpoints, once.
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.meta != nil {
+		return p.meta, nil
+	}
+
+	issuer := strings.TrimSuffix(p.cfg.Issuer, "/")
+	var meta metadata
+	if p.cfg.Name == GitHub {
+		meta = metadata{
+			Issuer:                issuer,
+			AuthorizationEndpoint: issuer + "/login/oauth/authorize",
+			TokenEndpoint:         issuer + "/login/oauth/access_token",
+			UserinfoEndpoint:      githubAPI(issuer),
+			TokenAuthMethods:      []string{"client_secret_post"},
+		}
+	} else {
+		if err := p.getJSON(ctx, issuer+"/.well-known/openid-configuration", "", &meta); err != nil {
+			return nil, fmt.Errorf("oauth provider %s: discovery: %w", p.cfg.Name, err)
+		}
+		// OIDC Discovery 1.0 §4.3: the document must be for the configured issuer
+		if strings.TrimSuffix(meta.Issuer, "/") != issuer {
+			return nil, fmt.Errorf("oauth provider %s: discovery returned issuer %q", p.cfg.Name, meta.Issuer)
+		}
+		if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+			return nil, fmt.Errorf("oauth provider %s: discovery document is missing endpoints", p.cfg.Name)
+		}
+		p.keys = newKeySet(p.client, meta.JWKSURI)
+	}
+	p.meta = &meta
+	return p.meta, nil
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// redeem calls the token endpoint, authenticating with the client secret.
+func (p *Provider) redeem(ctx context.Context, meta *metadata, code, codeVerifier string) (*tokenResponse, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	// client_secret_basic is the default when the provider does not say (RFC 8414 §2)
+	post := slices.Contains(meta.TokenAuthMethods, "client_secret_post") &&
+		!slices.Contains(meta.TokenAuthMethods, "client_secret_basic")
+	if post {
+		form.Set("client_id", p.cfg.ClientID)
+		form.Set("client_secret", p.cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if !post {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oauth provider %s: token request: %w", p.cfg.Name, err)
+	}
+	defer resp.Body.Close()
+
+	var tok tokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tok); err != nil {
+		return nil, fmt.Errorf("oauth provider %s: token response: %w", p.cfg.Name, err)
+	}
+	// GitHub reports errors with status 200, so check the body as well
+	if resp.StatusCode != http.StatusOK || tok.Error != "" || tok.AccessToken == "" {
+		return nil, fmt.Errorf("%w: %s: %s %s", ErrExchange, p.cfg.Name, tok.Error, tok.ErrorDescription)
+	}
+	return &tok, nil
+}
+
+// getJSON fetches url, with a bearer token when one is given, and decodes the body into v.
+func (p *Provider) getJSON(ctx context.Context, url, accessToken string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// NewCodeVerifier returns a random PKCE code verifier (43 characters).
+func NewCodeVerifier() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic("crypto/rand unavailable: " + err.Error())
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// CodeChallenge derives the S256 PKCE challenge sent with the authorization request.
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,203 @@
+package oauth_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/watup-lk/identity-service/internal/oauth"
+)
+
+// mockIdP is a minimal OpenID Connect provider: discovery, JWKS, an authorization
+// code issued up front and a token endpoint that checks PKCE and the client secret.
+type mockIdP struct {
+	srv       *httptest.Server
+	key       *rsa.PrivateKey
+	code      string
+	challenge string // PKCE challenge the code was issued for
+	nonce     string
+	claims    jwt.MapClaims
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockIdP{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                 m.srv.URL,
+			"authorization_endpoint": m.srv.URL + "/authorize",
+			"token_endpoint":         m.srv.URL + "/token",
+			"jwks_uri":               m.srv.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, _ *http.Request) {
+		b64 := base64.RawURLEncoding.EncodeToString
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "idp-1", "use": "sig", "alg": "RS256",
+			"n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != "client-1" || secret != "s3cret" || r.FormValue("code") != m.code ||
+			oauth.CodeChallenge(r.FormValue("code_verifier")) != m.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		claims := jwt.MapClaims{
+			"iss": m.srv.URL, "aud": "client-1", "sub": "idp-user-42", "nonce": m.nonce,
+			"exp": time.Now().Add(time.Hour).Unix(), "iat": time.Now().Unix(),
+		}
+		for k, v := range m.claims {
+			claims[k] = v
+		}
+		tok := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		tok.Header["kid"] = "idp-1"
+		signed, _ := tok.SignedString(key)
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "at-1", "id_token": signed, "token_type": "Bearer"})
+	})
+	m.srv = httptest.NewServer(mux)
+	t.Cleanup(m.srv.Close)
+	return m
+}
+
+// authorize plays the browser leg: the user approves and the IdP issues a code bound
+// to the PKCE challenge and nonce from the authorization URL.
+func (m *mockIdP) authorize(t *testing.T, authURL string) {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil || !strings.HasPrefix(authURL, m.srv.URL+"/authorize?") {
+		t.Fatalf("unexpected authorization URL %q", authURL)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("client_id") != "client-1" || q.Get("scope") != "openid email profile" {
+		t.Fatalf("unexpected authorization parameters: %v", q)
+	}
+	m.code, m.challenge, m.nonce = "code-1", q.Get("code_challenge"), q.Get("nonce")
+}
+
+func TestOIDCProvider_CodeFlow(t *testing.T) {
+	idp := newMockIdP(t)
+	idp.claims = jwt.MapClaims{"email": "Amaya@Example.lk", "email_verified": "true", "given_name": "Amaya", "family_name": "Silva"}
+	p, err := oauth.New(oauth.Config{
+		Name: "mock", Issuer: idp.srv.URL, ClientID: "client-1", ClientSecret: "s3cret",
+		RedirectURL: "http://localhost:3000/oauth/callback/mock",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	verifier := oauth.NewCodeVerifier()
+	authURL, err := p.AuthCodeURL(ctx, "state-1", "nonce-1", oauth.CodeChallenge(verifier))
+	if err != nil {
+		t.Fatalf("AuthCodeURL() error: %v", err)
+	}
+	idp.authorize(t, authURL)
+
+	id, err := p.Exchange(ctx, "code-1", verifier, "nonce-1")
+	if err != nil {
+		t.Fatalf("Exchange() error: %v", err)
+	}
+	want := oauth.Identity{Subject: "idp-user-42", Email: "Amaya@Example.lk", EmailVerified: true, Name: "Amaya Silva"}
+	if *id != want {
+		t.Errorf("Exchange() = %+v, want %+v", *id, want)
+	}
+
+	if _, err := p.Exchange(ctx, "code-1", oauth.NewCodeVerifier(), "nonce-1"); !errors.Is(err, oauth.ErrExchange) {
+		t.Errorf("expected ErrExchange for a wrong code verifier, got %v", err)
+	}
+	if _, err := p.Exchange(ctx, "code-1", verifier, "other-nonce"); !errors.Is(err, oauth.ErrExchange) {
+		t.Errorf("expected ErrExchange for a nonce mismatch, got %v", err)
+	}
+}
+
+func TestOIDCProvider_RejectsForeignToken(t *testing.T) {
+	idp := newMockIdP(t)
+	idp.claims = jwt.MapClaims{"aud": "another-client"}
+	p, _ := oauth.New(oauth.Config{
+		Name: "mock", Issuer: idp.srv.URL, ClientID: "client-1", ClientSecret: "s3cret", RedirectURL: "http://localhost/cb",
+	}, nil)
+	ctx := context.Background()
+
+	verifier := oauth.NewCodeVerifier()
+	authURL, _ := p.AuthCodeURL(ctx, "s", "n", oauth.CodeChallenge(verifier))
+	idp.authorize(t, authURL)
+	if _, err := p.Exchange(ctx, "code-1", verifier, "n"); !errors.Is(err, oauth.ErrExchange) {
+		t.Errorf("expected ErrExchange for a token issued to another client, got %v", err)
+	}
+}
+
+func TestOIDCProvider_DiscoveryIssuerMismatch(t *testing.T) {
+	idp := newMockIdP(t)
+	p, _ := oauth.New(oauth.Config{
+		Name: "mock", Issuer: idp.srv.URL + "/tenant", ClientID: "client-1", RedirectURL: "http://localhost/cb",
+	}, nil)
+	if _, err := p.AuthCodeURL(context.Background(), "s", "n", "c"); err == nil {
+		t.Error("expected an error when discovery fails or names another issuer")
+	}
+}
+
+func TestGitHubProvider(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("client_secret") != "s3cret" || r.FormValue("code") != "gh-code" {
+			json.NewEncoder(w).Encode(map[string]string{"error": "bad_verification_code"}) // GitHub answers 200
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "gho_1", "token_type": "bearer"})
+	})
+	mux.HandleFunc("GET /api/v3/user", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer gho_1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"id": 9001, "login": "kasun-dev", "name": ""})
+	})
+	mux.HandleFunc("GET /api/v3/user/emails", func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode([]map[string]any{
+			{"email": "old@example.lk", "primary": false, "verified": true},
+			{"email": "kasun@example.lk", "primary": true, "verified": true},
+		})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	p, _ := oauth.New(oauth.Config{
+		Name: oauth.GitHub, Issuer: srv.URL, ClientID: "gh-client", ClientSecret: "s3cret", RedirectURL: "http://localhost/cb",
+	}, nil)
+	ctx := context.Background()
+
+	authURL, err := p.AuthCodeURL(ctx, "s", "n", "c")
+	if err != nil || !strings.HasPrefix(authURL, srv.URL+"/login/oauth/authorize?") || strings.Contains(authURL, "nonce=") {
+		t.Fatalf("AuthCodeURL() = %q, %v", authURL, err)
+	}
+	id, err := p.Exchange(ctx, "gh-code", "verifier", "")
+	if err != nil {
+		t.Fatalf("Exchange() error: %v", err)
+	}
+	if want := (oauth.Identity{Subject: "9001", Email: "kasun@example.lk", EmailVerified: true, Name: "kasun-dev"}); *id != want {
+		t.Errorf("Exchange() = %+v, want %+v", *id, want)
+	}
+	if _, err := p.Exchange(ctx, "wrong", "verifier", ""); !errors.Is(err, oauth.ErrExchange) {
+		t.Errorf("expected ErrExchange for a rejected code, got %v", err)
+	}
+}
@@ -0,0 +1,247 @@
+// Package outbox relays Kafka events recorded in identity_schema.outbox. Events are
+// written in the same transaction as the change they announce, so a committed signup
+// or login is never left unannounced, and a rolled-back one is never announced.
+//
+// A single relay publishes at a time (the store hands a batch to one replica only),
+// in the order the events were recorded. When an event cannot be published, later
+// events of the same user wait for it; other users' events carry on.
+package outbox
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Event is a message for the Kafka topic Topic, keyed by UserID so each user's
+// events land on one partition in order.
+type Event struct {
+	ID         int64 // position in the outbox, assigned when recorded
+	Topic      string
+	UserID     string
+	EventType  string
+	OccurredAt time.Time // when the change was committed
+	Attempts   int       // failed publish attempts so far
+}
+
+// Batch is a run of unpublished events claimed by one relay, which holds the relay
+// lock until Release. Each mark is recorded on its own as soon as it is made, so a
+// batch cut short does not publish again the events it already marked.
+type Batch interface {
+	Events() []Event
+	MarkPublished(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, retryAt time.Time, cause string) error
+	Release() error
+}
+
+// Store is the outbox table (see repository.PostgresRepo).
+type Store interface {
+	// ClaimOutbox returns up to limit events that are due, oldest first, or a nil
+	// Batch while another relay holds one.
+	ClaimOutbox(ctx context.Context, limit int) (Batch, error)
+	// OutboxBacklog returns the number of unpublished events and when the oldest
+	// of them occurred (zero if there are none).
+	OutboxBacklog(ctx context.Context) (int, time.Time, error)
+	// DeletePublishedOutbox removes events published before the given time.
+	DeletePublishedOutbox(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Publisher delivers one event, returning once the broker has acknowledged it.
+type Publisher interface {
+	Publish(ctx context.Context, ev Event) error
+}
+
+// Config tunes the relay.
+type Config struct {
+	PollInterval time.Duration // wait between polls when the outbox is drained
+	BatchSize    int           // events claimed per batch
+	Retention    time.Duration // how long published events are kept; 0 keeps them
+
+	// PublishTimeout bounds the broker's acknowledgement of one event (default
+	// 10 seconds), so a stuck partition fails its own event instead of the batch.
+	PublishTimeout time.Duration
+}
+
+const (
+	minRetryDelay = time.Second
+	maxRetryDelay = 5 * time.Minute
+	purgeInterval = time.Hour
+	batchTimeout  = 30 * time.Second // bounds publishing one batch, including during shutdown
+	markTimeout   = 5 * time.Second  // bounds recording one mark, whatever is left of the batch
+)
+
+var (
+	outboxPublished = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "identity",
+		Name:      "outbox_published_total",
+		Help:      "Outbox events published to Kafka.",
+	})
+
+	outboxFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "identity",
+		Name:      "outbox_publish_failures_total",
+		Help:      "Failed attempts to publish an outbox event; the event is retried.",
+	})
+
+	outboxBacklog = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "identity",
+		Name:      "outbox_backlog",
+		Help:      "Outbox events not yet published.",
+	})
+
+	outboxLag = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "identity",
+		Name:      "outbox_lag_seconds",
+		Help:      "Age of the oldest unpublished outbox event; 0 when the outbox is drained.",
+	})
+)
+
+// Relay moves events from the outbox to Kafka.
+type Relay struct {
+	store     Store
+	pub       Publisher
+	cfg       Config
+	lastPurge time.Time
+}
+
+func NewRelay(store Store, pub Publisher, cfg Config) *Relay {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.PublishTimeout <= 0 {
+		cfg.PublishTimeout = 10 * time.Second
+	}
+	return &Relay{store: store, pub: pub, cfg: cfg}
+}
+
+// Run relays events until ctx is cancelled. A batch in progress at that point is
+// finished, so its events are not published twice.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		full := false
+		n, err := r.RelayBatch(ctx)
+		if err != nil {
+			log.Printf("[outbox] Relaying events failed: %v", err)
+		} else {
+			full = n == r.cfg.BatchSize
+		}
+		r.updateBacklog(ctx)
+		r.purge(ctx)
+
+		if full && ctx.Err() == nil {
+			continue // more events are waiting
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayBatch publishes one batch of due events and returns how many were claimed.
+// Events the batch has no time left for stay due and are claimed again by the next
+// batch, without counting as a failed attempt.
+func (r *Relay) RelayBatch(ctx context.Context) (int, error) {
+	base := context.WithoutCancel(ctx)
+	ctx, cancel := context.WithTimeout(base, batchTimeout)
+	defer cancel()
+
+	batch, err := r.store.ClaimOutbox(ctx, r.cfg.BatchSize)
+	if err != nil || batch == nil {
+		return 0, err
+	}
+	defer batch.Release() //nolint:errcheck
+
+	events := batch.Events()
+	blocked := make(map[string]bool) // users with an earlier event that failed in this batch
+	published := 0
+	defer func() { outboxPublished.Add(float64(published)) }()
+	for i, ev := range events {
+		if ctx.Err() != nil {
+			log.Printf("[outbox] Batch ran out of time; %d of %d event(s) left for the next one", len(events)-i, len(events))
+			break
+		}
+		if blocked[ev.UserID] {
+			continue
+		}
+		if err := r.publish(ctx, ev); err != nil {
+			blocked[ev.UserID] = true
+			outboxFailures.Inc()
+			log.Printf("[outbox] Publishing event %d (%s) for user %s failed, attempt %d: %v", ev.ID, ev.Topic, ev.UserID, ev.Attempts+1, err)
+			if err := r.mark(base, func(ctx context.Context) error {
+				return batch.MarkFailed(ctx, ev.ID, time.Now().Add(retryDelay(ev.Attempts+1)), err.Error())
+			}); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if err := r.mark(base, func(ctx context.Context) error { return batch.MarkPublished(ctx, ev.ID) }); err != nil {
+			return 0, err
+		}
+		published++
+	}
+	return len(events), nil
+}
+
+// publish sends one event within PublishTimeout.
+func (r *Relay) publish(ctx context.Context, ev Event) error {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.PublishTimeout)
+	defer cancel()
+	return r.pub.Publish(ctx, ev)
+}
+
+// mark records the outcome of a publish with its own deadline, so a publish that
+// used up the batch's time does not also lose its mark.
+func (r *Relay) mark(base context.Context, fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(base, markTimeout)
+	defer cancel()
+	return fn(ctx)
+}
+
+func (r *Relay) updateBacklog(ctx context.Context) {
+	n, oldest, err := r.store.OutboxBacklog(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("[outbox] Reading backlog failed: %v", err)
+		}
+		return
+	}
+	outboxBacklog.Set(float64(n))
+	if oldest.IsZero() {
+		outboxLag.Set(0)
+	} else {
+		outboxLag.Set(time.Since(oldest).Seconds())
+	}
+}
+
+func (r *Relay) purge(ctx context.Context) {
+	if r.cfg.Retention <= 0 || time.Since(r.lastPurge) < purgeInterval || ctx.Err() != nil {
+		return
+	}
+	r.lastPurge = time.Now()
+	n, err := r.store.DeletePublishedOutbox(ctx, time.Now().Add(-r.cfg.Retention))
+	if err != nil {
+		log.Printf("[outbox] Deleting published events failed: %v", err)
+	} else if n > 0 {
+		log.Printf("[outbox] Deleted %d published event(s)", n)
+	}
+}
+
+// retryDelay backs off exponentially from one second, capped at five minutes.
+func retryDelay(attempts int) time.Duration {
+	d := minRetryDelay
+	for i := 1; i < attempts && d < maxRetryDelay; i++ {
+		d *= 2
+	}
+	return min(d, maxRetryDelay)
+}
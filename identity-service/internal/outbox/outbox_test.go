@@ -0,0 +1,285 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/watup-lk/identity-service/internal/outbox"
+)
+
+// ── Fakes ─────────────────────────────────────────────────────────────────────
+
+type row struct {
+	ev        outbox.Event
+	published bool
+	due       time.Time
+	lastError string
+}
+
+// fakeStore mirrors the repository's claim query: due events in id order, holding
+// back a user's events while an earlier one waits for a retry.
+type fakeStore struct {
+	rows   []*row
+	locked bool // another relay holds the batch
+}
+
+func (s *fakeStore) add(userID, topic string) {
+	id := int64(len(s.rows) + 1)
+	s.rows = append(s.rows, &row{ev: outbox.Event{ID: id, Topic: topic, UserID: userID, EventType: topic, OccurredAt: time.Now()}})
+}
+
+func (s *fakeStore) ClaimOutbox(_ context.Context, limit int) (outbox.Batch, error) {
+	if s.locked {
+		return nil, nil
+	}
+	now := time.Now()
+	waiting := make(map[string]bool)
+	b := &fakeBatch{store: s}
+	for _, r := range s.rows {
+		if r.published {
+			continue
+		}
+		if r.due.After(now) {
+			waiting[r.ev.UserID] = true
+			continue
+		}
+		if waiting[r.ev.UserID] || len(b.events) == limit {
+			continue
+		}
+		b.events = append(b.events, r.ev)
+	}
+	return b, nil
+}
+
+func (s *fakeStore) OutboxBacklog(_ context.Context) (int, time.Time, error) {
+	n, oldest := 0, time.Time{}
+	for _, r := range s.rows {
+		if !r.published {
+			n++
+			if oldest.IsZero() {
+				oldest = r.ev.OccurredAt
+			}
+		}
+	}
+	return n, oldest, nil
+}
+
+func (s *fakeStore) DeletePublishedOutbox(_ context.Context, _ time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (s *fakeStore) row(id int64) *row { return s.rows[id-1] }
+
+// fakeBatch records each mark straight away, like the repository, and refuses
+// marks made with a context that is already done, as the database would.
+type fakeBatch struct {
+	store  *fakeStore
+	events []outbox.Event
+}
+
+func (b *fakeBatch) Events() []outbox.Event { return b.events }
+
+func (b *fakeBatch) MarkPublished(ctx context.Context, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	b.store.row(id).published = true
+	return nil
+}
+
+func (b *fakeBatch) MarkFailed(ctx context.Context, id int64, retryAt time.Time, cause string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r := b.store.row(id)
+	r.ev.Attempts++
+	r.due, r.lastError = retryAt, cause
+	return nil
+}
+
+func (b *fakeBatch) Release() error { return nil }
+
+type fakePublisher struct {
+	sent  []int64
+	fail  map[int64]int  // event id -> failures left
+	stuck map[int64]bool // event ids whose acknowledgement never comes
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, ev outbox.Event) error {
+	if p.stuck[ev.ID] {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if p.fail[ev.ID] > 0 {
+		p.fail[ev.ID]--
+		return errors.New("broker unavailable")
+	}
+	p.sent = append(p.sent, ev.ID)
+	return nil
+}
+
+// ── Relay Tests ───────────────────────────────────────────────────────────────
+
+func TestRelayBatch_PublishesInOrder(t *testing.T) {
+	store := &fakeStore{}
+	store.add("u1", "user.registered")
+	store.add("u2", "user.registered")
+	store.add("u1", "user.login")
+	pub := &fakePublisher{}
+	relay := outbox.NewRelay(store, pub, outbox.Config{})
+
+	n, err := relay.RelayBatch(context.Background())
+	if err != nil || n != 3 {
+		t.Fatalf("RelayBatch() = %d, %v; want 3, nil", n, err)
+	}
+	if !slices.Equal(pub.sent, []int64{1, 2, 3}) {
+		t.Errorf("expected events 1, 2, 3 in order, got %v", pub.sent)
+	}
+
+	// Published events are marked once and not handed out again
+	if n, _ := relay.RelayBatch(context.Background()); n != 0 || len(pub.sent) != 3 {
+		t.Errorf("expected a drained outbox, got %d claimed and %d sent", n, len(pub.sent))
+	}
+}
+
+func TestRelayBatch_BatchSize(t *testing.T) {
+	store := &fakeStore{}
+	for range 5 {
+		store.add("u1", "user.token_refresh")
+	}
+	pub := &fakePublisher{}
+	relay := outbox.NewRelay(store, pub, outbox.Config{BatchSize: 2})
+
+	for _, want := range []int{2, 2, 1, 0} {
+		if n, err := relay.RelayBatch(context.Background()); err != nil || n != want {
+			t.Fatalf("RelayBatch() = %d, %v; want %d", n, err, want)
+		}
+	}
+	if !slices.Equal(pub.sent, []int64{1, 2, 3, 4, 5}) {
+		t.Errorf("expected events 1 to 5 in order, got %v", pub.sent)
+	}
+}
+
+func TestRelayBatch_FailureHoldsBackUser(t *testing.T) {
+	store := &fakeStore{}
+	store.add("u1", "user.login")  // fails once
+	store.add("u2", "user.login")  // unaffected
+	store.add("u1", "user.logout") // must wait for u1's login
+	pub := &fakePublisher{fail: map[int64]int{1: 1}}
+	relay := outbox.NewRelay(store, pub, outbox.Config{})
+
+	before := time.Now()
+	if _, err := relay.RelayBatch(context.Background()); err != nil {
+		t.Fatalf("RelayBatch() error: %v", err)
+	}
+	if !slices.Equal(pub.sent, []int64{2}) {
+		t.Errorf("expected only u2's event to be sent, got %v", pub.sent)
+	}
+	failed := store.row(1)
+	if failed.published || failed.ev.Attempts != 1 || failed.lastError == "" {
+		t.Errorf("expected the failure to be recorded, got %+v", failed)
+	}
+	if d := failed.due.Sub(before); d < time.Second || d > 2*time.Second {
+		t.Errorf("expected a retry in about a second, got %v", d)
+	}
+	if store.row(3).published || store.row(3).ev.Attempts != 0 {
+		t.Error("u1's later event must not be attempted before the failed one")
+	}
+
+	// Still backing off: u1's events are not claimed
+	if n, _ := relay.RelayBatch(context.Background()); n != 0 {
+		t.Errorf("expected nothing due, got %d", n)
+	}
+
+	failed.due = time.Now()
+	if _, err := relay.RelayBatch(context.Background()); err != nil {
+		t.Fatalf("RelayBatch() error: %v", err)
+	}
+	if !slices.Equal(pub.sent, []int64{2, 1, 3}) {
+		t.Errorf("expected u1's events to follow in order once retried, got %v", pub.sent)
+	}
+}
+
+func TestRelayBatch_StuckPublishFailsOnlyItsEvent(t *testing.T) {
+	store := &fakeStore{}
+	store.add("u1", "user.login") // its partition never acknowledges
+	store.add("u2", "user.login")
+	store.add("u1", "user.logout")
+	pub := &fakePublisher{stuck: map[int64]bool{1: true}}
+	relay := outbox.NewRelay(store, pub, outbox.Config{PublishTimeout: 20 * time.Millisecond})
+
+	start := time.Now()
+	if _, err := relay.RelayBatch(context.Background()); err != nil {
+		t.Fatalf("RelayBatch() error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the stuck publish to time out on its own, took %v", elapsed)
+	}
+	stuck := store.row(1)
+	if stuck.published || stuck.ev.Attempts != 1 || !stuck.due.After(start) || stuck.lastError == "" {
+		t.Errorf("expected the timed-out attempt to be recorded, got %+v", stuck)
+	}
+	if !slices.Equal(pub.sent, []int64{2}) || !store.row(2).published {
+		t.Errorf("expected u2's event to be published and marked, got %v", pub.sent)
+	}
+	if store.row(3).published || store.row(3).ev.Attempts != 0 {
+		t.Error("u1's later event must wait for the stuck one")
+	}
+}
+
+func TestRelayBatch_BackoffIsCapped(t *testing.T) {
+	store := &fakeStore{}
+	store.add("u1", "user.login")
+	store.row(1).ev.Attempts = 30
+	relay := outbox.NewRelay(store, &fakePublisher{fail: map[int64]int{1: 1}}, outbox.Config{})
+
+	before := time.Now()
+	if _, err := relay.RelayBatch(context.Background()); err != nil {
+		t.Fatalf("RelayBatch() error: %v", err)
+	}
+	if d := store.row(1).due.Sub(before); d < 5*time.Minute || d > 5*time.Minute+time.Second {
+		t.Errorf("expected the retry delay to be capped at five minutes, got %v", d)
+	}
+}
+
+func TestRelayBatch_AnotherRelayHoldsBatch(t *testing.T) {
+	store := &fakeStore{locked: true}
+	store.add("u1", "user.login")
+	pub := &fakePublisher{}
+	relay := outbox.NewRelay(store, pub, outbox.Config{})
+
+	if n, err := relay.RelayBatch(context.Background()); err != nil || n != 0 {
+		t.Errorf("RelayBatch() = %d, %v; want 0, nil", n, err)
+	}
+	if len(pub.sent) != 0 {
+		t.Errorf("expected nothing sent, got %v", pub.sent)
+	}
+}
+
+func TestRun_StopsOnCancel(t *testing.T) {
+	store := &fakeStore{}
+	store.add("u1", "user.registered")
+	pub := &fakePublisher{}
+	relay := outbox.NewRelay(store, pub, outbox.Config{PollInterval: time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		relay.Run(ctx)
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run() did not return after cancel")
+	}
+	if !slices.Equal(pub.sent, []int64{1}) {
+		t.Errorf("expected the event to be sent once, got %v", pub.sent)
+	}
+}
@@ -0,0 +1,195 @@
+// Package passhash hashes and verifies passwords with argon2id or bcrypt.
+//
+// Argon2id hashes use the PHC string format
+//
+//	$argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>
+//
+// and bcrypt hashes the standard $2a$/$2b$ modular crypt format, so the algorithm
+// and cost of every stored hash can be read back. Verify reports when a hash was
+// made under a weaker policy than the current one so callers can upgrade it.
+package passhash
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+
+	saltLen = 16
+	keyLen  = 32
+)
+
+var ErrUnknownFormat = errors.New("unrecognised password hash format")
+
+// Policy is the algorithm and cost used for new hashes. Existing hashes weaker than
+// the policy are reported by Verify as needing a rehash; stronger ones are kept.
+type Policy struct {
+	Algorithm         string // Argon2id or Bcrypt
+	Argon2Memory      uint32 // KiB
+	Argon2Time        uint32 // iterations
+	Argon2Parallelism uint8
+	BcryptCost        int
+}
+
+// DefaultPolicy follows the OWASP argon2id recommendation that fits comfortably in
+// a small pod (19 MiB, 2 iterations, 1 lane); the bcrypt cost applies when
+// Algorithm is Bcrypt.
+var DefaultPolicy = Policy{
+	Algorithm:         Argon2id,
+	Argon2Memory:      19 * 1024,
+	Argon2Time:        2,
+	Argon2Parallelism: 1,
+	BcryptCost:        bcrypt.DefaultCost,
+}
+
+// Validate checks that the policy names a supported algorithm with usable costs.
+func (p Policy) Validate() error {
+	switch p.Algorithm {
+	case Argon2id:
+		if p.Argon2Memory < 8*uint32(p.Argon2Parallelism) || p.Argon2Time < 1 || p.Argon2Parallelism < 1 {
+			return fmt.Errorf("argon2id needs time >= 1, parallelism >= 1 and memory >= 8 KiB per lane")
+		}
+	case Bcrypt:
+		if p.BcryptCost < bcrypt.MinCost || p.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	default:
+		return fmt.Errorf("unsupported password hash algorithm %q", p.Algorithm)
+	}
+	return nil
+}
+
+// Hasher hashes new passwords under a Policy.
+type Hasher struct {
+	policy Policy
+}
+
+// New returns a Hasher for p. Zero fields take their value from DefaultPolicy.
+func New(p Policy) *Hasher {
+	if p.Algorithm == "" {
+		p.Algorithm = DefaultPolicy.Algorithm
+	}
+	if p.Argon2Memory == 0 {
+		p.Argon2Memory = DefaultPolicy.Argon2Memory
+	}
+	if p.Argon2Time == 0 {
+		p.Argon2Time = DefaultPolicy.Argon2Time
+	}
+	if p.Argon2Parallelism == 0 {
+		p.Argon2Parallelism = DefaultPolicy.Argon2Parallelism
+	}
+	if p.BcryptCost == 0 {
+		p.BcryptCost = DefaultPolicy.BcryptCost
+	}
+	return &Hasher{policy: p}
+}
+
+// Hash returns the encoded hash of password under the hasher's policy.
+func (h *Hasher) Hash(password string) (string, error) {
+	switch h.policy.Algorithm {
+	case Argon2id:
+		salt := make([]byte, saltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return "", fmt.Errorf("generating salt: %w", err)
+		}
+		p := argon2Params{memory: h.policy.Argon2Memory, time: h.policy.Argon2Time, parallelism: h.policy.Argon2Parallelism}
+		key := argon2.IDKey([]byte(password), salt, p.time, p.memory, p.parallelism, keyLen)
+		return p.encode(salt, key), nil
+	case Bcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.policy.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	default:
+		return "", fmt.Errorf("unsupported password hash algorithm %q", h.policy.Algorithm)
+	}
+}
+
+// Verify checks password against an encoded hash. needsRehash is only meaningful
+// when ok is true: it reports that the hash uses a different algorithm than the
+// policy, or weaker parameters. An error means the hash itself is unreadable.
+func (h *Hasher) Verify(password, encoded string) (ok, needsRehash bool, err error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		p, salt, key, err := decodeArgon2(encoded)
+		if err != nil {
+			return false, false, err
+		}
+		got := argon2.IDKey([]byte(password), salt, p.time, p.memory, p.parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(got, key) != 1 {
+			return false, false, nil
+		}
+		weaker := p.memory < h.policy.Argon2Memory || p.time < h.policy.Argon2Time ||
+			p.parallelism < h.policy.Argon2Parallelism || len(key) < keyLen
+		return true, h.policy.Algorithm != Argon2id || weaker, nil
+
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, nil
+		}
+		if err != nil {
+			return false, false, fmt.Errorf("%w: %v", ErrUnknownFormat, err)
+		}
+		cost, err := bcrypt.Cost([]byte(encoded))
+		if err != nil {
+			return false, false, fmt.Errorf("%w: %v", ErrUnknownFormat, err)
+		}
+		return true, h.policy.Algorithm != Bcrypt || cost < h.policy.BcryptCost, nil
+
+	default:
+		return false, false, ErrUnknownFormat
+	}
+}
+
+type argon2Params struct {
+	memory      uint32
+	time        uint32
+	parallelism uint8
+}
+
+var b64 = base64.RawStdEncoding
+
+func (p argon2Params) encode(salt, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.memory, p.time, p.parallelism, b64.EncodeToString(salt), b64.EncodeToString(key))
+}
+
+func decodeArgon2(encoded string) (argon2Params, []byte, []byte, error) {
+	var p argon2Params
+	parts := strings.Split(encoded, "$")
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, key
+	if len(parts) != 6 {
+		return p, nil, nil, ErrUnknownFormat
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, fmt.Errorf("%w: argon2 version %q", ErrUnknownFormat, parts[2])
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.parallelism); err != nil {
+		return p, nil, nil, fmt.Errorf("%w: argon2 parameters %q", ErrUnknownFormat, parts[3])
+	}
+	salt, err := b64.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, fmt.Errorf("%w: salt: %v", ErrUnknownFormat, err)
+	}
+	key, err := b64.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, fmt.Errorf("%w: hash", ErrUnknownFormat)
+	}
+	if p.time == 0 || p.parallelism == 0 {
+		return p, nil, nil, fmt.Errorf("%w: argon2 parameters %q", ErrUnknownFormat, parts[3])
+	}
+	return p, salt, key, nil
+}
@@ -0,0 +1,103 @@
+package passhash_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/watup-lk/identity-service/internal/passhash"
+)
+
+// fast keeps argon2id cheap enough for unit tests.
+var fast = passhash.Policy{Algorithm: passhash.Argon2id, Argon2Memory: 64, Argon2Time: 1, Argon2Parallelism: 1}
+
+func TestArgon2id_RoundTrip(t *testing.T) {
+	h := passhash.New(fast)
+	encoded, err := h.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Hash() error: %v", err)
+	}
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("unexpected PHC string %q", encoded)
+	}
+
+	ok, rehash, err := h.Verify("correct horse", encoded)
+	if err != nil || !ok || rehash {
+		t.Errorf("Verify(correct) = %v, %v, %v; want true, false, nil", ok, rehash, err)
+	}
+	ok, _, err = h.Verify("wrong horse", encoded)
+	if err != nil || ok {
+		t.Errorf("Verify(wrong) = %v, %v; want false, nil", ok, err)
+	}
+
+	other, _ := h.Hash("correct horse")
+	if other == encoded {
+		t.Error("expected a fresh salt per hash")
+	}
+}
+
+func TestVerify_FlagsWeakerArgon2Params(t *testing.T) {
+	old, _ := passhash.New(fast).Hash("pw")
+
+	stronger := fast
+	stronger.Argon2Time = 2
+	ok, rehash, err := passhash.New(stronger).Verify("pw", old)
+	if err != nil || !ok || !rehash {
+		t.Errorf("expected match needing rehash, got %v, %v, %v", ok, rehash, err)
+	}
+
+	// A hash stronger than the policy is left alone
+	weaker := fast
+	weaker.Argon2Memory = 32
+	if _, rehash, _ := passhash.New(weaker).Verify("pw", old); rehash {
+		t.Error("hash stronger than policy should not be rehashed")
+	}
+}
+
+func TestVerify_Bcrypt(t *testing.T) {
+	legacy, _ := bcrypt.GenerateFromPassword([]byte("pw"), bcrypt.MinCost)
+
+	// Under an argon2id policy a bcrypt hash still verifies but is outdated
+	ok, rehash, err := passhash.New(fast).Verify("pw", string(legacy))
+	if err != nil || !ok || !rehash {
+		t.Errorf("expected bcrypt match needing rehash, got %v, %v, %v", ok, rehash, err)
+	}
+
+	h := passhash.New(passhash.Policy{Algorithm: passhash.Bcrypt, BcryptCost: bcrypt.MinCost + 1})
+	if _, rehash, _ := h.Verify("pw", string(legacy)); !rehash {
+		t.Error("bcrypt hash below the policy cost should be rehashed")
+	}
+	encoded, _ := h.Hash("pw")
+	if ok, rehash, _ := h.Verify("pw", encoded); !ok || rehash {
+		t.Errorf("fresh bcrypt hash: ok=%v rehash=%v", ok, rehash)
+	}
+	if ok, _, _ := h.Verify("nope", encoded); ok {
+		t.Error("wrong password should not match")
+	}
+}
+
+func TestVerify_UnknownFormat(t *testing.T) {
+	h := passhash.New(fast)
+	for _, encoded := range []string{"", "plaintext", "$argon2id$v=18$m=64,t=1,p=1$c2FsdA$aGFzaA", "$argon2id$v=19$broken$c2FsdA$aGFzaA"} {
+		if _, _, err := h.Verify("pw", encoded); !errors.Is(err, passhash.ErrUnknownFormat) {
+			t.Errorf("Verify(%q): expected ErrUnknownFormat, got %v", encoded, err)
+		}
+	}
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	if err := passhash.DefaultPolicy.Validate(); err != nil {
+		t.Errorf("DefaultPolicy should be valid: %v", err)
+	}
+	for _, p := range []passhash.Policy{
+		{Algorithm: "md5"},
+		{Algorithm: passhash.Bcrypt, BcryptCost: 2},
+		{Algorithm: passhash.Argon2id, Argon2Memory: 64, Argon2Time: 0, Argon2Parallelism: 1},
+	} {
+		if err := p.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", p)
+		}
+	}
+}
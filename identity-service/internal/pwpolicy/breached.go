@@ -0,0 +1,70 @@
+package pwpolicy
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Corpus looks passwords up in a directory of Have I Been Pwned range files, as
+// written by the official PwnedPasswordsDownloader: one file per 5-hex-digit
+// SHA-1 prefix, named "<PREFIX>" or "<PREFIX>.txt", each line "<SUFFIX>:<COUNT>"
+// with the remaining 35 hex digits of the hash.
+//
+// Only the file for the password's prefix is read on each lookup, so the full
+// corpus (~1M files) never has to fit in memory, and a partial corpus — say only
+// the most common passwords — works too: missing files count as "not breached".
+type Corpus struct {
+	dir string
+}
+
+// NewCorpus returns a Corpus reading range files from dir.
+func NewCorpus(dir string) *Corpus {
+	return &Corpus{dir: dir}
+}
+
+// Count returns how often password appears in the corpus; 0 means it was not found.
+func (c *Corpus) Count(password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := digest[:5], digest[5:]
+
+	f, err := c.open(prefix)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		s, n, ok := strings.Cut(line, ":")
+		if !ok || !strings.EqualFold(s, suffix) {
+			continue
+		}
+		// Padding entries added by the range API have a count of 0
+		count, err := strconv.Atoi(n)
+		if err != nil {
+			return 0, err
+		}
+		return count, nil
+	}
+	return 0, sc.Err()
+}
+
+func (c *Corpus) open(prefix string) (*os.File, error) {
+	f, err := os.Open(filepath.Join(c.dir, prefix+".txt"))
+	if errors.Is(err, fs.ErrNotExist) {
+		f, err = os.Open(filepath.Join(c.dir, prefix))
+	}
+	return f, err
+}
@@ -0,0 +1,174 @@
+// Package pwpolicy decides whether a new password is acceptable.
+//
+// A password is rejected when it is too short or too long, lacks a letter or a
+// digit, scores below the minimum on a zxcvbn-style guessability estimate, contains
+// the user's name or email local-part, or appears in a breached-password corpus.
+// The corpus is read from local files in the Have I Been Pwned range format, so no
+// password material or hash prefix ever leaves the service.
+//
+// Every failed rule is reported as a Reason with a stable Code the frontend can
+// map to its own copy.
+package pwpolicy
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Reason codes returned by Check.
+const (
+	CodeTooShort        = "too_short"
+	CodeTooLong         = "too_long"
+	CodeLetterAndDigit  = "letter_and_digit_required"
+	CodeTooWeak         = "too_weak"
+	CodePersonalInfo    = "contains_personal_info"
+	CodeBreached        = "breached"
+	minPersonalTokenLen = 3 // shorter name parts ("Al", "Jo") match too many passwords
+)
+
+// Reason is one rule a password failed.
+type Reason struct {
+	Code    string
+	Message string
+}
+
+// Rules configures a Checker.
+type Rules struct {
+	MinLength   int    // in characters
+	MaxLength   int    // in characters; bounds the cost of hashing and scoring
+	MinScore    int    // 0–4, see Score
+	BreachedDir string // HIBP range files; empty disables the breach check
+}
+
+// DefaultRules follows NIST SP 800-63B: at least 8 characters, room for long
+// passphrases, and a check against known-breached passwords when a corpus is
+// configured.
+var DefaultRules = Rules{
+	MinLength: 8,
+	MaxLength: 128,
+	MinScore:  2,
+}
+
+// Validate checks that the rules are usable and the corpus directory exists.
+func (r Rules) Validate() error {
+	if r.MinLength < 1 || r.MaxLength < r.MinLength {
+		return fmt.Errorf("password length limits must satisfy 1 <= min <= max")
+	}
+	if r.MinScore < 0 || r.MinScore > 4 {
+		return fmt.Errorf("password minimum score must be between 0 and 4")
+	}
+	if r.BreachedDir != "" {
+		info, err := os.Stat(r.BreachedDir)
+		if err != nil {
+			return fmt.Errorf("breached password corpus: %w", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("breached password corpus %s is not a directory", r.BreachedDir)
+		}
+	}
+	return nil
+}
+
+// Checker applies Rules to candidate passwords. It is safe for concurrent use.
+type Checker struct {
+	rules    Rules
+	breached *Corpus
+}
+
+// New returns a Checker for r. Zero length limits take their value from
+// DefaultRules; MinScore is used as given, so 0 turns the strength check off.
+func New(r Rules) *Checker {
+	if r.MinLength == 0 {
+		r.MinLength = DefaultRules.MinLength
+	}
+	if r.MaxLength == 0 {
+		r.MaxLength = DefaultRules.MaxLength
+	}
+	c := &Checker{rules: r}
+	if r.BreachedDir != "" {
+		c.breached = NewCorpus(r.BreachedDir)
+	}
+	return c
+}
+
+// Check returns every rule password fails, or nil if it is acceptable. name and
+// email are the account's own details, which the password must not contain.
+//
+// A non-nil error means the breach corpus could not be read; the returned reasons
+// then cover every other rule, and the caller decides whether to fail open.
+func (c *Checker) Check(password, name, email string) ([]Reason, error) {
+	var reasons []Reason
+
+	n := utf8.RuneCountInString(password)
+	if n < c.rules.MinLength {
+		reasons = append(reasons, Reason{CodeTooShort, fmt.Sprintf("password must be at least %d characters", c.rules.MinLength)})
+	}
+	if n > c.rules.MaxLength {
+		// Nothing else is worth computing on an oversized input
+		return append(reasons, Reason{CodeTooLong, fmt.Sprintf("password must be at most %d characters", c.rules.MaxLength)}), nil
+	}
+
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		hasLetter = hasLetter || unicode.IsLetter(r)
+		hasDigit = hasDigit || unicode.IsDigit(r)
+	}
+	if !hasLetter || !hasDigit {
+		reasons = append(reasons, Reason{CodeLetterAndDigit, "password must contain at least one letter and one digit"})
+	}
+
+	personal := personalTokens(name, email)
+	if containsAny(password, personal) {
+		reasons = append(reasons, Reason{CodePersonalInfo, "password must not contain your name or email address"})
+	}
+
+	if Score(password, personal...) < c.rules.MinScore {
+		reasons = append(reasons, Reason{CodeTooWeak, "password is too easy to guess; try a longer phrase of unrelated words"})
+	}
+
+	if c.breached == nil {
+		return reasons, nil
+	}
+	count, err := c.breached.Count(password)
+	if err != nil {
+		return reasons, err
+	}
+	if count > 0 {
+		reasons = append(reasons, Reason{CodeBreached, "password has appeared in a data breach; choose a different one"})
+	}
+	return reasons, nil
+}
+
+// personalTokens splits a display name and an email local-part into the lowercase
+// words a password must not contain, e.g. "Mary-Jane Doe", "mj.doe+news@x.lk" →
+// [mary jane doe doe news].
+func personalTokens(name, email string) []string {
+	local, _, _ := strings.Cut(email, "@")
+	split := func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }
+
+	var tokens []string
+	for _, f := range append(strings.FieldsFunc(name, split), strings.FieldsFunc(local, split)...) {
+		// Trailing digits are not part of the name: "bob2" still matches "bob"
+		f = strings.ToLower(strings.TrimRightFunc(f, unicode.IsDigit))
+		if utf8.RuneCountInString(f) >= minPersonalTokenLen {
+			tokens = append(tokens, f)
+		}
+	}
+	return tokens
+}
+
+// containsAny reports whether password contains any token, ignoring case and
+// common character substitutions ("4l1ce" contains "alice").
+func containsAny(password string, tokens []string) bool {
+	lower := strings.ToLower(password)
+	plain := unleet(lower)
+	for _, t := range tokens {
+		if strings.Contains(lower, t) || strings.Contains(plain, t) {
+			return true
+		}
+	}
+	return false
+}
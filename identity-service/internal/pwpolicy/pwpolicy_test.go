@@ -0,0 +1,184 @@
+package pwpolicy_test
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/watup-lk/identity-service/internal/pwpolicy"
+)
+
+func codes(reasons []pwpolicy.Reason) []string {
+	var out []string
+	for _, r := range reasons {
+		out = append(out, r.Code)
+	}
+	return out
+}
+
+// writeRange adds password to a HIBP range file in dir, as "<PREFIX><ext>".
+func writeRange(t *testing.T, dir, ext, password string, count int) {
+	t.Helper()
+	sum := sha1.Sum([]byte(password))
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+	line := digest[5:] + ":" + strconv.Itoa(count) + "\r\n"
+	f, err := os.OpenFile(filepath.Join(dir, digest[:5]+ext), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// A decoy line first, as real range files hold hundreds of suffixes
+	if _, err := f.WriteString("0000000000000000000000000000000000A:7\r\n" + line); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScore(t *testing.T) {
+	cases := []struct {
+		password string
+		min, max int
+	}{
+		{"password", 0, 0},
+		{"qwerty123", 0, 1},
+		{"abcdefghij", 0, 0},
+		{"1qaz2wsx", 0, 0},
+		{"p4ssw0rd1", 0, 1},
+		{"Summer2024", 0, 1},
+		{"SecurePass1", 2, 4},
+		{"correct horse battery staple", 4, 4},
+		{"x7#kQ9!vLm", 3, 4},
+	}
+	for _, c := range cases {
+		got := pwpolicy.Score(c.password)
+		if got < c.min || got > c.max {
+			t.Errorf("Score(%q) = %d, want %d..%d", c.password, got, c.min, c.max)
+		}
+	}
+}
+
+func TestScore_UserInputs(t *testing.T) {
+	if pwpolicy.Score("Wijesinghe2019") <= pwpolicy.Score("Wijesinghe2019", "wijesinghe") {
+		t.Error("expected the user's own details to lower the score")
+	}
+}
+
+func TestCheck_Acceptable(t *testing.T) {
+	c := pwpolicy.New(pwpolicy.DefaultRules)
+	reasons, err := c.Check("SecurePass1", "Alice Perera", "alice@example.com")
+	if err != nil || reasons != nil {
+		t.Errorf("Check() = %v, %v; want nil, nil", reasons, err)
+	}
+}
+
+func TestCheck_ReportsEveryFailedRule(t *testing.T) {
+	c := pwpolicy.New(pwpolicy.DefaultRules)
+	reasons, _ := c.Check("alice", "Alice", "alice@example.com")
+	want := []string{pwpolicy.CodeTooShort, pwpolicy.CodeLetterAndDigit, pwpolicy.CodePersonalInfo, pwpolicy.CodeTooWeak}
+	if !slices.Equal(codes(reasons), want) {
+		t.Errorf("codes = %v, want %v", codes(reasons), want)
+	}
+	for _, r := range reasons {
+		if r.Message == "" {
+			t.Errorf("reason %s has no message", r.Code)
+		}
+	}
+}
+
+func TestCheck_TooLong(t *testing.T) {
+	c := pwpolicy.New(pwpolicy.DefaultRules)
+	reasons, _ := c.Check(strings.Repeat("a1", 65), "", "")
+	if !slices.Equal(codes(reasons), []string{pwpolicy.CodeTooLong}) {
+		t.Errorf("codes = %v, want [too_long]", codes(reasons))
+	}
+}
+
+func TestCheck_PersonalInfo(t *testing.T) {
+	c := pwpolicy.New(pwpolicy.Rules{})
+	cases := []struct {
+		password, name, email string
+		want                  bool
+	}{
+		{"Perera1985!", "Nimal Perera", "np@example.com", true},
+		{"xX-sunil.dev-Xx9", "S. Fernando", "sunil.dev@example.com", true},
+		{"Drop-tables-99", "Bob2", "bob2@example.com", false},
+		{"My-b0b-is-great9", "Bob2", "bob2@example.com", true},
+		{"4l1ce-in-chains7", "Alice", "a@example.com", true},
+		{"Jo-is-not-checked7", "Jo", "jo@example.com", false},
+	}
+	for _, tc := range cases {
+		reasons, _ := c.Check(tc.password, tc.name, tc.email)
+		got := slices.Contains(codes(reasons), pwpolicy.CodePersonalInfo)
+		if got != tc.want {
+			t.Errorf("Check(%q, %q, %q) personal info = %v, want %v", tc.password, tc.name, tc.email, got, tc.want)
+		}
+	}
+}
+
+func TestCheck_MinScoreZeroDisablesStrength(t *testing.T) {
+	c := pwpolicy.New(pwpolicy.Rules{MinScore: 0})
+	reasons, _ := c.Check("password1", "", "")
+	if reasons != nil {
+		t.Errorf("codes = %v, want none", codes(reasons))
+	}
+}
+
+func TestCheck_Breached(t *testing.T) {
+	dir := t.TempDir()
+	writeRange(t, dir, ".txt", "Kottu-Roti-42", 3)
+	writeRange(t, dir, "", "Hoppers&Sambol7", 9)
+	writeRange(t, dir, ".txt", "Padded-Entry-8", 0)
+
+	c := pwpolicy.New(pwpolicy.Rules{MinScore: 2, BreachedDir: dir})
+	for _, pw := range []string{"Kottu-Roti-42", "Hoppers&Sambol7"} {
+		reasons, err := c.Check(pw, "", "")
+		if err != nil {
+			t.Fatalf("Check(%q) error: %v", pw, err)
+		}
+		if !slices.Equal(codes(reasons), []string{pwpolicy.CodeBreached}) {
+			t.Errorf("Check(%q) codes = %v, want [breached]", pw, codes(reasons))
+		}
+	}
+	// Count 0 is range API padding; a missing prefix file is simply not breached
+	for _, pw := range []string{"Padded-Entry-8", "Lamprais-Feast-31"} {
+		if reasons, err := c.Check(pw, "", ""); err != nil || reasons != nil {
+			t.Errorf("Check(%q) = %v, %v; want nil, nil", pw, codes(reasons), err)
+		}
+	}
+}
+
+func TestCorpus_Count(t *testing.T) {
+	dir := t.TempDir()
+	writeRange(t, dir, ".txt", "Kottu-Roti-42", 3)
+
+	n, err := pwpolicy.NewCorpus(dir).Count("Kottu-Roti-42")
+	if err != nil || n != 3 {
+		t.Errorf("Count() = %d, %v; want 3, nil", n, err)
+	}
+}
+
+func TestRules_Validate(t *testing.T) {
+	if err := pwpolicy.DefaultRules.Validate(); err != nil {
+		t.Errorf("DefaultRules.Validate() error: %v", err)
+	}
+	file := filepath.Join(t.TempDir(), "corpus")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	bad := []pwpolicy.Rules{
+		{MinLength: 0, MaxLength: 128, MinScore: 2},
+		{MinLength: 12, MaxLength: 8, MinScore: 2},
+		{MinLength: 8, MaxLength: 128, MinScore: 5},
+		{MinLength: 8, MaxLength: 128, MinScore: 2, BreachedDir: filepath.Join(t.TempDir(), "missing")},
+		{MinLength: 8, MaxLength: 128, MinScore: 2, BreachedDir: file},
+	}
+	for _, r := range bad {
+		if err := r.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want error", r)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package pwpolicy
+
+import (
+	_ "embed"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Score estimates how hard password is to guess, on zxcvbn's 0–4 scale:
+//
+//	0  < 10^3 guesses    too guessable: top passwords, keyboard runs
+//	1  < 10^6 guesses    very guessable
+//	2  < 10^8 guesses    somewhat guessable: resists throttled online attacks
+//	3  < 10^10 guesses   safely unguessable online
+//	4  ≥ 10^10 guesses   very unguessable
+//
+// Like zxcvbn, the password is split into the cheapest sequence of patterns —
+// common passwords and words (also reversed or with l33t substitutions), the
+// userInputs, character sequences, repeats, keyboard rows and recent years — with
+// brute force filling the gaps. It is a smaller model than zxcvbn's (a few hundred
+// dictionary words rather than 30k), so it errs towards higher scores; the breach
+// corpus is what catches real-world leaked passwords.
+func Score(password string, userInputs ...string) int {
+	g := log10Guesses(password, userInputs)
+	switch {
+	case g < math.Log10(1e3+5):
+		return 0
+	case g < math.Log10(1e6+5):
+		return 1
+	case g < math.Log10(1e8+5):
+		return 2
+	case g < math.Log10(1e10+5):
+		return 3
+	default:
+		return 4
+	}
+}
+
+const (
+	maxScoredRunes = 100 // longer passwords only get stronger; cap the O(n³) search
+	minYearSpace   = 20
+	// Guesses are at least this many per pattern that is not the whole password,
+	// so splitting into tiny dictionary words is never "cheaper" than it should be.
+	minGuessesSingleChar = 10
+	minGuessesMultiChar  = 50
+	// Each extra pattern costs an attacker this much more, as in zxcvbn.
+	log10ExtraPatternPenalty = 4
+	keyboardStartingKeys     = 94
+)
+
+//go:embed common.txt
+var commonList string
+
+// commonRank maps each common password or word to its popularity rank (1 = most common).
+var commonRank = func() map[string]int {
+	ranks := make(map[string]int)
+	for i, w := range strings.Fields(commonList) {
+		if _, dup := ranks[w]; !dup {
+			ranks[w] = i + 1
+		}
+	}
+	return ranks
+}()
+
+var keyboardRows = []string{"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./", "1qaz2wsx3edc4rfv5tgb6yhn7ujm8ik,9ol.0p;/"}
+
+var leet = strings.NewReplacer("4", "a", "@", "a", "3", "e", "1", "i", "!", "i", "0", "o", "$", "s", "5", "s", "7", "t", "+", "t", "|", "l")
+
+// unleet undoes common character substitutions ("p4$$w0rd" → "password").
+func unleet(s string) string { return leet.Replace(s) }
+
+// match is a pattern covering runes i..j (inclusive) that takes 10^log10 guesses.
+type match struct {
+	i, j  int
+	log10 float64
+}
+
+// log10Guesses returns log10 of the estimated number of guesses for password.
+func log10Guesses(password string, userInputs []string) float64 {
+	runes := []rune(password)
+	if len(runes) > maxScoredRunes {
+		runes = runes[:maxScoredRunes]
+	}
+	n := len(runes)
+	if n == 0 {
+		return 0
+	}
+
+	ranks := commonRank
+	if len(userInputs) > 0 {
+		ranks = make(map[string]int, len(commonRank)+len(userInputs))
+		for w, r := range commonRank {
+			ranks[w] = r
+		}
+		for i, w := range userInputs {
+			ranks[strings.ToLower(w)] = i + 1
+		}
+	}
+
+	matches := dictionaryMatches(runes, ranks)
+	matches = append(matches, sequenceMatches(runes)...)
+	matches = append(matches, repeatMatches(runes)...)
+	matches = append(matches, keyboardMatches(runes)...)
+	matches = append(matches, yearMatches(runes)...)
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			matches = append(matches, match{i, j, float64(j - i + 1)}) // brute force, 10 per char
+		}
+	}
+
+	byEnd := make([][]match, n)
+	for _, m := range matches {
+		if m.i > 0 || m.j < n-1 {
+			floor := math.Log10(minGuessesMultiChar)
+			if m.i == m.j {
+				floor = math.Log10(minGuessesSingleChar)
+			}
+			m.log10 = math.Max(m.log10, floor)
+		}
+		byEnd[m.j] = append(byEnd[m.j], m)
+	}
+
+	// best[k][l] is the smallest log10 product of guesses covering runes 0..k with
+	// exactly l patterns.
+	inf := math.Inf(1)
+	best := make([][]float64, n)
+	for k := range best {
+		best[k] = make([]float64, n+1)
+		for l := range best[k] {
+			best[k][l] = inf
+		}
+	}
+	for k := 0; k < n; k++ {
+		for _, m := range byEnd[k] {
+			if m.i == 0 {
+				best[k][1] = math.Min(best[k][1], m.log10)
+				continue
+			}
+			for l, prev := range best[m.i-1] {
+				if prev < inf && l < n {
+					best[k][l+1] = math.Min(best[k][l+1], prev+m.log10)
+				}
+			}
+		}
+	}
+
+	// zxcvbn's total: l! orderings of the product, plus a penalty per extra pattern
+	total := inf
+	for l := 1; l <= n; l++ {
+		if best[n-1][l] == inf {
+			continue
+		}
+		lf, _ := math.Lgamma(float64(l + 1))
+		total = math.Min(total, log10Add(best[n-1][l]+lf/math.Ln10, float64(l-1)*log10ExtraPatternPenalty))
+	}
+	return total
+}
+
+// dictionaryMatches finds ranked words, as typed, reversed, or with l33t
+// substitutions undone.
+func dictionaryMatches(runes []rune, ranks map[string]int) []match {
+	var out []match
+	for i := range runes {
+		for j := i + 2; j < len(runes); j++ { // words of at least 3 characters
+			word := string(runes[i : j+1])
+			lower := strings.ToLower(word)
+			variations := uppercaseVariations(runes[i : j+1])
+			if r, ok := ranks[lower]; ok {
+				out = append(out, match{i, j, math.Log10(float64(r) * variations)})
+			}
+			if r, ok := ranks[reverse(lower)]; ok {
+				out = append(out, match{i, j, math.Log10(float64(r) * variations * 2)})
+			}
+			if plain := unleet(lower); plain != lower {
+				if r, ok := ranks[plain]; ok {
+					out = append(out, match{i, j, math.Log10(float64(r) * variations * 2)})
+				}
+			}
+		}
+	}
+	return out
+}
+
+// uppercaseVariations is the number of ways the word's capitalisation could have
+// been chosen; the common "Capitalised", "ALL CAPS" and "lasT" forms count as 2.
+func uppercaseVariations(word []rune) float64 {
+	var upper, lower int
+	for _, r := range word {
+		switch {
+		case unicode.IsUpper(r):
+			upper++
+		case unicode.IsLower(r):
+			lower++
+		}
+	}
+	if upper == 0 {
+		return 1
+	}
+	if lower == 0 || (upper == 1 && (unicode.IsUpper(word[0]) || unicode.IsUpper(word[len(word)-1]))) {
+		return 2
+	}
+	var v float64
+	for k := 1; k <= min(upper, lower); k++ {
+		v += binomial(upper+lower, k)
+	}
+	return v
+}
+
+// sequenceMatches finds runs like "abcd", "9876" or "ace" of three or more.
+func sequenceMatches(runes []rune) []match {
+	var out []match
+	for i := 0; i < len(runes)-2; {
+		delta := runes[i+1] - runes[i]
+		j := i + 1
+		for j+1 < len(runes) && runes[j+1]-runes[j] == delta {
+			j++
+		}
+		if j-i >= 2 && delta != 0 && delta >= -5 && delta <= 5 {
+			base := 26.0
+			switch {
+			case strings.ContainsRune("aAzZ019", runes[i]):
+				base = 4
+			case unicode.IsDigit(runes[i]):
+				base = 10
+			}
+			if delta < 0 {
+				base *= 2
+			}
+			out = append(out, match{i, j, math.Log10(base * float64(j-i+1))})
+		}
+		i = j
+	}
+	return out
+}
+
+// repeatMatches finds runs of one repeated character ("aaa", "1111").
+func repeatMatches(runes []rune) []match {
+	var out []match
+	for i := 0; i < len(runes); {
+		j := i
+		for j+1 < len(runes) && runes[j+1] == runes[i] {
+			j++
+		}
+		if j-i >= 2 {
+			out = append(out, match{i, j, math.Log10(float64(minGuessesSingleChar+1) * float64(j-i+1))})
+		}
+		i = j + 1
+	}
+	return out
+}
+
+// keyboardMatches finds runs of four or more adjacent keys along a keyboard row
+// or column ("qwerty", "asdf", "1qaz2wsx"), in either direction.
+func keyboardMatches(runes []rune) []match {
+	var out []match
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	for i := range lower {
+		for j := i + 3; j < len(lower); j++ {
+			s := string(lower[i : j+1])
+			for _, row := range keyboardRows {
+				if strings.Contains(row, s) || strings.Contains(row, reverse(s)) {
+					g := keyboardStartingKeys * float64(j-i) * uppercaseVariations(runes[i:j+1])
+					out = append(out, match{i, j, math.Log10(g)})
+					break
+				}
+			}
+		}
+	}
+	return out
+}
+
+// yearMatches finds four-digit years from 1900 to 2099; recent ones are cheapest.
+func yearMatches(runes []rune) []match {
+	var out []match
+	now := time.Now().Year()
+	for i := 0; i+4 <= len(runes); i++ {
+		year := 0
+		for _, r := range runes[i : i+4] {
+			if r < '0' || r > '9' {
+				year = -1
+				break
+			}
+			year = year*10 + int(r-'0')
+		}
+		if year >= 1900 && year <= 2099 {
+			space := max(now-year, year-now, minYearSpace)
+			out = append(out, match{i, i + 3, math.Log10(float64(space))})
+		}
+	}
+	return out
+}
+
+func reverse(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
+
+func binomial(n, k int) float64 {
+	v := 1.0
+	for i := 1; i <= k; i++ {
+		v = v * float64(n-k+i) / float64(i)
+	}
+	return v
+}
+
+// log10Add returns log10(10^a + 10^b).
+func log10Add(a, b float64) float64 {
+	hi, lo := math.Max(a, b), math.Min(a, b)
+	return hi + math.Log10(1+math.Pow(10, lo-hi))
+}
@@ -4,10 +4,31 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"net"
+	"strings"
 	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/watup-lk/identity-service/internal/auditchain"
+	"github.com/watup-lk/identity-service/internal/auditlog"
+	"github.com/watup-lk/identity-service/internal/outbox"
 )
 
-var ErrNotFound = errors.New("record not found")
+var (
+	ErrNotFound = errors.New("record not found")
+	// ErrUserAlreadyExists is returned when a write would give two accounts the same email.
+	ErrUserAlreadyExists = errors.New("email already registered")
+	// ErrIdentityAlreadyLinked is returned when an external account is already linked,
+	// or the user already has an account of that provider linked.
+	ErrIdentityAlreadyLinked = errors.New("external account already linked")
+	// ErrCredentialAlreadyRegistered is returned when a WebAuthn credential id is registered already.
+	ErrCredentialAlreadyRegistered = errors.New("credential already registered")
+	// ErrExportPending is returned when the user already has an export being built.
+	ErrExportPending = errors.New("data export already pending")
+)
 
 type User struct {
 	ID           string
@@ -17,14 +38,186 @@ type User struct {
 	Age          *int // nullable
 	IsActive     bool
 	CreatedAt    time.Time
+	UpdatedAt    time.Time // bumped by a trigger on every change to the row
+
+	FailedLoginCount int        // consecutive failed login attempts
+	LockedUntil      *time.Time // nil = not locked
+	EmailVerifiedAt  *time.Time // nil = email not yet verified
+	DeletedAt        *time.Time // set when the user asked for the account to be deleted
 }
 
 type RefreshToken struct {
+	ID         string
+	UserID     string
+	FamilyID   string // shared by all tokens rotated from the same login
+	ParentID   string // token this one replaced; empty for the first in a family
+	ReplacedBy string // set once the token has been rotated
+	TokenHash  string
+	UserAgent  string // client the token was issued to
+	IPAddress  string
+	ExpiresAt  time.Time
+	CreatedAt  time.Time
+	Revoked    bool
+}
+
+// Session is a login as seen by the user: the active refresh token of one token
+// family, together with the client that last used it.
+type Session struct {
+	ID         string // token family id — stable across refresh token rotation
+	UserAgent  string
+	IPAddress  string
+	CreatedAt  time.Time // when the user logged in
+	LastUsedAt time.Time // when the current refresh token was issued
+	ExpiresAt  time.Time
+}
+
+type PasswordResetToken struct {
+	ID        string
+	UserID    string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time // nil = not yet used
+}
+
+type EmailVerificationToken struct {
 	ID        string
 	UserID    string
 	TokenHash string
 	ExpiresAt time.Time
-	Revoked   bool
+	UsedAt    *time.Time // nil = not yet used
+}
+
+// TOTPCredential is a user's authenticator enrollment.
+type TOTPCredential struct {
+	UserID       string
+	Secret       string     // base32 shared secret
+	ConfirmedAt  *time.Time // nil = enrollment started but not yet verified
+	LastUsedStep int64      // RFC 6238 step of the last accepted code
+}
+
+// EmailChange is a pending or completed change of a user's login email.
+type EmailChange struct {
+	ID               string
+	UserID           string
+	OldEmail         string
+	NewEmail         string
+	ConfirmTokenHash string
+	CancelTokenHash  string
+	ExpiresAt        time.Time  // the new address must confirm before this
+	CancelUntil      time.Time  // the old address can cancel (or undo) until this
+	ConfirmedAt      *time.Time // nil = not yet confirmed
+	CancelledAt      *time.Time // nil = not cancelled
+}
+
+// UserIdentity is an external (social login) account linked to a user.
+type UserIdentity struct {
+	ID        string
+	UserID    string
+	Provider  string
+	Subject   string // the provider's stable user id
+	Email     string // as reported by the provider when linked
+	CreatedAt time.Time
+}
+
+// OAuthState is an authorization request waiting for the provider's callback.
+type OAuthState struct {
+	StateHash    string
+	Provider     string
+	CodeVerifier string
+	Nonce        string
+	UserID       string // set when linking to a signed-in account; empty for a login
+	Reauth       bool   // UserID is re-authenticating rather than linking
+	ExpiresAt    time.Time
+}
+
+// WebAuthnCredential is a registered passkey or security key.
+type WebAuthnCredential struct {
+	ID           string
+	UserID       string
+	CredentialID []byte
+	PublicKey    []byte // COSE_Key
+	SignCount    uint32
+	AAGUID       []byte
+	Transports   []string
+	Name         string
+	CreatedAt    time.Time
+	LastUsedAt   *time.Time // nil = never used to sign in
+}
+
+// WebAuthnChallenge is a WebAuthn ceremony waiting for the authenticator's response.
+type WebAuthnChallenge struct {
+	SessionHash string
+	UserID      string // empty for a passkey login, where the user is not known yet
+	Purpose     string // "register", "login" or "mfa"
+	Challenge   []byte
+	ExpiresAt   time.Time
+}
+
+// AuditLog is one recorded auth event.
+type AuditLog struct {
+	ID        string
+	UserID    string // empty if the user was unknown, e.g. a login with an unknown email
+	EventType string
+	Success   bool
+	IPAddress string // empty if unknown or scrubbed
+	CreatedAt time.Time
+}
+
+// AuditFilter selects audit logs. Zero fields match every row.
+type AuditFilter struct {
+	UserID    string
+	EventType string
+	Success   *bool
+	Network   string    // CIDR the IP address must fall in, e.g. "10.0.0.0/8" or "203.0.113.7/32"
+	Since     time.Time // inclusive
+	Until     time.Time // exclusive
+}
+
+// AuditCursor is the last row of a page of audit logs; the next page starts after it.
+type AuditCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// AuditCount is the number of audit logs in one time bucket with one value of the
+// grouping column.
+type AuditCount struct {
+	BucketStart time.Time
+	Key         string // "" when not grouped
+	Count       int64
+}
+
+// Audit log groupings for CountAuditLogs.
+const (
+	AuditGroupNone      = ""
+	AuditGroupIP        = "ip"
+	AuditGroupEventType = "event_type"
+	AuditGroupUser      = "user_id"
+)
+
+var auditGroupColumns = map[string]string{
+	AuditGroupNone:      `''`,
+	AuditGroupIP:        `COALESCE(host(ip_address), '')`,
+	AuditGroupEventType: `event_type`,
+	AuditGroupUser:      `COALESCE(user_id::text, '')`,
+}
+
+// Data export states.
+const (
+	ExportPending = "pending"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+)
+
+// DataExport is a copy of a user's personal data, built in the background.
+type DataExport struct {
+	ID          string
+	UserID      string
+	Status      string // ExportPending, ExportReady or ExportFailed
+	Payload     []byte // the JSON bundle; nil until ready
+	RequestedAt time.Time
+	CompletedAt *time.Time
+	ExpiresAt   *time.Time // nil until ready
 }
 
 type PostgresRepo struct {
@@ -35,32 +228,60 @@ func NewPostgresRepo(db *sql.DB) *PostgresRepo {
 	return &PostgresRepo{db: db}
 }
 
-// CreateUser inserts a new user. Caller must ensure the email does not already exist.
-func (r *PostgresRepo) CreateUser(ctx context.Context, id, name, email, passwordHash string, age *int) error {
+// CreateUser inserts a new user. Returns ErrUserAlreadyExists if the email is taken,
+// compared case-insensitively, including by a signup that raced this one.
+func (r *PostgresRepo) CreateUser(ctx context.Context, id, name, email, passwordHash string, age *int, events ...outbox.Event) error {
 	const q = `
 		INSERT INTO identity_schema.users (id, name, email, password_hash, age)
 		VALUES ($1, $2, $3, $4, $5)`
-	_, err := r.db.ExecContext(ctx, q, id, name, email, passwordHash, age)
-	return err
+	return r.inTxWithEvents(ctx, events, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, q, id, name, email, passwordHash, age)
+		if isUniqueViolation(err) {
+			return ErrUserAlreadyExists
+		}
+		return err
+	})
 }
 
-// UserExistsByEmail returns true if a user with the given email already exists.
+// UserExistsByEmail returns true if a user with the given email already exists, or the
+// address is reserved because its owner can still undo a change away from it. Emails
+// are compared case-insensitively.
 func (r *PostgresRepo) UserExistsByEmail(ctx context.Context, email string) (bool, error) {
 	var exists bool
-	const q = `SELECT EXISTS(SELECT 1 FROM identity_schema.users WHERE email = $1)`
+	const q = `
+		SELECT EXISTS(SELECT 1 FROM identity_schema.users WHERE LOWER(email) = LOWER($1))
+		    OR EXISTS(SELECT 1 FROM identity_schema.email_change_requests
+		              WHERE LOWER(old_email) = LOWER($1) AND confirmed_at IS NOT NULL
+		                AND cancelled_at IS NULL AND cancel_until > NOW())`
 	err := r.db.QueryRowContext(ctx, q, email).Scan(&exists)
 	return exists, err
 }
 
-// FindUserByEmail retrieves a user by their email address.
+// CountEmailsChangedByRules counts the stored addresses that stripping "+tag"
+// suffixes (when stripSubaddress is set) or dropping dots for dotlessDomains would
+// rewrite, i.e. the accounts those rules would lock out, since lookups use the
+// normalised input.
+func (r *PostgresRepo) CountEmailsChangedByRules(ctx context.Context, stripSubaddress bool, dotlessDomains []string) (int, error) {
+	const q = `
+		SELECT COUNT(*) FROM identity_schema.users
+		WHERE ($1 AND split_part(email, '@', 1) LIKE '_%+%')
+		   OR (LOWER(split_part(email, '@', 2)) = ANY($2) AND split_part(email, '@', 1) LIKE '%.%')`
+	var n int
+	err := r.db.QueryRowContext(ctx, q, stripSubaddress, pq.Array(dotlessDomains)).Scan(&n)
+	return n, err
+}
+
+// FindUserByEmail retrieves a user by their email address, ignoring case.
 func (r *PostgresRepo) FindUserByEmail(ctx context.Context, email string) (*User, error) {
 	const q = `
-		SELECT id, name, email, password_hash, age, is_active, created_at
+		SELECT id, name, email, password_hash, age, is_active, created_at, updated_at,
+		       failed_login_count, locked_until, email_verified_at, deleted_at
 		FROM identity_schema.users
-		WHERE email = $1`
+		WHERE LOWER(email) = LOWER($1)`
 	u := &User{}
 	err := r.db.QueryRowContext(ctx, q, email).Scan(
-		&u.ID, &u.Name, &u.Email, &u.PasswordHash, &u.Age, &u.IsActive, &u.CreatedAt,
+		&u.ID, &u.Name, &u.Email, &u.PasswordHash, &u.Age, &u.IsActive, &u.CreatedAt, &u.UpdatedAt,
+		&u.FailedLoginCount, &u.LockedUntil, &u.EmailVerifiedAt, &u.DeletedAt,
 	)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, ErrNotFound
@@ -71,12 +292,14 @@ func (r *PostgresRepo) FindUserByEmail(ctx context.Context, email string) (*User
 // FindUserByID retrieves a user by their UUID.
 func (r *PostgresRepo) FindUserByID(ctx context.Context, id string) (*User, error) {
 	const q = `
-		SELECT id, name, email, password_hash, age, is_active, created_at
+		SELECT id, name, email, password_hash, age, is_active, created_at, updated_at,
+		       failed_login_count, locked_until, email_verified_at, deleted_at
 		FROM identity_schema.users
 		WHERE id = $1`
 	u := &User{}
 	err := r.db.QueryRowContext(ctx, q, id).Scan(
-		&u.ID, &u.Name, &u.Email, &u.PasswordHash, &u.Age, &u.IsActive, &u.CreatedAt,
+		&u.ID, &u.Name, &u.Email, &u.PasswordHash, &u.Age, &u.IsActive, &u.CreatedAt, &u.UpdatedAt,
+		&u.FailedLoginCount, &u.LockedUntil, &u.EmailVerifiedAt, &u.DeletedAt,
 	)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, ErrNotFound
@@ -84,24 +307,195 @@ func (r *PostgresRepo) FindUserByID(ctx context.Context, id string) (*User, erro
 	return u, err
 }
 
-// StoreRefreshToken persists a hashed refresh token for a user.
-func (r *PostgresRepo) StoreRefreshToken(ctx context.Context, id, userID, tokenHash string, expiresAt time.Time) error {
+// UpdateUserProfile sets the user's display name and age (nil clears the age).
+func (r *PostgresRepo) UpdateUserProfile(ctx context.Context, userID, name string, age *int, events ...outbox.Event) error {
+	const q = `UPDATE identity_schema.users SET name = $2, age = $3 WHERE id = $1`
+	return r.inTxWithEvents(ctx, events, func(tx *sql.Tx) error {
+		return updateOne(ctx, tx, q, userID, name, age)
+	})
+}
+
+// FindUserRoles returns the roles explicitly granted to a user, in a stable order.
+// The implicit "user" role is not stored.
+func (r *PostgresRepo) FindUserRoles(ctx context.Context, userID string) ([]string, error) {
+	const q = `SELECT role FROM identity_schema.user_roles WHERE user_id = $1 ORDER BY role`
+	rows, err := r.db.QueryContext(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []string
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// MarkUserDeleted deactivates the account and records when deletion was requested.
+// Returns ErrNotFound if the user does not exist or is already marked deleted.
+func (r *PostgresRepo) MarkUserDeleted(ctx context.Context, userID string) error {
 	const q = `
-		INSERT INTO identity_schema.refresh_tokens (id, user_id, token_hash, expires_at)
-		VALUES ($1, $2, $3, $4)`
-	_, err := r.db.ExecContext(ctx, q, id, userID, tokenHash, expiresAt)
+		UPDATE identity_schema.users SET is_active = FALSE, deleted_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL`
+	res, err := r.db.ExecContext(ctx, q, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// anonymiseUserStatements scrub one deleted user ($1). The users row is kept as a
+// tombstone with placeholder values; everything else tied to the person goes.
+var anonymiseUserStatements = []string{
+	`UPDATE identity_schema.users
+	 SET name = 'Deleted user', email = 'deleted-' || id || '@invalid', password_hash = '',
+	     age = NULL, email_verified_at = NULL, failed_login_count = 0,
+	     last_failed_login_at = NULL, locked_until = NULL, anonymised_at = NOW()
+	 WHERE id = $1`,
+	`DELETE FROM identity_schema.refresh_tokens WHERE user_id = $1`,
+	`DELETE FROM identity_schema.password_reset_tokens WHERE user_id = $1`,
+	`DELETE FROM identity_schema.email_verification_tokens WHERE user_id = $1`,
+	`DELETE FROM identity_schema.user_totp WHERE user_id = $1`,
+	`DELETE FROM identity_schema.mfa_recovery_codes WHERE user_id = $1`,
+	`DELETE FROM identity_schema.user_roles WHERE user_id = $1`,
+	`DELETE FROM identity_schema.data_exports WHERE user_id = $1`,
+	`DELETE FROM identity_schema.email_change_requests WHERE user_id = $1`,
+	`DELETE FROM identity_schema.user_identities WHERE user_id = $1`,
+	`DELETE FROM identity_schema.oauth_states WHERE user_id = $1`,
+	`DELETE FROM identity_schema.webauthn_credentials WHERE user_id = $1`,
+	`DELETE FROM identity_schema.webauthn_challenges WHERE user_id = $1`,
+	`DELETE FROM identity_schema.revoked_access_tokens WHERE user_id = $1`,
+	// ip_digest stays: it keeps the audit chain verifiable, and without the salt
+	// it reveals nothing about the address
+	`UPDATE identity_schema.audit_logs SET ip_address = NULL, ip_salt = NULL WHERE user_id = $1`,
+}
+
+// AnonymiseDeletedUsers scrubs the PII of up to limit users whose deletion was
+// requested before deletedBefore, and returns their ids. Rows are claimed with
+// SKIP LOCKED, so replicas running the purge concurrently never process the same user.
+// The events eventsFor returns for each user are recorded in the same transaction.
+func (r *PostgresRepo) AnonymiseDeletedUsers(ctx context.Context, deletedBefore time.Time, limit int, eventsFor func(userID string) []outbox.Event) ([]string, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	const claim = `
+		SELECT id FROM identity_schema.users
+		WHERE deleted_at < $1 AND anonymised_at IS NULL
+		ORDER BY deleted_at
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED`
+	rows, err := tx.QueryContext(ctx, claim, deletedBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		for _, q := range anonymiseUserStatements {
+			if _, err := tx.ExecContext(ctx, q, id); err != nil {
+				return nil, err
+			}
+		}
+		if err := insertOutbox(ctx, tx, eventsFor(id)); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// RecordFailedLogin increments the user's failed login counter and returns the new
+// count. The counter restarts at 1 if the previous failure is older than window.
+func (r *PostgresRepo) RecordFailedLogin(ctx context.Context, userID string, window time.Duration) (int, error) {
+	const q = `
+		UPDATE identity_schema.users
+		SET failed_login_count = CASE
+		        WHEN last_failed_login_at < NOW() - make_interval(secs => $2) THEN 1
+		        ELSE failed_login_count + 1
+		    END,
+		    last_failed_login_at = NOW()
+		WHERE id = $1
+		RETURNING failed_login_count`
+	var n int
+	err := r.db.QueryRowContext(ctx, q, userID, window.Seconds()).Scan(&n)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	return n, err
+}
+
+// LockUser blocks logins for the user until the given time.
+func (r *PostgresRepo) LockUser(ctx context.Context, userID string, until time.Time, events ...outbox.Event) error {
+	const q = `UPDATE identity_schema.users SET locked_until = $2 WHERE id = $1`
+	return r.inTxWithEvents(ctx, events, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, q, userID, until)
+		return err
+	})
+}
+
+// ResetFailedLogins clears the failed login counter and any lockout.
+// It is a no-op (no row rewrite) for users without failures, so it is cheap to call on every login.
+func (r *PostgresRepo) ResetFailedLogins(ctx context.Context, userID string) error {
+	const q = `
+		UPDATE identity_schema.users
+		SET failed_login_count = 0, last_failed_login_at = NULL, locked_until = NULL
+		WHERE id = $1 AND (failed_login_count > 0 OR locked_until IS NOT NULL)`
+	_, err := r.db.ExecContext(ctx, q, userID)
 	return err
 }
 
+// StoreRefreshToken persists a hashed refresh token for a user along with the client
+// it was issued to. parentID is empty for the first token of a family (i.e. on login).
+func (r *PostgresRepo) StoreRefreshToken(ctx context.Context, id, userID, familyID, parentID, tokenHash, userAgent, ipAddress string, expiresAt time.Time, events ...outbox.Event) error {
+	const q = `
+		INSERT INTO identity_schema.refresh_tokens
+		    (id, user_id, family_id, parent_id, token_hash, user_agent, ip_address, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7::inet, $8)`
+	return r.inTxWithEvents(ctx, events, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, q, id, userID, familyID, nullIfEmpty(parentID), tokenHash,
+			nullIfEmpty(userAgent), inetOrNull(ipAddress), expiresAt)
+		return err
+	})
+}
+
 // FindRefreshToken looks up a refresh token by its hash.
 func (r *PostgresRepo) FindRefreshToken(ctx context.Context, tokenHash string) (*RefreshToken, error) {
 	const q = `
-		SELECT id, user_id, token_hash, expires_at, revoked
+		SELECT id, user_id, family_id, COALESCE(parent_id::text, ''), COALESCE(replaced_by::text, ''),
+		       token_hash, COALESCE(user_agent, ''), COALESCE(host(ip_address), ''),
+		       expires_at, created_at, revoked
 		FROM identity_schema.refresh_tokens
 		WHERE token_hash = $1`
 	rt := &RefreshToken{}
 	err := r.db.QueryRowContext(ctx, q, tokenHash).Scan(
-		&rt.ID, &rt.UserID, &rt.TokenHash, &rt.ExpiresAt, &rt.Revoked,
+		&rt.ID, &rt.UserID, &rt.FamilyID, &rt.ParentID, &rt.ReplacedBy,
+		&rt.TokenHash, &rt.UserAgent, &rt.IPAddress,
+		&rt.ExpiresAt, &rt.CreatedAt, &rt.Revoked,
 	)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, ErrNotFound
@@ -109,43 +503,1317 @@ func (r *PostgresRepo) FindRefreshToken(ctx context.Context, tokenHash string) (
 	return rt, err
 }
 
+// RotateRefreshToken revokes an active refresh token, stamps when it was used and
+// records the id of the token replacing it. Returns false if the token was already
+// revoked — the conditional UPDATE means only one of two concurrent refreshes with
+// the same token can win.
+func (r *PostgresRepo) RotateRefreshToken(ctx context.Context, tokenHash, replacedBy string) (bool, error) {
+	const q = `
+		UPDATE identity_schema.refresh_tokens SET revoked = TRUE, replaced_by = $2, last_used_at = NOW()
+		WHERE token_hash = $1 AND revoked = FALSE`
+	res, err := r.db.ExecContext(ctx, q, tokenHash, replacedBy)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// RevokeTokenFamily revokes every refresh token descended from the same login.
+func (r *PostgresRepo) RevokeTokenFamily(ctx context.Context, familyID string, events ...outbox.Event) error {
+	const q = `UPDATE identity_schema.refresh_tokens SET revoked = TRUE WHERE family_id = $1 AND revoked = FALSE`
+	return r.inTxWithEvents(ctx, events, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, q, familyID)
+		return err
+	})
+}
+
+// ListActiveSessions returns one row per token family that still has a usable
+// refresh token, most recently used first. A family's first token id equals the
+// family id, so joining on it yields the original login time.
+func (r *PostgresRepo) ListActiveSessions(ctx context.Context, userID string) ([]Session, error) {
+	const q = `
+		SELECT t.family_id, COALESCE(t.user_agent, ''), COALESCE(host(t.ip_address), ''),
+		       COALESCE(f.created_at, t.created_at), t.created_at, t.expires_at
+		FROM identity_schema.refresh_tokens t
+		LEFT JOIN identity_schema.refresh_tokens f ON f.id = t.family_id
+		WHERE t.user_id = $1 AND t.revoked = FALSE AND t.expires_at > NOW()
+		ORDER BY t.created_at DESC`
+	rows, err := r.db.QueryContext(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []Session
+	for rows.Next() {
+		var s Session
+		if err := rows.Scan(&s.ID, &s.UserAgent, &s.IPAddress, &s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
 // RevokeRefreshToken marks a refresh token as revoked.
-func (r *PostgresRepo) RevokeRefreshToken(ctx context.Context, tokenHash string) error {
+func (r *PostgresRepo) RevokeRefreshToken(ctx context.Context, tokenHash string, events ...outbox.Event) error {
 	const q = `UPDATE identity_schema.refresh_tokens SET revoked = TRUE WHERE token_hash = $1`
-	_, err := r.db.ExecContext(ctx, q, tokenHash)
-	return err
+	return r.inTxWithEvents(ctx, events, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, q, tokenHash)
+		return err
+	})
 }
 
 // RevokeAllUserTokens revokes all active refresh tokens for a user (e.g., on password change).
-func (r *PostgresRepo) RevokeAllUserTokens(ctx context.Context, userID string) error {
+func (r *PostgresRepo) RevokeAllUserTokens(ctx context.Context, userID string, events ...outbox.Event) error {
 	const q = `UPDATE identity_schema.refresh_tokens SET revoked = TRUE WHERE user_id = $1 AND revoked = FALSE`
-	_, err := r.db.ExecContext(ctx, q, userID)
+	return r.inTxWithEvents(ctx, events, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, q, userID)
+		return err
+	})
+}
+
+// RevokeAllUserTokensExcept revokes all active refresh tokens for a user apart from
+// the one identified by keepTokenHash (the caller's current session).
+func (r *PostgresRepo) RevokeAllUserTokensExcept(ctx context.Context, userID, keepTokenHash string) error {
+	const q = `
+		UPDATE identity_schema.refresh_tokens SET revoked = TRUE
+		WHERE user_id = $1 AND revoked = FALSE AND token_hash <> $2`
+	_, err := r.db.ExecContext(ctx, q, userID, keepTokenHash)
+	return err
+}
+
+// UpdatePasswordHash replaces a user's password hash.
+func (r *PostgresRepo) UpdatePasswordHash(ctx context.Context, userID, passwordHash string, events ...outbox.Event) error {
+	const q = `UPDATE identity_schema.users SET password_hash = $2 WHERE id = $1`
+	return r.inTxWithEvents(ctx, events, func(tx *sql.Tx) error {
+		return updateOne(ctx, tx, q, userID, passwordHash)
+	})
+}
+
+// StorePasswordResetToken persists a hashed one-time password reset token.
+func (r *PostgresRepo) StorePasswordResetToken(ctx context.Context, id, userID, tokenHash string, expiresAt time.Time, events ...outbox.Event) error {
+	const q = `
+		INSERT INTO identity_schema.password_reset_tokens (id, user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)`
+	return r.inTxWithEvents(ctx, events, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, q, id, userID, tokenHash, expiresAt)
+		return err
+	})
+}
+
+// FindPasswordResetToken returns an unused, unexpired reset token without consuming
+// it. Returns ErrNotFound otherwise.
+func (r *PostgresRepo) FindPasswordResetToken(ctx context.Context, tokenHash string) (*PasswordResetToken, error) {
+	const q = `
+		SELECT id, user_id, token_hash, expires_at, used_at
+		FROM identity_schema.password_reset_tokens
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()`
+	t := &PasswordResetToken{}
+	err := r.db.QueryRowContext(ctx, q, tokenHash).Scan(
+		&t.ID, &t.UserID, &t.TokenHash, &t.ExpiresAt, &t.UsedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return t, err
+}
+
+// ResetPassword consumes an unused, unexpired reset token and sets its user's
+// password hash in one transaction. The user's other outstanding reset tokens are
+// marked used as well, so an older link cannot undo the reset. The UPDATE ...
+// RETURNING guarantees that two concurrent requests with the same token cannot both
+// succeed. Returns ErrNotFound if the token does not exist, has expired, or was
+// already used.
+func (r *PostgresRepo) ResetPassword(ctx context.Context, tokenHash, passwordHash string, events ...outbox.Event) (*PasswordResetToken, error) {
+	const consume = `
+		UPDATE identity_schema.password_reset_tokens
+		SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING id, user_id, token_hash, expires_at, used_at`
+	const update = `UPDATE identity_schema.users SET password_hash = $2 WHERE id = $1`
+	const consumeOthers = `
+		UPDATE identity_schema.password_reset_tokens
+		SET used_at = NOW()
+		WHERE user_id = $1 AND used_at IS NULL`
+
+	t := &PasswordResetToken{}
+	err := r.inTxWithEvents(ctx, events, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, consume, tokenHash).Scan(
+			&t.ID, &t.UserID, &t.TokenHash, &t.ExpiresAt, &t.UsedAt,
+		)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := updateOne(ctx, tx, update, t.UserID, passwordHash); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, consumeOthers, t.UserID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// StoreEmailVerificationToken persists a hashed one-time email verification token.
+func (r *PostgresRepo) StoreEmailVerificationToken(ctx context.Context, id, userID, tokenHash string, expiresAt time.Time) error {
+	const q = `
+		INSERT INTO identity_schema.email_verification_tokens (id, user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)`
+	_, err := r.db.ExecContext(ctx, q, id, userID, tokenHash, expiresAt)
+	return err
+}
+
+// ConsumeEmailVerificationToken atomically marks an unused, unexpired verification
+// token as used and returns it. Returns ErrNotFound if the token does not exist,
+// has expired, or was already used.
+func (r *PostgresRepo) ConsumeEmailVerificationToken(ctx context.Context, tokenHash string) (*EmailVerificationToken, error) {
+	const q = `
+		UPDATE identity_schema.email_verification_tokens
+		SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING id, user_id, token_hash, expires_at, used_at`
+	t := &EmailVerificationToken{}
+	err := r.db.QueryRowContext(ctx, q, tokenHash).Scan(
+		&t.ID, &t.UserID, &t.TokenHash, &t.ExpiresAt, &t.UsedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return t, err
+}
+
+// MarkEmailVerified records that the user's email address has been confirmed.
+// The original verification time is kept if it was already set.
+func (r *PostgresRepo) MarkEmailVerified(ctx context.Context, userID string, events ...outbox.Event) error {
+	const q = `
+		UPDATE identity_schema.users SET email_verified_at = COALESCE(email_verified_at, NOW())
+		WHERE id = $1`
+	return r.inTxWithEvents(ctx, events, func(tx *sql.Tx) error {
+		return updateOne(ctx, tx, q, userID)
+	})
+}
+
+// UpsertTOTPEnrollment starts (or restarts) TOTP enrollment with a new secret.
+// An already-confirmed enrollment is never overwritten — it must be disabled first.
+// Returns false if the user already has a confirmed authenticator.
+func (r *PostgresRepo) UpsertTOTPEnrollment(ctx context.Context, userID, secret string) (bool, error) {
+	const q = `
+		INSERT INTO identity_schema.user_totp (user_id, secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = 0, created_at = NOW()
+		WHERE identity_schema.user_totp.confirmed_at IS NULL`
+	res, err := r.db.ExecContext(ctx, q, userID, secret)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// FindTOTP returns the user's TOTP enrollment, confirmed or not.
+func (r *PostgresRepo) FindTOTP(ctx context.Context, userID string) (*TOTPCredential, error) {
+	const q = `
+		SELECT user_id, secret, confirmed_at, last_used_step
+		FROM identity_schema.user_totp
+		WHERE user_id = $1`
+	c := &TOTPCredential{}
+	err := r.db.QueryRowContext(ctx, q, userID).Scan(&c.UserID, &c.Secret, &c.ConfirmedAt, &c.LastUsedStep)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return c, err
+}
+
+// ConfirmTOTP enables MFA for the user and stores their recovery code hashes.
+// It also records step as used, so the confirmation code cannot be replayed at login.
+func (r *PostgresRepo) ConfirmTOTP(ctx context.Context, userID string, step int64, recoveryCodeHashes []string, events ...outbox.Event) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	const confirm = `
+		UPDATE identity_schema.user_totp SET confirmed_at = NOW(), last_used_step = $2
+		WHERE user_id = $1 AND confirmed_at IS NULL`
+	res, err := tx.ExecContext(ctx, confirm, userID, step)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	if err := replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+	if err := insertOutbox(ctx, tx, events); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseTOTPStep records step as the last accepted code. Returns false if that step
+// (or a later one) was already used — the conditional UPDATE makes replays of the
+// same code fail even when two requests race.
+func (r *PostgresRepo) UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error) {
+	const q = `
+		UPDATE identity_schema.user_totp SET last_used_step = $2
+		WHERE user_id = $1 AND last_used_step < $2`
+	res, err := r.db.ExecContext(ctx, q, userID, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// DeleteTOTP removes the user's authenticator and any remaining recovery codes.
+func (r *PostgresRepo) DeleteTOTP(ctx context.Context, userID string, events ...outbox.Event) error {
+	return r.inTxWithEvents(ctx, events, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM identity_schema.mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM identity_schema.user_totp WHERE user_id = $1`, userID)
+		return err
+	})
+}
+
+// ReplaceRecoveryCodes discards the user's recovery codes and stores a new set.
+func (r *PostgresRepo) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID string, codeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM identity_schema.mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	const q = `INSERT INTO identity_schema.mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)`
+	for _, h := range codeHashes {
+		if _, err := tx.ExecContext(ctx, q, userID, h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConsumeRecoveryCode marks an unused recovery code as used.
+// Returns false if the code does not exist or was already used.
+func (r *PostgresRepo) ConsumeRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	const q = `
+		UPDATE identity_schema.mfa_recovery_codes SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+	res, err := r.db.ExecContext(ctx, q, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// InsertAuditLogs records significant auth events (signup, login, logout, etc.)
+// in the identity_schema.audit_logs table for security monitoring, in one INSERT,
+// as the next links of the audit hash chain (see auditchain).
+// UserID may be empty for events where the user is unknown (e.g. login_failed with unknown email).
+func (r *PostgresRepo) InsertAuditLogs(ctx context.Context, events []auditlog.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	// Batches are chained one at a time: the lock is held until the batch commits
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(`+auditChainLockClass+`)`); err != nil {
+		return err
+	}
+	seq, prev, err := auditChainHead(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	const columns = 11
+	var q strings.Builder
+	q.WriteString(`
+		INSERT INTO identity_schema.audit_logs
+			(id, user_id, event_type, success, ip_address, created_at, seq, prev_hash, row_hash, ip_salt, ip_digest)
+		VALUES `)
+	args := make([]any, 0, len(events)*columns)
+	for i, ev := range events {
+		rec := auditchain.Record{
+			ID:        uuid.New().String(),
+			UserID:    ev.UserID,
+			EventType: ev.EventType,
+			Success:   ev.Success,
+			IPAddress: ev.IPAddress,
+			CreatedAt: ev.CreatedAt,
+		}
+		auditchain.Seal(&rec, seq+1, prev)
+		seq, prev = rec.Seq, rec.Hash
+
+		if i > 0 {
+			q.WriteString(", ")
+		}
+		n := i * columns
+		fmt.Fprintf(&q, "($%d, $%d, $%d, $%d, $%d::inet, $%d, $%d, $%d, $%d, $%d, $%d)",
+			n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10, n+11)
+		// Convert empty strings to nil so PostgreSQL stores NULL
+		// (empty string is not a valid UUID or INET value)
+		args = append(args, rec.ID, nullIfEmpty(rec.UserID), rec.EventType, rec.Success,
+			nullIfEmpty(rec.IPAddress), rec.CreatedAt, rec.Seq, rec.PrevHash, rec.Hash, rec.IPSalt, rec.IPDigest)
+	}
+	if _, err := tx.ExecContext(ctx, q.String(), args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListAuditLogs returns every audit event recorded for the user, oldest first.
+func (r *PostgresRepo) ListAuditLogs(ctx context.Context, userID string) ([]AuditLog, error) {
+	const q = `
+		SELECT ` + auditLogColumns + `
+		FROM identity_schema.audit_logs
+		WHERE user_id = $1
+		ORDER BY created_at`
+	rows, err := r.db.QueryContext(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanAuditLogs(rows)
+}
+
+const auditLogColumns = `id, COALESCE(user_id::text, ''), event_type, success, COALESCE(host(ip_address), ''), created_at`
+
+func scanAuditLogs(rows *sql.Rows) ([]AuditLog, error) {
+	defer rows.Close()
+	var logs []AuditLog
+	for rows.Next() {
+		var l AuditLog
+		if err := rows.Scan(&l.ID, &l.UserID, &l.EventType, &l.Success, &l.IPAddress, &l.CreatedAt); err != nil {
+			return nil, err
+		}
+		logs = append(logs, l)
+	}
+	return logs, rows.Err()
+}
+
+// QueryAuditLogs returns up to limit audit logs matching f, newest first, starting
+// after the cursor if one is given. Paging walks idx_audit_logs_created_id; the id
+// breaks ties between rows recorded in the same microsecond.
+func (r *PostgresRepo) QueryAuditLogs(ctx context.Context, f AuditFilter, after *AuditCursor, limit int) ([]AuditLog, error) {
+	where, args := auditConditions(f)
+	if after != nil {
+		args = append(args, after.CreatedAt, after.ID)
+		where = append(where, fmt.Sprintf("(created_at, id) < ($%d, $%d::uuid)", len(args)-1, len(args)))
+	}
+	args = append(args, limit)
+	q := `SELECT ` + auditLogColumns + ` FROM identity_schema.audit_logs` + whereClause(where) +
+		fmt.Sprintf(` ORDER BY created_at DESC, id DESC LIMIT $%d`, len(args))
+
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanAuditLogs(rows)
+}
+
+// CountAuditLogs counts the audit logs matching f per bucket ("hour" or "day", in
+// UTC) and per value of the groupBy column, newest bucket first and the largest
+// counts first within a bucket. At most limit counts are returned.
+func (r *PostgresRepo) CountAuditLogs(ctx context.Context, f AuditFilter, bucket, groupBy string, limit int) ([]AuditCount, error) {
+	key, ok := auditGroupColumns[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("unknown audit log grouping %q", groupBy)
+	}
+	if bucket != "hour" && bucket != "day" {
+		return nil, fmt.Errorf("unknown audit log bucket %q", bucket)
+	}
+	where, args := auditConditions(f)
+	args = append(args, limit)
+	q := `SELECT date_trunc('` + bucket + `', created_at AT TIME ZONE 'UTC'), ` + key + `, COUNT(*)
+		FROM identity_schema.audit_logs` + whereClause(where) +
+		fmt.Sprintf(` GROUP BY 1, 2 ORDER BY 1 DESC, 3 DESC, 2 LIMIT $%d`, len(args))
+
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []AuditCount
+	for rows.Next() {
+		var c AuditCount
+		if err := rows.Scan(&c.BucketStart, &c.Key, &c.Count); err != nil {
+			return nil, err
+		}
+		// The bucket is a timestamp without time zone, already in UTC
+		c.BucketStart = time.Date(c.BucketStart.Year(), c.BucketStart.Month(), c.BucketStart.Day(),
+			c.BucketStart.Hour(), 0, 0, 0, time.UTC)
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// auditConditions turns an AuditFilter into SQL conditions and their arguments.
+func auditConditions(f AuditFilter) ([]string, []any) {
+	var where []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if f.UserID != "" {
+		add("user_id = $%d::uuid", f.UserID)
+	}
+	if f.EventType != "" {
+		add("event_type = $%d", f.EventType)
+	}
+	if f.Success != nil {
+		add("success = $%d", *f.Success)
+	}
+	if f.Network != "" {
+		add("ip_address <<= $%d::inet", f.Network)
+	}
+	if !f.Since.IsZero() {
+		add("created_at >= $%d", f.Since)
+	}
+	if !f.Until.IsZero() {
+		add("created_at < $%d", f.Until)
+	}
+	return where, args
+}
+
+func whereClause(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conds, " AND ")
+}
+
+// auditChainLockClass is the advisory lock serialising writers of the audit chain.
+const auditChainLockClass = `hashtext('identity_schema.audit_logs')`
+
+// rowQuerier is a *sql.DB or *sql.Tx.
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// auditChainHead returns the seq and hash of the last chained audit log, or 0 and
+// auditchain.Genesis if there is none yet.
+func auditChainHead(ctx context.Context, q rowQuerier) (int64, []byte, error) {
+	var seq int64
+	var hash []byte
+	err := q.QueryRowContext(ctx, `
+		SELECT seq, row_hash FROM identity_schema.audit_logs
+		WHERE seq IS NOT NULL
+		ORDER BY seq DESC LIMIT 1`).Scan(&seq, &hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, auditchain.Genesis, nil
+	}
+	return seq, hash, err
+}
+
+// AuditChainHead returns the seq and hash of the last chained audit log.
+func (r *PostgresRepo) AuditChainHead(ctx context.Context) (int64, []byte, error) {
+	return auditChainHead(ctx, r.db)
+}
+
+// ScanAuditChain returns up to limit chained audit logs after seq afterSeq, in
+// chain order.
+func (r *PostgresRepo) ScanAuditChain(ctx context.Context, afterSeq int64, limit int) ([]auditchain.Record, error) {
+	const q = `
+		SELECT seq, id, COALESCE(user_id::text, ''), event_type, success, COALESCE(host(ip_address), ''),
+		       created_at, ip_salt, ip_digest, prev_hash, row_hash
+		FROM identity_schema.audit_logs
+		WHERE seq > $1
+		ORDER BY seq
+		LIMIT $2`
+	rows, err := r.db.QueryContext(ctx, q, afterSeq, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recs []auditchain.Record
+	for rows.Next() {
+		var rec auditchain.Record
+		if err := rows.Scan(&rec.Seq, &rec.ID, &rec.UserID, &rec.EventType, &rec.Success, &rec.IPAddress,
+			&rec.CreatedAt, &rec.IPSalt, &rec.IPDigest, &rec.PrevHash, &rec.Hash); err != nil {
+			return nil, err
+		}
+		recs = append(recs, rec)
+	}
+	return recs, rows.Err()
+}
+
+// CountUnchainedAuditLogs counts audit logs without a place in the chain that were
+// recorded at or after since. Once the chain has started, every row should have one.
+func (r *PostgresRepo) CountUnchainedAuditLogs(ctx context.Context, since time.Time) (int64, error) {
+	const q = `SELECT COUNT(*) FROM identity_schema.audit_logs WHERE seq IS NULL AND created_at >= $1`
+	var n int64
+	err := r.db.QueryRowContext(ctx, q, since).Scan(&n)
+	return n, err
+}
+
+// InsertAuditCheckpoint records a signed checkpoint of the chain. A checkpoint for
+// the same seq, taken by another replica, is kept.
+func (r *PostgresRepo) InsertAuditCheckpoint(ctx context.Context, cp auditchain.Checkpoint) error {
+	const q = `
+		INSERT INTO identity_schema.audit_checkpoints (seq, row_hash, token, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (seq) DO NOTHING`
+	_, err := r.db.ExecContext(ctx, q, cp.Seq, cp.Hash, cp.Token, cp.CreatedAt)
+	return err
+}
+
+// ListAuditCheckpoints returns every checkpoint of the chain, oldest first.
+func (r *PostgresRepo) ListAuditCheckpoints(ctx context.Context) ([]auditchain.Checkpoint, error) {
+	const q = `SELECT seq, row_hash, token, created_at FROM identity_schema.audit_checkpoints ORDER BY seq`
+	rows, err := r.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cps []auditchain.Checkpoint
+	for rows.Next() {
+		var cp auditchain.Checkpoint
+		if err := rows.Scan(&cp.Seq, &cp.Hash, &cp.Token, &cp.CreatedAt); err != nil {
+			return nil, err
+		}
+		cps = append(cps, cp)
+	}
+	return cps, rows.Err()
+}
+
+// CreateDataExport records a new pending export, replacing the user's earlier ones
+// so that at most one bundle per user is stored. A pending export requested before
+// staleBefore is replaced as well; a newer one is kept and ErrExportPending returned,
+// also when it was created by a request that raced this one.
+func (r *PostgresRepo) CreateDataExport(ctx context.Context, id, userID string, staleBefore time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	const del = `
+		DELETE FROM identity_schema.data_exports
+		WHERE user_id = $1 AND (status <> 'pending' OR requested_at < $2)`
+	if _, err := tx.ExecContext(ctx, del, userID, staleBefore); err != nil {
+		return err
+	}
+	// idx_data_exports_pending allows one pending export per user
+	const q = `
+		INSERT INTO identity_schema.data_exports (id, user_id, status)
+		VALUES ($1, $2, 'pending')`
+	if _, err := tx.ExecContext(ctx, q, id, userID); err != nil {
+		if isUniqueViolation(err) {
+			return ErrExportPending
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// FindLatestDataExport returns the user's most recent export, including the bundle
+// once it is ready. Returns ErrNotFound if none exists.
+func (r *PostgresRepo) FindLatestDataExport(ctx context.Context, userID string) (*DataExport, error) {
+	const q = `
+		SELECT id, user_id, status, payload, requested_at, completed_at, expires_at
+		FROM identity_schema.data_exports
+		WHERE user_id = $1
+		ORDER BY requested_at DESC
+		LIMIT 1`
+	e := &DataExport{}
+	err := r.db.QueryRowContext(ctx, q, userID).Scan(
+		&e.ID, &e.UserID, &e.Status, &e.Payload, &e.RequestedAt, &e.CompletedAt, &e.ExpiresAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return e, err
+}
+
+// CompleteDataExport stores the bundle of a pending export and makes it downloadable
+// until expiresAt. Returns ErrNotFound if the export was replaced in the meantime.
+func (r *PostgresRepo) CompleteDataExport(ctx context.Context, id string, payload []byte, expiresAt time.Time) error {
+	const q = `
+		UPDATE identity_schema.data_exports
+		SET status = 'ready', payload = $2, completed_at = NOW(), expires_at = $3
+		WHERE id = $1 AND status = 'pending'`
+	res, err := r.db.ExecContext(ctx, q, id, payload, expiresAt)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// FailDataExport marks a pending export as failed.
+func (r *PostgresRepo) FailDataExport(ctx context.Context, id string) error {
+	const q = `
+		UPDATE identity_schema.data_exports SET status = 'failed', completed_at = NOW()
+		WHERE id = $1 AND status = 'pending'`
+	_, err := r.db.ExecContext(ctx, q, id)
 	return err
 }
 
-// InsertAuditLog records a significant auth event (signup, login, logout, etc.)
-// in the identity_schema.audit_logs table for security monitoring.
-// userID may be empty for events where the user is unknown (e.g. login_failed with unknown email).
-func (r *PostgresRepo) InsertAuditLog(ctx context.Context, userID, eventType string, success bool, ipAddress string) error {
+// DeleteExpiredDataExports removes exports whose download window has passed and
+// returns how many were deleted.
+func (r *PostgresRepo) DeleteExpiredDataExports(ctx context.Context) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM identity_schema.data_exports WHERE expires_at < NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// CreateEmailChange stores a new email change request, cancelling the user's earlier
+// requests that were not confirmed yet.
+func (r *PostgresRepo) CreateEmailChange(ctx context.Context, c *EmailChange) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	const cancel = `
+		UPDATE identity_schema.email_change_requests SET cancelled_at = NOW()
+		WHERE user_id = $1 AND confirmed_at IS NULL AND cancelled_at IS NULL`
+	if _, err := tx.ExecContext(ctx, cancel, c.UserID); err != nil {
+		return err
+	}
 	const q = `
-		INSERT INTO identity_schema.audit_logs (user_id, event_type, success, ip_address)
-		VALUES ($1, $2, $3, $4::inet)`
+		INSERT INTO identity_schema.email_change_requests
+		    (id, user_id, old_email, new_email, confirm_token_hash, cancel_token_hash, expires_at, cancel_until)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	if _, err := tx.ExecContext(ctx, q, c.ID, c.UserID, c.OldEmail, c.NewEmail,
+		c.ConfirmTokenHash, c.CancelTokenHash, c.ExpiresAt, c.CancelUntil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+const emailChangeColumns = `
+	id, user_id, old_email, new_email, confirm_token_hash, cancel_token_hash,
+	expires_at, cancel_until, confirmed_at, cancelled_at`
+
+func scanEmailChange(row *sql.Row) (*EmailChange, error) {
+	c := &EmailChange{}
+	err := row.Scan(&c.ID, &c.UserID, &c.OldEmail, &c.NewEmail, &c.ConfirmTokenHash, &c.CancelTokenHash,
+		&c.ExpiresAt, &c.CancelUntil, &c.ConfirmedAt, &c.CancelledAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return c, err
+}
+
+// ConfirmEmailChange swaps in the new address of the open request identified by its
+// confirmation token, and marks the address verified. The uniqueness of the address is
+// enforced by the unique index on LOWER(users.email) in the same transaction, so two
+// accounts confirming the same address cannot both succeed; the loser gets
+// ErrUserAlreadyExists, as does an address its previous owner can still reclaim.
+// Returns ErrNotFound if the token is unknown, used, cancelled or expired, or the
+// account's email changed in the meantime. The events eventsFor returns for the
+// request are recorded in the same transaction.
+func (r *PostgresRepo) ConfirmEmailChange(ctx context.Context, confirmTokenHash string, eventsFor func(c *EmailChange) []outbox.Event) (*EmailChange, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	c, err := scanEmailChange(tx.QueryRowContext(ctx, `
+		SELECT`+emailChangeColumns+`
+		FROM identity_schema.email_change_requests
+		WHERE confirm_token_hash = $1 AND confirmed_at IS NULL AND cancelled_at IS NULL AND expires_at > NOW()
+		FOR UPDATE`, confirmTokenHash))
+	if err != nil {
+		return nil, err
+	}
+
+	var reserved bool
+	const reservedQ = `
+		SELECT EXISTS(SELECT 1 FROM identity_schema.email_change_requests
+		              WHERE LOWER(old_email) = LOWER($1) AND user_id <> $2 AND confirmed_at IS NOT NULL
+		                AND cancelled_at IS NULL AND cancel_until > NOW())`
+	if err := tx.QueryRowContext(ctx, reservedQ, c.NewEmail, c.UserID).Scan(&reserved); err != nil {
+		return nil, err
+	}
+	if reserved {
+		return nil, ErrUserAlreadyExists
+	}
+
+	const swap = `
+		UPDATE identity_schema.users SET email = $3, email_verified_at = NOW()
+		WHERE id = $1 AND email = $2`
+	if err := execOne(ctx, tx, swap, c.UserID, c.OldEmail, c.NewEmail); err != nil {
+		return nil, err
+	}
+	const confirm = `UPDATE identity_schema.email_change_requests SET confirmed_at = NOW() WHERE id = $1`
+	if _, err := tx.ExecContext(ctx, confirm, c.ID); err != nil {
+		return nil, err
+	}
+	// Links sent to the old address must not verify the new one
+	const dropVerify = `DELETE FROM identity_schema.email_verification_tokens WHERE user_id = $1`
+	if _, err := tx.ExecContext(ctx, dropVerify, c.UserID); err != nil {
+		return nil, err
+	}
+	if err := insertOutbox(ctx, tx, eventsFor(c)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	c.ConfirmedAt = &now
+	return c, nil
+}
+
+// CancelEmailChange cancels the request identified by the token sent to the old
+// address. If the change was already confirmed, the old address is restored. Returns
+// ErrNotFound if the token is unknown, already used or past its window, and
+// ErrUserAlreadyExists if the old address can no longer be restored. The events
+// eventsFor returns for the request, as it was before cancelling, are recorded in
+// the same transaction.
+func (r *PostgresRepo) CancelEmailChange(ctx context.Context, cancelTokenHash string, eventsFor func(c *EmailChange) []outbox.Event) (*EmailChange, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	c, err := scanEmailChange(tx.QueryRowContext(ctx, `
+		SELECT`+emailChangeColumns+`
+		FROM identity_schema.email_change_requests
+		WHERE cancel_token_hash = $1 AND cancelled_at IS NULL AND cancel_until > NOW()
+		FOR UPDATE`, cancelTokenHash))
+	if err != nil {
+		return nil, err
+	}
 
-	// Convert empty strings to nil so PostgreSQL stores NULL
-	// (empty string is not a valid UUID or INET value)
-	var uid interface{} = userID
-	if userID == "" {
-		uid = nil
+	if c.ConfirmedAt != nil {
+		const restore = `
+			UPDATE identity_schema.users SET email = $3, email_verified_at = NOW()
+			WHERE id = $1 AND email = $2`
+		if err := execOne(ctx, tx, restore, c.UserID, c.NewEmail, c.OldEmail); err != nil {
+			return nil, err
+		}
 	}
-	var ip interface{} = ipAddress
-	if ipAddress == "" {
-		ip = nil
+	const cancel = `UPDATE identity_schema.email_change_requests SET cancelled_at = NOW() WHERE id = $1`
+	if _, err := tx.ExecContext(ctx, cancel, c.ID); err != nil {
+		return nil, err
 	}
+	if err := insertOutbox(ctx, tx, eventsFor(c)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	c.CancelledAt = &now
+	return c, nil
+}
 
-	_, err := r.db.ExecContext(ctx, q, uid, eventType, success, ip)
+// StoreOAuthState saves an authorization request, removing abandoned ones.
+func (r *PostgresRepo) StoreOAuthState(ctx context.Context, st *OAuthState) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM identity_schema.oauth_states WHERE expires_at < NOW()`); err != nil {
+		return err
+	}
+	const q = `
+		INSERT INTO identity_schema.oauth_states (state_hash, provider, code_verifier, nonce, user_id, reauth, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := r.db.ExecContext(ctx, q, st.StateHash, st.Provider, st.CodeVerifier, st.Nonce, nullIfEmpty(st.UserID), st.Reauth, st.ExpiresAt)
 	return err
 }
 
+// ConsumeOAuthState atomically deletes and returns an unexpired authorization request,
+// so each state is accepted once. Returns ErrNotFound otherwise.
+func (r *PostgresRepo) ConsumeOAuthState(ctx context.Context, stateHash string) (*OAuthState, error) {
+	const q = `
+		DELETE FROM identity_schema.oauth_states
+		WHERE state_hash = $1 AND expires_at > NOW()
+		RETURNING state_hash, provider, code_verifier, nonce, COALESCE(user_id::text, ''), reauth, expires_at`
+	st := &OAuthState{}
+	err := r.db.QueryRowContext(ctx, q, stateHash).Scan(
+		&st.StateHash, &st.Provider, &st.CodeVerifier, &st.Nonce, &st.UserID, &st.Reauth, &st.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return st, err
+}
+
+// FindUserIdentity returns the link for a provider account, or ErrNotFound.
+func (r *PostgresRepo) FindUserIdentity(ctx context.Context, provider, subject string) (*UserIdentity, error) {
+	const q = `
+		SELECT id, user_id, provider, subject, COALESCE(email, ''), created_at
+		FROM identity_schema.user_identities
+		WHERE provider = $1 AND subject = $2`
+	id := &UserIdentity{}
+	err := r.db.QueryRowContext(ctx, q, provider, subject).Scan(
+		&id.ID, &id.UserID, &id.Provider, &id.Subject, &id.Email, &id.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return id, err
+}
+
+// ListUserIdentities returns the external accounts linked to a user, oldest first.
+func (r *PostgresRepo) ListUserIdentities(ctx context.Context, userID string) ([]UserIdentity, error) {
+	const q = `
+		SELECT id, user_id, provider, subject, COALESCE(email, ''), created_at
+		FROM identity_schema.user_identities
+		WHERE user_id = $1
+		ORDER BY created_at`
+	rows, err := r.db.QueryContext(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []UserIdentity
+	for rows.Next() {
+		var id UserIdentity
+		if err := rows.Scan(&id.ID, &id.UserID, &id.Provider, &id.Subject, &id.Email, &id.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
+
+// LinkUserIdentity links an external account to an existing user. Returns
+// ErrIdentityAlreadyLinked if the account is linked already, to anyone, or the user
+// has another account of the same provider linked.
+func (r *PostgresRepo) LinkUserIdentity(ctx context.Context, id *UserIdentity, events ...outbox.Event) error {
+	const q = `
+		INSERT INTO identity_schema.user_identities (id, user_id, provider, subject, email)
+		VALUES ($1, $2, $3, $4, $5)`
+	return r.inTxWithEvents(ctx, events, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, q, id.ID, id.UserID, id.Provider, id.Subject, id.Email)
+		if isUniqueViolation(err) {
+			return ErrIdentityAlreadyLinked
+		}
+		return err
+	})
+}
+
+// CreateOAuthUser creates a passwordless user whose email the provider has verified,
+// together with the link to their external account, in one transaction. Returns
+// ErrUserAlreadyExists if the email is taken and ErrIdentityAlreadyLinked if the
+// external account was linked concurrently.
+func (r *PostgresRepo) CreateOAuthUser(ctx context.Context, userID, name, email string, id *UserIdentity, events ...outbox.Event) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	const user = `
+		INSERT INTO identity_schema.users (id, name, email, password_hash, email_verified_at)
+		VALUES ($1, $2, $3, '', NOW())`
+	if _, err := tx.ExecContext(ctx, user, userID, name, email); err != nil {
+		if isUniqueViolation(err) {
+			return ErrUserAlreadyExists
+		}
+		return err
+	}
+	const link = `
+		INSERT INTO identity_schema.user_identities (id, user_id, provider, subject, email)
+		VALUES ($1, $2, $3, $4, $5)`
+	if _, err := tx.ExecContext(ctx, link, id.ID, userID, id.Provider, id.Subject, id.Email); err != nil {
+		if isUniqueViolation(err) {
+			return ErrIdentityAlreadyLinked
+		}
+		return err
+	}
+	if err := insertOutbox(ctx, tx, events); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteUserIdentity unlinks the user's account of the given provider. Returns
+// ErrNotFound if none is linked.
+func (r *PostgresRepo) DeleteUserIdentity(ctx context.Context, userID, provider string, events ...outbox.Event) error {
+	const q = `DELETE FROM identity_schema.user_identities WHERE user_id = $1 AND provider = $2`
+	return r.inTxWithEvents(ctx, events, func(tx *sql.Tx) error {
+		return updateOne(ctx, tx, q, userID, provider)
+	})
+}
+
+// StoreWebAuthnChallenge saves a ceremony challenge, removing abandoned ones.
+func (r *PostgresRepo) StoreWebAuthnChallenge(ctx context.Context, c *WebAuthnChallenge) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM identity_schema.webauthn_challenges WHERE expires_at < NOW()`); err != nil {
+		return err
+	}
+	const q = `
+		INSERT INTO identity_schema.webauthn_challenges (session_hash, user_id, purpose, challenge, expires_at)
+		VALUES ($1, $2, $3, $4, $5)`
+	_, err := r.db.ExecContext(ctx, q, c.SessionHash, nullIfEmpty(c.UserID), c.Purpose, c.Challenge, c.ExpiresAt)
+	return err
+}
+
+// ConsumeWebAuthnChallenge atomically deletes and returns an unexpired ceremony
+// challenge, so each is answered once. Returns ErrNotFound otherwise.
+func (r *PostgresRepo) ConsumeWebAuthnChallenge(ctx context.Context, sessionHash string) (*WebAuthnChallenge, error) {
+	const q = `
+		DELETE FROM identity_schema.webauthn_challenges
+		WHERE session_hash = $1 AND expires_at > NOW()
+		RETURNING session_hash, COALESCE(user_id::text, ''), purpose, challenge, expires_at`
+	c := &WebAuthnChallenge{}
+	err := r.db.QueryRowContext(ctx, q, sessionHash).Scan(&c.SessionHash, &c.UserID, &c.Purpose, &c.Challenge, &c.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return c, err
+}
+
+// CreateWebAuthnCredential stores a newly registered credential. Returns
+// ErrCredentialAlreadyRegistered if its credential id is taken.
+func (r *PostgresRepo) CreateWebAuthnCredential(ctx context.Context, c *WebAuthnCredential, events ...outbox.Event) error {
+	const q = `
+		INSERT INTO identity_schema.webauthn_credentials
+			(id, user_id, credential_id, public_key, sign_count, aaguid, transports, name)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	return r.inTxWithEvents(ctx, events, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, q, c.ID, c.UserID, c.CredentialID, c.PublicKey, int64(c.SignCount),
+			c.AAGUID, pq.Array(c.Transports), c.Name)
+		if isUniqueViolation(err) {
+			return ErrCredentialAlreadyRegistered
+		}
+		return err
+	})
+}
+
+const webAuthnCredentialColumns = `id, user_id, credential_id, public_key, sign_count, aaguid, transports, name, created_at, last_used_at`
+
+func scanWebAuthnCredential(row interface{ Scan(...any) error }) (*WebAuthnCredential, error) {
+	c := &WebAuthnCredential{}
+	var signCount int64
+	err := row.Scan(&c.ID, &c.UserID, &c.CredentialID, &c.PublicKey, &signCount, &c.AAGUID,
+		pq.Array(&c.Transports), &c.Name, &c.CreatedAt, &c.LastUsedAt)
+	c.SignCount = uint32(signCount)
+	return c, err
+}
+
+// ListWebAuthnCredentials returns the user's credentials, oldest first.
+func (r *PostgresRepo) ListWebAuthnCredentials(ctx context.Context, userID string) ([]WebAuthnCredential, error) {
+	q := `SELECT ` + webAuthnCredentialColumns + `
+		FROM identity_schema.webauthn_credentials
+		WHERE user_id = $1
+		ORDER BY created_at`
+	rows, err := r.db.QueryContext(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []WebAuthnCredential
+	for rows.Next() {
+		c, err := scanWebAuthnCredential(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *c)
+	}
+	return out, rows.Err()
+}
+
+// FindWebAuthnCredential looks a credential up by the id the authenticator reports,
+// or returns ErrNotFound.
+func (r *PostgresRepo) FindWebAuthnCredential(ctx context.Context, credentialID []byte) (*WebAuthnCredential, error) {
+	q := `SELECT ` + webAuthnCredentialColumns + `
+		FROM identity_schema.webauthn_credentials
+		WHERE credential_id = $1`
+	c, err := scanWebAuthnCredential(r.db.QueryRowContext(ctx, q, credentialID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// UseWebAuthnCredential records a successful assertion. It reports false if the stored
+// counter has moved on since the credential was loaded — a concurrent use of the same
+// signature counter value, so the assertion must be rejected. Authenticators without
+// a counter always report 0 and are only checked for having been used.
+func (r *PostgresRepo) UseWebAuthnCredential(ctx context.Context, id string, prevSignCount, signCount uint32) (bool, error) {
+	const q = `
+		UPDATE identity_schema.webauthn_credentials SET sign_count = $3, last_used_at = NOW()
+		WHERE id = $1 AND sign_count = $2`
+	res, err := r.db.ExecContext(ctx, q, id, int64(prevSignCount), int64(signCount))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// DeleteWebAuthnCredential removes one of the user's credentials. Returns ErrNotFound
+// if the user has no credential with that id.
+func (r *PostgresRepo) DeleteWebAuthnCredential(ctx context.Context, userID, id string, events ...outbox.Event) error {
+	const q = `DELETE FROM identity_schema.webauthn_credentials WHERE id = $1 AND user_id = $2`
+	return r.inTxWithEvents(ctx, events, func(tx *sql.Tx) error {
+		return updateOne(ctx, tx, q, id, userID)
+	})
+}
+
+// DenyAccessTokens adds access token ids to the denylist until expiresAt, removing
+// entries that have outlived their tokens. An id is either a token's jti or a
+// session id, which denies every access token issued within that session.
+func (r *PostgresRepo) DenyAccessTokens(ctx context.Context, userID string, ids []string, expiresAt time.Time) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM identity_schema.revoked_access_tokens WHERE expires_at < NOW()`); err != nil {
+		return err
+	}
+	const q = `
+		INSERT INTO identity_schema.revoked_access_tokens (token_id, user_id, expires_at)
+		SELECT DISTINCT unnest($1::text[]), $2::uuid, $3::timestamptz
+		ON CONFLICT (token_id) DO UPDATE
+		SET expires_at = GREATEST(revoked_access_tokens.expires_at, EXCLUDED.expires_at)`
+	_, err := r.db.ExecContext(ctx, q, pq.Array(ids), userID, expiresAt)
+	return err
+}
+
+// AccessTokenDenied reports whether any of ids (a token's jti and session id) is
+// on the denylist.
+func (r *PostgresRepo) AccessTokenDenied(ctx context.Context, ids []string) (bool, error) {
+	const q = `
+		SELECT EXISTS (
+			SELECT 1 FROM identity_schema.revoked_access_tokens
+			WHERE token_id = ANY($1) AND expires_at > NOW()
+		)`
+	var denied bool
+	err := r.db.QueryRowContext(ctx, q, pq.Array(ids)).Scan(&denied)
+	return denied, err
+}
+
+// outboxLockClass namespaces the advisory locks taken on the outbox: (class, 0) is
+// held by the relay, (class, hashtext(user_id)) by a transaction recording that
+// user's events.
+const outboxLockClass = `hashtext('identity_schema.outbox')`
+
+// inTxWithEvents runs fn in a transaction that also records events in the outbox,
+// so they are published if and only if fn's change commits.
+func (r *PostgresRepo) inTxWithEvents(ctx context.Context, events []outbox.Event, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := insertOutbox(ctx, tx, events); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RecordEvents records events in the outbox on their own, for events that do not
+// announce a change to the database, e.g. a suspected passkey clone.
+func (r *PostgresRepo) RecordEvents(ctx context.Context, events ...outbox.Event) error {
+	return r.inTxWithEvents(ctx, events, func(*sql.Tx) error { return nil })
+}
+
+// insertOutbox records events in tx. Each user's events are numbered under a lock
+// held until the transaction ends, so a user's ids follow commit order and the relay
+// never sees a later event of theirs before an earlier one has committed.
+func insertOutbox(ctx context.Context, tx *sql.Tx, events []outbox.Event) error {
+	for _, ev := range events {
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(`+outboxLockClass+`, hashtext($1))`, ev.UserID); err != nil {
+			return err
+		}
+		const q = `
+			INSERT INTO identity_schema.outbox (topic, user_id, event_type)
+			VALUES ($1, $2, $3)`
+		if _, err := tx.ExecContext(ctx, q, ev.Topic, ev.UserID, ev.EventType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// outboxBatch is a claimed batch: the transaction holding the relay lock, and the
+// events it selected. The transaction writes nothing; marks are committed on their
+// own through db, so none is lost if the lock's transaction ends early.
+type outboxBatch struct {
+	db     *sql.DB
+	tx     *sql.Tx
+	events []outbox.Event
+}
+
+// ClaimOutbox takes the relay lock and returns up to limit due events in outbox
+// order. An event is held back while an earlier one of the same user waits for a
+// retry. Returns a nil Batch if another relay holds the lock.
+func (r *PostgresRepo) ClaimOutbox(ctx context.Context, limit int) (outbox.Batch, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	var locked bool
+	if err := tx.QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock(`+outboxLockClass+`, 0)`).Scan(&locked); err != nil || !locked {
+		tx.Rollback() //nolint:errcheck
+		return nil, err
+	}
+
+	const q = `
+		SELECT o.id, o.topic, o.user_id, o.event_type, o.occurred_at, o.attempts
+		FROM identity_schema.outbox o
+		WHERE o.published_at IS NULL AND o.next_attempt_at <= NOW()
+		  AND NOT EXISTS (
+		      SELECT 1 FROM identity_schema.outbox w
+		      WHERE w.user_id = o.user_id AND w.id < o.id
+		        AND w.published_at IS NULL AND w.next_attempt_at > NOW())
+		ORDER BY o.id
+		LIMIT $1`
+	rows, err := tx.QueryContext(ctx, q, limit)
+	if err != nil {
+		tx.Rollback() //nolint:errcheck
+		return nil, err
+	}
+	defer rows.Close()
+
+	b := &outboxBatch{db: r.db, tx: tx}
+	for rows.Next() {
+		var ev outbox.Event
+		if err := rows.Scan(&ev.ID, &ev.Topic, &ev.UserID, &ev.EventType, &ev.OccurredAt, &ev.Attempts); err != nil {
+			tx.Rollback() //nolint:errcheck
+			return nil, err
+		}
+		b.events = append(b.events, ev)
+	}
+	if err := rows.Err(); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *outboxBatch) Events() []outbox.Event { return b.events }
+
+// MarkPublished records that an event reached Kafka. The published_at guard makes
+// the mark happen once, whatever the relay does.
+func (b *outboxBatch) MarkPublished(ctx context.Context, id int64) error {
+	const q = `
+		UPDATE identity_schema.outbox SET published_at = NOW(), last_error = NULL
+		WHERE id = $1 AND published_at IS NULL`
+	_, err := b.db.ExecContext(ctx, q, id)
+	return err
+}
+
+// MarkFailed records a failed attempt and when to try the event again.
+func (b *outboxBatch) MarkFailed(ctx context.Context, id int64, retryAt time.Time, cause string) error {
+	const q = `
+		UPDATE identity_schema.outbox
+		SET attempts = attempts + 1, next_attempt_at = $2, last_error = $3
+		WHERE id = $1`
+	_, err := b.db.ExecContext(ctx, q, id, retryAt, cause)
+	return err
+}
+
+// Release ends the transaction, letting another relay take the lock.
+func (b *outboxBatch) Release() error { return b.tx.Rollback() }
+
+// OutboxBacklog returns the number of unpublished events and when the oldest of
+// them occurred.
+func (r *PostgresRepo) OutboxBacklog(ctx context.Context) (int, time.Time, error) {
+	const q = `
+		SELECT COUNT(*), MIN(occurred_at)
+		FROM identity_schema.outbox WHERE published_at IS NULL`
+	var n int
+	var oldest sql.NullTime
+	if err := r.db.QueryRowContext(ctx, q).Scan(&n, &oldest); err != nil {
+		return 0, time.Time{}, err
+	}
+	return n, oldest.Time, nil
+}
+
+// DeletePublishedOutbox removes events published before the given time.
+func (r *PostgresRepo) DeletePublishedOutbox(ctx context.Context, before time.Time) (int64, error) {
+	const q = `DELETE FROM identity_schema.outbox WHERE published_at < $1`
+	res, err := r.db.ExecContext(ctx, q, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// updateOne runs an UPDATE or DELETE in tx and returns ErrNotFound if it matched no row.
+func updateOne(ctx context.Context, tx *sql.Tx, q string, args ...any) error {
+	res, err := tx.ExecContext(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// execOne runs an UPDATE that must affect exactly one row, mapping "no row" to
+// ErrNotFound and a unique constraint violation to ErrUserAlreadyExists.
+func execOne(ctx context.Context, tx *sql.Tx, q string, args ...interface{}) error {
+	res, err := tx.ExecContext(ctx, q, args...)
+	if isUniqueViolation(err) {
+		return ErrUserAlreadyExists
+	}
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// isUniqueViolation reports whether err is PostgreSQL's unique_violation (23505).
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// nullIfEmpty maps "" to NULL for nullable UUID/INET columns.
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// inetOrNull maps anything that is not a valid IP address (e.g. a spoofed
+// X-Forwarded-For value) to NULL, so it cannot make the INSERT fail.
+func inetOrNull(ip string) interface{} {
+	if net.ParseIP(ip) == nil {
+		return nil
+	}
+	return ip
+}
+
 // Ping checks the database connection (used by readiness probe).
 func (r *PostgresRepo) Ping(ctx context.Context) error {
 	return r.db.PingContext(ctx)
@@ -0,0 +1,38 @@
+// Package roles defines the authorization roles carried in access tokens.
+//
+// Roles are ordered: admin implies moderator, and moderator implies user.
+// Every account holds the user role; higher roles are granted explicitly.
+package roles
+
+const (
+	User      = "user"
+	Moderator = "moderator"
+	Admin     = "admin"
+)
+
+// rank orders the roles from least to most privileged.
+var rank = map[string]int{
+	User:      1,
+	Moderator: 2,
+	Admin:     3,
+}
+
+// Valid reports whether role is a known role.
+func Valid(role string) bool {
+	_, ok := rank[role]
+	return ok
+}
+
+// Has reports whether any of the held roles grants want. Unknown roles grant nothing.
+func Has(held []string, want string) bool {
+	need, ok := rank[want]
+	if !ok {
+		return false
+	}
+	for _, r := range held {
+		if rank[r] >= need {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package roles_test
+
+import (
+	"testing"
+
+	"github.com/watup-lk/identity-service/internal/roles"
+)
+
+func TestHas(t *testing.T) {
+	tests := []struct {
+		held []string
+		want string
+		ok   bool
+	}{
+		{[]string{roles.User}, roles.User, true},
+		{[]string{roles.User}, roles.Moderator, false},
+		{[]string{roles.User, roles.Moderator}, roles.Moderator, true},
+		{[]string{roles.Admin}, roles.Moderator, true},
+		{[]string{roles.Moderator}, roles.Admin, false},
+		{nil, roles.User, false},
+		{[]string{"superuser"}, roles.User, false},
+		{[]string{roles.Admin}, "superuser", false},
+	}
+	for _, tt := range tests {
+		if got := roles.Has(tt.held, tt.want); got != tt.ok {
+			t.Errorf("Has(%v, %q) = %v, want %v", tt.held, tt.want, got, tt.ok)
+		}
+	}
+}
+
+func TestValid(t *testing.T) {
+	for _, r := range []string{roles.User, roles.Moderator, roles.Admin} {
+		if !roles.Valid(r) {
+			t.Errorf("expected %q to be valid", r)
+		}
+	}
+	if roles.Valid("root") {
+		t.Error("expected unknown role to be invalid")
+	}
+}
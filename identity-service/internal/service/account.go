@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/watup-lk/identity-service/internal/mailer"
+	"github.com/watup-lk/identity-service/internal/outbox"
+	"github.com/watup-lk/identity-service/internal/repository"
+)
+
+// purgeBatchSize bounds how many accounts one purge transaction scrubs.
+const purgeBatchSize = 100
+
+// DeleteAccount deactivates the user's account after re-checking their password, or
+// a reauthentication token for an account without one, and signs out every session. The PII is kept for the grace period — so a deletion made
+// with a stolen session can still be undone by support — and then scrubbed by
+// PurgeDeletedAccounts. Returns when the purge becomes due.
+func (s *IdentityService) DeleteAccount(ctx context.Context, userID string, proof Reauthentication, clientIP string) (time.Time, error) {
+	user, err := s.activeUser(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := s.confirmReauth(ctx, user, proof, clientIP); err != nil {
+		s.auditLog(userID, "account_delete", false, clientIP)
+		return time.Time{}, err
+	}
+
+	if err := s.repo.MarkUserDeleted(ctx, userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return time.Time{}, ErrAccountDisabled
+		}
+		return time.Time{}, fmt.Errorf("deleting account: %w", err)
+	}
+	if err := s.denySessions(ctx, userID, ""); err != nil {
+		return time.Time{}, err
+	}
+	if err := s.repo.RevokeAllUserTokens(ctx, userID); err != nil {
+		return time.Time{}, fmt.Errorf("revoking sessions: %w", err)
+	}
+	purgeAt := time.Now().Add(s.deletionGracePeriod())
+
+	go s.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Your watup.lk account has been deleted",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nYour watup.lk account has been deleted and you have been signed out everywhere. Your personal data will be permanently erased on %s.\n\nIf you did not do this, contact support before then to restore your account.\n",
+			user.Name, purgeAt.UTC().Format("2 January 2006"),
+		),
+	})
+	s.auditLog(userID, "account_delete", true, clientIP)
+
+	return purgeAt, nil
+}
+
+// PurgeDeletedAccounts scrubs the PII of every account whose grace period has ended
+// and records a user.deleted event for each, so other services can disassociate
+// their records. Returns the number of accounts purged. Safe to run on every replica.
+func (s *IdentityService) PurgeDeletedAccounts(ctx context.Context) (int, error) {
+	cutoff := time.Now().Add(-s.deletionGracePeriod())
+	total := 0
+	for {
+		ids, err := s.repo.AnonymiseDeletedUsers(ctx, cutoff, purgeBatchSize, func(id string) []outbox.Event {
+			return []outbox.Event{lifecycleEvent(id, eventUserDeleted)}
+		})
+		if err != nil {
+			return total, fmt.Errorf("anonymising deleted accounts: %w", err)
+		}
+		for _, id := range ids {
+			s.auditLog(id, "account_purge", true, "")
+		}
+		total += len(ids)
+		if len(ids) < purgeBatchSize {
+			return total, nil
+		}
+	}
+}
+
+func (s *IdentityService) deletionGracePeriod() time.Duration {
+	return time.Duration(s.cfg.AccountDeletionDays) * 24 * time.Hour
+}
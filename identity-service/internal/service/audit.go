@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/watup-lk/identity-service/internal/repository"
+)
+
+var ErrInvalidAuditQuery = errors.New("invalid audit log query")
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 500
+	maxAuditCounts       = 1000
+)
+
+// AuditQuery filters the audit log for admin tooling. Zero fields match everything.
+type AuditQuery struct {
+	UserID    string
+	EventType string
+	Success   *bool
+	IP        string // a single address or a CIDR block, e.g. "10.0.0.0/8"
+	Since     time.Time
+	Until     time.Time
+}
+
+// AuditPage is one page of audit logs, newest first. NextCursor is empty on the
+// last page.
+type AuditPage struct {
+	Logs       []repository.AuditLog
+	NextCursor string
+}
+
+// QueryAuditLogs returns a page of audit logs matching q, continuing from cursor
+// (the NextCursor of the previous page, or "" for the first). limit defaults to 50
+// and is capped at 500.
+func (s *IdentityService) QueryAuditLogs(ctx context.Context, q AuditQuery, cursor string, limit int) (*AuditPage, error) {
+	f, err := q.filter()
+	if err != nil {
+		return nil, err
+	}
+	var after *repository.AuditCursor
+	if cursor != "" {
+		if after, err = decodeAuditCursor(cursor); err != nil {
+			return nil, err
+		}
+	}
+	if limit <= 0 {
+		limit = defaultAuditPageSize
+	}
+	limit = min(limit, maxAuditPageSize)
+
+	// One row more than asked tells whether there is a next page
+	logs, err := s.repo.QueryAuditLogs(ctx, f, after, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("querying audit logs: %w", err)
+	}
+	page := &AuditPage{Logs: logs}
+	if len(logs) > limit {
+		page.Logs = logs[:limit]
+		last := page.Logs[limit-1]
+		page.NextCursor = encodeAuditCursor(last.CreatedAt, last.ID)
+	}
+	return page, nil
+}
+
+// AuditLogStats counts audit logs matching q per bucket ("hour", the default, or
+// "day") and per groupBy value (see repository.AuditGroupIP and friends), e.g. failed
+// logins per IP per hour. Without a Since, it covers the last 24 hours for hourly
+// buckets and the last 30 days for daily ones. limit is capped at 1000.
+func (s *IdentityService) AuditLogStats(ctx context.Context, q AuditQuery, bucket, groupBy string, limit int) ([]repository.AuditCount, error) {
+	f, err := q.filter()
+	if err != nil {
+		return nil, err
+	}
+	window := 24 * time.Hour
+	switch bucket {
+	case "", "hour":
+		bucket = "hour"
+	case "day":
+		window = 30 * 24 * time.Hour
+	default:
+		return nil, fmt.Errorf("%w: bucket must be hour or day", ErrInvalidAuditQuery)
+	}
+	switch groupBy {
+	case repository.AuditGroupNone, repository.AuditGroupIP, repository.AuditGroupEventType, repository.AuditGroupUser:
+	default:
+		return nil, fmt.Errorf("%w: group_by must be ip, event_type or user_id", ErrInvalidAuditQuery)
+	}
+	if f.Since.IsZero() {
+		end := f.Until
+		if end.IsZero() {
+			end = time.Now()
+		}
+		f.Since = end.Add(-window)
+	}
+	if limit <= 0 || limit > maxAuditCounts {
+		limit = maxAuditCounts
+	}
+
+	counts, err := s.repo.CountAuditLogs(ctx, f, bucket, groupBy, limit)
+	if err != nil {
+		return nil, fmt.Errorf("counting audit logs: %w", err)
+	}
+	return counts, nil
+}
+
+// filter validates the query and turns it into a repository filter.
+func (q AuditQuery) filter() (repository.AuditFilter, error) {
+	f := repository.AuditFilter{
+		EventType: q.EventType,
+		Success:   q.Success,
+		Since:     q.Since,
+		Until:     q.Until,
+	}
+	if q.UserID != "" {
+		id, err := uuid.Parse(q.UserID)
+		if err != nil {
+			return f, fmt.Errorf("%w: user_id must be a UUID", ErrInvalidAuditQuery)
+		}
+		f.UserID = id.String()
+	}
+	if q.IP != "" {
+		prefix, err := parseNetwork(q.IP)
+		if err != nil {
+			return f, fmt.Errorf("%w: ip must be an IP address or CIDR block", ErrInvalidAuditQuery)
+		}
+		f.Network = prefix.String()
+	}
+	if !q.Since.IsZero() && !q.Until.IsZero() && !q.Since.Before(q.Until) {
+		return f, fmt.Errorf("%w: since must be before until", ErrInvalidAuditQuery)
+	}
+	return f, nil
+}
+
+// parseNetwork accepts "10.0.0.0/8" or a bare address, which stands for itself.
+func parseNetwork(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		return p.Masked(), err
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), nil
+}
+
+// Audit cursors are opaque to clients: the last row's timestamp and id.
+func encodeAuditCursor(createdAt time.Time, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(createdAt.UTC().Format(time.RFC3339Nano) + "," + id))
+}
+
+func decodeAuditCursor(cursor string) (*repository.AuditCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidAuditQuery)
+	}
+	ts, id, ok := strings.Cut(string(b), ",")
+	createdAt, err := time.Parse(time.RFC3339Nano, ts)
+	if !ok || err != nil || uuid.Validate(id) != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidAuditQuery)
+	}
+	return &repository.AuditCursor{CreatedAt: createdAt, ID: id}, nil
+}
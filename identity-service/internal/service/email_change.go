@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/watup-lk/identity-service/internal/mailer"
+	"github.com/watup-lk/identity-service/internal/outbox"
+	"github.com/watup-lk/identity-service/internal/repository"
+)
+
+var (
+	ErrInvalidEmailChangeToken = errors.New("invalid or expired email change token")
+	ErrSameEmail               = errors.New("new email must differ from the current email")
+)
+
+// RequestEmailChange starts changing the user's login email after re-checking their
+// password or reauthentication token. A confirmation link goes to the new address; the old address is told and
+// gets a link to cancel the change, which also undoes it for EmailChangeDays after it
+// went through. Returns when the confirmation link expires.
+func (s *IdentityService) RequestEmailChange(ctx context.Context, userID string, proof Reauthentication, newEmail, clientIP string) (time.Time, error) {
+	user, err := s.activeUser(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := s.confirmReauth(ctx, user, proof, clientIP); err != nil {
+		s.auditLog(userID, "email_change_requested", false, clientIP)
+		return time.Time{}, err
+	}
+	newEmail = s.emails.Normalise(newEmail)
+	if strings.EqualFold(newEmail, user.Email) {
+		return time.Time{}, ErrSameEmail
+	}
+
+	// Fails early for the common case; ConfirmEmailChange re-checks atomically
+	exists, err := s.repo.UserExistsByEmail(ctx, newEmail)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("checking email: %w", err)
+	}
+	if exists {
+		return time.Time{}, ErrUserAlreadyExists
+	}
+
+	confirmToken, cancelToken := newOpaqueToken(), newOpaqueToken()
+	now := time.Now()
+	change := &repository.EmailChange{
+		ID:               uuid.New().String(),
+		UserID:           userID,
+		OldEmail:         user.Email,
+		NewEmail:         newEmail,
+		ConfirmTokenHash: hashToken(confirmToken),
+		CancelTokenHash:  hashToken(cancelToken),
+		ExpiresAt:        now.Add(time.Duration(s.cfg.EmailChangeHours) * time.Hour),
+		CancelUntil:      now.Add(time.Duration(s.cfg.EmailChangeDays) * 24 * time.Hour),
+	}
+	if err := s.repo.CreateEmailChange(ctx, change); err != nil {
+		return time.Time{}, fmt.Errorf("storing email change: %w", err)
+	}
+
+	go s.sendMail(mailer.Message{
+		To:      newEmail,
+		Subject: "Confirm your new watup.lk email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm that you want to use this address to sign in to watup.lk by opening the link below within %d hours:\n\n%s/confirm-email-change?token=%s\n\nYou will be signed out everywhere and can then sign in with this address. If you did not ask for this, you can ignore this email.\n",
+			user.Name, s.cfg.EmailChangeHours, s.cfg.FrontendURL, url.QueryEscape(confirmToken),
+		),
+	})
+	go s.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Your watup.lk email address is being changed",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nSomeone signed in to your watup.lk account asked to change its email address to %s.\n\nIf this was not you, open the link below to cancel the change. It keeps working for %d days, even after the change has been confirmed, and restores this address:\n\n%s/cancel-email-change?token=%s\n",
+			user.Name, newEmail, s.cfg.EmailChangeDays, s.cfg.FrontendURL, url.QueryEscape(cancelToken),
+		),
+	})
+	s.auditLog(userID, "email_change_requested", true, clientIP)
+
+	return change.ExpiresAt, nil
+}
+
+// ConfirmEmailChange consumes the token sent to the new address, makes it the login
+// email and revokes every session.
+func (s *IdentityService) ConfirmEmailChange(ctx context.Context, rawToken, clientIP string) error {
+	change, err := s.repo.ConfirmEmailChange(ctx, hashToken(rawToken), func(c *repository.EmailChange) []outbox.Event {
+		return []outbox.Event{securityEvent(c.UserID, "email_changed")}
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			s.auditLog("", "email_change", false, clientIP)
+			return ErrInvalidEmailChangeToken
+		case errors.Is(err, ErrUserAlreadyExists):
+			return err
+		}
+		return fmt.Errorf("confirming email change: %w", err)
+	}
+
+	if err := s.denySessions(ctx, change.UserID, ""); err != nil {
+		return err
+	}
+	if err := s.repo.RevokeAllUserTokens(ctx, change.UserID); err != nil {
+		return fmt.Errorf("revoking sessions: %w", err)
+	}
+
+	s.auditLog(change.UserID, "email_change", true, clientIP)
+
+	return nil
+}
+
+// CancelEmailChange consumes the token sent to the old address. A change that was
+// already confirmed is undone: the old address is restored and every session revoked,
+// since whoever made the change may still be signed in.
+func (s *IdentityService) CancelEmailChange(ctx context.Context, rawToken, clientIP string) error {
+	change, err := s.repo.CancelEmailChange(ctx, hashToken(rawToken), func(c *repository.EmailChange) []outbox.Event {
+		if c.ConfirmedAt == nil {
+			return nil // nothing had changed yet
+		}
+		return []outbox.Event{securityEvent(c.UserID, "email_change_reverted")}
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			s.auditLog("", "email_change_cancel", false, clientIP)
+			return ErrInvalidEmailChangeToken
+		case errors.Is(err, ErrUserAlreadyExists):
+			return err
+		}
+		return fmt.Errorf("cancelling email change: %w", err)
+	}
+
+	if change.ConfirmedAt != nil {
+		if err := s.denySessions(ctx, change.UserID, ""); err != nil {
+			return err
+		}
+		if err := s.repo.RevokeAllUserTokens(ctx, change.UserID); err != nil {
+			return fmt.Errorf("revoking sessions: %w", err)
+		}
+		go s.sendMail(mailer.Message{
+			To:      change.OldEmail,
+			Subject: "Your watup.lk email address has been restored",
+			Body: fmt.Sprintf(
+				"Hello,\n\nThe change of your watup.lk email address to %s has been undone, and every session has been signed out. Sign in with this address again.\n\nIf you did not make the change, reset your password now:\n\n%s/forgot-password\n",
+				change.NewEmail, s.cfg.FrontendURL,
+			),
+		})
+	}
+	s.auditLog(change.UserID, "email_change_cancel", true, clientIP)
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/watup-lk/identity-service/internal/mailer"
+	"github.com/watup-lk/identity-service/internal/repository"
+)
+
+var (
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+	ErrEmailAlreadyVerified     = errors.New("email is already verified")
+)
+
+// VerifyEmail consumes a verification token and marks the user's email as verified.
+func (s *IdentityService) VerifyEmail(ctx context.Context, rawToken, clientIP string) error {
+	token, err := s.repo.ConsumeEmailVerificationToken(ctx, hashToken(rawToken))
+	if err != nil {
+		s.auditLog("", "email_verify", false, clientIP)
+		return ErrInvalidVerificationToken
+	}
+
+	if err := s.repo.MarkEmailVerified(ctx, token.UserID, lifecycleEvent(token.UserID, eventEmailVerified)); err != nil {
+		return fmt.Errorf("marking email verified: %w", err)
+	}
+
+	s.auditLog(token.UserID, "email_verify", true, clientIP)
+
+	return nil
+}
+
+// ResendVerificationEmail issues a fresh verification link for an authenticated user.
+// Earlier links stay valid until they expire.
+func (s *IdentityService) ResendVerificationEmail(ctx context.Context, userID, clientIP string) error {
+	user, err := s.activeUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return ErrEmailAlreadyVerified
+	}
+	return s.sendVerificationEmail(ctx, user, clientIP)
+}
+
+// sendVerificationEmail stores a new one-time token and mails the verification link.
+func (s *IdentityService) sendVerificationEmail(ctx context.Context, user *repository.User, clientIP string) error {
+	rawToken := newOpaqueToken()
+	expiresAt := time.Now().Add(time.Duration(s.cfg.EmailVerifyHours) * time.Hour)
+	if err := s.repo.StoreEmailVerificationToken(ctx, uuid.New().String(), user.ID, hashToken(rawToken), expiresAt); err != nil {
+		return fmt.Errorf("storing verification token: %w", err)
+	}
+
+	go s.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your watup.lk email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm your email address by opening the link below within %d hours:\n\n%s/verify-email?token=%s\n\nIf you did not create an account, you can ignore this email.\n",
+			user.Name, s.cfg.EmailVerifyHours, s.cfg.FrontendURL, url.QueryEscape(rawToken),
+		),
+	})
+	s.auditLog(user.ID, "email_verification_sent", true, clientIP)
+
+	return nil
+}
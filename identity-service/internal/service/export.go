@@ -0,0 +1,342 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/watup-lk/identity-service/internal/export"
+	"github.com/watup-lk/identity-service/internal/mailer"
+	"github.com/watup-lk/identity-service/internal/repository"
+)
+
+var ErrExportNotFound = errors.New("no data export requested")
+
+// Data export states, as reported in DataExport.Status.
+const (
+	ExportPending = repository.ExportPending
+	ExportReady   = repository.ExportReady
+	ExportFailed  = repository.ExportFailed
+)
+
+const (
+	// exportBuildTimeout bounds building one export. A pending export older than this
+	// (e.g. its replica was restarted) is reported as failed so it can be requested again.
+	exportBuildTimeout = 10 * time.Minute
+	// exportContributorTimeout bounds each contributing service's section.
+	exportContributorTimeout = 30 * time.Second
+)
+
+// Sections identity-service writes itself; contributors cannot reuse these names.
+var builtinExportSections = []string{"profile", "sessions", "audit_log", "mfa", "linked_accounts", "passkeys"}
+
+// DataExport is the state of a user's request for a copy of their personal data.
+type DataExport struct {
+	ID          string
+	Status      string // ExportPending, ExportReady or ExportFailed
+	RequestedAt time.Time
+	ExpiresAt   *time.Time // set once ready: the bundle is deleted after this
+	Bundle      []byte     // JSON document, set once ready
+}
+
+// AddExportContributors registers services whose data is included in every export.
+// Must be called before the service starts handling requests.
+func (s *IdentityService) AddExportContributors(contributors ...export.Contributor) error {
+	for _, c := range contributors {
+		if slices.Contains(builtinExportSections, c.Section()) {
+			return fmt.Errorf("export section %q is reserved", c.Section())
+		}
+		for _, existing := range s.exportContributors {
+			if existing.Section() == c.Section() {
+				return fmt.Errorf("export section %q is registered twice", c.Section())
+			}
+		}
+		s.exportContributors = append(s.exportContributors, c)
+	}
+	return nil
+}
+
+// RequestDataExport starts building a copy of the user's personal data in the
+// background and emails them when it can be downloaded. While an export is still
+// being built, it is returned instead of starting another.
+func (s *IdentityService) RequestDataExport(ctx context.Context, userID, clientIP string) (*DataExport, error) {
+	user, err := s.activeUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	id := uuid.New().String()
+	err = s.repo.CreateDataExport(ctx, id, userID, time.Now().Add(-exportBuildTimeout))
+	if errors.Is(err, repository.ErrExportPending) {
+		pending, err := s.repo.FindLatestDataExport(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("loading data export: %w", err)
+		}
+		return toDataExport(pending), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("creating data export: %w", err)
+	}
+
+	go s.buildDataExport(id, user)
+	s.auditLog(userID, "data_export_request", true, clientIP)
+
+	return &DataExport{ID: id, Status: ExportPending, RequestedAt: time.Now()}, nil
+}
+
+// LatestDataExport returns the state of the user's most recent export, without the
+// bundle. Returns ErrExportNotFound if none was requested or the last one has expired.
+func (s *IdentityService) LatestDataExport(ctx context.Context, userID string) (*DataExport, error) {
+	e, err := s.latestDataExport(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	e.Bundle = nil
+	return e, nil
+}
+
+// DownloadDataExport is LatestDataExport including the bundle once it is ready.
+// Handing out the bundle is audited; looking at a pending or failed export is not.
+func (s *IdentityService) DownloadDataExport(ctx context.Context, userID, clientIP string) (*DataExport, error) {
+	e, err := s.latestDataExport(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if e.Status == ExportReady {
+		s.auditLog(userID, "data_export_download", true, clientIP)
+	}
+	return e, nil
+}
+
+func (s *IdentityService) latestDataExport(ctx context.Context, userID string) (*DataExport, error) {
+	if _, err := s.activeUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	latest, err := s.repo.FindLatestDataExport(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrExportNotFound
+		}
+		return nil, fmt.Errorf("loading data export: %w", err)
+	}
+	e := toDataExport(latest)
+	if e.Status == ExportReady && (e.ExpiresAt == nil || time.Now().After(*e.ExpiresAt)) {
+		return nil, ErrExportNotFound
+	}
+	return e, nil
+}
+
+// PurgeExpiredDataExports deletes exports whose download window has passed.
+func (s *IdentityService) PurgeExpiredDataExports(ctx context.Context) (int64, error) {
+	n, err := s.repo.DeleteExpiredDataExports(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("deleting expired data exports: %w", err)
+	}
+	return n, nil
+}
+
+// buildDataExport assembles and stores the bundle for a pending export. Intended to
+// be called in a goroutine.
+func (s *IdentityService) buildDataExport(exportID string, user *repository.User) {
+	ctx, cancel := context.WithTimeout(context.Background(), exportBuildTimeout)
+	defer cancel()
+
+	bundle, err := s.collectDataExport(ctx, user)
+	if err != nil {
+		log.Printf("[export] building export %s for user %s failed: %v", exportID, user.ID, err)
+		if err := s.repo.FailDataExport(context.Background(), exportID); err != nil {
+			log.Printf("[export] marking export %s failed: %v", exportID, err)
+		}
+		s.auditLog(user.ID, "data_export", false, "")
+		return
+	}
+
+	expiresAt := time.Now().Add(time.Duration(s.cfg.DataExportHours) * time.Hour)
+	if err := s.repo.CompleteDataExport(ctx, exportID, bundle, expiresAt); err != nil {
+		if !errors.Is(err, repository.ErrNotFound) { // not replaced by a newer request
+			log.Printf("[export] storing export %s failed: %v", exportID, err)
+		}
+		return
+	}
+
+	s.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Your watup.lk data export is ready",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nThe copy of your personal data you requested is ready. Download it from your account settings before %s:\n\n%s/account/export\n\nIf you did not request this, change your password and sign out your other sessions.\n",
+			user.Name, expiresAt.UTC().Format("2 January 2006 15:04 MST"), s.cfg.FrontendURL,
+		),
+	})
+	s.auditLog(user.ID, "data_export", true, "")
+}
+
+// Sections written by identity-service. Secrets — password hashes, token hashes,
+// the TOTP secret and recovery codes — are never included.
+type (
+	exportProfile struct {
+		UserID          string   `json:"user_id"`
+		Name            string   `json:"name"`
+		Email           string   `json:"email"`
+		Age             *int     `json:"age"`
+		EmailVerifiedAt string   `json:"email_verified_at,omitempty"`
+		CreatedAt       string   `json:"created_at"`
+		Roles           []string `json:"roles"`
+	}
+	exportSession struct {
+		ID         string `json:"id"`
+		UserAgent  string `json:"user_agent"`
+		IPAddress  string `json:"ip_address"`
+		CreatedAt  string `json:"created_at"`
+		LastUsedAt string `json:"last_used_at"`
+		ExpiresAt  string `json:"expires_at"`
+	}
+	exportAuditEvent struct {
+		Event     string `json:"event"`
+		Success   bool   `json:"success"`
+		IPAddress string `json:"ip_address,omitempty"`
+		CreatedAt string `json:"created_at"`
+	}
+	exportMFA struct {
+		TOTPEnabled bool   `json:"totp_enabled"`
+		EnabledAt   string `json:"enabled_at,omitempty"`
+	}
+	exportLinkedAccount struct {
+		Provider string `json:"provider"`
+		Email    string `json:"email,omitempty"`
+		LinkedAt string `json:"linked_at"`
+	}
+	exportPasskey struct {
+		Name       string   `json:"name"`
+		Transports []string `json:"transports,omitempty"`
+		CreatedAt  string   `json:"created_at"`
+		LastUsedAt string   `json:"last_used_at,omitempty"`
+	}
+)
+
+// collectDataExport gathers identity-service's own sections and every contributor's
+// into one JSON document.
+func (s *IdentityService) collectDataExport(ctx context.Context, user *repository.User) ([]byte, error) {
+	granted, err := s.userRoles(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	profile := exportProfile{
+		UserID:    user.ID,
+		Name:      user.Name,
+		Email:     user.Email,
+		Age:       user.Age,
+		CreatedAt: exportTime(user.CreatedAt),
+		Roles:     granted,
+	}
+	if user.EmailVerifiedAt != nil {
+		profile.EmailVerifiedAt = exportTime(*user.EmailVerifiedAt)
+	}
+
+	rows, err := s.repo.ListActiveSessions(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("listing sessions: %w", err)
+	}
+	sessions := make([]exportSession, 0, len(rows))
+	for _, r := range rows {
+		sessions = append(sessions, exportSession{
+			ID:         r.ID,
+			UserAgent:  r.UserAgent,
+			IPAddress:  r.IPAddress,
+			CreatedAt:  exportTime(r.CreatedAt),
+			LastUsedAt: exportTime(r.LastUsedAt),
+			ExpiresAt:  exportTime(r.ExpiresAt),
+		})
+	}
+
+	logs, err := s.repo.ListAuditLogs(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("listing audit logs: %w", err)
+	}
+	events := make([]exportAuditEvent, 0, len(logs))
+	for _, l := range logs {
+		events = append(events, exportAuditEvent{
+			Event:     l.EventType,
+			Success:   l.Success,
+			IPAddress: l.IPAddress,
+			CreatedAt: exportTime(l.CreatedAt),
+		})
+	}
+
+	var mfa exportMFA
+	cred, err := s.repo.FindTOTP(ctx, user.ID)
+	switch {
+	case err == nil:
+		if cred.ConfirmedAt != nil {
+			mfa = exportMFA{TOTPEnabled: true, EnabledAt: exportTime(*cred.ConfirmedAt)}
+		}
+	case !errors.Is(err, repository.ErrNotFound):
+		return nil, fmt.Errorf("loading TOTP enrollment: %w", err)
+	}
+
+	identities, err := s.repo.ListUserIdentities(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("listing linked accounts: %w", err)
+	}
+	linked := make([]exportLinkedAccount, 0, len(identities))
+	for _, id := range identities {
+		linked = append(linked, exportLinkedAccount{Provider: id.Provider, Email: id.Email, LinkedAt: exportTime(id.CreatedAt)})
+	}
+
+	creds, err := s.repo.ListWebAuthnCredentials(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("listing passkeys: %w", err)
+	}
+	passkeys := make([]exportPasskey, 0, len(creds))
+	for _, c := range creds {
+		p := exportPasskey{Name: c.Name, Transports: c.Transports, CreatedAt: exportTime(c.CreatedAt)}
+		if c.LastUsedAt != nil {
+			p.LastUsedAt = exportTime(*c.LastUsedAt)
+		}
+		passkeys = append(passkeys, p)
+	}
+
+	b := export.NewBundle(user.ID, time.Now())
+	for section, v := range map[string]any{
+		"profile":         profile,
+		"sessions":        sessions,
+		"audit_log":       events,
+		"mfa":             mfa,
+		"linked_accounts": linked,
+		"passkeys":        passkeys,
+	} {
+		if err := b.Add(section, v); err != nil {
+			return nil, err
+		}
+	}
+	b.Collect(ctx, exportContributorTimeout, s.exportContributors)
+
+	return json.MarshalIndent(b, "", "  ")
+}
+
+// toDataExport converts a stored export, reporting one stuck in pending past
+// exportBuildTimeout as failed.
+func toDataExport(e *repository.DataExport) *DataExport {
+	out := &DataExport{
+		ID:          e.ID,
+		Status:      e.Status,
+		RequestedAt: e.RequestedAt,
+		ExpiresAt:   e.ExpiresAt,
+	}
+	if e.Status == ExportPending && time.Since(e.RequestedAt) > exportBuildTimeout {
+		out.Status = ExportFailed
+	}
+	if e.Status == ExportReady {
+		out.Bundle = e.Payload
+	}
+	return out
+}
+
+func exportTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05Z")
+}
@@ -6,26 +6,75 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"slices"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 
+	"github.com/watup-lk/identity-service/internal/auditlog"
 	"github.com/watup-lk/identity-service/internal/config"
+	"github.com/watup-lk/identity-service/internal/emailaddr"
+	"github.com/watup-lk/identity-service/internal/export"
+	"github.com/watup-lk/identity-service/internal/keys"
+	"github.com/watup-lk/identity-service/internal/outbox"
+	"github.com/watup-lk/identity-service/internal/passhash"
+	"github.com/watup-lk/identity-service/internal/pwpolicy"
 	"github.com/watup-lk/identity-service/internal/repository"
+	"github.com/watup-lk/identity-service/internal/roles"
+	"github.com/watup-lk/identity-service/internal/webauthn"
 )
 
 var (
-	ErrUserAlreadyExists  = errors.New("email already registered")
+	ErrUserAlreadyExists  = repository.ErrUserAlreadyExists
 	ErrInvalidCredentials = errors.New("invalid email or password")
 	ErrInvalidToken       = errors.New("invalid or expired token")
 	ErrAccountDisabled    = errors.New("account is disabled")
+	ErrInvalidResetToken  = errors.New("invalid or expired reset token")
+	ErrSamePassword       = errors.New("new password must differ from the current password")
+	ErrWeakPassword       = errors.New("password does not meet the password policy")
 )
 
-// Claims is the JWT payload. Only user_id is included — no PII.
+// PasswordPolicyError is returned when a new password is rejected, listing every
+// rule it failed. errors.Is(err, ErrWeakPassword) matches it.
+type PasswordPolicyError struct {
+	Reasons []pwpolicy.Reason
+}
+
+func (e *PasswordPolicyError) Error() string { return ErrWeakPassword.Error() }
+func (e *PasswordPolicyError) Unwrap() error { return ErrWeakPassword }
+
+// Events are recorded in the outbox in the same transaction as the change they
+// announce (see package outbox). Lifecycle events are relayed to the Kafka topic of
+// the same name, security events (e.g. refresh_token_reuse) to user.security.
+const (
+	eventUserRegistered         = "user.registered"
+	eventUserLogin              = "user.login"
+	eventUserLogout             = "user.logout"
+	eventTokenRefresh           = "user.token_refresh"
+	eventEmailVerified          = "user.email_verified"
+	eventUserDeleted            = "user.deleted"
+	eventPasswordResetRequested = "user.password_reset_requested"
+	eventPasswordReset          = "user.password_reset"
+	eventPasswordChanged        = "user.password_changed"
+	eventProfileUpdated         = "user.profile_updated"
+
+	topicSecurity = "user.security"
+)
+
+func lifecycleEvent(userID, eventType string) outbox.Event {
+	return outbox.Event{Topic: eventType, UserID: userID, EventType: eventType}
+}
+
+func securityEvent(userID, eventType string) outbox.Event {
+	return outbox.Event{Topic: topicSecurity, UserID: userID, EventType: eventType}
+}
+
+// Claims is the JWT payload. Only user_id, roles and the session id are included — no PII.
 type Claims struct {
-	UserID string `json:"user_id"`
+	UserID    string   `json:"user_id"`
+	Roles     []string `json:"roles,omitempty"`
+	SessionID string   `json:"sid,omitempty"` // refresh token family the token was issued for
 	jwt.RegisteredClaims
 }
 
@@ -42,20 +91,43 @@ type SignupResult struct {
 }
 
 // IdentityService contains all authentication business logic.
-// It depends on the Repo and EventPublisher interfaces — not concrete types —
+// It depends on the Repo and Mailer interfaces — not concrete types —
 // which makes it easy to test in isolation with mocks.
 type IdentityService struct {
-	repo  Repo
-	kafka EventPublisher
-	cfg   *config.Config
+	repo      Repo
+	mailer    Mailer
+	keyring   *keys.Keyring
+	hasher    *passhash.Hasher
+	passwords *pwpolicy.Checker
+	emails    emailaddr.Rules
+	passkeys  *webauthn.RelyingParty
+	cfg       *config.Config
+	audit     AuditRecorder // nil writes audit events synchronously
+
+	exportContributors []export.Contributor        // other services' sections of a data export
+	identityProviders  map[string]IdentityProvider // social login providers by name
 }
 
-func NewIdentityService(repo Repo, k EventPublisher, cfg *config.Config) *IdentityService {
-	return &IdentityService{repo: repo, kafka: k, cfg: cfg}
+func NewIdentityService(repo Repo, m Mailer, keyring *keys.Keyring, cfg *config.Config) *IdentityService {
+	return &IdentityService{
+		repo:      repo,
+		mailer:    m,
+		keyring:   keyring,
+		hasher:    passhash.New(cfg.PasswordPolicy()),
+		passwords: pwpolicy.New(cfg.PasswordRules()),
+		emails:    cfg.EmailRules(),
+		passkeys:  webauthn.New(cfg.WebAuthnConfig()),
+		cfg:       cfg,
+	}
 }
 
-// Signup creates a new user account. Returns the new user's UUID.
+// Signup creates a new user account and emails a verification link. Returns the new user's UUID.
 func (s *IdentityService) Signup(ctx context.Context, name, email, password, clientIP string, age *int) (*SignupResult, error) {
+	email = s.emails.Normalise(email)
+	if err := s.checkNewPassword(password, name, email); err != nil {
+		return nil, err
+	}
+
 	exists, err := s.repo.UserExistsByEmail(ctx, email)
 	if err != nil {
 		return nil, fmt.Errorf("checking email: %w", err)
@@ -64,125 +136,254 @@ func (s *IdentityService) Signup(ctx context.Context, name, email, password, cli
 		return nil, ErrUserAlreadyExists
 	}
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hash, err := s.hasher.Hash(password)
 	if err != nil {
 		return nil, fmt.Errorf("hashing password: %w", err)
 	}
 
 	userID := uuid.New().String()
-	if err := s.repo.CreateUser(ctx, userID, name, email, string(hash), age); err != nil {
+	if err := s.repo.CreateUser(ctx, userID, name, email, hash, age, lifecycleEvent(userID, eventUserRegistered)); err != nil {
+		if errors.Is(err, ErrUserAlreadyExists) { // a concurrent signup won
+			return nil, err
+		}
 		return nil, fmt.Errorf("creating user: %w", err)
 	}
 
-	// Fire-and-forget: publish Kafka event and audit log without blocking the response
-	go s.kafka.PublishUserRegistered(context.Background(), userID)
-	go s.auditLog(userID, "signup", true, clientIP)
+	// The account is usable straight away; verification only gates actions that other
+	// services restrict to verified users. A failure here is recoverable via resend.
+	user := &repository.User{ID: userID, Name: name, Email: email}
+	if err := s.sendVerificationEmail(ctx, user, clientIP); err != nil {
+		log.Printf("[signup] failed to send verification email to user %s: %v", userID, err)
+	}
+
+	s.auditLog(userID, "signup", true, clientIP)
 
 	return &SignupResult{UserID: userID}, nil
 }
 
-// Login validates credentials and returns a token pair on success.
-func (s *IdentityService) Login(ctx context.Context, email, password, clientIP string) (*TokenPair, error) {
-	user, err := s.repo.FindUserByEmail(ctx, email)
+// Login validates credentials and returns a token pair on success. If the account
+// has two-factor authentication enabled — an authenticator app or a registered
+// passkey — it instead returns a *MFAChallenge error, which the caller completes with
+// VerifyMFA or VerifyMFAPasskey.
+func (s *IdentityService) Login(ctx context.Context, email, password, clientIP, userAgent string) (*TokenPair, error) {
+	user, err := s.repo.FindUserByEmail(ctx, s.emails.Normalise(email))
 	if err != nil {
 		// Return generic error — do not reveal whether the email exists
-		go s.auditLog("", "login_failed", false, clientIP)
+		s.auditLog("", "login_failed", false, clientIP)
 		return nil, ErrInvalidCredentials
 	}
 
 	if !user.IsActive {
-		go s.auditLog(user.ID, "login_failed", false, clientIP)
+		s.auditLog(user.ID, "login_failed", false, clientIP)
 		return nil, ErrAccountDisabled
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
-		go s.auditLog(user.ID, "login_failed", false, clientIP)
+	// Attempts during a lockout are rejected without checking the password and are
+	// not counted, so an attacker cannot keep extending the victim's lockout.
+	if isLocked(user, time.Now()) {
+		s.auditLog(user.ID, "login_locked", false, clientIP)
+		return nil, ErrAccountLocked
+	}
+
+	if !s.checkPassword(ctx, user, password) {
+		s.recordLoginFailure(ctx, user.ID, clientIP)
+		s.auditLog(user.ID, "login_failed", false, clientIP)
 		return nil, ErrInvalidCredentials
 	}
 
-	pair, err := s.generateTokenPair(ctx, user.ID)
+	methods, err := s.mfaMethods(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(methods) > 0 {
+		challenge, err := s.issueMFAChallenge(user.ID, methods)
+		if err != nil {
+			return nil, err
+		}
+		s.auditLog(user.ID, "mfa_challenge", true, clientIP)
+		return nil, challenge
+	}
+
+	return s.completeLogin(ctx, user.ID, clientIP, userAgent)
+}
+
+// completeLogin starts a new session once every required factor has been checked.
+func (s *IdentityService) completeLogin(ctx context.Context, userID, clientIP, userAgent string) (*TokenPair, error) {
+	pair, err := s.generateTokenPair(ctx, userID, newTokenFamily(), clientIP, userAgent, lifecycleEvent(userID, eventUserLogin))
 	if err != nil {
 		return nil, err
 	}
+	s.clearLoginFailures(ctx, userID)
 
-	go s.kafka.PublishUserLogin(context.Background(), user.ID)
-	go s.auditLog(user.ID, "login", true, clientIP)
+	s.auditLog(userID, "login", true, clientIP)
 
 	return pair, nil
 }
 
 // Refresh rotates a refresh token and returns a new token pair.
-func (s *IdentityService) Refresh(ctx context.Context, rawRefreshToken, clientIP string) (*TokenPair, error) {
+// Presenting a token that has already been rotated is treated as theft: the whole
+// token family is revoked so neither the attacker nor the victim can keep using it.
+func (s *IdentityService) Refresh(ctx context.Context, rawRefreshToken, clientIP, userAgent string) (*TokenPair, error) {
 	tokenHash := hashToken(rawRefreshToken)
 
 	stored, err := s.repo.FindRefreshToken(ctx, tokenHash)
 	if err != nil {
 		return nil, ErrInvalidToken
 	}
-	if stored.Revoked || time.Now().After(stored.ExpiresAt) {
+	if stored.Revoked {
+		if stored.ReplacedBy != "" {
+			s.handleRefreshTokenReuse(ctx, stored, clientIP)
+		}
+		return nil, ErrInvalidToken
+	}
+	if time.Now().After(stored.ExpiresAt) {
 		return nil, ErrInvalidToken
 	}
 
-	// Revoke the old token (token rotation)
-	if err := s.repo.RevokeRefreshToken(ctx, tokenHash); err != nil {
+	// Revoke the old token (token rotation). Losing this race means another request
+	// rotated the same token first — i.e. it is being replayed.
+	next := refreshLineage{id: uuid.New().String(), familyID: familyOf(stored), parentID: stored.ID}
+	rotated, err := s.repo.RotateRefreshToken(ctx, tokenHash, next.id)
+	if err != nil {
 		return nil, fmt.Errorf("revoking old token: %w", err)
 	}
+	if !rotated {
+		s.handleRefreshTokenReuse(ctx, stored, clientIP)
+		return nil, ErrInvalidToken
+	}
 
-	pair, err := s.generateTokenPair(ctx, stored.UserID)
+	pair, err := s.generateTokenPair(ctx, stored.UserID, next, clientIP, userAgent, lifecycleEvent(stored.UserID, eventTokenRefresh))
 	if err != nil {
 		return nil, err
 	}
 
-	go s.kafka.PublishTokenRefresh(context.Background(), stored.UserID)
-	go s.auditLog(stored.UserID, "token_refresh", true, clientIP)
+	s.auditLog(stored.UserID, "token_refresh", true, clientIP)
 
 	return pair, nil
 }
 
-// Logout revokes the given refresh token.
+// handleRefreshTokenReuse revokes the whole family of a replayed refresh token and
+// raises a security event.
+func (s *IdentityService) handleRefreshTokenReuse(ctx context.Context, stored *repository.RefreshToken, clientIP string) {
+	if err := s.denyAccessTokens(ctx, stored.UserID, familyOf(stored)); err != nil {
+		log.Printf("[security] failed to revoke access tokens of family %s for user %s: %v", familyOf(stored), stored.UserID, err)
+	}
+	err := s.repo.RevokeTokenFamily(ctx, familyOf(stored), securityEvent(stored.UserID, "refresh_token_reuse"))
+	if err != nil {
+		log.Printf("[security] failed to revoke token family %s for user %s: %v", familyOf(stored), stored.UserID, err)
+	}
+	log.Printf("[security] refresh token reuse detected for user %s (family %s) from %s", stored.UserID, familyOf(stored), clientIP)
+
+	s.auditLog(stored.UserID, "refresh_token_reuse", false, clientIP)
+}
+
+// Logout revokes the given refresh token, along with the access tokens issued in
+// its session.
 func (s *IdentityService) Logout(ctx context.Context, rawRefreshToken, clientIP string) error {
 	tokenHash := hashToken(rawRefreshToken)
 
 	// Look up the token to get the user ID for audit logging
 	stored, _ := s.repo.FindRefreshToken(ctx, tokenHash)
-	if err := s.repo.RevokeRefreshToken(ctx, tokenHash); err != nil {
+	var events []outbox.Event
+	if stored != nil {
+		if !stored.Revoked {
+			if err := s.denyAccessTokens(ctx, stored.UserID, familyOf(stored)); err != nil {
+				return err
+			}
+		}
+		events = append(events, lifecycleEvent(stored.UserID, eventUserLogout))
+	}
+	if err := s.repo.RevokeRefreshToken(ctx, tokenHash, events...); err != nil {
 		return err
 	}
 
 	if stored != nil {
-		go s.kafka.PublishUserLogout(context.Background(), stored.UserID)
-		go s.auditLog(stored.UserID, "logout", true, clientIP)
+		s.auditLog(stored.UserID, "logout", true, clientIP)
 	}
 
 	return nil
 }
 
 // ValidateAccessToken parses and validates a JWT, returning the user_id on success.
-func (s *IdentityService) ValidateAccessToken(_ context.Context, tokenString string) (string, error) {
-	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidToken
-		}
-		return []byte(s.cfg.JWTSecret), nil
-	})
-	if err != nil || !token.Valid {
-		return "", ErrInvalidToken
+// The verification key is chosen by the token's kid header; HS256 tokens (no kid)
+// are only accepted while JWT_SECRET is configured. Revoked tokens are rejected.
+func (s *IdentityService) ValidateAccessToken(ctx context.Context, tokenString string) (string, error) {
+	claims, err := s.parseAccessToken(ctx, tokenString)
+	if err != nil {
+		return "", err
 	}
 	return claims.UserID, nil
 }
 
-// GetUserByID returns basic user metadata (no email — privacy).
-func (s *IdentityService) GetUserByID(ctx context.Context, userID string) (*repository.User, error) {
-	return s.repo.FindUserByID(ctx, userID)
+// ValidateSessionToken is ValidateAccessToken for endpoints that act on the caller's
+// own session. sessionID is empty for tokens issued before sessions were tracked.
+func (s *IdentityService) ValidateSessionToken(ctx context.Context, tokenString string) (userID, sessionID string, err error) {
+	claims, err := s.parseAccessToken(ctx, tokenString)
+	if err != nil {
+		return "", "", err
+	}
+	return claims.UserID, claims.SessionID, nil
+}
+
+// ValidateAccessTokenRoles is ValidateAccessToken that also returns the roles the
+// token was issued with. Tokens issued before roles existed carry only the user role.
+func (s *IdentityService) ValidateAccessTokenRoles(ctx context.Context, tokenString string) (userID string, granted []string, err error) {
+	claims, err := s.parseAccessToken(ctx, tokenString)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(claims.Roles) == 0 {
+		return claims.UserID, []string{roles.User}, nil
+	}
+	return claims.UserID, claims.Roles, nil
+}
+
+// GetUserByID returns basic user metadata, whether or not the account is active.
+// Callers exposing it to other services must leave out the email (privacy).
+func (s *IdentityService) GetUserByID(ctx context.Context, userID string) (*Profile, error) {
+	user, err := s.repo.FindUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return profileOf(user), nil
+}
+
+// refreshLineage places a new refresh token within its rotation family.
+type refreshLineage struct {
+	id       string // id of the refresh token being issued
+	familyID string // shared by every token descended from the same login
+	parentID string // token being replaced; empty for the first token of a family
+}
+
+// newTokenFamily starts a new lineage; the first token's id doubles as the family id.
+func newTokenFamily() refreshLineage {
+	id := uuid.New().String()
+	return refreshLineage{id: id, familyID: id}
 }
 
-// generateTokenPair creates a new JWT access token and an opaque refresh token.
-func (s *IdentityService) generateTokenPair(ctx context.Context, userID string) (*TokenPair, error) {
+// familyOf returns a stored token's family, treating pre-lineage tokens as their own family.
+func familyOf(rt *repository.RefreshToken) string {
+	if rt.FamilyID == "" {
+		return rt.ID
+	}
+	return rt.FamilyID
+}
+
+// generateTokenPair creates a new JWT access token and an opaque refresh token,
+// recording the client it was issued to for the session list. events are recorded
+// with the refresh token, so they are announced only if the session starts.
+func (s *IdentityService) generateTokenPair(ctx context.Context, userID string, lineage refreshLineage, clientIP, userAgent string, events ...outbox.Event) (*TokenPair, error) {
 	accessExpiry := time.Now().Add(time.Duration(s.cfg.AccessTokenMinutes) * time.Minute)
 
+	granted, err := s.userRoles(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
 	accessClaims := &Claims{
-		UserID: userID,
+		UserID:    userID,
+		Roles:     granted,
+		SessionID: lineage.familyID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ID:        uuid.New().String(), // jti — ensures every token is unique
 			ExpiresAt: jwt.NewNumericDate(accessExpiry),
@@ -192,22 +393,26 @@ func (s *IdentityService) generateTokenPair(ctx context.Context, userID string)
 		},
 	}
 
-	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims).
-		SignedString([]byte(s.cfg.JWTSecret))
+	accessToken, err := s.keyring.Sign(accessClaims)
 	if err != nil {
 		return nil, fmt.Errorf("signing access token: %w", err)
 	}
 
 	// Refresh token is a random opaque string stored as its SHA-256 hash
-	rawRefresh := uuid.New().String() + "-" + uuid.New().String()
+	rawRefresh := newOpaqueToken()
 	refreshExpiry := time.Now().AddDate(0, 0, s.cfg.RefreshTokenDays)
 
 	if err := s.repo.StoreRefreshToken(
 		ctx,
-		uuid.New().String(),
+		lineage.id,
 		userID,
+		lineage.familyID,
+		lineage.parentID,
 		hashToken(rawRefresh),
+		truncateUserAgent(userAgent),
+		clientIP,
 		refreshExpiry,
+		events...,
 	); err != nil {
 		return nil, fmt.Errorf("storing refresh token: %w", err)
 	}
@@ -219,17 +424,51 @@ func (s *IdentityService) generateTokenPair(ctx context.Context, userID string)
 	}, nil
 }
 
+// userRoles returns the roles to embed in the user's access tokens: the implicit
+// user role followed by any granted roles. Roles changed in the database take
+// effect on the next login or refresh.
+func (s *IdentityService) userRoles(ctx context.Context, userID string) ([]string, error) {
+	stored, err := s.repo.FindUserRoles(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("loading roles: %w", err)
+	}
+	granted := []string{roles.User}
+	for _, r := range stored {
+		if roles.Valid(r) && !slices.Contains(granted, r) {
+			granted = append(granted, r)
+		}
+	}
+	return granted, nil
+}
+
+// newOpaqueToken returns a random, URL-safe token for refresh and one-time email links.
+func newOpaqueToken() string {
+	return uuid.New().String() + "-" + uuid.New().String()
+}
+
 // hashToken returns the hex-encoded SHA-256 of a token string.
 func hashToken(token string) string {
 	h := sha256.Sum256([]byte(token))
 	return fmt.Sprintf("%x", h)
 }
 
-// auditLog records an auth event in the database. Fire-and-forget — errors are logged, not propagated.
+// SetAuditRecorder queues audit events with r instead of writing each one before
+// returning. Must be called before the service is used.
+func (s *IdentityService) SetAuditRecorder(r AuditRecorder) {
+	s.audit = r
+}
+
+// auditLog records an auth event. It is queued if an AuditRecorder is set, and
+// otherwise written straight away; errors are logged, not propagated.
 func (s *IdentityService) auditLog(userID, eventType string, success bool, ipAddress string) {
+	ev := auditlog.Event{UserID: userID, EventType: eventType, Success: success, IPAddress: ipAddress, CreatedAt: time.Now()}
+	if s.audit != nil {
+		s.audit.Record(ev)
+		return
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	if err := s.repo.InsertAuditLog(ctx, userID, eventType, success, ipAddress); err != nil {
+	if err := s.repo.InsertAuditLogs(ctx, []auditlog.Event{ev}); err != nil {
 		log.Printf("[audit] failed to log %s for user %s: %v", eventType, userID, err)
 	}
 }
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/watup-lk/identity-service/internal/repository"
+)
+
+// ErrAccountLocked is returned while an account is temporarily locked after repeated
+// failed logins. Handlers report it exactly like ErrInvalidCredentials so the
+// response does not reveal that the account exists.
+var ErrAccountLocked = errors.New("account is temporarily locked")
+
+// failedLoginWindow is how long failures are remembered: the counter restarts if
+// the previous failure is older than this.
+const failedLoginWindow = 24 * time.Hour
+
+// isLocked reports whether the user is inside a lockout period.
+func isLocked(user *repository.User, now time.Time) bool {
+	return user.LockedUntil != nil && now.Before(*user.LockedUntil)
+}
+
+// lockoutDuration returns how long to lock an account after its nth consecutive
+// failure: nothing below the threshold, then base, 2×base, 4×base … up to the cap.
+func (s *IdentityService) lockoutDuration(failures int) time.Duration {
+	if s.cfg.LockoutThreshold <= 0 || failures < s.cfg.LockoutThreshold {
+		return 0
+	}
+	maxLock := time.Duration(s.cfg.LockoutMaxMinutes) * time.Minute
+	d := time.Duration(s.cfg.LockoutBaseSeconds) * time.Second
+	for i := s.cfg.LockoutThreshold; i < failures && d < maxLock; i++ {
+		d *= 2
+	}
+	return min(d, maxLock)
+}
+
+// recordLoginFailure counts a failed password or second-factor attempt and locks the
+// account once the threshold is reached. Errors are logged, not propagated — the
+// caller already has an authentication error to return.
+func (s *IdentityService) recordLoginFailure(ctx context.Context, userID, clientIP string) {
+	if s.cfg.LockoutThreshold <= 0 {
+		return
+	}
+	failures, err := s.repo.RecordFailedLogin(ctx, userID, failedLoginWindow)
+	if err != nil {
+		log.Printf("[security] failed to record login failure for user %s: %v", userID, err)
+		return
+	}
+	d := s.lockoutDuration(failures)
+	if d == 0 {
+		return
+	}
+	until := time.Now().Add(d)
+	if err := s.repo.LockUser(ctx, userID, until, securityEvent(userID, "account_locked")); err != nil {
+		log.Printf("[security] failed to lock user %s: %v", userID, err)
+		return
+	}
+	log.Printf("[security] user %s locked for %s after %d failed logins (last from %s)", userID, d, failures, clientIP)
+
+	s.auditLog(userID, "account_locked", false, clientIP)
+}
+
+// clearLoginFailures resets the failure counter after a successful login or password reset.
+func (s *IdentityService) clearLoginFailures(ctx context.Context, userID string) {
+	if err := s.repo.ResetFailedLogins(ctx, userID); err != nil {
+		log.Printf("[security] failed to reset login failures for user %s: %v", userID, err)
+	}
+}
+
+// confirmPassword re-checks the password of a signed-in user before a sensitive
+// change. Like Login, it refuses while the account is locked and counts a wrong
+// password towards the lockout, so an access token cannot be used to guess it.
+func (s *IdentityService) confirmPassword(ctx context.Context, user *repository.User, password, clientIP string) error {
+	if isLocked(user, time.Now()) {
+		return ErrAccountLocked
+	}
+	if !s.checkPassword(ctx, user, password) {
+		s.recordLoginFailure(ctx, user.ID, clientIP)
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+// confirmSecondFactor checks a TOTP or recovery code of a signed-in user, with the
+// lockout rules of confirmPassword.
+func (s *IdentityService) confirmSecondFactor(ctx context.Context, user *repository.User, code, clientIP string) error {
+	if isLocked(user, time.Now()) {
+		return ErrAccountLocked
+	}
+	err := s.checkSecondFactor(ctx, user.ID, code, clientIP)
+	if errors.Is(err, ErrInvalidMFACode) {
+		s.recordLoginFailure(ctx, user.ID, clientIP)
+	}
+	return err
+}
+
+// Reauthentication is what a signed-in user presents to confirm a sensitive change:
+// their password or, for an account without one, a ReauthToken from a fresh sign-in
+// with a linked provider (StartOAuthReauth).
+type Reauthentication struct {
+	Password    string
+	ReauthToken string
+}
+
+// confirmReauth checks either proof, with the lockout rules of confirmPassword.
+func (s *IdentityService) confirmReauth(ctx context.Context, user *repository.User, proof Reauthentication, clientIP string) error {
+	if proof.ReauthToken == "" {
+		return s.confirmPassword(ctx, user, proof.Password, clientIP)
+	}
+	if isLocked(user, time.Now()) {
+		return ErrAccountLocked
+	}
+	if userID, err := s.parseReauthToken(proof.ReauthToken); err != nil || userID != user.ID {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+// UnlockAccount lifts a lockout before it expires (support/admin tooling).
+func (s *IdentityService) UnlockAccount(ctx context.Context, userID, clientIP string) error {
+	if _, err := s.repo.FindUserByID(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.repo.ResetFailedLogins(ctx, userID); err != nil {
+		return fmt.Errorf("unlocking account: %w", err)
+	}
+
+	s.auditLog(userID, "account_unlocked", true, clientIP)
+
+	return nil
+}
@@ -0,0 +1,348 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/watup-lk/identity-service/internal/keys"
+	"github.com/watup-lk/identity-service/internal/repository"
+	"github.com/watup-lk/identity-service/internal/totp"
+)
+
+var (
+	ErrMFARequired       = errors.New("two-factor authentication required")
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnrolled    = errors.New("two-factor authentication is not enabled")
+	ErrInvalidMFACode    = errors.New("invalid authentication code")
+)
+
+const (
+	// mfaChallengeAudience marks challenge tokens so they can never be used as access tokens.
+	mfaChallengeAudience = "watup-mfa-challenge"
+	recoveryCodeCount    = 10
+)
+
+// Second factors a challenge can be answered with, as listed in MFAChallenge.Methods.
+const (
+	MFAMethodTOTP         = "totp"
+	MFAMethodRecoveryCode = "recovery_code"
+	MFAMethodPasskey      = "passkey"
+)
+
+// MFAChallenge is returned as the error from Login when the account has two-factor
+// authentication enabled. The token must be exchanged, together with a TOTP or
+// recovery code, via VerifyMFA — or with a passkey via VerifyMFAPasskey, if Methods
+// includes MFAMethodPasskey. errors.Is(err, ErrMFARequired) matches it.
+type MFAChallenge struct {
+	Token     string
+	ExpiresAt time.Time
+	Methods   []string
+}
+
+func (c *MFAChallenge) Error() string { return ErrMFARequired.Error() }
+func (c *MFAChallenge) Unwrap() error { return ErrMFARequired }
+
+// TOTPEnrollment is the data the client needs to add the account to an authenticator app.
+type TOTPEnrollment struct {
+	Secret          string // base32, for manual entry
+	ProvisioningURI string // otpauth:// URI, rendered as a QR code by the client
+}
+
+// EnrollTOTP generates a new TOTP secret for the user. MFA is not enforced until
+// the enrollment is confirmed with ConfirmTOTP; calling this again before then
+// replaces the pending secret.
+func (s *IdentityService) EnrollTOTP(ctx context.Context, userID, clientIP string) (*TOTPEnrollment, error) {
+	user, err := s.activeUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("generating TOTP secret: %w", err)
+	}
+	started, err := s.repo.UpsertTOTPEnrollment(ctx, userID, secret)
+	if err != nil {
+		return nil, fmt.Errorf("storing TOTP enrollment: %w", err)
+	}
+	if !started {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	s.auditLog(userID, "mfa_enroll", true, clientIP)
+
+	return &TOTPEnrollment{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(s.cfg.MFAIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTP verifies the first code from the authenticator app, enables MFA and
+// returns the recovery codes. The plaintext codes are only ever returned here.
+func (s *IdentityService) ConfirmTOTP(ctx context.Context, userID, code, clientIP string) ([]string, error) {
+	cred, err := s.repo.FindTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrMFANotEnrolled
+		}
+		return nil, fmt.Errorf("loading TOTP enrollment: %w", err)
+	}
+	if cred.ConfirmedAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	step, ok := totp.Verify(cred.Secret, code, time.Now())
+	if !ok {
+		s.auditLog(userID, "mfa_enable", false, clientIP)
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.ConfirmTOTP(ctx, userID, step, hashes, securityEvent(userID, "mfa_enabled")); err != nil {
+		return nil, fmt.Errorf("enabling TOTP: %w", err)
+	}
+
+	s.auditLog(userID, "mfa_enable", true, clientIP)
+
+	return codes, nil
+}
+
+// DisableTOTP turns two-factor authentication off. It requires the account password
+// and a current TOTP or recovery code, so a stolen access token alone is not enough.
+func (s *IdentityService) DisableTOTP(ctx context.Context, userID, password, code, clientIP string) error {
+	user, err := s.activeUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.confirmPassword(ctx, user, password, clientIP); err != nil {
+		s.auditLog(userID, "mfa_disable", false, clientIP)
+		return err
+	}
+	if err := s.confirmSecondFactor(ctx, user, code, clientIP); err != nil {
+		s.auditLog(userID, "mfa_disable", false, clientIP)
+		return err
+	}
+
+	if err := s.repo.DeleteTOTP(ctx, userID, securityEvent(userID, "mfa_disabled")); err != nil {
+		return fmt.Errorf("disabling TOTP: %w", err)
+	}
+
+	s.auditLog(userID, "mfa_disable", true, clientIP)
+
+	return nil
+}
+
+// RegenerateRecoveryCodes invalidates the user's remaining recovery codes and
+// returns a new set. Requires a current TOTP code.
+func (s *IdentityService) RegenerateRecoveryCodes(ctx context.Context, userID, code, clientIP string) ([]string, error) {
+	user, err := s.activeUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.confirmSecondFactor(ctx, user, code, clientIP); err != nil {
+		s.auditLog(userID, "mfa_recovery_codes_regenerate", false, clientIP)
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, fmt.Errorf("storing recovery codes: %w", err)
+	}
+
+	s.auditLog(userID, "mfa_recovery_codes_regenerate", true, clientIP)
+
+	return codes, nil
+}
+
+// VerifyMFA completes a login started by Login: it exchanges an MFA challenge token
+// plus a TOTP or recovery code for a token pair.
+func (s *IdentityService) VerifyMFA(ctx context.Context, challengeToken, code, clientIP, userAgent string) (*TokenPair, error) {
+	userID, err := s.parseMFAChallenge(challengeToken)
+	if err != nil {
+		s.auditLog("", "mfa_verify", false, clientIP)
+		return nil, ErrInvalidToken
+	}
+	user, err := s.activeUser(ctx, userID)
+	if err != nil {
+		s.auditLog(userID, "mfa_verify", false, clientIP)
+		return nil, err
+	}
+	if isLocked(user, time.Now()) {
+		s.auditLog(userID, "login_locked", false, clientIP)
+		return nil, ErrAccountLocked
+	}
+	if err := s.checkSecondFactor(ctx, userID, code, clientIP); err != nil {
+		// Wrong codes count towards the lockout so TOTP codes cannot be brute-forced
+		if errors.Is(err, ErrInvalidMFACode) {
+			s.recordLoginFailure(ctx, userID, clientIP)
+		}
+		s.auditLog(userID, "mfa_verify", false, clientIP)
+		return nil, err
+	}
+
+	s.auditLog(userID, "mfa_verify", true, clientIP)
+	return s.completeLogin(ctx, userID, clientIP, userAgent)
+}
+
+// mfaMethods returns the second factors a login challenge can be answered with; none
+// means 2FA is off. A registered passkey or security key counts as enrolment on its
+// own: it can answer the challenge (VerifyMFAPasskey), and a user who added one should
+// not be signed in by their password alone.
+func (s *IdentityService) mfaMethods(ctx context.Context, userID string) ([]string, error) {
+	var methods []string
+	totpOn, err := s.totpEnabled(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if totpOn {
+		methods = append(methods, MFAMethodTOTP, MFAMethodRecoveryCode)
+	}
+	creds, err := s.repo.ListWebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("listing passkeys: %w", err)
+	}
+	if len(creds) > 0 {
+		methods = append(methods, MFAMethodPasskey)
+	}
+	return methods, nil
+}
+
+// totpEnabled reports whether the user has a confirmed authenticator app.
+func (s *IdentityService) totpEnabled(ctx context.Context, userID string) (bool, error) {
+	cred, err := s.repo.FindTOTP(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("loading TOTP enrollment: %w", err)
+	}
+	return cred.ConfirmedAt != nil, nil
+}
+
+// checkSecondFactor accepts either a TOTP code or an unused recovery code.
+// Each TOTP step and each recovery code is accepted at most once.
+func (s *IdentityService) checkSecondFactor(ctx context.Context, userID, code, clientIP string) error {
+	cred, err := s.repo.FindTOTP(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && cred.ConfirmedAt == nil) {
+		return ErrMFANotEnrolled
+	}
+	if err != nil {
+		return fmt.Errorf("loading TOTP enrollment: %w", err)
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		step, ok := totp.Verify(cred.Secret, code, time.Now())
+		if !ok || step <= cred.LastUsedStep {
+			return ErrInvalidMFACode
+		}
+		fresh, err := s.repo.UseTOTPStep(ctx, userID, step)
+		if err != nil {
+			return fmt.Errorf("recording TOTP use: %w", err)
+		}
+		if !fresh {
+			return ErrInvalidMFACode
+		}
+		return nil
+	}
+
+	used, err := s.repo.ConsumeRecoveryCode(ctx, userID, hashToken(normaliseRecoveryCode(code)))
+	if err != nil {
+		return fmt.Errorf("consuming recovery code: %w", err)
+	}
+	if !used {
+		return ErrInvalidMFACode
+	}
+	s.auditLog(userID, "mfa_recovery_code_used", true, clientIP)
+	return nil
+}
+
+// mfaChallengeClaims deliberately has no user_id claim, and carries an audience
+// that ValidateAccessToken rejects, so a challenge can never pass as an access token.
+type mfaChallengeClaims struct {
+	jwt.RegisteredClaims
+}
+
+// issueMFAChallenge returns a challenge to be answered with one of methods, as
+// returned by mfaMethods.
+func (s *IdentityService) issueMFAChallenge(userID string, methods []string) (*MFAChallenge, error) {
+	expiresAt := time.Now().Add(time.Duration(s.cfg.MFAChallengeMinutes) * time.Minute)
+	token, err := s.keyring.Sign(&mfaChallengeClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Audience:  jwt.ClaimStrings{mfaChallengeAudience},
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    "watup-identity-service",
+			Subject:   userID,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("signing MFA challenge: %w", err)
+	}
+	return &MFAChallenge{Token: token, ExpiresAt: expiresAt, Methods: methods}, nil
+}
+
+func (s *IdentityService) parseMFAChallenge(tokenString string) (string, error) {
+	claims := &mfaChallengeClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, s.keyring.Keyfunc,
+		jwt.WithValidMethods([]string{keys.AlgHS256, keys.AlgRS256, keys.AlgEdDSA}),
+		jwt.WithAudience(mfaChallengeAudience),
+	)
+	if err != nil || !token.Valid || claims.Subject == "" {
+		return "", ErrInvalidToken
+	}
+	return claims.Subject, nil
+}
+
+// activeUser loads a user and rejects disabled accounts.
+func (s *IdentityService) activeUser(ctx context.Context, userID string) (*repository.User, error) {
+	user, err := s.repo.FindUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("loading user: %w", err)
+	}
+	if !user.IsActive {
+		return nil, ErrAccountDisabled
+	}
+	return user, nil
+}
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newRecoveryCodes returns recoveryCodeCount codes formatted as "xxxxx-xxxxx"
+// (50 bits each) together with the hashes to store.
+func newRecoveryCodes() (codes, hashes []string, err error) {
+	buf := make([]byte, 7)
+	for i := 0; i < recoveryCodeCount; i++ {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, fmt.Errorf("generating recovery codes: %w", err)
+		}
+		raw := strings.ToLower(recoveryEncoding.EncodeToString(buf))[:10]
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		hashes = append(hashes, hashToken(raw))
+	}
+	return codes, hashes, nil
+}
+
+// normaliseRecoveryCode lets users type codes with or without the dash, in any case.
+func normaliseRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,467 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/watup-lk/identity-service/internal/keys"
+	"github.com/watup-lk/identity-service/internal/oauth"
+	"github.com/watup-lk/identity-service/internal/repository"
+)
+
+var (
+	ErrUnknownProvider      = errors.New("unknown identity provider")
+	ErrInvalidOAuthState    = errors.New("invalid or expired login state")
+	ErrOAuthFailed          = errors.New("identity provider login failed")
+	ErrOAuthEmailUnverified = errors.New("identity provider did not confirm the email address")
+	// ErrLinkRequired is returned when the provider's email belongs to an account whose
+	// own email is unverified: the user must sign in and link the provider themselves.
+	ErrLinkRequired      = errors.New("an account with this email exists; sign in and link the provider from your account")
+	ErrIdentityLinked    = errors.New("this external account is already linked")
+	ErrLastSignInMethod  = errors.New("cannot unlink the only way to sign in; set a password or add a passkey first")
+	ErrIdentityNotLinked = errors.New("no account of this provider is linked")
+	// ErrOAuthLinkCaller is returned when a link or reauthentication is completed by
+	// anyone but the signed-in user who started it.
+	ErrOAuthLinkCaller = errors.New("the signed-in account that started this flow must complete it")
+)
+
+const (
+	// maxOAuthNameLen matches the users.name column (VARCHAR(100)).
+	maxOAuthNameLen = 100
+	// reauthAudience marks reauthentication tokens, which are not access tokens.
+	reauthAudience = "watup-reauth"
+	// reauthTokenLifetime bounds how long a fresh provider sign-in confirms changes.
+	reauthTokenLifetime = 5 * time.Minute
+)
+
+// OAuthStart is a started social login: the browser is sent to URL, and the
+// provider redirects back with State and a code before ExpiresAt.
+type OAuthStart struct {
+	URL       string
+	State     string
+	ExpiresAt time.Time
+}
+
+// OAuthResult is the outcome of a completed social login. Tokens is set for a login;
+// for a link started by a signed-in user, Linked is true instead, and for a
+// reauthentication, Reauth is set.
+type OAuthResult struct {
+	Tokens *TokenPair
+	Linked bool
+	Reauth *ReauthToken
+}
+
+// ReauthToken confirms that the user has just signed in with a linked provider. It is
+// accepted instead of the password (see Reauthentication) until ExpiresAt.
+type ReauthToken struct {
+	Token     string
+	ExpiresAt time.Time
+}
+
+// LinkedIdentity is an external account linked to the user.
+type LinkedIdentity struct {
+	Provider string
+	Email    string
+	LinkedAt time.Time
+}
+
+// AddIdentityProviders enables social login through the given providers. Must be
+// called before the service starts handling requests.
+func (s *IdentityService) AddIdentityProviders(providers ...IdentityProvider) error {
+	if s.identityProviders == nil {
+		s.identityProviders = make(map[string]IdentityProvider)
+	}
+	for _, p := range providers {
+		if _, dup := s.identityProviders[p.Name()]; dup {
+			return fmt.Errorf("identity provider %q is registered twice", p.Name())
+		}
+		s.identityProviders[p.Name()] = p
+	}
+	return nil
+}
+
+// IdentityProviders returns the names of the enabled providers, sorted.
+func (s *IdentityService) IdentityProviders() []string {
+	names := make([]string, 0, len(s.identityProviders))
+	for name := range s.identityProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StartOAuth begins a login through provider, or — when linkUserID is set — links
+// the provider to that signed-in user. The PKCE verifier and nonce stay server-side,
+// keyed by the hash of the returned state.
+func (s *IdentityService) StartOAuth(ctx context.Context, provider, linkUserID string) (*OAuthStart, error) {
+	if _, ok := s.identityProviders[provider]; !ok {
+		return nil, ErrUnknownProvider
+	}
+	if linkUserID != "" {
+		if _, err := s.activeUser(ctx, linkUserID); err != nil {
+			return nil, err
+		}
+	}
+	return s.startOAuth(ctx, provider, linkUserID, false)
+}
+
+// StartOAuthReauth begins a sign-in with a provider already linked to the user, so
+// that they can confirm a sensitive change without a password. CompleteOAuth then
+// returns a ReauthToken.
+func (s *IdentityService) StartOAuthReauth(ctx context.Context, provider, userID string) (*OAuthStart, error) {
+	if _, ok := s.identityProviders[provider]; !ok {
+		return nil, ErrUnknownProvider
+	}
+	if _, err := s.activeUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	rows, err := s.repo.ListUserIdentities(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("listing linked accounts: %w", err)
+	}
+	if !slices.ContainsFunc(rows, func(r repository.UserIdentity) bool { return r.Provider == provider }) {
+		return nil, ErrIdentityNotLinked
+	}
+	return s.startOAuth(ctx, provider, userID, true)
+}
+
+func (s *IdentityService) startOAuth(ctx context.Context, provider, userID string, reauth bool) (*OAuthStart, error) {
+	p := s.identityProviders[provider]
+	state, verifier, nonce := newOpaqueToken(), oauth.NewCodeVerifier(), newOpaqueToken()
+	authURL, err := p.AuthCodeURL(ctx, state, nonce, oauth.CodeChallenge(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("building authorization URL: %w", err)
+	}
+	expiresAt := time.Now().Add(time.Duration(s.cfg.OAuthStateMinutes) * time.Minute)
+	if err := s.repo.StoreOAuthState(ctx, &repository.OAuthState{
+		StateHash:    hashToken(state),
+		Provider:     provider,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		UserID:       userID,
+		Reauth:       reauth,
+		ExpiresAt:    expiresAt,
+	}); err != nil {
+		return nil, fmt.Errorf("storing login state: %w", err)
+	}
+	return &OAuthStart{URL: authURL, State: state, ExpiresAt: expiresAt}, nil
+}
+
+// CompleteOAuth finishes a login or link with the state and code the provider sent
+// back. A login signs in the user already linked to the external account. Otherwise
+// the provider-verified email decides: an account with that (verified) email gets the
+// external account linked; if there is none, a passwordless account is created.
+// Like Login, it returns a *MFAChallenge error when the account has 2FA enabled.
+//
+// callerID is the user signed in on the callback request, if any. A link or
+// reauthentication is only completed for the user who started it, so a state leaked
+// from their browser cannot link someone else's external account to them.
+func (s *IdentityService) CompleteOAuth(ctx context.Context, provider, state, code, callerID, clientIP, userAgent string) (*OAuthResult, error) {
+	p, ok := s.identityProviders[provider]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	st, err := s.repo.ConsumeOAuthState(ctx, hashToken(state))
+	if err != nil || st.Provider != provider {
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return nil, fmt.Errorf("loading login state: %w", err)
+		}
+		s.auditLog("", "oauth_login", false, clientIP)
+		return nil, ErrInvalidOAuthState
+	}
+	if st.UserID != "" && st.UserID != callerID {
+		s.auditLog(st.UserID, oauthUserEvent(st), false, clientIP)
+		return nil, ErrOAuthLinkCaller
+	}
+
+	ext, err := p.Exchange(ctx, code, st.CodeVerifier, st.Nonce)
+	if err != nil {
+		log.Printf("[oauth] %s code exchange failed: %v", provider, err)
+		s.auditLog(st.UserID, "oauth_login", false, clientIP)
+		return nil, ErrOAuthFailed
+	}
+
+	if st.Reauth {
+		reauth, err := s.reauthenticate(ctx, st.UserID, provider, ext, clientIP)
+		if err != nil {
+			return nil, err
+		}
+		return &OAuthResult{Reauth: reauth}, nil
+	}
+	if st.UserID != "" {
+		if err := s.linkIdentity(ctx, st.UserID, provider, ext, clientIP); err != nil {
+			return nil, err
+		}
+		return &OAuthResult{Linked: true}, nil
+	}
+
+	user, err := s.oauthUser(ctx, provider, ext, clientIP)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsActive {
+		s.auditLog(user.ID, "oauth_login", false, clientIP)
+		return nil, ErrAccountDisabled
+	}
+	if isLocked(user, time.Now()) {
+		s.auditLog(user.ID, "login_locked", false, clientIP)
+		return nil, ErrAccountLocked
+	}
+
+	methods, err := s.mfaMethods(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(methods) > 0 {
+		challenge, err := s.issueMFAChallenge(user.ID, methods)
+		if err != nil {
+			return nil, err
+		}
+		s.auditLog(user.ID, "mfa_challenge", true, clientIP)
+		return nil, challenge
+	}
+
+	pair, err := s.completeLogin(ctx, user.ID, clientIP, userAgent)
+	if err != nil {
+		return nil, err
+	}
+	return &OAuthResult{Tokens: pair}, nil
+}
+
+// oauthUser finds the user an external account signs in as, linking or creating
+// an account on first use.
+func (s *IdentityService) oauthUser(ctx context.Context, provider string, ext *oauth.Identity, clientIP string) (*repository.User, error) {
+	linked, err := s.repo.FindUserIdentity(ctx, provider, ext.Subject)
+	if err == nil {
+		user, err := s.repo.FindUserByID(ctx, linked.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("loading user: %w", err)
+		}
+		return user, nil
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("loading linked account: %w", err)
+	}
+
+	// Only an address the provider vouches for may be matched or used for a new account
+	if !ext.EmailVerified || ext.Email == "" {
+		s.auditLog("", "oauth_login", false, clientIP)
+		return nil, ErrOAuthEmailUnverified
+	}
+	email := s.emails.Normalise(ext.Email)
+	identity := &repository.UserIdentity{ID: uuid.New().String(), Provider: provider, Subject: ext.Subject, Email: email}
+
+	user, err := s.repo.FindUserByEmail(ctx, email)
+	switch {
+	case err == nil:
+		// Linking to an account nobody proved they own would let whoever registered the
+		// address first (possibly with a password of their choosing) share the account
+		if user.EmailVerifiedAt == nil {
+			s.auditLog(user.ID, "oauth_link", false, clientIP)
+			return nil, ErrLinkRequired
+		}
+		identity.UserID = user.ID
+		if err := s.repo.LinkUserIdentity(ctx, identity, securityEvent(user.ID, "identity_linked")); err != nil {
+			if errors.Is(err, repository.ErrIdentityAlreadyLinked) {
+				return nil, ErrIdentityLinked
+			}
+			return nil, fmt.Errorf("linking account: %w", err)
+		}
+		s.auditLog(user.ID, "oauth_link", true, clientIP)
+		return user, nil
+	case !errors.Is(err, repository.ErrNotFound):
+		return nil, fmt.Errorf("loading user: %w", err)
+	}
+
+	// Same check as Signup: the address may be reserved by a recent email change
+	exists, err := s.repo.UserExistsByEmail(ctx, email)
+	if err != nil {
+		return nil, fmt.Errorf("checking email: %w", err)
+	}
+	if exists {
+		return nil, ErrUserAlreadyExists
+	}
+
+	userID := uuid.New().String()
+	name := oauthDisplayName(ext.Name, email)
+	if err := s.repo.CreateOAuthUser(ctx, userID, name, email, identity,
+		lifecycleEvent(userID, eventUserRegistered), lifecycleEvent(userID, eventEmailVerified)); err != nil {
+		switch {
+		case errors.Is(err, ErrUserAlreadyExists):
+			return nil, err
+		case errors.Is(err, repository.ErrIdentityAlreadyLinked):
+			return nil, ErrIdentityLinked
+		}
+		return nil, fmt.Errorf("creating user: %w", err)
+	}
+
+	s.auditLog(userID, "oauth_signup", true, clientIP)
+
+	now := time.Now()
+	return &repository.User{ID: userID, Name: name, Email: email, IsActive: true, EmailVerifiedAt: &now, CreatedAt: now}, nil
+}
+
+// linkIdentity links an external account to a signed-in user.
+func (s *IdentityService) linkIdentity(ctx context.Context, userID, provider string, ext *oauth.Identity, clientIP string) error {
+	if _, err := s.activeUser(ctx, userID); err != nil {
+		return err
+	}
+	existing, err := s.repo.FindUserIdentity(ctx, provider, ext.Subject)
+	switch {
+	case err == nil && existing.UserID == userID:
+		return nil
+	case err == nil:
+		s.auditLog(userID, "oauth_link", false, clientIP)
+		return ErrIdentityLinked
+	case !errors.Is(err, repository.ErrNotFound):
+		return fmt.Errorf("loading linked account: %w", err)
+	}
+
+	err = s.repo.LinkUserIdentity(ctx, &repository.UserIdentity{
+		ID:       uuid.New().String(),
+		UserID:   userID,
+		Provider: provider,
+		Subject:  ext.Subject,
+		Email:    s.emails.Normalise(ext.Email),
+	}, securityEvent(userID, "identity_linked"))
+	if err != nil {
+		if errors.Is(err, repository.ErrIdentityAlreadyLinked) {
+			s.auditLog(userID, "oauth_link", false, clientIP)
+			return ErrIdentityLinked
+		}
+		return fmt.Errorf("linking account: %w", err)
+	}
+
+	s.auditLog(userID, "oauth_link", true, clientIP)
+	return nil
+}
+
+// reauthenticate issues a ReauthToken once the external account a signed-in user
+// just signed in with turns out to be linked to them.
+func (s *IdentityService) reauthenticate(ctx context.Context, userID, provider string, ext *oauth.Identity, clientIP string) (*ReauthToken, error) {
+	if _, err := s.activeUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	linked, err := s.repo.FindUserIdentity(ctx, provider, ext.Subject)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("loading linked account: %w", err)
+	}
+	if err != nil || linked.UserID != userID {
+		s.auditLog(userID, "oauth_reauth", false, clientIP)
+		return nil, ErrOAuthFailed
+	}
+
+	expiresAt := time.Now().Add(reauthTokenLifetime)
+	token, err := s.keyring.Sign(&reauthClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Audience:  jwt.ClaimStrings{reauthAudience},
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    "watup-identity-service",
+			Subject:   userID,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("signing reauthentication token: %w", err)
+	}
+
+	s.auditLog(userID, "oauth_reauth", true, clientIP)
+	return &ReauthToken{Token: token, ExpiresAt: expiresAt}, nil
+}
+
+// reauthClaims, like mfaChallengeClaims, has no user_id claim and so never passes as
+// an access token.
+type reauthClaims struct {
+	jwt.RegisteredClaims
+}
+
+// parseReauthToken returns the user a reauthentication token was issued to.
+func (s *IdentityService) parseReauthToken(tokenString string) (string, error) {
+	claims := &reauthClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, s.keyring.Keyfunc,
+		jwt.WithValidMethods([]string{keys.AlgHS256, keys.AlgRS256, keys.AlgEdDSA}),
+		jwt.WithAudience(reauthAudience),
+	)
+	if err != nil || !token.Valid || claims.Subject == "" {
+		return "", ErrInvalidToken
+	}
+	return claims.Subject, nil
+}
+
+// oauthUserEvent is the audit event of a flow started by a signed-in user.
+func oauthUserEvent(st *repository.OAuthState) string {
+	if st.Reauth {
+		return "oauth_reauth"
+	}
+	return "oauth_link"
+}
+
+// ListLinkedIdentities returns the external accounts the user can sign in with.
+func (s *IdentityService) ListLinkedIdentities(ctx context.Context, userID string) ([]LinkedIdentity, error) {
+	if _, err := s.activeUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	rows, err := s.repo.ListUserIdentities(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("listing linked accounts: %w", err)
+	}
+	out := make([]LinkedIdentity, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, LinkedIdentity{Provider: r.Provider, Email: r.Email, LinkedAt: r.CreatedAt})
+	}
+	return out, nil
+}
+
+// UnlinkIdentity removes the user's account of provider, unless it is their only way
+// to sign in: a password or a passkey also counts.
+func (s *IdentityService) UnlinkIdentity(ctx context.Context, userID, provider, clientIP string) error {
+	user, err := s.activeUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.PasswordHash == "" {
+		rows, err := s.repo.ListUserIdentities(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("listing linked accounts: %w", err)
+		}
+		passkeys, err := s.repo.ListWebAuthnCredentials(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("listing passkeys: %w", err)
+		}
+		if len(rows) <= 1 && len(passkeys) == 0 {
+			return ErrLastSignInMethod
+		}
+	}
+	if err := s.repo.DeleteUserIdentity(ctx, userID, provider, securityEvent(userID, "identity_unlinked")); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrIdentityNotLinked
+		}
+		return fmt.Errorf("unlinking account: %w", err)
+	}
+
+	s.auditLog(userID, "oauth_unlink", true, clientIP)
+	return nil
+}
+
+// oauthDisplayName uses the provider's name, falling back to the email's local part.
+func oauthDisplayName(name, email string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name, _, _ = strings.Cut(email, "@")
+	}
+	if utf8.RuneCountInString(name) > maxOAuthNameLen {
+		name = string([]rune(name)[:maxOAuthNameLen])
+	}
+	return name
+}
@@ -0,0 +1,404 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/watup-lk/identity-service/internal/repository"
+	"github.com/watup-lk/identity-service/internal/webauthn"
+)
+
+var (
+	ErrInvalidPasskeySession    = errors.New("invalid or expired passkey session")
+	ErrInvalidPasskey           = errors.New("passkey verification failed")
+	ErrPasskeyAlreadyRegistered = errors.New("this passkey is already registered")
+	ErrPasskeyNotFound          = errors.New("passkey not found")
+	ErrInvalidPasskeyName       = errors.New("passkey name must be at most 100 characters")
+)
+
+// WebAuthn ceremony purposes, stored with each challenge so a challenge issued for
+// one ceremony cannot complete another.
+const (
+	passkeyPurposeRegister = "register"
+	passkeyPurposeLogin    = "login"
+	passkeyPurposeMFA      = "mfa"
+)
+
+// maxPasskeyNameLen matches the webauthn_credentials.name column (VARCHAR(100)).
+const maxPasskeyNameLen = 100
+
+// PasskeyRegistration is a started registration: Options are passed to
+// navigator.credentials.create(), and the result is sent back with Session.
+type PasskeyRegistration struct {
+	Session   string
+	Options   *webauthn.CreationOptions
+	ExpiresAt time.Time
+}
+
+// PasskeyAssertion is a started passkey sign-in or second-factor check: Options are
+// passed to navigator.credentials.get(), and the result is sent back with Session.
+type PasskeyAssertion struct {
+	Session   string
+	Options   *webauthn.RequestOptions
+	ExpiresAt time.Time
+}
+
+// Passkey is a WebAuthn credential registered to the user.
+type Passkey struct {
+	ID         string
+	Name       string
+	Transports []string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+}
+
+// BeginPasskeyRegistration starts registering a passkey or security key for a
+// signed-in user. The user's existing credentials are excluded, so the same
+// authenticator is not registered twice.
+//
+// A passkey signs in without a second factor, so a stolen access token must not be
+// enough to add one: the user confirms with their password (or reauthentication
+// token) or, when TOTP is on, with a TOTP or recovery code instead.
+func (s *IdentityService) BeginPasskeyRegistration(ctx context.Context, userID string, proof Reauthentication, code, clientIP string) (*PasskeyRegistration, error) {
+	user, err := s.activeUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.confirmPasskeyOwner(ctx, user, proof, code, clientIP); err != nil {
+		s.auditLog(userID, "passkey_register", false, clientIP)
+		return nil, err
+	}
+	existing, err := s.webAuthnCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	session, challenge, expiresAt, err := s.startPasskeyCeremony(ctx, userID, passkeyPurposeRegister)
+	if err != nil {
+		return nil, err
+	}
+	// The user handle is the account id: stable, opaque and not personal data
+	opts := s.passkeys.CreationOptions(challenge, webauthn.User{ID: []byte(userID), Name: user.Email, DisplayName: user.Name}, existing)
+	return &PasskeyRegistration{Session: session, Options: opts, ExpiresAt: expiresAt}, nil
+}
+
+// confirmPasskeyOwner checks the proof BeginPasskeyRegistration asks for.
+func (s *IdentityService) confirmPasskeyOwner(ctx context.Context, user *repository.User, proof Reauthentication, code, clientIP string) error {
+	totpOn, err := s.totpEnabled(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if !totpOn {
+		return s.confirmReauth(ctx, user, proof, clientIP)
+	}
+	if code == "" {
+		return ErrMFARequired
+	}
+	return s.confirmSecondFactor(ctx, user, code, clientIP)
+}
+
+// FinishPasskeyRegistration verifies the authenticator's response to a registration
+// started by BeginPasskeyRegistration and stores the new credential under name.
+func (s *IdentityService) FinishPasskeyRegistration(ctx context.Context, userID, session, name string, resp *webauthn.RegistrationResponse, clientIP string) (*Passkey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Passkey"
+	}
+	if utf8.RuneCountInString(name) > maxPasskeyNameLen {
+		return nil, ErrInvalidPasskeyName
+	}
+
+	ch, err := s.consumePasskeyCeremony(ctx, session, passkeyPurposeRegister)
+	if err != nil {
+		return nil, err
+	}
+	if ch.UserID != userID {
+		s.auditLog(userID, "passkey_register", false, clientIP)
+		return nil, ErrInvalidPasskeySession
+	}
+	if _, err := s.activeUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	cred, err := s.passkeys.VerifyRegistration(ch.Challenge, resp)
+	if err != nil {
+		log.Printf("[passkeys] registration for user %s rejected: %v", userID, err)
+		s.auditLog(userID, "passkey_register", false, clientIP)
+		return nil, ErrInvalidPasskey
+	}
+
+	stored := &repository.WebAuthnCredential{
+		ID:           uuid.New().String(),
+		UserID:       userID,
+		CredentialID: cred.ID,
+		PublicKey:    cred.PublicKey,
+		SignCount:    cred.SignCount,
+		AAGUID:       cred.AAGUID,
+		Transports:   cred.Transports,
+		Name:         name,
+	}
+	if err := s.repo.CreateWebAuthnCredential(ctx, stored, securityEvent(userID, "passkey_added")); err != nil {
+		if errors.Is(err, repository.ErrCredentialAlreadyRegistered) {
+			s.auditLog(userID, "passkey_register", false, clientIP)
+			return nil, ErrPasskeyAlreadyRegistered
+		}
+		return nil, fmt.Errorf("storing passkey: %w", err)
+	}
+
+	s.auditLog(userID, "passkey_register", true, clientIP)
+
+	return &Passkey{ID: stored.ID, Name: name, Transports: stored.Transports, CreatedAt: time.Now()}, nil
+}
+
+// ListPasskeys returns the user's registered passkeys, oldest first.
+func (s *IdentityService) ListPasskeys(ctx context.Context, userID string) ([]Passkey, error) {
+	if _, err := s.activeUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	rows, err := s.repo.ListWebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("listing passkeys: %w", err)
+	}
+	out := make([]Passkey, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, Passkey{ID: r.ID, Name: r.Name, Transports: r.Transports, CreatedAt: r.CreatedAt, LastUsedAt: r.LastUsedAt})
+	}
+	return out, nil
+}
+
+// DeletePasskey removes one of the user's passkeys. Like DisableTOTP it requires the
+// account password, or a reauthentication token, so a stolen access token cannot
+// remove a sign-in method.
+func (s *IdentityService) DeletePasskey(ctx context.Context, userID, passkeyID string, proof Reauthentication, clientIP string) error {
+	user, err := s.activeUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.confirmReauth(ctx, user, proof, clientIP); err != nil {
+		s.auditLog(userID, "passkey_remove", false, clientIP)
+		return err
+	}
+	if _, err := uuid.Parse(passkeyID); err != nil {
+		return ErrPasskeyNotFound
+	}
+	if err := s.repo.DeleteWebAuthnCredential(ctx, userID, passkeyID, securityEvent(userID, "passkey_removed")); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrPasskeyNotFound
+		}
+		return fmt.Errorf("deleting passkey: %w", err)
+	}
+
+	s.auditLog(userID, "passkey_remove", true, clientIP)
+
+	return nil
+}
+
+// BeginPasskeyLogin starts a passwordless sign-in. No account is named: the browser
+// offers the passkeys it holds for this site and the response identifies the user.
+func (s *IdentityService) BeginPasskeyLogin(ctx context.Context) (*PasskeyAssertion, error) {
+	session, challenge, expiresAt, err := s.startPasskeyCeremony(ctx, "", passkeyPurposeLogin)
+	if err != nil {
+		return nil, err
+	}
+	opts := s.passkeys.RequestOptions(challenge, nil, webauthn.UVRequired)
+	return &PasskeyAssertion{Session: session, Options: opts, ExpiresAt: expiresAt}, nil
+}
+
+// FinishPasskeyLogin completes a passwordless sign-in. The authenticator must have
+// verified the user (PIN or biometrics), which makes the passkey both factors, so no
+// MFA challenge follows.
+func (s *IdentityService) FinishPasskeyLogin(ctx context.Context, session string, resp *webauthn.AssertionResponse, clientIP, userAgent string) (*TokenPair, error) {
+	ch, err := s.consumePasskeyCeremony(ctx, session, passkeyPurposeLogin)
+	if err != nil {
+		s.auditLog("", "passkey_login", false, clientIP)
+		return nil, err
+	}
+	cred, err := s.verifyPasskey(ctx, ch.Challenge, resp, "", true, clientIP)
+	if err != nil {
+		s.auditLog("", "passkey_login", false, clientIP)
+		return nil, err
+	}
+
+	user, err := s.repo.FindUserByID(ctx, cred.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("loading user: %w", err)
+	}
+	if !user.IsActive {
+		s.auditLog(user.ID, "passkey_login", false, clientIP)
+		return nil, ErrAccountDisabled
+	}
+	if isLocked(user, time.Now()) {
+		s.auditLog(user.ID, "login_locked", false, clientIP)
+		return nil, ErrAccountLocked
+	}
+
+	return s.completeLogin(ctx, user.ID, clientIP, userAgent)
+}
+
+// BeginPasskeyMFA starts answering an MFA challenge with one of the user's passkeys
+// instead of a TOTP or recovery code.
+func (s *IdentityService) BeginPasskeyMFA(ctx context.Context, challengeToken string) (*PasskeyAssertion, error) {
+	userID, err := s.parseMFAChallenge(challengeToken)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	creds, err := s.webAuthnCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(creds) == 0 {
+		return nil, ErrMFANotEnrolled
+	}
+
+	session, challenge, expiresAt, err := s.startPasskeyCeremony(ctx, userID, passkeyPurposeMFA)
+	if err != nil {
+		return nil, err
+	}
+	// The password was the first factor; presence on a registered authenticator is the second
+	opts := s.passkeys.RequestOptions(challenge, creds, webauthn.UVDiscouraged)
+	return &PasskeyAssertion{Session: session, Options: opts, ExpiresAt: expiresAt}, nil
+}
+
+// VerifyMFAPasskey completes a login started by Login, like VerifyMFA, with the
+// passkey response to a ceremony started by BeginPasskeyMFA.
+func (s *IdentityService) VerifyMFAPasskey(ctx context.Context, challengeToken, session string, resp *webauthn.AssertionResponse, clientIP, userAgent string) (*TokenPair, error) {
+	userID, err := s.parseMFAChallenge(challengeToken)
+	if err != nil {
+		s.auditLog("", "mfa_verify", false, clientIP)
+		return nil, ErrInvalidToken
+	}
+	ch, err := s.consumePasskeyCeremony(ctx, session, passkeyPurposeMFA)
+	if err == nil && ch.UserID != userID {
+		err = ErrInvalidPasskeySession
+	}
+	if err != nil {
+		s.auditLog(userID, "mfa_verify", false, clientIP)
+		return nil, err
+	}
+	user, err := s.activeUser(ctx, userID)
+	if err != nil {
+		s.auditLog(userID, "mfa_verify", false, clientIP)
+		return nil, err
+	}
+	if isLocked(user, time.Now()) {
+		s.auditLog(userID, "login_locked", false, clientIP)
+		return nil, ErrAccountLocked
+	}
+	if _, err := s.verifyPasskey(ctx, ch.Challenge, resp, userID, false, clientIP); err != nil {
+		s.auditLog(userID, "mfa_verify", false, clientIP)
+		return nil, err
+	}
+
+	s.auditLog(userID, "mfa_verify", true, clientIP)
+	return s.completeLogin(ctx, userID, clientIP, userAgent)
+}
+
+// startPasskeyCeremony stores a new challenge for purpose and returns the opaque
+// session the client sends back with the authenticator's response.
+func (s *IdentityService) startPasskeyCeremony(ctx context.Context, userID, purpose string) (string, []byte, time.Time, error) {
+	session, challenge := newOpaqueToken(), webauthn.NewChallenge()
+	expiresAt := time.Now().Add(time.Duration(s.cfg.WebAuthnMinutes) * time.Minute)
+	if err := s.repo.StoreWebAuthnChallenge(ctx, &repository.WebAuthnChallenge{
+		SessionHash: hashToken(session),
+		UserID:      userID,
+		Purpose:     purpose,
+		Challenge:   challenge,
+		ExpiresAt:   expiresAt,
+	}); err != nil {
+		return "", nil, time.Time{}, fmt.Errorf("storing passkey challenge: %w", err)
+	}
+	return session, challenge, expiresAt, nil
+}
+
+// consumePasskeyCeremony loads and deletes the challenge behind session, so each
+// ceremony is answered at most once.
+func (s *IdentityService) consumePasskeyCeremony(ctx context.Context, session, purpose string) (*repository.WebAuthnChallenge, error) {
+	ch, err := s.repo.ConsumeWebAuthnChallenge(ctx, hashToken(session))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidPasskeySession
+		}
+		return nil, fmt.Errorf("loading passkey challenge: %w", err)
+	}
+	if ch.Purpose != purpose {
+		return nil, ErrInvalidPasskeySession
+	}
+	return ch, nil
+}
+
+// verifyPasskey checks an assertion against challenge and records the credential's
+// new signature counter. When userID is set, only that user's credentials are
+// accepted. A counter that went backwards is reported as a suspected clone.
+func (s *IdentityService) verifyPasskey(ctx context.Context, challenge []byte, resp *webauthn.AssertionResponse, userID string, requireUV bool, clientIP string) (*repository.WebAuthnCredential, error) {
+	credID, err := resp.CredentialID()
+	if err != nil {
+		return nil, ErrInvalidPasskey
+	}
+	stored, err := s.repo.FindWebAuthnCredential(ctx, credID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidPasskey
+		}
+		return nil, fmt.Errorf("loading passkey: %w", err)
+	}
+	if userID != "" && stored.UserID != userID {
+		return nil, ErrInvalidPasskey
+	}
+	if handle, err := resp.UserHandle(); err != nil || (handle != nil && string(handle) != stored.UserID) {
+		return nil, ErrInvalidPasskey
+	}
+
+	signCount, err := s.passkeys.VerifyAssertion(challenge, toWebAuthnCredential(stored), resp, requireUV)
+	if errors.Is(err, webauthn.ErrSignCount) {
+		log.Printf("[security] passkey %s of user %s reported a stale signature counter; possible clone", stored.ID, stored.UserID)
+		if err := s.repo.RecordEvents(ctx, securityEvent(stored.UserID, "passkey_clone_suspected")); err != nil {
+			log.Printf("[security] failed to record passkey clone event for user %s: %v", stored.UserID, err)
+		}
+		s.auditLog(stored.UserID, "passkey_clone_suspected", false, clientIP)
+		return nil, ErrInvalidPasskey
+	}
+	if err != nil {
+		log.Printf("[passkeys] assertion for passkey %s rejected: %v", stored.ID, err)
+		return nil, ErrInvalidPasskey
+	}
+
+	// Another request may have used the same counter value since the credential was loaded
+	fresh, err := s.repo.UseWebAuthnCredential(ctx, stored.ID, stored.SignCount, signCount)
+	if err != nil {
+		return nil, fmt.Errorf("recording passkey use: %w", err)
+	}
+	if !fresh {
+		return nil, ErrInvalidPasskey
+	}
+	return stored, nil
+}
+
+// webAuthnCredentials returns the user's credentials in the form the ceremonies take.
+func (s *IdentityService) webAuthnCredentials(ctx context.Context, userID string) ([]webauthn.Credential, error) {
+	rows, err := s.repo.ListWebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("listing passkeys: %w", err)
+	}
+	out := make([]webauthn.Credential, 0, len(rows))
+	for i := range rows {
+		out = append(out, *toWebAuthnCredential(&rows[i]))
+	}
+	return out, nil
+}
+
+func toWebAuthnCredential(c *repository.WebAuthnCredential) *webauthn.Credential {
+	return &webauthn.Credential{
+		ID:         c.CredentialID,
+		PublicKey:  c.PublicKey,
+		SignCount:  c.SignCount,
+		AAGUID:     c.AAGUID,
+		Transports: c.Transports,
+	}
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/watup-lk/identity-service/internal/mailer"
+	"github.com/watup-lk/identity-service/internal/repository"
+)
+
+// RequestPasswordReset issues a one-time reset token and emails it to the user.
+// It always returns nil for unknown or disabled accounts so the endpoint cannot be
+// used to discover which emails are registered.
+func (s *IdentityService) RequestPasswordReset(ctx context.Context, email, clientIP string) error {
+	user, err := s.repo.FindUserByEmail(ctx, s.emails.Normalise(email))
+	if err != nil || !user.IsActive {
+		s.auditLog("", "password_reset_requested", false, clientIP)
+		return nil
+	}
+
+	// The token is stored and emailed in the background, so known and unknown
+	// accounts both return straight after the lookup and response timing does not
+	// reveal whether the account exists
+	go s.sendPasswordReset(user, clientIP)
+	return nil
+}
+
+// sendPasswordReset stores a reset token for user, together with a
+// user.password_reset_requested event, and emails the link. Errors are logged, not
+// propagated.
+func (s *IdentityService) sendPasswordReset(user *repository.User, clientIP string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rawToken := newOpaqueToken()
+	expiresAt := time.Now().Add(time.Duration(s.cfg.PasswordResetMinutes) * time.Minute)
+	event := lifecycleEvent(user.ID, eventPasswordResetRequested)
+	if err := s.repo.StorePasswordResetToken(ctx, uuid.New().String(), user.ID, hashToken(rawToken), expiresAt, event); err != nil {
+		log.Printf("[password] failed to store reset token for user %s: %v", user.ID, err)
+		s.auditLog(user.ID, "password_reset_requested", false, clientIP)
+		return
+	}
+
+	s.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your watup.lk password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nWe received a request to reset your password. Use the link below within %d minutes:\n\n%s/reset-password?token=%s\n\nIf you did not request this, you can ignore this email.\n",
+			user.Name, s.cfg.PasswordResetMinutes, s.cfg.FrontendURL, url.QueryEscape(rawToken),
+		),
+	})
+	s.auditLog(user.ID, "password_reset_requested", true, clientIP)
+}
+
+// ResetPassword consumes a reset token, sets the new password and revokes every
+// refresh token the user holds so all existing sessions are terminated. The user's
+// other reset links stop working and any login lockout is cleared as well. Links of
+// disabled accounts are refused like unknown ones.
+func (s *IdentityService) ResetPassword(ctx context.Context, rawToken, newPassword, clientIP string) error {
+	// The token is only consumed once the new password is accepted, so a rejected
+	// password can be retried with the same link
+	pending, err := s.repo.FindPasswordResetToken(ctx, hashToken(rawToken))
+	if err != nil {
+		s.auditLog("", "password_reset", false, clientIP)
+		return ErrInvalidResetToken
+	}
+	user, err := s.activeUser(ctx, pending.UserID)
+	if errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrAccountDisabled) {
+		s.auditLog(pending.UserID, "password_reset", false, clientIP)
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.checkNewPassword(newPassword, user.Name, user.Email); err != nil {
+		return err
+	}
+
+	hash, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		return fmt.Errorf("hashing password: %w", err)
+	}
+	token, err := s.repo.ResetPassword(ctx, hashToken(rawToken), hash, lifecycleEvent(user.ID, eventPasswordReset))
+	if errors.Is(err, repository.ErrNotFound) {
+		s.auditLog("", "password_reset", false, clientIP)
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return fmt.Errorf("updating password: %w", err)
+	}
+	if err := s.denySessions(ctx, token.UserID, ""); err != nil {
+		return err
+	}
+	if err := s.repo.RevokeAllUserTokens(ctx, token.UserID); err != nil {
+		return fmt.Errorf("revoking sessions: %w", err)
+	}
+	// Proving control of the mailbox also lifts a lockout
+	s.clearLoginFailures(ctx, token.UserID)
+
+	s.auditLog(token.UserID, "password_reset", true, clientIP)
+
+	return nil
+}
+
+// ChangePassword updates the password of an authenticated user after re-checking the
+// current password. Every refresh token is revoked except keepRefreshToken (the caller's
+// own session), which may be empty to sign out everywhere.
+func (s *IdentityService) ChangePassword(ctx context.Context, userID, currentPassword, newPassword, keepRefreshToken, clientIP string) error {
+	user, err := s.repo.FindUserByID(ctx, userID)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if !user.IsActive {
+		s.auditLog(user.ID, "password_change", false, clientIP)
+		return ErrAccountDisabled
+	}
+	if err := s.confirmPassword(ctx, user, currentPassword, clientIP); err != nil {
+		s.auditLog(user.ID, "password_change", false, clientIP)
+		return err
+	}
+	if currentPassword == newPassword {
+		return ErrSamePassword
+	}
+	if err := s.checkNewPassword(newPassword, user.Name, user.Email); err != nil {
+		return err
+	}
+
+	hash, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		return fmt.Errorf("hashing password: %w", err)
+	}
+	if err := s.repo.UpdatePasswordHash(ctx, user.ID, hash, lifecycleEvent(user.ID, eventPasswordChanged)); err != nil {
+		return fmt.Errorf("updating password: %w", err)
+	}
+
+	// Only spare the caller's session if the token really belongs to them
+	keepHash, keepSession := "", ""
+	if keepRefreshToken != "" {
+		if stored, err := s.repo.FindRefreshToken(ctx, hashToken(keepRefreshToken)); err == nil && stored.UserID == user.ID {
+			keepHash, keepSession = stored.TokenHash, familyOf(stored)
+		}
+	}
+	if err := s.denySessions(ctx, user.ID, keepSession); err != nil {
+		return err
+	}
+	if keepHash != "" {
+		err = s.repo.RevokeAllUserTokensExcept(ctx, user.ID, keepHash)
+	} else {
+		err = s.repo.RevokeAllUserTokens(ctx, user.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("revoking sessions: %w", err)
+	}
+
+	s.auditLog(user.ID, "password_change", true, clientIP)
+
+	return nil
+}
+
+// checkPassword verifies a password against the user's stored hash. When it matches
+// a hash made under an older algorithm or weaker cost than the current policy, the
+// hash is upgraded in place — the plaintext is only available at this moment.
+func (s *IdentityService) checkPassword(ctx context.Context, user *repository.User, password string) bool {
+	if user.PasswordHash == "" { // signed up through a social login provider
+		return false
+	}
+	ok, needsRehash, err := s.hasher.Verify(password, user.PasswordHash)
+	if err != nil {
+		log.Printf("[password] unreadable password hash for user %s: %v", user.ID, err)
+		return false
+	}
+	if !ok || !needsRehash {
+		return ok
+	}
+
+	hash, err := s.hasher.Hash(password)
+	if err == nil {
+		err = s.repo.UpdatePasswordHash(ctx, user.ID, hash)
+	}
+	if err != nil {
+		// The login itself succeeded; the upgrade is retried next time
+		log.Printf("[password] failed to upgrade password hash for user %s: %v", user.ID, err)
+		return true
+	}
+	user.PasswordHash = hash
+	return true
+}
+
+// checkNewPassword applies the password policy to a password the user is about to
+// set. If the breach corpus cannot be read the check fails open — the remaining
+// rules still apply — so a disk problem does not block every signup.
+func (s *IdentityService) checkNewPassword(password, name, email string) error {
+	reasons, err := s.passwords.Check(password, name, email)
+	if err != nil {
+		log.Printf("[password] breached password lookup failed: %v", err)
+	}
+	if len(reasons) > 0 {
+		return &PasswordPolicyError{Reasons: reasons}
+	}
+	return nil
+}
+
+// sendMail delivers an email. Fire-and-forget — errors are logged, not propagated.
+func (s *IdentityService) sendMail(msg mailer.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		log.Printf("[mailer] failed to send %q: %v", msg.Subject, err)
+	}
+}
@@ -4,6 +4,10 @@ import (
 	"context"
 	"time"
 
+	"github.com/watup-lk/identity-service/internal/auditlog"
+	"github.com/watup-lk/identity-service/internal/mailer"
+	"github.com/watup-lk/identity-service/internal/oauth"
+	"github.com/watup-lk/identity-service/internal/outbox"
 	"github.com/watup-lk/identity-service/internal/repository"
 )
 
@@ -11,24 +15,85 @@ import (
 // Using an interface allows the service to be tested with a mock
 // without needing a real PostgreSQL database.
 type Repo interface {
-	CreateUser(ctx context.Context, id, name, email, passwordHash string, age *int) error
+	CreateUser(ctx context.Context, id, name, email, passwordHash string, age *int, events ...outbox.Event) error
 	UserExistsByEmail(ctx context.Context, email string) (bool, error)
 	FindUserByEmail(ctx context.Context, email string) (*repository.User, error)
 	FindUserByID(ctx context.Context, id string) (*repository.User, error)
-	StoreRefreshToken(ctx context.Context, id, userID, tokenHash string, expiresAt time.Time) error
+	UpdateUserProfile(ctx context.Context, userID, name string, age *int, events ...outbox.Event) error
+	FindUserRoles(ctx context.Context, userID string) ([]string, error)
+	MarkUserDeleted(ctx context.Context, userID string) error
+	AnonymiseDeletedUsers(ctx context.Context, deletedBefore time.Time, limit int, eventsFor func(userID string) []outbox.Event) ([]string, error)
+	RecordFailedLogin(ctx context.Context, userID string, window time.Duration) (int, error)
+	LockUser(ctx context.Context, userID string, until time.Time, events ...outbox.Event) error
+	ResetFailedLogins(ctx context.Context, userID string) error
+	StoreRefreshToken(ctx context.Context, id, userID, familyID, parentID, tokenHash, userAgent, ipAddress string, expiresAt time.Time, events ...outbox.Event) error
 	FindRefreshToken(ctx context.Context, tokenHash string) (*repository.RefreshToken, error)
-	RevokeRefreshToken(ctx context.Context, tokenHash string) error
-	RevokeAllUserTokens(ctx context.Context, userID string) error // used on password change / forced logout
-	InsertAuditLog(ctx context.Context, userID, eventType string, success bool, ipAddress string) error
+	RevokeRefreshToken(ctx context.Context, tokenHash string, events ...outbox.Event) error
+	RotateRefreshToken(ctx context.Context, tokenHash, replacedBy string) (bool, error)
+	RevokeTokenFamily(ctx context.Context, familyID string, events ...outbox.Event) error
+	ListActiveSessions(ctx context.Context, userID string) ([]repository.Session, error)
+	RevokeAllUserTokens(ctx context.Context, userID string, events ...outbox.Event) error // used on password reset / forced logout
+	RevokeAllUserTokensExcept(ctx context.Context, userID, keepTokenHash string) error
+	UpdatePasswordHash(ctx context.Context, userID, passwordHash string, events ...outbox.Event) error
+	StorePasswordResetToken(ctx context.Context, id, userID, tokenHash string, expiresAt time.Time, events ...outbox.Event) error
+	FindPasswordResetToken(ctx context.Context, tokenHash string) (*repository.PasswordResetToken, error)
+	ResetPassword(ctx context.Context, tokenHash, passwordHash string, events ...outbox.Event) (*repository.PasswordResetToken, error)
+	StoreEmailVerificationToken(ctx context.Context, id, userID, tokenHash string, expiresAt time.Time) error
+	ConsumeEmailVerificationToken(ctx context.Context, tokenHash string) (*repository.EmailVerificationToken, error)
+	MarkEmailVerified(ctx context.Context, userID string, events ...outbox.Event) error
+	CreateEmailChange(ctx context.Context, c *repository.EmailChange) error
+	ConfirmEmailChange(ctx context.Context, confirmTokenHash string, eventsFor func(c *repository.EmailChange) []outbox.Event) (*repository.EmailChange, error)
+	CancelEmailChange(ctx context.Context, cancelTokenHash string, eventsFor func(c *repository.EmailChange) []outbox.Event) (*repository.EmailChange, error)
+	UpsertTOTPEnrollment(ctx context.Context, userID, secret string) (bool, error)
+	FindTOTP(ctx context.Context, userID string) (*repository.TOTPCredential, error)
+	ConfirmTOTP(ctx context.Context, userID string, step int64, recoveryCodeHashes []string, events ...outbox.Event) error
+	UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error)
+	DeleteTOTP(ctx context.Context, userID string, events ...outbox.Event) error
+	ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error
+	ConsumeRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error)
+	InsertAuditLogs(ctx context.Context, events []auditlog.Event) error
+	ListAuditLogs(ctx context.Context, userID string) ([]repository.AuditLog, error)
+	QueryAuditLogs(ctx context.Context, f repository.AuditFilter, after *repository.AuditCursor, limit int) ([]repository.AuditLog, error)
+	CountAuditLogs(ctx context.Context, f repository.AuditFilter, bucket, groupBy string, limit int) ([]repository.AuditCount, error)
+	CreateDataExport(ctx context.Context, id, userID string, staleBefore time.Time) error
+	FindLatestDataExport(ctx context.Context, userID string) (*repository.DataExport, error)
+	CompleteDataExport(ctx context.Context, id string, payload []byte, expiresAt time.Time) error
+	FailDataExport(ctx context.Context, id string) error
+	DeleteExpiredDataExports(ctx context.Context) (int64, error)
+	StoreOAuthState(ctx context.Context, st *repository.OAuthState) error
+	ConsumeOAuthState(ctx context.Context, stateHash string) (*repository.OAuthState, error)
+	FindUserIdentity(ctx context.Context, provider, subject string) (*repository.UserIdentity, error)
+	ListUserIdentities(ctx context.Context, userID string) ([]repository.UserIdentity, error)
+	LinkUserIdentity(ctx context.Context, id *repository.UserIdentity, events ...outbox.Event) error
+	CreateOAuthUser(ctx context.Context, userID, name, email string, id *repository.UserIdentity, events ...outbox.Event) error
+	DeleteUserIdentity(ctx context.Context, userID, provider string, events ...outbox.Event) error
+	StoreWebAuthnChallenge(ctx context.Context, c *repository.WebAuthnChallenge) error
+	ConsumeWebAuthnChallenge(ctx context.Context, sessionHash string) (*repository.WebAuthnChallenge, error)
+	CreateWebAuthnCredential(ctx context.Context, c *repository.WebAuthnCredential, events ...outbox.Event) error
+	ListWebAuthnCredentials(ctx context.Context, userID string) ([]repository.WebAuthnCredential, error)
+	FindWebAuthnCredential(ctx context.Context, credentialID []byte) (*repository.WebAuthnCredential, error)
+	UseWebAuthnCredential(ctx context.Context, id string, prevSignCount, signCount uint32) (bool, error)
+	DeleteWebAuthnCredential(ctx context.Context, userID, id string, events ...outbox.Event) error
+	DenyAccessTokens(ctx context.Context, userID string, ids []string, expiresAt time.Time) error
+	AccessTokenDenied(ctx context.Context, ids []string) (bool, error)
+	RecordEvents(ctx context.Context, events ...outbox.Event) error
 	Ping(ctx context.Context) error
 }
 
-// EventPublisher abstracts the Kafka producer so the service is not coupled
-// to a specific messaging implementation.
-type EventPublisher interface {
-	PublishUserRegistered(ctx context.Context, userID string)
-	PublishUserLogin(ctx context.Context, userID string)
-	PublishUserLogout(ctx context.Context, userID string)
-	PublishTokenRefresh(ctx context.Context, userID string)
-	Close()
+// IdentityProvider is an external login provider (see package oauth).
+type IdentityProvider interface {
+	Name() string
+	AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error)
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*oauth.Identity, error)
+}
+
+// Mailer abstracts outbound email delivery (SMTP in production, log/file locally).
+type Mailer interface {
+	Send(ctx context.Context, msg mailer.Message) error
+}
+
+// AuditRecorder queues audit events for writing off the request path (see
+// auditlog.Writer).
+type AuditRecorder interface {
+	Record(ev auditlog.Event)
 }
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/watup-lk/identity-service/internal/repository"
+)
+
+// Profile is the account as shown to the user and to other services. Unlike
+// repository.User it carries no credentials or lockout state.
+type Profile struct {
+	ID              string
+	Name            string
+	Email           string
+	Age             *int
+	IsActive        bool
+	EmailVerifiedAt *time.Time // nil = email not yet verified
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// ProfileUpdate lists the profile fields to change. Nil fields are left as they are;
+// ClearAge removes the age.
+type ProfileUpdate struct {
+	Name     *string
+	Age      *int
+	ClearAge bool
+}
+
+// GetProfile returns the caller's own profile.
+func (s *IdentityService) GetProfile(ctx context.Context, userID string) (*Profile, error) {
+	user, err := s.activeUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return profileOf(user), nil
+}
+
+// UpdateProfile changes the user's name and/or age and returns the updated profile.
+// Input is validated by the caller with the same rules as Signup. An update that
+// changes nothing is not stored, announced or audited.
+func (s *IdentityService) UpdateProfile(ctx context.Context, userID string, update ProfileUpdate, clientIP string) (*Profile, error) {
+	user, err := s.activeUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	name, age := user.Name, user.Age
+	if update.Name != nil {
+		name = *update.Name
+	}
+	if update.ClearAge {
+		age = nil
+	} else if update.Age != nil {
+		age = update.Age
+	}
+	if name == user.Name && sameAge(age, user.Age) {
+		return profileOf(user), nil
+	}
+
+	if err := s.repo.UpdateUserProfile(ctx, userID, name, age, lifecycleEvent(userID, eventProfileUpdated)); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("updating profile: %w", err)
+	}
+	updated, err := s.repo.FindUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("loading profile: %w", err)
+	}
+
+	s.auditLog(userID, "profile_update", true, clientIP)
+
+	return profileOf(updated), nil
+}
+
+func profileOf(u *repository.User) *Profile {
+	return &Profile{
+		ID:              u.ID,
+		Name:            u.Name,
+		Email:           u.Email,
+		Age:             u.Age,
+		IsActive:        u.IsActive,
+		EmailVerifiedAt: u.EmailVerifiedAt,
+		CreatedAt:       u.CreatedAt,
+		UpdatedAt:       u.UpdatedAt,
+	}
+}
+
+func sameAge(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
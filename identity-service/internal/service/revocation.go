@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/watup-lk/identity-service/internal/keys"
+	"github.com/watup-lk/identity-service/internal/roles"
+)
+
+// Access tokens are stateless JWTs, so revoking one before it expires means
+// denylisting its id until then. Whole sessions are denylisted by their id (the
+// token's sid claim), which covers every access token issued within the session,
+// including ones minted by a refresh racing the revocation.
+
+// TokenIntrospection describes an access token, in the spirit of RFC 7662.
+type TokenIntrospection struct {
+	Active    bool // the token verifies, has not expired and has not been revoked
+	Revoked   bool
+	UserID    string
+	Roles     []string
+	SessionID string
+	TokenID   string // jti
+	Issuer    string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// IntrospectToken reports on an access token. Tokens that fail verification come
+// back inactive and empty; expired and revoked tokens come back inactive but with
+// their claims, so the caller can tell whose session ended.
+func (s *IdentityService) IntrospectToken(ctx context.Context, tokenString string) (*TokenIntrospection, error) {
+	claims, err := s.verifyAccessToken(tokenString, jwt.WithoutClaimsValidation())
+	if err != nil {
+		return &TokenIntrospection{}, nil
+	}
+	revoked, err := s.accessTokenDenied(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &TokenIntrospection{
+		Revoked:   revoked,
+		UserID:    claims.UserID,
+		Roles:     claims.Roles,
+		SessionID: claims.SessionID,
+		TokenID:   claims.ID,
+		Issuer:    claims.Issuer,
+	}
+	if len(info.Roles) == 0 {
+		info.Roles = []string{roles.User}
+	}
+	if claims.IssuedAt != nil {
+		info.IssuedAt = claims.IssuedAt.Time
+	}
+	if claims.ExpiresAt != nil {
+		info.ExpiresAt = claims.ExpiresAt.Time
+	}
+	info.Active = !revoked && time.Now().Before(info.ExpiresAt)
+	return info, nil
+}
+
+// RevokeAccessToken denylists a single access token until it expires. The rest of
+// its session is unaffected. Returns ErrInvalidToken for a token that does not
+// verify or has already expired.
+func (s *IdentityService) RevokeAccessToken(ctx context.Context, tokenString, clientIP string) error {
+	claims, err := s.verifyAccessToken(tokenString)
+	if err != nil {
+		return err
+	}
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return ErrInvalidToken
+	}
+	if err := s.repo.DenyAccessTokens(ctx, claims.UserID, []string{claims.ID}, claims.ExpiresAt.Time); err != nil {
+		return fmt.Errorf("revoking access token: %w", err)
+	}
+
+	s.auditLog(claims.UserID, "access_token_revoke", true, clientIP)
+
+	return nil
+}
+
+// RevokeUserSessions signs the user out everywhere: every refresh token is revoked
+// and the access tokens of their sessions stop validating at once (support/admin
+// tooling, or another service reacting to a compromised account).
+func (s *IdentityService) RevokeUserSessions(ctx context.Context, userID, clientIP string) error {
+	if _, err := s.repo.FindUserByID(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.denySessions(ctx, userID, ""); err != nil {
+		return err
+	}
+	if err := s.repo.RevokeAllUserTokens(ctx, userID, securityEvent(userID, "sessions_revoked")); err != nil {
+		return fmt.Errorf("revoking sessions: %w", err)
+	}
+
+	s.auditLog(userID, "session_revoke_all", true, clientIP)
+
+	return nil
+}
+
+// denySessions denylists the access tokens of the user's active sessions except
+// keepSessionID. It must run before their refresh tokens are revoked, as revoked
+// sessions are no longer listed.
+func (s *IdentityService) denySessions(ctx context.Context, userID, keepSessionID string) error {
+	sessions, err := s.repo.ListActiveSessions(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("listing sessions: %w", err)
+	}
+	var ids []string
+	for _, sess := range sessions {
+		if sess.ID != keepSessionID && !slices.Contains(ids, sess.ID) {
+			ids = append(ids, sess.ID)
+		}
+	}
+	return s.denyAccessTokens(ctx, userID, ids...)
+}
+
+// denyAccessTokens denylists token or session ids for as long as an access token
+// issued now stays valid.
+func (s *IdentityService) denyAccessTokens(ctx context.Context, userID string, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	until := time.Now().Add(time.Duration(s.cfg.AccessTokenMinutes) * time.Minute)
+	if err := s.repo.DenyAccessTokens(ctx, userID, ids, until); err != nil {
+		return fmt.Errorf("revoking access tokens: %w", err)
+	}
+	return nil
+}
+
+// accessTokenDenied reports whether the token or its session has been revoked.
+func (s *IdentityService) accessTokenDenied(ctx context.Context, claims *Claims) (bool, error) {
+	var ids []string
+	for _, id := range []string{claims.ID, claims.SessionID} {
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return false, nil
+	}
+	denied, err := s.repo.AccessTokenDenied(ctx, ids)
+	if err != nil {
+		return false, fmt.Errorf("checking access token denylist: %w", err)
+	}
+	return denied, nil
+}
+
+// parseAccessToken verifies an access token and checks it has not been revoked.
+// A denylist lookup failure is returned as is: the token is not trusted without it.
+func (s *IdentityService) parseAccessToken(ctx context.Context, tokenString string) (*Claims, error) {
+	claims, err := s.verifyAccessToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	denied, err := s.accessTokenDenied(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	if denied {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// verifyAccessToken checks an access token's signature and claims. The verification
+// key is chosen by the token's kid header.
+func (s *IdentityService) verifyAccessToken(tokenString string, opts ...jwt.ParserOption) (*Claims, error) {
+	claims := &Claims{}
+	opts = append(opts, jwt.WithValidMethods([]string{keys.AlgHS256, keys.AlgRS256, keys.AlgEdDSA}))
+	token, err := jwt.ParseWithClaims(tokenString, claims, s.keyring.Keyfunc, opts...)
+	if err != nil || !token.Valid || claims.UserID == "" {
+		return nil, ErrInvalidToken
+	}
+	// MFA challenge and reauthentication tokens are signed with the same keys but must
+	// not grant access
+	if slices.Contains(claims.Audience, mfaChallengeAudience) || slices.Contains(claims.Audience, reauthAudience) {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
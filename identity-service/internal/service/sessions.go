@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// maxUserAgentLen bounds the client-supplied User-Agent stored with each refresh token.
+const maxUserAgentLen = 512
+
+// Session describes one of the user's active logins.
+type Session struct {
+	ID         string
+	UserAgent  string
+	IPAddress  string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	ExpiresAt  time.Time
+	Current    bool // the session the request was made with
+}
+
+// ListSessions returns the user's active sessions, flagging the one identified by
+// currentSessionID.
+func (s *IdentityService) ListSessions(ctx context.Context, userID, currentSessionID string) ([]Session, error) {
+	rows, err := s.repo.ListActiveSessions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("listing sessions: %w", err)
+	}
+	sessions := make([]Session, 0, len(rows))
+	for _, r := range rows {
+		sessions = append(sessions, Session{
+			ID:         r.ID,
+			UserAgent:  r.UserAgent,
+			IPAddress:  r.IPAddress,
+			CreatedAt:  r.CreatedAt,
+			LastUsedAt: r.LastUsedAt,
+			ExpiresAt:  r.ExpiresAt,
+			Current:    r.ID != "" && r.ID == currentSessionID,
+		})
+	}
+	return sessions, nil
+}
+
+// RevokeSession logs the user out of one session. Only the user's own active
+// sessions can be revoked; any other id yields ErrSessionNotFound.
+func (s *IdentityService) RevokeSession(ctx context.Context, userID, sessionID, clientIP string) error {
+	sessions, err := s.repo.ListActiveSessions(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("listing sessions: %w", err)
+	}
+	found := false
+	for _, sess := range sessions {
+		if sess.ID == sessionID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		s.auditLog(userID, "session_revoke", false, clientIP)
+		return ErrSessionNotFound
+	}
+
+	if err := s.denyAccessTokens(ctx, userID, sessionID); err != nil {
+		return err
+	}
+	if err := s.repo.RevokeTokenFamily(ctx, sessionID, lifecycleEvent(userID, eventUserLogout)); err != nil {
+		return fmt.Errorf("revoking session: %w", err)
+	}
+
+	s.auditLog(userID, "session_revoke", true, clientIP)
+
+	return nil
+}
+
+// RevokeOtherSessions logs the user out everywhere except the current session, with
+// a user.logout event per session revoked. Tokens issued before sessions were tracked
+// carry no session id, in which case every session is revoked and the caller has to
+// log in again.
+func (s *IdentityService) RevokeOtherSessions(ctx context.Context, userID, currentSessionID, clientIP string) error {
+	if err := s.denySessions(ctx, userID, currentSessionID); err != nil {
+		return err
+	}
+	if currentSessionID == "" {
+		if err := s.repo.RevokeAllUserTokens(ctx, userID, lifecycleEvent(userID, eventUserLogout)); err != nil {
+			return fmt.Errorf("revoking sessions: %w", err)
+		}
+	} else {
+		sessions, err := s.repo.ListActiveSessions(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("listing sessions: %w", err)
+		}
+		for _, sess := range sessions {
+			if sess.ID == currentSessionID {
+				continue
+			}
+			if err := s.repo.RevokeTokenFamily(ctx, sess.ID, lifecycleEvent(userID, eventUserLogout)); err != nil {
+				return fmt.Errorf("revoking session: %w", err)
+			}
+		}
+	}
+
+	s.auditLog(userID, "session_revoke_others", true, clientIP)
+
+	return nil
+}
+
+// truncateUserAgent caps the stored User-Agent without splitting a UTF-8 sequence.
+func truncateUserAgent(ua string) string {
+	if len(ua) <= maxUserAgentLen {
+		return ua
+	}
+	return strings.ToValidUTF8(ua[:maxUserAgentLen], "")
+}
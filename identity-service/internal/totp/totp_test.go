@@ -0,0 +1,65 @@
+package totp_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/watup-lk/identity-service/internal/totp"
+)
+
+// RFC 6238 Appendix B test vectors (SHA-1, secret "12345678901234567890"), truncated to 6 digits.
+func TestCode_RFC6238Vectors(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" // base32 of "12345678901234567890"
+	cases := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, c := range cases {
+		got, err := totp.Code(secret, totp.Step(time.Unix(c.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code() error: %v", err)
+		}
+		if got != c.want {
+			t.Errorf("T=%d: expected %s, got %s", c.unix, c.want, got)
+		}
+	}
+}
+
+func TestVerify_AcceptsAdjacentStep(t *testing.T) {
+	secret, _ := totp.GenerateSecret()
+	now := time.Now()
+	prev, _ := totp.Code(secret, totp.Step(now)-1)
+
+	step, ok := totp.Verify(secret, prev, now)
+	if !ok || step != totp.Step(now)-1 {
+		t.Errorf("expected previous step to be accepted, got step=%d ok=%v", step, ok)
+	}
+}
+
+func TestVerify_RejectsWrongOrStaleCode(t *testing.T) {
+	secret, _ := totp.GenerateSecret()
+	now := time.Now()
+	stale, _ := totp.Code(secret, totp.Step(now)-5)
+
+	for _, code := range []string{stale, "12345", "abcdef", ""} {
+		if _, ok := totp.Verify(secret, code, now); ok {
+			t.Errorf("expected %q to be rejected", code)
+		}
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := totp.ProvisioningURI("watup.lk", "alice@example.com", "ABC")
+	for _, want := range []string{"otpauth://totp/watup.lk:alice@example.com?", "secret=ABC", "issuer=watup.lk", "digits=6", "period=30"} {
+		if !strings.Contains(uri, want) {
+			t.Errorf("expected URI to contain %q, got %s", want, uri)
+		}
+	}
+}
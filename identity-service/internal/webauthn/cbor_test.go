@@ -0,0 +1,44 @@
+package webauthn
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestDecodeCBOR(t *testing.T) {
+	// {1: 2, 3: -7, "k": h'0102', "t": [true, null]}
+	in := []byte{0xa4, 0x01, 0x02, 0x03, 0x26, 0x61, 'k', 0x42, 0x01, 0x02, 0x61, 't', 0x82, 0xf5, 0xf6, 0xff}
+	v, rest, err := decodeCBOR(in)
+	if err != nil {
+		t.Fatalf("decodeCBOR() error: %v", err)
+	}
+	m := v.(map[any]any)
+	if m[int64(1)] != int64(2) || m[int64(3)] != int64(-7) || !bytes.Equal(m["k"].([]byte), []byte{1, 2}) {
+		t.Errorf("unexpected map %v", m)
+	}
+	if arr := m["t"].([]any); len(arr) != 2 || arr[0] != true || arr[1] != nil {
+		t.Errorf("unexpected array %v", arr)
+	}
+	if !bytes.Equal(rest, []byte{0xff}) {
+		t.Errorf("expected the trailing byte to be returned, got %x", rest)
+	}
+}
+
+func TestDecodeCBOR_RejectsMalformed(t *testing.T) {
+	cases := map[string][]byte{
+		"empty":              {},
+		"truncated string":   {0x45, 0x01},
+		"indefinite length":  {0x5f},
+		"float":              {0xf9, 0x3c, 0x00},
+		"tag":                {0xc1, 0x00},
+		"duplicate map key":  {0xa2, 0x01, 0x00, 0x01, 0x00},
+		"array beyond input": {0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"too deep":           bytes.Repeat([]byte{0x81}, maxCBORDepth+2),
+	}
+	for name, in := range cases {
+		if _, _, err := decodeCBOR(in); !errors.Is(err, errCBOR) {
+			t.Errorf("%s: expected errCBOR, got %v", name, err)
+		}
+	}
+}
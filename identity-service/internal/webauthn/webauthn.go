@@ -0,0 +1,462 @@
+// Package webauthn implements the relying party side of the WebAuthn registration
+// and authentication ceremonies (W3C Web Authentication Level 2, §7), for passkeys
+// and security keys.
+//
+// Only "none" attestation is accepted: the service trusts the credential public key
+// the browser hands over, not a statement about the authenticator's make. Credential
+// keys may be ES256, EdDSA or RS256. The browser-facing JSON types follow the
+// PublicKeyCredential JSON serialisation, with binary fields as base64url.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// User verification requirements.
+const (
+	UVRequired    = "required"
+	UVPreferred   = "preferred"
+	UVDiscouraged = "discouraged"
+)
+
+const (
+	challengeSize = 32
+
+	flagUserPresent      = 0x01
+	flagUserVerified     = 0x04
+	flagAttestedCredData = 0x40
+	flagExtensionData    = 0x80
+)
+
+var (
+	// ErrVerification is returned (wrapped with the reason) for any response that
+	// does not pass the ceremony checks.
+	ErrVerification = errors.New("webauthn verification failed")
+	// ErrSignCount is returned when an authenticator's signature counter did not
+	// increase, a sign that the credential may have been cloned.
+	ErrSignCount = errors.New("webauthn signature counter did not increase")
+)
+
+// Config describes the relying party.
+type Config struct {
+	RPID    string        // registrable domain the credentials are scoped to, e.g. "watup.lk"
+	RPName  string        // shown by the browser during registration
+	Origins []string      // origins the ceremonies may run on, e.g. "https://watup.lk"
+	Timeout time.Duration // how long the browser waits for the user
+}
+
+// Validate checks that every origin is a secure origin on the RP ID's domain,
+// which browsers require before they will run a ceremony.
+func (c Config) Validate() error {
+	if c.RPID == "" || strings.ContainsAny(c.RPID, ":/") {
+		return fmt.Errorf("webauthn: RP ID %q must be a bare domain name", c.RPID)
+	}
+	if len(c.Origins) == 0 {
+		return fmt.Errorf("webauthn: at least one origin is required")
+	}
+	for _, o := range c.Origins {
+		u, err := url.Parse(o)
+		if err != nil || u.Host == "" || u.Path != "" {
+			return fmt.Errorf("webauthn: origin %q must be scheme://host[:port]", o)
+		}
+		host := u.Hostname()
+		if host != c.RPID && !strings.HasSuffix(host, "."+c.RPID) {
+			return fmt.Errorf("webauthn: origin %q is not on the RP ID domain %q", o, c.RPID)
+		}
+		if u.Scheme != "https" && host != "localhost" {
+			return fmt.Errorf("webauthn: origin %q must use https", o)
+		}
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("webauthn: timeout must be positive")
+	}
+	return nil
+}
+
+// RelyingParty runs the ceremonies for one Config.
+type RelyingParty struct {
+	cfg      Config
+	rpIDHash [32]byte
+}
+
+// New returns a relying party. cfg should have passed Validate.
+func New(cfg Config) *RelyingParty {
+	return &RelyingParty{cfg: cfg, rpIDHash: sha256.Sum256([]byte(cfg.RPID))}
+}
+
+// Credential is a registered public key credential.
+type Credential struct {
+	ID           []byte
+	PublicKey    []byte // COSE_Key, as registered
+	SignCount    uint32
+	AAGUID       []byte // authenticator model; all zero under "none" attestation
+	Transports   []string
+	UserVerified bool // the registration was performed with user verification
+}
+
+// User identifies the account a credential is registered for. ID is the WebAuthn
+// user handle: opaque, at most 64 bytes and never personal data.
+type User struct {
+	ID          []byte
+	Name        string
+	DisplayName string
+}
+
+// ── Options sent to the browser ──────────────────────────────────────────────
+
+type RelyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type UserEntity struct {
+	ID          string `json:"id"` // base64url user handle
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+type CredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"` // base64url credential id
+	Transports []string `json:"transports,omitempty"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CreationOptions is PublicKeyCredentialCreationOptions, for navigator.credentials.create().
+type CreationOptions struct {
+	Challenge              string                 `json:"challenge"`
+	RP                     RelyingPartyEntity     `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"` // milliseconds
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions is PublicKeyCredentialRequestOptions, for navigator.credentials.get().
+// An empty AllowCredentials lets the user pick any passkey for the RP ID.
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	Timeout          int64                  `json:"timeout"` // milliseconds
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// ── Responses from the browser ───────────────────────────────────────────────
+
+// RegistrationResponse is the PublicKeyCredential returned by navigator.credentials.create().
+type RegistrationResponse struct {
+	ID       string                  `json:"id"`
+	RawID    string                  `json:"rawId"`
+	Type     string                  `json:"type"`
+	Response AttestationResponseData `json:"response"`
+}
+
+type AttestationResponseData struct {
+	ClientDataJSON    string   `json:"clientDataJSON"`
+	AttestationObject string   `json:"attestationObject"`
+	Transports        []string `json:"transports,omitempty"`
+}
+
+// AssertionResponse is the PublicKeyCredential returned by navigator.credentials.get().
+type AssertionResponse struct {
+	ID       string                `json:"id"`
+	RawID    string                `json:"rawId"`
+	Type     string                `json:"type"`
+	Response AssertionResponseData `json:"response"`
+}
+
+type AssertionResponseData struct {
+	ClientDataJSON    string `json:"clientDataJSON"`
+	AuthenticatorData string `json:"authenticatorData"`
+	Signature         string `json:"signature"`
+	UserHandle        string `json:"userHandle,omitempty"`
+}
+
+// CredentialID returns the id of the credential that signed the assertion.
+func (r *AssertionResponse) CredentialID() ([]byte, error) {
+	id, err := decodeB64(r.RawID)
+	if err != nil || len(id) == 0 {
+		return nil, fmt.Errorf("%w: invalid credential id", ErrVerification)
+	}
+	return id, nil
+}
+
+// UserHandle returns the user handle a discoverable credential reported, or nil.
+func (r *AssertionResponse) UserHandle() ([]byte, error) {
+	if r.Response.UserHandle == "" {
+		return nil, nil
+	}
+	h, err := decodeB64(r.Response.UserHandle)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid user handle", ErrVerification)
+	}
+	return h, nil
+}
+
+// ── Ceremonies ───────────────────────────────────────────────────────────────
+
+// NewChallenge returns a random ceremony challenge.
+func NewChallenge() []byte {
+	b := make([]byte, challengeSize)
+	if _, err := rand.Read(b); err != nil {
+		panic("crypto/rand unavailable: " + err.Error())
+	}
+	return b
+}
+
+// CreationOptions returns the options for registering a new credential for user.
+// exclude lists the user's existing credentials, so the same authenticator is not
+// registered twice.
+func (rp *RelyingParty) CreationOptions(challenge []byte, user User, exclude []Credential) *CreationOptions {
+	opts := &CreationOptions{
+		Challenge:          encodeB64(challenge),
+		RP:                 RelyingPartyEntity{ID: rp.cfg.RPID, Name: rp.cfg.RPName},
+		User:               UserEntity{ID: encodeB64(user.ID), Name: user.Name, DisplayName: user.DisplayName},
+		Timeout:            rp.cfg.Timeout.Milliseconds(),
+		ExcludeCredentials: descriptors(exclude),
+		// Discoverable credentials are what makes a passkey usable without typing an email
+		AuthenticatorSelection: AuthenticatorSelection{ResidentKey: "preferred", UserVerification: UVPreferred},
+		Attestation:            "none",
+	}
+	for _, alg := range SupportedAlgorithms {
+		opts.PubKeyCredParams = append(opts.PubKeyCredParams, CredentialParameter{Type: "public-key", Alg: alg})
+	}
+	return opts
+}
+
+// RequestOptions returns the options for an authentication ceremony. With no allowed
+// credentials, the browser offers every passkey it holds for the RP ID.
+func (rp *RelyingParty) RequestOptions(challenge []byte, allow []Credential, userVerification string) *RequestOptions {
+	return &RequestOptions{
+		Challenge:        encodeB64(challenge),
+		Timeout:          rp.cfg.Timeout.Milliseconds(),
+		RPID:             rp.cfg.RPID,
+		AllowCredentials: descriptors(allow),
+		UserVerification: userVerification,
+	}
+}
+
+// VerifyRegistration checks a registration response against the challenge it was
+// issued for (§7.1) and returns the new credential.
+func (rp *RelyingParty) VerifyRegistration(challenge []byte, resp *RegistrationResponse) (*Credential, error) {
+	if resp.Type != "public-key" {
+		return nil, fmt.Errorf("%w: credential type %q", ErrVerification, resp.Type)
+	}
+	if _, err := rp.verifyClientData(resp.Response.ClientDataJSON, "webauthn.create", challenge); err != nil {
+		return nil, err
+	}
+
+	rawAtt, err := decodeB64(resp.Response.AttestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: attestation object encoding", ErrVerification)
+	}
+	v, rest, err := decodeCBOR(rawAtt)
+	att, ok := v.(map[any]any)
+	if err != nil || !ok || len(rest) != 0 {
+		return nil, fmt.Errorf("%w: attestation object: %v", ErrVerification, err)
+	}
+	format, _ := att["fmt"].(string)
+	stmt, _ := att["attStmt"].(map[any]any)
+	if format != "none" || len(stmt) != 0 {
+		return nil, fmt.Errorf("%w: attestation format %q is not accepted", ErrVerification, format)
+	}
+	rawAuth, _ := att["authData"].([]byte)
+
+	auth, err := rp.parseAuthData(rawAuth)
+	if err != nil {
+		return nil, err
+	}
+	if auth.flags&flagAttestedCredData == 0 {
+		return nil, fmt.Errorf("%w: no attested credential data", ErrVerification)
+	}
+	if _, err := parseCOSEKey(auth.publicKey); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrVerification, err)
+	}
+	if rawID, err := decodeB64(resp.RawID); err != nil || !bytes.Equal(rawID, auth.credentialID) {
+		return nil, fmt.Errorf("%w: credential id does not match the authenticator data", ErrVerification)
+	}
+
+	return &Credential{
+		ID:           auth.credentialID,
+		PublicKey:    auth.publicKey,
+		SignCount:    auth.signCount,
+		AAGUID:       auth.aaguid,
+		Transports:   resp.Response.Transports,
+		UserVerified: auth.flags&flagUserVerified != 0,
+	}, nil
+}
+
+// VerifyAssertion checks an authentication response from cred against the challenge
+// (§7.2) and returns the authenticator's new signature counter. requireUV demands
+// that the authenticator verified the user (PIN or biometrics), as needed when the
+// passkey is the only factor.
+func (rp *RelyingParty) VerifyAssertion(challenge []byte, cred *Credential, resp *AssertionResponse, requireUV bool) (uint32, error) {
+	if resp.Type != "public-key" {
+		return 0, fmt.Errorf("%w: credential type %q", ErrVerification, resp.Type)
+	}
+	if id, err := resp.CredentialID(); err != nil || !bytes.Equal(id, cred.ID) {
+		return 0, fmt.Errorf("%w: unexpected credential", ErrVerification)
+	}
+	clientData, err := rp.verifyClientData(resp.Response.ClientDataJSON, "webauthn.get", challenge)
+	if err != nil {
+		return 0, err
+	}
+	rawAuth, err := decodeB64(resp.Response.AuthenticatorData)
+	if err != nil {
+		return 0, fmt.Errorf("%w: authenticator data encoding", ErrVerification)
+	}
+	auth, err := rp.parseAuthData(rawAuth)
+	if err != nil {
+		return 0, err
+	}
+	if requireUV && auth.flags&flagUserVerified == 0 {
+		return 0, fmt.Errorf("%w: user was not verified", ErrVerification)
+	}
+
+	key, err := parseCOSEKey(cred.PublicKey)
+	if err != nil {
+		return 0, fmt.Errorf("%w: stored key: %v", ErrVerification, err)
+	}
+	sig, err := decodeB64(resp.Response.Signature)
+	if err != nil {
+		return 0, fmt.Errorf("%w: signature encoding", ErrVerification)
+	}
+	clientDataHash := sha256.Sum256(clientData)
+	if !key.verify(append(rawAuth, clientDataHash[:]...), sig) {
+		return 0, fmt.Errorf("%w: bad signature", ErrVerification)
+	}
+
+	// §7.2 step 21: authenticators without a counter always report 0
+	if (auth.signCount != 0 || cred.SignCount != 0) && auth.signCount <= cred.SignCount {
+		return 0, ErrSignCount
+	}
+	return auth.signCount, nil
+}
+
+// ── Internals ────────────────────────────────────────────────────────────────
+
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// verifyClientData checks the ceremony type, challenge and origin, and returns the
+// raw JSON, whose hash the authenticator signed.
+func (rp *RelyingParty) verifyClientData(encoded, ceremony string, challenge []byte) ([]byte, error) {
+	raw, err := decodeB64(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: client data encoding", ErrVerification)
+	}
+	var cd clientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return nil, fmt.Errorf("%w: client data: %v", ErrVerification, err)
+	}
+	if cd.Type != ceremony {
+		return nil, fmt.Errorf("%w: client data type %q", ErrVerification, cd.Type)
+	}
+	got, err := decodeB64(cd.Challenge)
+	if err != nil || subtle.ConstantTimeCompare(got, challenge) != 1 {
+		return nil, fmt.Errorf("%w: challenge mismatch", ErrVerification)
+	}
+	if !slices.Contains(rp.cfg.Origins, cd.Origin) || cd.CrossOrigin {
+		return nil, fmt.Errorf("%w: unexpected origin %q", ErrVerification, cd.Origin)
+	}
+	return raw, nil
+}
+
+type authenticatorData struct {
+	flags        byte
+	signCount    uint32
+	aaguid       []byte
+	credentialID []byte
+	publicKey    []byte
+}
+
+// parseAuthData parses authenticator data (§6.1), checking the RP ID hash and that
+// the user was present.
+func (rp *RelyingParty) parseAuthData(b []byte) (*authenticatorData, error) {
+	if len(b) < 37 {
+		return nil, fmt.Errorf("%w: authenticator data too short", ErrVerification)
+	}
+	if subtle.ConstantTimeCompare(b[:32], rp.rpIDHash[:]) != 1 {
+		return nil, fmt.Errorf("%w: credential is scoped to another RP ID", ErrVerification)
+	}
+	a := &authenticatorData{flags: b[32], signCount: binary.BigEndian.Uint32(b[33:37])}
+	if a.flags&flagUserPresent == 0 {
+		return nil, fmt.Errorf("%w: user was not present", ErrVerification)
+	}
+	rest := b[37:]
+
+	if a.flags&flagAttestedCredData != 0 {
+		if len(rest) < 18 {
+			return nil, fmt.Errorf("%w: attested credential data too short", ErrVerification)
+		}
+		a.aaguid = append([]byte(nil), rest[:16]...)
+		n := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if n == 0 || n > 1023 || len(rest) < n {
+			return nil, fmt.Errorf("%w: invalid credential id length", ErrVerification)
+		}
+		a.credentialID = append([]byte(nil), rest[:n]...)
+		rest = rest[n:]
+
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: credential public key: %v", ErrVerification, err)
+		}
+		a.publicKey = append([]byte(nil), rest[:len(rest)-len(after)]...)
+		rest = after
+	}
+	if a.flags&flagExtensionData != 0 {
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: extensions: %v", ErrVerification, err)
+		}
+		rest = after
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%w: trailing authenticator data", ErrVerification)
+	}
+	return a, nil
+}
+
+func descriptors(creds []Credential) []CredentialDescriptor {
+	out := make([]CredentialDescriptor, 0, len(creds))
+	for _, c := range creds {
+		out = append(out, CredentialDescriptor{Type: "public-key", ID: encodeB64(c.ID), Transports: c.Transports})
+	}
+	return out
+}
+
+func encodeB64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+// decodeB64 accepts base64url with or without padding, as browsers differ.
+func decodeB64(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
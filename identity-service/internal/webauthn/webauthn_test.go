@@ -0,0 +1,175 @@
+package webauthn_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/watup-lk/identity-service/internal/webauthn"
+	"github.com/watup-lk/identity-service/internal/webauthn/webauthntest"
+)
+
+var testConfig = webauthn.Config{
+	RPID:    "watup.lk",
+	RPName:  "WatUp",
+	Origins: []string{"https://watup.lk"},
+	Timeout: 5 * time.Minute,
+}
+
+// register runs a registration ceremony and returns the stored credential.
+func register(t *testing.T, rp *webauthn.RelyingParty, auth *webauthntest.Authenticator) *webauthn.Credential {
+	t.Helper()
+	challenge := webauthn.NewChallenge()
+	resp, err := auth.Create(rp.CreationOptions(challenge, webauthn.User{ID: []byte("user-1"), Name: "amaya@watup.lk"}, nil))
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	cred, err := rp.VerifyRegistration(challenge, resp)
+	if err != nil {
+		t.Fatalf("VerifyRegistration() error: %v", err)
+	}
+	return cred
+}
+
+func TestCeremonies_RoundTrip(t *testing.T) {
+	rp := webauthn.New(testConfig)
+	auth := webauthntest.New("https://watup.lk")
+	cred := register(t, rp, auth)
+	if !cred.UserVerified || len(cred.ID) == 0 {
+		t.Errorf("unexpected credential %+v", cred)
+	}
+
+	for want := uint32(1); want <= 2; want++ {
+		challenge := webauthn.NewChallenge()
+		resp, err := auth.Get(rp.RequestOptions(challenge, nil, webauthn.UVRequired))
+		if err != nil {
+			t.Fatalf("Get() error: %v", err)
+		}
+		if handle, _ := resp.UserHandle(); string(handle) != "user-1" {
+			t.Errorf("expected user handle user-1, got %q", handle)
+		}
+		count, err := rp.VerifyAssertion(challenge, cred, resp, true)
+		if err != nil {
+			t.Fatalf("VerifyAssertion() error: %v", err)
+		}
+		if count != want {
+			t.Errorf("expected sign count %d, got %d", want, count)
+		}
+		cred.SignCount = count
+	}
+}
+
+func TestVerifyAssertion_Rejects(t *testing.T) {
+	rp := webauthn.New(testConfig)
+	auth := webauthntest.New("https://watup.lk")
+	cred := register(t, rp, auth)
+
+	challenge := webauthn.NewChallenge()
+	resp, _ := auth.Get(rp.RequestOptions(challenge, []webauthn.Credential{*cred}, webauthn.UVPreferred))
+	if _, err := rp.VerifyAssertion(webauthn.NewChallenge(), cred, resp, false); !errors.Is(err, webauthn.ErrVerification) {
+		t.Errorf("expected ErrVerification for another challenge, got %v", err)
+	}
+
+	tampered := *resp
+	tampered.Response.Signature = resp.Response.ClientDataJSON
+	if _, err := rp.VerifyAssertion(challenge, cred, &tampered, false); !errors.Is(err, webauthn.ErrVerification) {
+		t.Errorf("expected ErrVerification for a bad signature, got %v", err)
+	}
+
+	// A phishing page relaying the ceremony shows up as a foreign origin
+	auth.Origin = "https://watup-login.example"
+	challenge = webauthn.NewChallenge()
+	resp, _ = auth.Get(rp.RequestOptions(challenge, nil, webauthn.UVPreferred))
+	if _, err := rp.VerifyAssertion(challenge, cred, resp, false); !errors.Is(err, webauthn.ErrVerification) {
+		t.Errorf("expected ErrVerification for a foreign origin, got %v", err)
+	}
+	auth.Origin = "https://watup.lk"
+
+	auth.UserVerified = false
+	challenge = webauthn.NewChallenge()
+	resp, _ = auth.Get(rp.RequestOptions(challenge, nil, webauthn.UVRequired))
+	if _, err := rp.VerifyAssertion(challenge, cred, resp, true); !errors.Is(err, webauthn.ErrVerification) {
+		t.Errorf("expected ErrVerification without user verification, got %v", err)
+	}
+	if _, err := rp.VerifyAssertion(challenge, cred, resp, false); err != nil {
+		t.Errorf("user presence alone should pass as a second factor, got %v", err)
+	}
+}
+
+func TestVerifyAssertion_SignCountRegression(t *testing.T) {
+	rp := webauthn.New(testConfig)
+	auth := webauthntest.New("https://watup.lk")
+	cred := register(t, rp, auth)
+	cred.SignCount = 5 // the server has seen later signatures than the authenticator is about to make
+
+	challenge := webauthn.NewChallenge()
+	resp, _ := auth.Get(rp.RequestOptions(challenge, nil, webauthn.UVPreferred))
+	if _, err := rp.VerifyAssertion(challenge, cred, resp, false); !errors.Is(err, webauthn.ErrSignCount) {
+		t.Errorf("expected ErrSignCount, got %v", err)
+	}
+}
+
+func TestVerifyRegistration_WrongRPOrCeremony(t *testing.T) {
+	rp := webauthn.New(testConfig)
+	auth := webauthntest.New("https://watup.lk")
+
+	// A credential created for another RP ID must not register here
+	opts := rp.CreationOptions(webauthn.NewChallenge(), webauthn.User{ID: []byte("user-1"), Name: "u"}, nil)
+	opts.RP.ID = "evil.example"
+	resp, _ := auth.Create(opts)
+	if _, err := rp.VerifyRegistration(mustDecode(t, opts.Challenge), resp); !errors.Is(err, webauthn.ErrVerification) {
+		t.Errorf("expected ErrVerification for another RP ID, got %v", err)
+	}
+
+	// An assertion's client data cannot be replayed as a registration
+	cred := register(t, rp, auth)
+	challenge := webauthn.NewChallenge()
+	assertion, _ := auth.Get(rp.RequestOptions(challenge, []webauthn.Credential{*cred}, webauthn.UVPreferred))
+	reg, _ := auth.Create(rp.CreationOptions(challenge, webauthn.User{ID: []byte("user-2"), Name: "v"}, nil))
+	reg.Response.ClientDataJSON = assertion.Response.ClientDataJSON
+	if _, err := rp.VerifyRegistration(challenge, reg); !errors.Is(err, webauthn.ErrVerification) {
+		t.Errorf("expected ErrVerification for webauthn.get client data, got %v", err)
+	}
+}
+
+func TestCreationOptions_ExcludesRegisteredCredentials(t *testing.T) {
+	rp := webauthn.New(testConfig)
+	auth := webauthntest.New("https://watup.lk")
+	cred := register(t, rp, auth)
+
+	opts := rp.CreationOptions(webauthn.NewChallenge(), webauthn.User{ID: []byte("user-1"), Name: "u"}, []webauthn.Credential{*cred})
+	if _, err := auth.Create(opts); err == nil {
+		t.Error("the authenticator should refuse to register twice")
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	cases := []struct {
+		name string
+		cfg  webauthn.Config
+		ok   bool
+	}{
+		{"valid", testConfig, true},
+		{"subdomain origin", webauthn.Config{RPID: "watup.lk", Origins: []string{"https://app.watup.lk"}, Timeout: time.Minute}, true},
+		{"localhost over http", webauthn.Config{RPID: "localhost", Origins: []string{"http://localhost:3000"}, Timeout: time.Minute}, true},
+		{"origin on another domain", webauthn.Config{RPID: "watup.lk", Origins: []string{"https://notwatup.lk"}, Timeout: time.Minute}, false},
+		{"plain http", webauthn.Config{RPID: "watup.lk", Origins: []string{"http://watup.lk"}, Timeout: time.Minute}, false},
+		{"RP ID with scheme", webauthn.Config{RPID: "https://watup.lk", Origins: []string{"https://watup.lk"}, Timeout: time.Minute}, false},
+		{"no origins", webauthn.Config{RPID: "watup.lk", Timeout: time.Minute}, false},
+	}
+	for _, c := range cases {
+		if err := c.cfg.Validate(); (err == nil) != c.ok {
+			t.Errorf("%s: Validate() = %v, want ok=%v", c.name, err, c.ok)
+		}
+	}
+}
+
+func mustDecode(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
@@ -0,0 +1,214 @@
+// Package webauthntest provides a software authenticator for testing the WebAuthn
+// ceremonies without a browser: it answers creation and request options the way
+// navigator.credentials would, with ES256 keys and "none" attestation.
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"slices"
+
+	"github.com/watup-lk/identity-service/internal/webauthn"
+)
+
+// Authenticator holds credentials for any number of relying parties.
+type Authenticator struct {
+	Origin       string // reported in client data, as the browser would
+	UserVerified bool   // whether the user "enters their PIN"; true by default
+
+	creds []*credential
+}
+
+type credential struct {
+	id         []byte
+	rpID       string
+	userHandle []byte
+	key        *ecdsa.PrivateKey
+	signCount  uint32
+}
+
+// New returns an authenticator used from origin.
+func New(origin string) *Authenticator {
+	return &Authenticator{Origin: origin, UserVerified: true}
+}
+
+// Create answers navigator.credentials.create() with a new discoverable credential.
+func (a *Authenticator) Create(opts *webauthn.CreationOptions) (*webauthn.RegistrationResponse, error) {
+	if !slices.ContainsFunc(opts.PubKeyCredParams, func(p webauthn.CredentialParameter) bool { return p.Alg == webauthn.AlgES256 }) {
+		return nil, errors.New("NotSupportedError: ES256 not offered")
+	}
+	for _, ex := range opts.ExcludeCredentials {
+		if a.find(opts.RP.ID, ex.ID) != nil {
+			return nil, errors.New("InvalidStateError: authenticator already registered")
+		}
+	}
+	userHandle, err := decode(opts.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	c := &credential{id: make([]byte, 16), rpID: opts.RP.ID, userHandle: userHandle, key: key}
+	rand.Read(c.id) //nolint:errcheck
+	a.creds = append(a.creds, c)
+
+	// Attested credential data: AAGUID (zero under "none" attestation), id length, id, COSE key
+	attested := make([]byte, 16, 16+2+len(c.id))
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(c.id)))
+	attested = append(attested, c.id...)
+	attested = append(attested, encode(cborMap{
+		{int64(1), int64(2)},  // kty: EC2
+		{int64(3), int64(-7)}, // alg: ES256
+		{int64(-1), int64(1)}, // crv: P-256
+		{int64(-2), fixed(key.X.Bytes())},
+		{int64(-3), fixed(key.Y.Bytes())},
+	})...)
+	authData := a.authData(c, 0x40)
+	authData = append(authData, attested...)
+
+	attObj := encode(cborMap{
+		{"fmt", "none"},
+		{"attStmt", cborMap{}},
+		{"authData", authData},
+	})
+	return &webauthn.RegistrationResponse{
+		ID:    enc(c.id),
+		RawID: enc(c.id),
+		Type:  "public-key",
+		Response: webauthn.AttestationResponseData{
+			ClientDataJSON:    enc(a.clientData("webauthn.create", opts.Challenge)),
+			AttestationObject: enc(attObj),
+			Transports:        []string{"internal"},
+		},
+	}, nil
+}
+
+// Get answers navigator.credentials.get() with the first credential for the RP ID
+// that the options allow.
+func (a *Authenticator) Get(opts *webauthn.RequestOptions) (*webauthn.AssertionResponse, error) {
+	var c *credential
+	if len(opts.AllowCredentials) == 0 {
+		for _, cand := range a.creds {
+			if cand.rpID == opts.RPID {
+				c = cand
+				break
+			}
+		}
+	}
+	for _, allowed := range opts.AllowCredentials {
+		if c = a.find(opts.RPID, allowed.ID); c != nil {
+			break
+		}
+	}
+	if c == nil {
+		return nil, errors.New("NotAllowedError: no matching credential")
+	}
+
+	c.signCount++
+	authData := a.authData(c, 0)
+	clientData := a.clientData("webauthn.get", opts.Challenge)
+	hash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(slices.Clone(authData), hash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, c.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	return &webauthn.AssertionResponse{
+		ID:    enc(c.id),
+		RawID: enc(c.id),
+		Type:  "public-key",
+		Response: webauthn.AssertionResponseData{
+			ClientDataJSON:    enc(clientData),
+			AuthenticatorData: enc(authData),
+			Signature:         enc(sig),
+			UserHandle:        enc(c.userHandle),
+		},
+	}, nil
+}
+
+// ResetSignCount rewinds every credential's signature counter, as a clone of the
+// authenticator made earlier would.
+func (a *Authenticator) ResetSignCount() {
+	for _, c := range a.creds {
+		c.signCount = 0
+	}
+}
+
+func (a *Authenticator) find(rpID, id string) *credential {
+	for _, c := range a.creds {
+		if c.rpID == rpID && enc(c.id) == id {
+			return c
+		}
+	}
+	return nil
+}
+
+func (a *Authenticator) authData(c *credential, flags byte) []byte {
+	flags |= 0x01 // user present
+	if a.UserVerified {
+		flags |= 0x04
+	}
+	rpIDHash := sha256.Sum256([]byte(c.rpID))
+	b := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(b, c.signCount)
+}
+
+func (a *Authenticator) clientData(typ, challenge string) []byte {
+	b, _ := json.Marshal(map[string]any{"type": typ, "challenge": challenge, "origin": a.Origin, "crossOrigin": false})
+	return b
+}
+
+// fixed left-pads a P-256 coordinate to 32 bytes.
+func fixed(b []byte) []byte {
+	return append(make([]byte, 32-len(b)), b...)
+}
+
+func enc(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+func decode(s string) ([]byte, error) { return base64.RawURLEncoding.DecodeString(s) }
+
+// ── Minimal CBOR encoder ─────────────────────────────────────────────────────
+
+type cborMap []struct{ k, v any }
+
+func encode(v any) []byte {
+	switch v := v.(type) {
+	case int64:
+		if v < 0 {
+			return head(1, uint64(-1-v))
+		}
+		return head(0, uint64(v))
+	case []byte:
+		return append(head(2, uint64(len(v))), v...)
+	case string:
+		return append(head(3, uint64(len(v))), v...)
+	case cborMap:
+		out := head(5, uint64(len(v)))
+		for _, kv := range v {
+			out = append(out, encode(kv.k)...)
+			out = append(out, encode(kv.v)...)
+		}
+		return out
+	}
+	panic("webauthntest: cannot encode value")
+}
+
+func head(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n <= 0xff:
+		return []byte{major<<5 | 24, byte(n)}
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+	}
+	return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+}
@@ -7,7 +7,7 @@ CREATE TABLE IF NOT EXISTS identity_schema.users (
     id            UUID         PRIMARY KEY DEFAULT gen_random_uuid(),
     name          VARCHAR(100) NOT NULL,             -- display name
     email         VARCHAR(255) UNIQUE NOT NULL,
-    password_hash TEXT         NOT NULL,              -- bcrypt hash, never plain text
+    password_hash TEXT         NOT NULL,              -- argon2id PHC string or legacy bcrypt hash, never plain text
     age           SMALLINT     CHECK (age BETWEEN 13 AND 120),   -- optional
     is_active     BOOLEAN      NOT NULL DEFAULT TRUE,
     created_at    TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
@@ -59,7 +59,11 @@ CREATE TABLE IF NOT EXISTS identity_schema.audit_logs (
 
 CREATE INDEX IF NOT EXISTS idx_audit_logs_user    ON identity_schema.audit_logs (user_id);
 CREATE INDEX IF NOT EXISTS idx_audit_logs_event   ON identity_schema.audit_logs (event_type);
-CREATE INDEX IF NOT EXISTS idx_audit_logs_created ON identity_schema.audit_logs (created_at DESC);
+-- Admin queries page through (created_at, id) newest first; id breaks ties
+DROP INDEX IF EXISTS identity_schema.idx_audit_logs_created;   -- superseded; created_at only
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created_id ON identity_schema.audit_logs (created_at DESC, id DESC);
+-- IP / CIDR filters (ip_address <<= '10.0.0.0/8')
+CREATE INDEX IF NOT EXISTS idx_audit_logs_ip      ON identity_schema.audit_logs USING gist (ip_address inet_ops);
 
 -- Password reset tokens: one-time use, expire after 1 hour.
 CREATE TABLE IF NOT EXISTS identity_schema.password_reset_tokens (
@@ -73,3 +77,270 @@ CREATE TABLE IF NOT EXISTS identity_schema.password_reset_tokens (
 
 CREATE INDEX IF NOT EXISTS idx_reset_tokens_user    ON identity_schema.password_reset_tokens (user_id);
 CREATE INDEX IF NOT EXISTS idx_reset_tokens_expires ON identity_schema.password_reset_tokens (expires_at);
+
+-- Refresh token lineage: every token issued by rotating another shares the
+-- family_id of the login that started the chain. parent_id points at the token it
+-- replaced; replaced_by is set when a token is rotated. Replaying a token whose
+-- replaced_by is set means it was stolen (or leaked) — the whole family is revoked.
+ALTER TABLE identity_schema.refresh_tokens ADD COLUMN IF NOT EXISTS family_id   UUID;
+ALTER TABLE identity_schema.refresh_tokens ADD COLUMN IF NOT EXISTS parent_id   UUID;
+ALTER TABLE identity_schema.refresh_tokens ADD COLUMN IF NOT EXISTS replaced_by UUID;
+
+-- Tokens issued before lineage tracking start their own family
+UPDATE identity_schema.refresh_tokens SET family_id = id WHERE family_id IS NULL;
+ALTER TABLE identity_schema.refresh_tokens ALTER COLUMN family_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON identity_schema.refresh_tokens (family_id);
+
+-- TOTP two-factor authentication (RFC 6238). One authenticator per user.
+-- confirmed_at is NULL while enrollment is pending; MFA is only enforced at login
+-- once the user has proven possession by submitting a valid code.
+-- last_used_step stops a code from being accepted twice within its 30s window.
+CREATE TABLE IF NOT EXISTS identity_schema.user_totp (
+    user_id        UUID         PRIMARY KEY REFERENCES identity_schema.users(id) ON DELETE CASCADE,
+    secret         TEXT         NOT NULL,   -- base32 shared secret
+    confirmed_at   TIMESTAMPTZ,
+    last_used_step BIGINT       NOT NULL DEFAULT 0,
+    created_at     TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+-- Single-use recovery codes for when the authenticator is lost.
+-- Stored as SHA-256 hashes; the plaintext codes are shown to the user exactly once.
+CREATE TABLE IF NOT EXISTS identity_schema.mfa_recovery_codes (
+    id         UUID         PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id    UUID         NOT NULL REFERENCES identity_schema.users(id) ON DELETE CASCADE,
+    code_hash  TEXT         NOT NULL,
+    used_at    TIMESTAMPTZ,                -- NULL = still usable
+    created_at TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, code_hash)
+);
+
+-- Per-account login throttling. Every failed password or second-factor attempt
+-- increments failed_login_count (reset after 24h without failures); from the
+-- configured threshold on, each failure locks the account for an exponentially
+-- growing period. A successful login, password reset or admin unlock clears both.
+ALTER TABLE identity_schema.users ADD COLUMN IF NOT EXISTS failed_login_count   INT NOT NULL DEFAULT 0;
+ALTER TABLE identity_schema.users ADD COLUMN IF NOT EXISTS last_failed_login_at TIMESTAMPTZ;
+ALTER TABLE identity_schema.users ADD COLUMN IF NOT EXISTS locked_until         TIMESTAMPTZ;
+
+-- Email verification. email_verified_at stays NULL until the user follows the link
+-- mailed at signup; other services read it via the gRPC GetUser call (e.g. vote-service
+-- refuses votes from unverified accounts). Accounts created before verification
+-- existed are not backfilled — they can request a new link from /auth/email/resend.
+ALTER TABLE identity_schema.users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS identity_schema.email_verification_tokens (
+    id         UUID         PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id    UUID         NOT NULL REFERENCES identity_schema.users(id) ON DELETE CASCADE,
+    token_hash TEXT         UNIQUE NOT NULL,   -- SHA-256 of the emailed verification token
+    expires_at TIMESTAMPTZ  NOT NULL,
+    used_at    TIMESTAMPTZ,                    -- NULL = not yet used
+    created_at TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_verification_user ON identity_schema.email_verification_tokens (user_id);
+
+-- Session metadata: the client each refresh token was issued to, and when it was
+-- exchanged. Lets users review and revoke their logins (GET/DELETE /auth/sessions).
+ALTER TABLE identity_schema.refresh_tokens ADD COLUMN IF NOT EXISTS user_agent   TEXT;
+ALTER TABLE identity_schema.refresh_tokens ADD COLUMN IF NOT EXISTS ip_address   INET;
+ALTER TABLE identity_schema.refresh_tokens ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_active
+    ON identity_schema.refresh_tokens (user_id) WHERE revoked = FALSE;
+
+-- Role-based access control. Every account implicitly has the 'user' role; rows here
+-- grant the elevated roles, which are embedded in access tokens as the "roles" claim.
+-- Grants take effect on the user's next login or token refresh.
+--   INSERT INTO identity_schema.user_roles (user_id, role) VALUES ('<uuid>', 'admin');
+CREATE TABLE IF NOT EXISTS identity_schema.user_roles (
+    user_id    UUID         NOT NULL REFERENCES identity_schema.users(id) ON DELETE CASCADE,
+    role       VARCHAR(20)  NOT NULL CHECK (role IN ('user', 'moderator', 'admin')),
+    granted_at TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, role)
+);
+
+-- Account deletion (DELETE /auth/account). The account is deactivated and its sessions
+-- revoked at once; after the grace period a background job scrubs the PII (name, email,
+-- password hash, age, MFA secrets, audit IPs) and publishes user.deleted. The row stays
+-- as a tombstone so user_id references in other services remain unambiguous.
+-- Within the grace period support can restore an account:
+--   UPDATE identity_schema.users SET deleted_at = NULL, is_active = TRUE WHERE id = '<uuid>';
+ALTER TABLE identity_schema.users ADD COLUMN IF NOT EXISTS deleted_at    TIMESTAMPTZ;
+ALTER TABLE identity_schema.users ADD COLUMN IF NOT EXISTS anonymised_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_users_pending_purge
+    ON identity_schema.users (deleted_at) WHERE deleted_at IS NOT NULL AND anonymised_at IS NULL;
+
+-- Personal data exports (POST / GET /auth/account/export). The JSON bundle is built in
+-- the background and can be downloaded until expires_at; a new request replaces the
+-- user's previous export, so at most one bundle per user is stored.
+CREATE TABLE IF NOT EXISTS identity_schema.data_exports (
+    id           UUID         PRIMARY KEY,
+    user_id      UUID         NOT NULL REFERENCES identity_schema.users(id) ON DELETE CASCADE,
+    status       VARCHAR(10)  NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'ready', 'failed')),
+    payload      BYTEA,                   -- JSON bundle, set once ready
+    requested_at TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ,
+    expires_at   TIMESTAMPTZ              -- NULL until ready
+);
+
+CREATE INDEX IF NOT EXISTS idx_data_exports_user    ON identity_schema.data_exports (user_id);
+CREATE INDEX IF NOT EXISTS idx_data_exports_expires ON identity_schema.data_exports (expires_at) WHERE expires_at IS NOT NULL;
+-- One export is built at a time: concurrent requests cannot both start one
+CREATE UNIQUE INDEX IF NOT EXISTS idx_data_exports_pending
+    ON identity_schema.data_exports (user_id) WHERE status = 'pending';
+
+-- Email change (POST /auth/email/change). The new address confirms with confirm_token;
+-- the old address is told and can cancel with cancel_token until cancel_until — even
+-- after the change went through, in which case the old address is restored. While that
+-- window is open the old address stays reserved for this user.
+CREATE TABLE IF NOT EXISTS identity_schema.email_change_requests (
+    id                 UUID         PRIMARY KEY,
+    user_id            UUID         NOT NULL REFERENCES identity_schema.users(id) ON DELETE CASCADE,
+    old_email          VARCHAR(255) NOT NULL,
+    new_email          VARCHAR(255) NOT NULL,
+    confirm_token_hash TEXT         UNIQUE NOT NULL,   -- SHA-256 of the token sent to new_email
+    cancel_token_hash  TEXT         UNIQUE NOT NULL,   -- SHA-256 of the token sent to old_email
+    expires_at         TIMESTAMPTZ  NOT NULL,          -- confirmation deadline
+    cancel_until       TIMESTAMPTZ  NOT NULL,
+    confirmed_at       TIMESTAMPTZ,
+    cancelled_at       TIMESTAMPTZ,
+    created_at         TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_change_user ON identity_schema.email_change_requests (user_id);
+CREATE INDEX IF NOT EXISTS idx_email_change_old_email
+    ON identity_schema.email_change_requests (old_email) WHERE cancelled_at IS NULL;
+
+-- Case-insensitive email identity. The service stores normalised addresses (domain
+-- always lower-cased), and this index stops two accounts from sharing an address that
+-- differs only in case — including two signups racing each other, which would pass the
+-- application's existence check together. Creating it fails if such duplicates already
+-- exist; merge or rename them first (see README, "Email Addresses").
+DROP INDEX IF EXISTS identity_schema.idx_users_email;   -- superseded; the UNIQUE constraint covers exact lookups
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON identity_schema.users (LOWER(email));
+
+DROP INDEX IF EXISTS identity_schema.idx_email_change_old_email;
+CREATE INDEX IF NOT EXISTS idx_email_change_old_email_lower
+    ON identity_schema.email_change_requests (LOWER(old_email)) WHERE cancelled_at IS NULL;
+
+-- Social login (OAuth2 / OpenID Connect). Each row links an external account to a user;
+-- subject is the provider's stable user id. Accounts created this way have an empty
+-- password_hash until the user sets one through the password reset flow.
+CREATE TABLE IF NOT EXISTS identity_schema.user_identities (
+    id         UUID         PRIMARY KEY,
+    user_id    UUID         NOT NULL REFERENCES identity_schema.users(id) ON DELETE CASCADE,
+    provider   VARCHAR(40)  NOT NULL,           -- e.g. google, linkedin, github
+    subject    VARCHAR(255) NOT NULL,
+    email      VARCHAR(255),                    -- as reported by the provider when linked
+    created_at TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    UNIQUE (provider, subject),
+    UNIQUE (user_id, provider)                  -- at most one account per provider
+);
+
+-- In-flight authorization requests, consumed by the callback. Abandoned rows are
+-- removed whenever a new login starts.
+CREATE TABLE IF NOT EXISTS identity_schema.oauth_states (
+    state_hash    TEXT         PRIMARY KEY,     -- SHA-256 of the state parameter
+    provider      VARCHAR(40)  NOT NULL,
+    code_verifier TEXT         NOT NULL,        -- PKCE verifier; never leaves the service
+    nonce         TEXT         NOT NULL,
+    user_id       UUID         REFERENCES identity_schema.users(id) ON DELETE CASCADE,  -- set when linking to a signed-in account
+    expires_at    TIMESTAMPTZ  NOT NULL,
+    created_at    TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_oauth_states_expires ON identity_schema.oauth_states (expires_at);
+
+-- Set when the signed-in user_id is re-authenticating with a linked provider instead of linking it
+ALTER TABLE identity_schema.oauth_states ADD COLUMN IF NOT EXISTS reauth BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- WebAuthn credentials (passkeys and security keys). public_key is the COSE key from
+-- registration; sign_count only ever increases, and a regression suggests a cloned
+-- authenticator. Usable as a second factor and, with user verification, on their own.
+CREATE TABLE IF NOT EXISTS identity_schema.webauthn_credentials (
+    id            UUID         PRIMARY KEY,
+    user_id       UUID         NOT NULL REFERENCES identity_schema.users(id) ON DELETE CASCADE,
+    credential_id BYTEA        NOT NULL UNIQUE,
+    public_key    BYTEA        NOT NULL,
+    sign_count    BIGINT       NOT NULL DEFAULT 0,
+    aaguid        BYTEA,
+    transports    TEXT[]       NOT NULL DEFAULT '{}',
+    name          VARCHAR(100) NOT NULL,           -- user-chosen label, e.g. "MacBook Touch ID"
+    created_at    TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    last_used_at  TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_webauthn_credentials_user ON identity_schema.webauthn_credentials (user_id);
+
+-- In-flight WebAuthn ceremonies. The challenge stays server-side, keyed by the hash of
+-- the session token handed to the client; each row is consumed once.
+CREATE TABLE IF NOT EXISTS identity_schema.webauthn_challenges (
+    session_hash TEXT         PRIMARY KEY,
+    user_id      UUID         REFERENCES identity_schema.users(id) ON DELETE CASCADE,  -- NULL for a passkey login
+    purpose      VARCHAR(20)  NOT NULL,           -- register, login or mfa
+    challenge    BYTEA        NOT NULL,
+    expires_at   TIMESTAMPTZ  NOT NULL,
+    created_at   TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webauthn_challenges_expires ON identity_schema.webauthn_challenges (expires_at);
+
+-- Access token denylist. Access tokens are stateless JWTs, so revoking one before it
+-- expires means remembering its id until then: token_id is either a token's jti or a
+-- session id (the sid claim), which denies every access token of that session.
+CREATE TABLE IF NOT EXISTS identity_schema.revoked_access_tokens (
+    token_id   TEXT         PRIMARY KEY,
+    user_id    UUID         NOT NULL REFERENCES identity_schema.users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ  NOT NULL,             -- when the last token it denies expires
+    revoked_at TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_revoked_access_tokens_expires ON identity_schema.revoked_access_tokens (expires_at);
+
+-- Transactional outbox for Kafka events. Rows are written in the same transaction as
+-- the change they announce and relayed to Kafka by a single relay, in id order.
+-- Writers take a per-user advisory lock before inserting, so each user's ids follow
+-- commit order. There is no foreign key: the table only holds ids and is purged once
+-- events are published.
+CREATE TABLE IF NOT EXISTS identity_schema.outbox (
+    id              BIGSERIAL    PRIMARY KEY,
+    topic           VARCHAR(100) NOT NULL,
+    user_id         UUID         NOT NULL,
+    event_type      VARCHAR(100) NOT NULL,
+    occurred_at     TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    attempts        INT          NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ  NOT NULL DEFAULT NOW(),  -- pushed back after a failed publish
+    last_error      TEXT,
+    published_at    TIMESTAMPTZ                           -- NULL until Kafka acknowledged the event
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_pending      ON identity_schema.outbox (id) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_pending_user ON identity_schema.outbox (user_id, id) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_published    ON identity_schema.outbox (published_at) WHERE published_at IS NOT NULL;
+
+-- Tamper-evident audit log (see identity-service/internal/auditchain). Each row is
+-- numbered by seq and stores row_hash, a hash of its content and of the previous
+-- row's hash (prev_hash), so an edited, deleted or reordered row breaks the chain.
+-- The IP address is covered through ip_digest, salted with ip_salt: anonymising an
+-- account clears ip_address and ip_salt but keeps the digest, and the chain still
+-- verifies. Rows written before the chain existed have no seq. Hard-deleting a user
+-- nulls user_id on their rows and therefore shows up as a break.
+ALTER TABLE identity_schema.audit_logs ADD COLUMN IF NOT EXISTS seq       BIGINT;
+ALTER TABLE identity_schema.audit_logs ADD COLUMN IF NOT EXISTS prev_hash BYTEA;
+ALTER TABLE identity_schema.audit_logs ADD COLUMN IF NOT EXISTS row_hash  BYTEA;
+ALTER TABLE identity_schema.audit_logs ADD COLUMN IF NOT EXISTS ip_salt   BYTEA;
+ALTER TABLE identity_schema.audit_logs ADD COLUMN IF NOT EXISTS ip_digest BYTEA;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_audit_logs_seq ON identity_schema.audit_logs (seq);
+
+-- Signed checkpoints of the audit chain head. token is a JWT carrying seq and
+-- row_hash, signed with AUDIT_CHECKPOINT_KEY_FILE, a key that is not stored here:
+-- rewriting the chain or cutting rows off its end no longer matches a checkpoint.
+CREATE TABLE IF NOT EXISTS identity_schema.audit_checkpoints (
+    seq        BIGINT       PRIMARY KEY,
+    row_hash   BYTEA        NOT NULL,
+    token      TEXT         NOT NULL,
+    created_at TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
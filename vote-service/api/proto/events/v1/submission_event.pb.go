@@ -0,0 +1,142 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        v5.29.3
+// source: events/v1/submission_event.proto
+
+package eventsv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// SubmissionThresholdReached is the data of vote-service's threshold-reached topic:
+// a salary submission collected enough upvotes to be approved.
+type SubmissionThresholdReached struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SubmissionId  string                 `protobuf:"bytes,1,opt,name=submission_id,json=submissionId,proto3" json:"submission_id,omitempty"`
+	Upvotes       int32                  `protobuf:"varint,2,opt,name=upvotes,proto3" json:"upvotes,omitempty"`
+	Threshold     int32                  `protobuf:"varint,3,opt,name=threshold,proto3" json:"threshold,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmissionThresholdReached) Reset() {
+	*x = SubmissionThresholdReached{}
+	mi := &file_events_v1_submission_event_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmissionThresholdReached) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmissionThresholdReached) ProtoMessage() {}
+
+func (x *SubmissionThresholdReached) ProtoReflect() protoreflect.Message {
+	mi := &file_events_v1_submission_event_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmissionThresholdReached.ProtoReflect.Descriptor instead.
+func (*SubmissionThresholdReached) Descriptor() ([]byte, []int) {
+	return file_events_v1_submission_event_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SubmissionThresholdReached) GetSubmissionId() string {
+	if x != nil {
+		return x.SubmissionId
+	}
+	return ""
+}
+
+func (x *SubmissionThresholdReached) GetUpvotes() int32 {
+	if x != nil {
+		return x.Upvotes
+	}
+	return 0
+}
+
+func (x *SubmissionThresholdReached) GetThreshold() int32 {
+	if x != nil {
+		return x.Threshold
+	}
+	return 0
+}
+
+var File_events_v1_submission_event_proto protoreflect.FileDescriptor
+
+const file_events_v1_submission_event_proto_rawDesc = "" +
+	"\n" +
+	" events/v1/submission_event.proto\x12\x0fwatup.events.v1\"y\n" +
+	"\x1aSubmissionThresholdReached\x12#\n" +
+	"\rsubmission_id\x18\x01 \x01(\tR\fsubmissionId\x12\x18\n" +
+	"\aupvotes\x18\x02 \x01(\x05R\aupvotes\x12\x1c\n" +
+	"\tthreshold\x18\x03 \x01(\x05R\tthresholdb\x06proto3"
+
+var (
+	file_events_v1_submission_event_proto_rawDescOnce sync.Once
+	file_events_v1_submission_event_proto_rawDescData []byte
+)
+
+func file_events_v1_submission_event_proto_rawDescGZIP() []byte {
+	file_events_v1_submission_event_proto_rawDescOnce.Do(func() {
+		file_events_v1_submission_event_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_events_v1_submission_event_proto_rawDesc), len(file_events_v1_submission_event_proto_rawDesc)))
+	})
+	return file_events_v1_submission_event_proto_rawDescData
+}
+
+var file_events_v1_submission_event_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_events_v1_submission_event_proto_goTypes = []any{
+	(*SubmissionThresholdReached)(nil), // 0: watup.events.v1.SubmissionThresholdReached
+}
+var file_events_v1_submission_event_proto_depIdxs = []int32{
+	0, // [0:0] is the sub-list for method output_type
+	0, // [0:0] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_events_v1_submission_event_proto_init() }
+func file_events_v1_submission_event_proto_init() {
+	if File_events_v1_submission_event_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_events_v1_submission_event_proto_rawDesc), len(file_events_v1_submission_event_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_events_v1_submission_event_proto_goTypes,
+		DependencyIndexes: file_events_v1_submission_event_proto_depIdxs,
+		MessageInfos:      file_events_v1_submission_event_proto_msgTypes,
+	}.Build()
+	File_events_v1_submission_event_proto = out.File
+	file_events_v1_submission_event_proto_goTypes = nil
+	file_events_v1_submission_event_proto_depIdxs = nil
+}
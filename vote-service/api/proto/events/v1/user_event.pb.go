@@ -0,0 +1,136 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        v5.29.3
+// source: events/v1/user_event.proto
+
+package eventsv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// UserEvent is the data of identity-service's user.* topics. The envelope carries the
+// time, and its subject is the user_id.
+type UserEvent struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	UserId string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	// The topic's event, e.g. "user.login"; on user.security, the security event such
+	// as "refresh_token_reuse".
+	EventType     string `protobuf:"bytes,2,opt,name=event_type,json=eventType,proto3" json:"event_type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserEvent) Reset() {
+	*x = UserEvent{}
+	mi := &file_events_v1_user_event_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserEvent) ProtoMessage() {}
+
+func (x *UserEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_events_v1_user_event_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserEvent.ProtoReflect.Descriptor instead.
+func (*UserEvent) Descriptor() ([]byte, []int) {
+	return file_events_v1_user_event_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *UserEvent) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *UserEvent) GetEventType() string {
+	if x != nil {
+		return x.EventType
+	}
+	return ""
+}
+
+var File_events_v1_user_event_proto protoreflect.FileDescriptor
+
+const file_events_v1_user_event_proto_rawDesc = "" +
+	"\n" +
+	"\x1aevents/v1/user_event.proto\x12\x0fwatup.events.v1\"C\n" +
+	"\tUserEvent\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1d\n" +
+	"\n" +
+	"event_type\x18\x02 \x01(\tR\teventTypeb\x06proto3"
+
+var (
+	file_events_v1_user_event_proto_rawDescOnce sync.Once
+	file_events_v1_user_event_proto_rawDescData []byte
+)
+
+func file_events_v1_user_event_proto_rawDescGZIP() []byte {
+	file_events_v1_user_event_proto_rawDescOnce.Do(func() {
+		file_events_v1_user_event_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_events_v1_user_event_proto_rawDesc), len(file_events_v1_user_event_proto_rawDesc)))
+	})
+	return file_events_v1_user_event_proto_rawDescData
+}
+
+var file_events_v1_user_event_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_events_v1_user_event_proto_goTypes = []any{
+	(*UserEvent)(nil), // 0: watup.events.v1.UserEvent
+}
+var file_events_v1_user_event_proto_depIdxs = []int32{
+	0, // [0:0] is the sub-list for method output_type
+	0, // [0:0] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_events_v1_user_event_proto_init() }
+func file_events_v1_user_event_proto_init() {
+	if File_events_v1_user_event_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_events_v1_user_event_proto_rawDesc), len(file_events_v1_user_event_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_events_v1_user_event_proto_goTypes,
+		DependencyIndexes: file_events_v1_user_event_proto_depIdxs,
+		MessageInfos:      file_events_v1_user_event_proto_msgTypes,
+	}.Build()
+	File_events_v1_user_event_proto = out.File
+	file_events_v1_user_event_proto_goTypes = nil
+	file_events_v1_user_event_proto_depIdxs = nil
+}
@@ -0,0 +1,115 @@
+package events_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	eventsv1 "github.com/watup-lk/vote-service/api/proto/events/v1"
+	"github.com/watup-lk/vote-service/internal/events"
+)
+
+func newThresholdReached(t *testing.T) *eventsv1.Envelope {
+	t.Helper()
+	env, err := events.New("evt-7", "/watup/vote-service", "lk.watup.submission.threshold_reached", "sub-1",
+		time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC), &eventsv1.SubmissionThresholdReached{SubmissionId: "sub-1", Upvotes: 10, Threshold: 10})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	return env
+}
+
+func marshal(t *testing.T, env *eventsv1.Envelope) []byte {
+	t.Helper()
+	b, err := proto.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestNew_RoundTrip(t *testing.T) {
+	env, err := events.Decode(marshal(t, newThresholdReached(t)))
+	if err != nil {
+		t.Fatalf("Decode() error: %v", err)
+	}
+	if env.Id != "evt-7" || env.SpecVersion != "1.0" || env.SchemaVersion != events.SchemaVersion || env.Subject != "sub-1" {
+		t.Errorf("unexpected envelope %v", env)
+	}
+	if env.DataSchema != "watup.events.v1.SubmissionThresholdReached" || env.DataContentType != "application/protobuf" {
+		t.Errorf("unexpected data attributes %q, %q", env.DataSchema, env.DataContentType)
+	}
+
+	var data eventsv1.SubmissionThresholdReached
+	if err := events.UnmarshalData(env, &data); err != nil {
+		t.Fatalf("UnmarshalData() error: %v", err)
+	}
+	if data.SubmissionId != "sub-1" || data.Upvotes != 10 || data.Threshold != 10 {
+		t.Errorf("unexpected data %v", &data)
+	}
+}
+
+func TestDecode_Versions(t *testing.T) {
+	cases := []struct {
+		spec, schema string
+		want         error
+	}{
+		{"1.0", "1.0", nil},
+		{"1.0", "1.7", nil}, // a newer minor only adds fields
+		{"1.0", "1", nil},
+		{"1.0", "2.0", events.ErrUnsupportedVersion},
+		{"1.0", "0.9", events.ErrUnsupportedVersion},
+		{"1.0", "", events.ErrUnsupportedVersion},
+		{"1.0", "v1", events.ErrUnsupportedVersion},
+		{"0.3", "1.0", events.ErrUnsupportedVersion},
+	}
+	for _, c := range cases {
+		env := newThresholdReached(t)
+		env.SpecVersion, env.SchemaVersion = c.spec, c.schema
+		if _, err := events.Decode(marshal(t, env)); !errors.Is(err, c.want) {
+			t.Errorf("spec %q, schema %q: Decode() = %v, want %v", c.spec, c.schema, err, c.want)
+		}
+	}
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	env := newThresholdReached(t)
+	env.Type = ""
+	if _, err := events.Decode(marshal(t, env)); !errors.Is(err, events.ErrInvalidEvent) {
+		t.Errorf("expected ErrInvalidEvent without a type, got %v", err)
+	}
+	if _, err := events.Decode([]byte("Submission 1 reached upvote threshold")); !errors.Is(err, events.ErrInvalidEvent) {
+		t.Errorf("expected ErrInvalidEvent for a plain string, got %v", err)
+	}
+}
+
+func TestUnmarshalData_WrongSchema(t *testing.T) {
+	var data eventsv1.UserEvent
+	if err := events.UnmarshalData(newThresholdReached(t), &data); !errors.Is(err, events.ErrInvalidEvent) {
+		t.Errorf("expected ErrInvalidEvent for another data schema, got %v", err)
+	}
+}
+
+// The package is a copy of identity-service/internal/events, which writes the user.*
+// events this service may consume; the two must agree on what they write and accept.
+func TestMatchesIdentityService(t *testing.T) {
+	theirs, err := os.ReadFile("../../../identity-service/internal/events/events.go")
+	if os.IsNotExist(err) {
+		t.Skip("identity-service is not checked out next to vote-service")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	ours, err := os.ReadFile("events.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	theirs = bytes.ReplaceAll(theirs, []byte("watup-lk/identity-service/"), []byte("watup-lk/vote-service/"))
+	if !bytes.Equal(ours, theirs) {
+		t.Error("events.go differs from identity-service/internal/events/events.go; change both together")
+	}
+}
@@ -2,10 +2,19 @@ package kafka
 
 import (
 	"context"
-	"fmt"
+	"crypto/rand"
+	"time"
+
 	"github.com/segmentio/kafka-go"
+	"google.golang.org/protobuf/proto"
+
+	eventsv1 "github.com/watup-lk/vote-service/api/proto/events/v1"
+	"github.com/watup-lk/vote-service/internal/events"
 )
 
+// source identifies this service in the envelope of every event it publishes.
+const source = "/watup/vote-service"
+
 type Producer struct {
 	writer *kafka.Writer
 }
@@ -13,17 +22,29 @@ type Producer struct {
 func NewProducer(brokers []string, topic string) *Producer {
 	return &Producer{
 		writer: &kafka.Writer{
-			Addr: kafka.TCP(brokers...),
-			Topic: topic,
+			Addr:     kafka.TCP(brokers...),
+			Topic:    topic,
 			Balancer: &kafka.LeastBytes{},
 		},
 	}
 }
 
-func (p *Producer) PublishThresholdReached(ctx context.Context, submissionID string) error {
+// PublishThresholdReached announces that a submission collected enough upvotes to be
+// approved, as a SubmissionThresholdReached event keyed by the submission id.
+func (p *Producer) PublishThresholdReached(ctx context.Context, submissionID string, upvotes, threshold int) error {
+	env, err := events.New(newEventID(), source, events.TypePrefix+"submission.threshold_reached", submissionID, time.Now(),
+		&eventsv1.SubmissionThresholdReached{SubmissionId: submissionID, Upvotes: int32(upvotes), Threshold: int32(threshold)})
+	if err != nil {
+		return err
+	}
+	value, err := proto.Marshal(env)
+	if err != nil {
+		return err
+	}
 	msg := kafka.Message{
-		Key: []byte(submissionID),
-		Value: []byte(fmt.Sprintf("Submission %s reached upvote threshold", submissionID)),
+		Key:     []byte(submissionID),
+		Value:   value,
+		Headers: []kafka.Header{{Key: "content-type", Value: []byte(events.ContentType)}},
 	}
 	return p.writer.WriteMessages(ctx, msg)
 }
@@ -31,3 +52,9 @@ func (p *Producer) PublishThresholdReached(ctx context.Context, submissionID str
 func (p *Producer) Close() error {
 	return p.writer.Close()
 }
+
+// newEventID returns a random identifier for an event envelope: 26 base32
+// characters, 128 bits of randomness.
+func newEventID() string {
+	return rand.Text()
+}
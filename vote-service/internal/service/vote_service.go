@@ -43,7 +43,7 @@ func (s *VoteService) RecordVote(ctx context.Context, req *v1.RecordVoteRequest)
 	thresholdReached := currentUpvotes >= s.approvalThreshold
 
 	if thresholdReached {
-		err := s.kafka.PublishThresholdReached(ctx, req.SubmissionId)
+		err := s.kafka.PublishThresholdReached(ctx, req.SubmissionId, currentUpvotes, s.approvalThreshold)
 		if err != nil {
 			log.Printf("Failed to publish threshold reached event: %v", err)
 		}